@@ -36,13 +36,14 @@ type BinaryClassifier struct {
 
 // BinaryClassifierTrainer_L1Logistic_PrimalSGD returns an trained BinaryClassifier with FTRL-Proximal (McMahan+ 2013) method for L1-penalized logistic regression.
 // This can be used for estimating the probability which the given data point belongs to the positive class, and this algorithm would produce the smaller model.
+// The loss and the gradient of each data point are scaled by its weight.
 //
 // This function returns no error currently.
 //
 // References:
 //
 // (McMahan+ 2013) H. B. McMahan, et al. "Ad Click Prediction: a View from the Trenches." Proceedings of the 19th ACM SIGKDD International Conference on Knowledge Discovery and Data Mining, 2013.
-func BinaryClassifierTrainer_L1Logistic_PrimalSGD(X FeatureVectors, Y []bool, C, epsilon float32, debug *log.Logger) (*BinaryClassifier, error) {
+func BinaryClassifierTrainer_L1Logistic_PrimalSGD(X FeatureVectors, Y []bool, W []float32, C, epsilon float32, debug *log.Logger) (*BinaryClassifier, error) {
 	rng := rand.New(rand.NewSource(0))
 	// lambda is the penalty parameter.
 	lambda := 1.0 / C
@@ -98,28 +99,33 @@ func BinaryClassifierTrainer_L1Logistic_PrimalSGD(X FeatureVectors, Y []bool, C,
 			if Y[i] {
 				yi = +1.0
 			}
-			// Calculate the $l_i = -y_i\log(p_i) - (1 - y_i)\log(1 - p_i)$.
+			// $v_i$ is the weight of $\bm{x}_i$.
+			vi := float32(1.0)
+			if W != nil {
+				vi = W[i]
+			}
+			// Calculate the $l_i = -v_i(y_i\log(p_i) + (1 - y_i)\log(1 - p_i))$.
 			if Y[i] {
 				x := float32(0.0)
 				if x < -zi {
 					x = -zi
 				}
-				loss += x + Log32(Exp32(0-x)+Exp32(-zi-x))
+				loss += vi * (x + Log32(Exp32(0-x)+Exp32(-zi-x)))
 			} else {
 				x := float32(0.0)
 				if x < zi {
 					x = zi
 				}
-				loss += x + Log32(Exp32(0-x)+Exp32(zi-x))
+				loss += vi * (x + Log32(Exp32(0-x)+Exp32(zi-x)))
 			}
 			// gBias is the gradient for the bias.
-			gBias := -(yi - pi) * 1.0
+			gBias := -vi * (yi - pi) * 1.0
 			// Update the bias and the squared sum of the gradients for the bias.
 			bias -= (alpha / (beta + Sqrt32(gSqSum[d]))) * gBias * 1.0
 			gSqSum[d] += gBias * gBias
 			for _, xipair := range xi {
 				// gj is the gradient for the weight parameter.
-				gj := -(yi - pi) * xipair.Value
+				gj := -vi * (yi - pi) * xipair.Value
 				// Update the auxiliary vector and the squared sum of the gradient for the weight vector.
 				gSqSumj := gSqSum[xipair.Key] + gj*gj
 				sigmaj := (Sqrt32(gSqSumj) - Sqrt32(gSqSum[xipair.Key])) / alpha
@@ -156,6 +162,7 @@ func BinaryClassifierTrainer_L1Logistic_PrimalSGD(X FeatureVectors, Y []bool, C,
 // The used update procedure is the one used by Online Passive-Aggressive Algorithm (Crammer+ 2006) with the dynamic penalty parameter depending on the round number t.
 // This update is proven to be safe, that is, this leads to sane results even when the learning rate is large (Karampatziakis+ 2011, SubSection 4.2).
 // Thus, although we fix the eta0 as 1.0 and the learning rate as eta0 / t, this algorithm is enough fast and accurate.
+// The penalty parameter and the learning rate of each entry are multiplied by its weight.
 //
 // This function returns no error currently.
 //
@@ -164,7 +171,7 @@ func BinaryClassifierTrainer_L1Logistic_PrimalSGD(X FeatureVectors, Y []bool, C,
 // (Crammer+ 2006) K.Crammer, O. Dekel, J. Keshet, S. Shalev-Shwarts, and Y. Singer. "Online Passive-Aggressive Algorithms." Journal of Machine Learning Research, vol. 7, pp. 551-585, 2006.
 //
 // (Karampatziakis+ 2011) N. Karampatziakis, and J. Langford, "Online Importance Weight Aware Updates." Association for Uncertainty in Artificial Intelligence, 2011.
-func BinaryClassifierTrainer_L1SVC_PrimalSGD(X FeatureVectors, Y []bool, C, epsilon float32, debug *log.Logger) (*BinaryClassifier, error) {
+func BinaryClassifierTrainer_L1SVC_PrimalSGD(X FeatureVectors, Y []bool, W []float32, C, epsilon float32, debug *log.Logger) (*BinaryClassifier, error) {
 	rng := rand.New(rand.NewSource(0))
 	n, d := len(X), X.Dim()
 	b, w := float32(0.0), make([]float32, d)
@@ -201,13 +208,18 @@ func BinaryClassifierTrainer_L1SVC_PrimalSGD(X FeatureVectors, Y []bool, C, epsi
 			for _, xipair := range xi {
 				zi += w[xipair.Key] * xipair.Value
 			}
-			// loss: l_i = C\max\{0, 1 - y_iz_i\}
-			lossi := C * (1.0 - yi*zi)
+			// vi is the weight of the entry.
+			vi := float32(1.0)
+			if W != nil {
+				vi = W[i]
+			}
+			// loss: l_i = C v_i\max\{0, 1 - y_iz_i\}
+			lossi := C * vi * (1.0 - yi*zi)
 			if lossi > 0.0 {
-				// Step size: s_i = y_i \min\{eta0/t, l_i/(t(x_i)x_i)\}
+				// Step size: s_i = y_i \min\{v_i eta0/t, l_i/(t(x_i)x_i)\}
 				// Here, the weights are normalized for the case of size 1 sample.
 				si := lossi / Qdiag[i]
-				lambdai := eta0 / float32(t)
+				lambdai := vi * eta0 / float32(t)
 				if si > lambdai {
 					si = lambdai
 				}
@@ -243,10 +255,11 @@ func BinaryClassifierTrainer_L1SVC_PrimalSGD(X FeatureVectors, Y []bool, C, epsi
 
 // BinaryClassifierTrainer is the type of binary classifier trainers.
 // A trainer returns a new BinaryClassifier on X and Y.
+// W is the weight of each entry, or nil if the entries are weighted uniformly with 1.0.
 // C is the inverse of the penalty parameter.
 // epsilon is the tolerance parameter for checking the convergence.
 // debug is used for debug logs.
-type BinaryClassifierTrainer func(X FeatureVectors, Y []bool, C, epsilon float32, debug *log.Logger) (*BinaryClassifier, error)
+type BinaryClassifierTrainer func(X FeatureVectors, Y []bool, W []float32, C, epsilon float32, debug *log.Logger) (*BinaryClassifier, error)
 
 // BinaryClassifierTrainers is the map from the binary classifier trainer name to the corresponding binary classifier trainer.
 var BinaryClassifierTrainers = map[string]BinaryClassifierTrainer{
@@ -48,7 +48,7 @@ func TestBinaryClassifierTrainer_L1Logistic_PrimalSGD(t *testing.T) {
 		FeatureVector{KeyValue32{0, 0.0}, KeyValue32{1, 0.0}},
 		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0}},
 	}, []bool{false, true}
-	bsvc1 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X1, Y1, C, epsilon, debug)).(*BinaryClassifier)
+	bsvc1 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X1, Y1, nil, C, epsilon, debug)).(*BinaryClassifier)
 	goassert.New(t, Y1).Equal(ClassifyAllToBinaryClass(bsvc1.PredictAll(X1)))
 	// Case 2: fully-separable 2x2 points
 	X2, Y2 := FeatureVectors{
@@ -57,7 +57,7 @@ func TestBinaryClassifierTrainer_L1Logistic_PrimalSGD(t *testing.T) {
 		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0}},
 		FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 2.0}},
 	}, []bool{false, false, true, true}
-	bsvc2 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X2, Y2, C, epsilon, debug)).(*BinaryClassifier)
+	bsvc2 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X2, Y2, nil, C, epsilon, debug)).(*BinaryClassifier)
 	goassert.New(t, Y2).Equal(ClassifyAllToBinaryClass(bsvc2.PredictAll(X2)))
 	// Case 3: fully-separable 1 and 3 points
 	X3, Y3 := FeatureVectors{
@@ -66,7 +66,7 @@ func TestBinaryClassifierTrainer_L1Logistic_PrimalSGD(t *testing.T) {
 		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0}},
 		FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 2.0}},
 	}, []bool{true, false, true, true}
-	bsvc3 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X3, Y3, C, epsilon, debug)).(*BinaryClassifier)
+	bsvc3 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X3, Y3, nil, C, epsilon, debug)).(*BinaryClassifier)
 	goassert.New(t, Y3).Equal(ClassifyAllToBinaryClass(bsvc3.PredictAll(X3)))
 	// Case 4: fully-separable 3 and 1 points
 	X4, Y4 := FeatureVectors{
@@ -75,11 +75,21 @@ func TestBinaryClassifierTrainer_L1Logistic_PrimalSGD(t *testing.T) {
 		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0}},
 		FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 2.0}},
 	}, []bool{false, false, false, true}
-	bsvc4 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X4, Y4, C, epsilon, nil)).(*BinaryClassifier)
+	bsvc4 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X4, Y4, nil, C, epsilon, nil)).(*BinaryClassifier)
 	goassert.New(t, Y4).Equal(ClassifyAllToBinaryClass(bsvc4.PredictAll(X4)))
+	// Case 5: the conflicting points weighted differently
+	X5, Y5 := FeatureVectors{
+		FeatureVector{KeyValue32{0, 1.0}},
+		FeatureVector{KeyValue32{0, 1.0}},
+		FeatureVector{KeyValue32{1, 1.0}},
+	}, []bool{false, true, false}
+	bsvc5 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X5, Y5, []float32{1.0, 10.0, 1.0}, C, epsilon, nil)).(*BinaryClassifier)
+	goassert.New(t, []bool{true, true, false}).Equal(ClassifyAllToBinaryClass(bsvc5.PredictAll(X5)))
+	bsvc5 = goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X5, Y5, []float32{10.0, 1.0, 1.0}, C, epsilon, nil)).(*BinaryClassifier)
+	goassert.New(t, []bool{false, false, false}).Equal(ClassifyAllToBinaryClass(bsvc5.PredictAll(X5)))
 	// Expect any debug log.
 	var debugBuffer bytes.Buffer
-	goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X1, Y1, C, epsilon, log.New(&debugBuffer, "", 0)))
+	goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X1, Y1, nil, C, epsilon, log.New(&debugBuffer, "", 0)))
 	goassert.New(t, true).Equal(debugBuffer.String() != "")
 }
 
@@ -90,7 +100,7 @@ func TestBinaryClassifierTrainer_L1SVC_PrimalSGD(t *testing.T) {
 		FeatureVector{KeyValue32{0, 0.0}, KeyValue32{1, 0.0}},
 		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0}},
 	}, []bool{false, true}
-	bsvc1 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X1, Y1, C, epsilon, debug)).(*BinaryClassifier)
+	bsvc1 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X1, Y1, nil, C, epsilon, debug)).(*BinaryClassifier)
 	goassert.New(t, Y1).Equal(ClassifyAllToBinaryClass(bsvc1.PredictAll(X1)))
 	// Case 2: fully-separable 2x2 points
 	X2, Y2 := FeatureVectors{
@@ -99,7 +109,7 @@ func TestBinaryClassifierTrainer_L1SVC_PrimalSGD(t *testing.T) {
 		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0}},
 		FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 2.0}},
 	}, []bool{false, false, true, true}
-	bsvc2 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X2, Y2, C, epsilon, debug)).(*BinaryClassifier)
+	bsvc2 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X2, Y2, nil, C, epsilon, debug)).(*BinaryClassifier)
 	goassert.New(t, Y2).Equal(ClassifyAllToBinaryClass(bsvc2.PredictAll(X2)))
 	// Case 3: fully-separable 1 and 3 points
 	X3, Y3 := FeatureVectors{
@@ -108,7 +118,7 @@ func TestBinaryClassifierTrainer_L1SVC_PrimalSGD(t *testing.T) {
 		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0}},
 		FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 2.0}},
 	}, []bool{true, false, true, true}
-	bsvc3 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X3, Y3, C, epsilon, debug)).(*BinaryClassifier)
+	bsvc3 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X3, Y3, nil, C, epsilon, debug)).(*BinaryClassifier)
 	goassert.New(t, Y3).Equal(ClassifyAllToBinaryClass(bsvc3.PredictAll(X3)))
 	// Case 4: fully-separable 3 and 1 points
 	X4, Y4 := FeatureVectors{
@@ -117,11 +127,21 @@ func TestBinaryClassifierTrainer_L1SVC_PrimalSGD(t *testing.T) {
 		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0}},
 		FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 2.0}},
 	}, []bool{false, false, false, true}
-	bsvc4 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X4, Y4, C, epsilon, nil)).(*BinaryClassifier)
+	bsvc4 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X4, Y4, nil, C, epsilon, nil)).(*BinaryClassifier)
 	goassert.New(t, Y4).Equal(ClassifyAllToBinaryClass(bsvc4.PredictAll(X4)))
+	// Case 5: the conflicting points weighted differently
+	X5, Y5 := FeatureVectors{
+		FeatureVector{KeyValue32{0, 1.0}},
+		FeatureVector{KeyValue32{0, 1.0}},
+		FeatureVector{KeyValue32{1, 1.0}},
+	}, []bool{false, true, false}
+	bsvc5 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X5, Y5, []float32{1.0, 10.0, 1.0}, C, epsilon, nil)).(*BinaryClassifier)
+	goassert.New(t, []bool{true, true, false}).Equal(ClassifyAllToBinaryClass(bsvc5.PredictAll(X5)))
+	bsvc5 = goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X5, Y5, []float32{10.0, 1.0, 1.0}, C, epsilon, nil)).(*BinaryClassifier)
+	goassert.New(t, []bool{false, false, false}).Equal(ClassifyAllToBinaryClass(bsvc5.PredictAll(X5)))
 	// Expect any debug log.
 	var debugBuffer bytes.Buffer
-	goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X1, Y1, C, epsilon, log.New(&debugBuffer, "", 0)))
+	goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X1, Y1, nil, C, epsilon, log.New(&debugBuffer, "", 0)))
 	goassert.New(t, true).Equal(debugBuffer.String() != "")
 }
 
@@ -149,11 +169,11 @@ func BenchmarkBinaryClassifierTrainer_L1Logistic_PrimalSGD(b *testing.B) {
 	C, epsilon, debug := float32(1.0), float32(0.01), (*log.Logger)(nil)
 	X, Y := createBenchmarkDatasetForBinaryClassifier()
 	// Check the integrity
-	bsvc := goassert.New(b).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X, Y, C, epsilon, debug)).(*BinaryClassifier)
+	bsvc := goassert.New(b).SucceedNew(BinaryClassifierTrainer_L1Logistic_PrimalSGD(X, Y, nil, C, epsilon, debug)).(*BinaryClassifier)
 	goassert.New(b, Y).Equal(ClassifyAllToBinaryClass(bsvc.PredictAll(X)))
 	b.ResetTimer()
 	for t := 0; t < b.N; t++ {
-		BinaryClassifierTrainer_L1SVC_PrimalSGD(X, Y, C, epsilon, nil)
+		BinaryClassifierTrainer_L1SVC_PrimalSGD(X, Y, nil, C, epsilon, nil)
 	}
 }
 
@@ -161,10 +181,10 @@ func BenchmarkBinaryClassifierTrainer_L1SVC_PrimalSGD(b *testing.B) {
 	C, epsilon, debug := float32(1.0), float32(0.01), (*log.Logger)(nil)
 	X, Y := createBenchmarkDatasetForBinaryClassifier()
 	// Check the integrity
-	bsvc := goassert.New(b).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X, Y, C, epsilon, debug)).(*BinaryClassifier)
+	bsvc := goassert.New(b).SucceedNew(BinaryClassifierTrainer_L1SVC_PrimalSGD(X, Y, nil, C, epsilon, debug)).(*BinaryClassifier)
 	goassert.New(b, Y).Equal(ClassifyAllToBinaryClass(bsvc.PredictAll(X)))
 	b.ResetTimer()
 	for t := 0; t < b.N; t++ {
-		BinaryClassifierTrainer_L1SVC_PrimalSGD(X, Y, C, epsilon, nil)
+		BinaryClassifierTrainer_L1SVC_PrimalSGD(X, Y, nil, C, epsilon, nil)
 	}
 }
@@ -0,0 +1,139 @@
+package sticker
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// gzipMagic and bzip2Magic are the magic bytes at the head of gzip and bzip2 streams, respectively.
+// bzip2Magic is followed by the block size '1'-'9' and the magic bytes of the first block or the end of the stream.
+var (
+	gzipMagic             = []byte{0x1f, 0x8b}
+	bzip2Magic            = []byte("BZh")
+	bzip2BlockMagic       = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2EndOfStreamMagic = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// isBzip2Header returns true if head is the header of the bzip2 stream.
+// The plain text starting with "BZh" (like "BZhang") is not the header.
+func isBzip2Header(head []byte) bool {
+	if len(head) < len(bzip2Magic)+1+len(bzip2BlockMagic) || !bytes.HasPrefix(head, bzip2Magic) {
+		return false
+	}
+	if level := head[len(bzip2Magic)]; level < '1' || level > '9' {
+		return false
+	}
+	blockMagic := head[len(bzip2Magic)+1:]
+	return bytes.HasPrefix(blockMagic, bzip2BlockMagic) || bytes.HasPrefix(blockMagic, bzip2EndOfStreamMagic)
+}
+
+// NewDecompressedReader returns a new reader decompressing reader if reader is a gzip or bzip2 stream.
+// The compression is detected from the magic bytes, so the other stream (including the plain text starting with "BZh") is read as it is.
+// If the returned reader is io.Closer, then it must be closed after reading.
+//
+// This function returns an error in reading the magic bytes or the gzip header.
+func NewDecompressedReader(reader io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(reader)
+	magic, err := br.Peek(len(bzip2Magic) + 1 + len(bzip2BlockMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case isBzip2Header(magic):
+		return bzip2.NewReader(br), nil
+	default:
+		return br, nil
+	}
+}
+
+// decompressedFile is the io.ReadCloser on the decompressed file.
+type decompressedFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (file *decompressedFile) Close() error {
+	var err error
+	if closer, ok := file.Reader.(io.Closer); ok {
+		err = closer.Close()
+	}
+	if err2 := file.file.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// OpenDecompressedFile opens the given file decompressed transparently as NewDecompressedReader does.
+//
+// This function returns an error in opening the file.
+func OpenDecompressedFile(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewDecompressedReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &decompressedFile{
+		Reader: reader,
+		file:   file,
+	}, nil
+}
+
+// compressedFile is the io.WriteCloser on the compressed file.
+type compressedFile struct {
+	io.Writer
+	writer io.WriteCloser
+	bw     *bufio.Writer
+	file   *os.File
+}
+
+func (file *compressedFile) Close() error {
+	err := file.bw.Flush()
+	if file.writer != nil {
+		if err2 := file.writer.Close(); err == nil {
+			err = err2
+		}
+	}
+	if err2 := file.file.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// CreateCompressedFile creates the given file compressed by the extension.
+// The file is gzip-compressed if the extension is .gz, otherwise the file is not compressed.
+// The returned writer is buffered, so it must be closed for flushing.
+//
+// This function returns an error in creating the file, or the extension is .bz2 whose compression is not supported.
+func CreateCompressedFile(filename string) (io.WriteCloser, error) {
+	ext := filepath.Ext(filename)
+	if ext == ".bz2" {
+		return nil, fmt.Errorf("%s: bzip2 compression is not supported", filename)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	compressed := &compressedFile{
+		file: file,
+	}
+	if ext == ".gz" {
+		compressed.writer = gzip.NewWriter(file)
+		compressed.bw = bufio.NewWriter(compressed.writer)
+	} else {
+		compressed.bw = bufio.NewWriter(file)
+	}
+	compressed.Writer = compressed.bw
+	return compressed, nil
+}
@@ -0,0 +1,62 @@
+package sticker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestNewDecompressedReader(t *testing.T) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	gzipWriter.Write([]byte("hello"))
+	gzipWriter.Close()
+	reader := goassert.New(t).SucceedNew(NewDecompressedReader(&buf))
+	goassert.New(t, []byte("hello")).EqualWithoutError(ioutil.ReadAll(reader.(io.Reader)))
+	// The bzip2-compressed "hello\n".
+	bzip2Data := []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xc1, 0xc0, 0x80, 0xe2, 0x00, 0x00,
+		0x01, 0x41, 0x00, 0x00, 0x10, 0x02, 0x44, 0xa0, 0x00, 0x30, 0xcd, 0x00, 0xc3, 0x46, 0x29, 0x97,
+		0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0xc1, 0xc0, 0x80, 0xe2,
+	}
+	reader = goassert.New(t).SucceedNew(NewDecompressedReader(bytes.NewReader(bzip2Data)))
+	goassert.New(t, []byte("hello\n")).EqualWithoutError(ioutil.ReadAll(reader.(io.Reader)))
+	reader = goassert.New(t).SucceedNew(NewDecompressedReader(bytes.NewReader([]byte("B"))))
+	goassert.New(t, []byte("B")).EqualWithoutError(ioutil.ReadAll(reader.(io.Reader)))
+	// The plain texts starting with "BZh" are not bzip2 streams.
+	for _, text := range []string{"BZhang 0:1.0\n", "BZh9 0:1.0\n", "BZh", "BZh9"} {
+		reader = goassert.New(t).SucceedNew(NewDecompressedReader(bytes.NewReader([]byte(text))))
+		goassert.New(t, []byte(text)).EqualWithoutError(ioutil.ReadAll(reader.(io.Reader)))
+	}
+	// The empty bzip2 stream has only the header and the end-of-stream marker.
+	reader = goassert.New(t).SucceedNew(NewDecompressedReader(bytes.NewReader([]byte{0x42, 0x5a, 0x68, 0x39, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x00, 0x00, 0x00, 0x00})))
+	goassert.New(t, []byte{}).EqualWithoutError(ioutil.ReadAll(reader.(io.Reader)))
+}
+
+func TestWriteTextDatasetFile(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{0, 1.0}},
+			FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 3.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{0}, LabelVector{0, 1},
+		},
+	}
+	dirname := goassert.New(t).SucceedNew(ioutil.TempDir("", "sticker")).(string)
+	defer os.RemoveAll(dirname)
+	for _, name := range []string{"train.txt", "train.txt.gz"} {
+		filename := filepath.Join(dirname, name)
+		goassert.New(t).SucceedWithoutError(ds.WriteTextDatasetFile(filename))
+		goassert.New(t, ds).EqualWithoutError(ReadTextDatasetFile(filename))
+	}
+	data := goassert.New(t).SucceedNew(ioutil.ReadFile(filepath.Join(dirname, "train.txt.gz"))).([]byte)
+	goassert.New(t, gzipMagic).Equal(data[:2])
+	goassert.New(t, ".+: bzip2 compression is not supported").ExpectError(CreateCompressedFile(filepath.Join(dirname, "train.txt.bz2")))
+}
@@ -1,14 +1,13 @@
 package sticker
 
 import (
-	"bufio"
 	"encoding/gob"
 	"fmt"
 	"io"
 	"math/bits"
+	"runtime"
 	"sort"
-	"strconv"
-	"strings"
+	"sync"
 )
 
 // Float32Slice implements the interface sort.Interface.
@@ -273,6 +272,20 @@ func (m KeyCountMap32) Get(key uint32) KeyCount32 {
 	return KeyCount32{0, 0}
 }
 
+// Add adds count to the entry's value with the given key, and returns the entry.
+// count must be positive.
+func (m KeyCountMap32) Add(key, count uint32) KeyCount32 {
+	k := HashUint32(key) & uint32(len(m)-1)
+	for ; m[k].Count > 0; k = (k + 1) & uint32(len(m)-1) {
+		if m[k].Key == key {
+			m[k].Count += count
+			return KeyCount32{m[k].Key, m[k].Count}
+		}
+	}
+	m[k] = KeyCount32{key, count}
+	return KeyCount32{m[k].Key, m[k].Count}
+}
+
 // Inc increments the entry's value with the given key, and returns the entry.
 func (m KeyCountMap32) Inc(key uint32) KeyCount32 {
 	k := HashUint32(key) & uint32(len(m)-1)
@@ -410,9 +423,12 @@ func (Y LabelVectors) Dim() (d int) {
 }
 
 // Dataset is a collection of the pair of one feature vector and one label vector.
+// Each entry can have the weight used in training.
 type Dataset struct {
 	X FeatureVectors
 	Y LabelVectors
+	// W is the weight of each entry, or nil if the entries are weighted uniformly with 1.0.
+	W []float32
 }
 
 // ReadTextDataset returns a new Dataset from reader.
@@ -421,88 +437,227 @@ type Dataset struct {
 // It is a plain text whose cells are separated by single space.
 // The first line has the number of entries, features, and labels.
 // The remaining lines have entries of the dataset.
-// Each entry is encoded in one line like (comma-separated label) (feature:value)*.
+// Each entry is encoded in one line like (comma-separated label) [weight] (feature:value)*.
+// The optional weight is a non-negative number without colon, and W is nil if every entry has the weight 1.0.
+//
+// The lines are parsed by GOMAXPROCS workers (see ReadTextDatasetWithWorkers).
+// See TextDatasetReader for reading the entries one by one, and ReadTextDatasetWithParameters for reading the text tolerantly.
 //
 // This function returns an error in reading the dataset.
 func ReadTextDataset(reader io.Reader) (*Dataset, error) {
-	br := bufio.NewReader(reader)
-	line, err := br.ReadString('\n')
-	if err != nil {
-		return nil, fmt.Errorf("cannot read first line")
-	}
-	line = strings.TrimSpace(line)
-	nentriesNfeaturesNlabels := strings.Split(line, " ")
-	if len(nentriesNfeaturesNlabels) != 3 {
-		return nil, fmt.Errorf("illegal first line")
-	}
-	nentries, err := strconv.ParseUint(nentriesNfeaturesNlabels[0], 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("illegal nentries in first line")
-	}
-	nfeatures, err := strconv.ParseUint(nentriesNfeaturesNlabels[1], 10, 32)
+	return ReadTextDatasetWithWorkers(reader, runtime.GOMAXPROCS(0))
+}
+
+// textDatasetBlockSize is the number of the lines in each block parsed by a worker in ReadTextDatasetWithWorkers.
+var textDatasetBlockSize = 4096
+
+// ReadTextDatasetFile returns a new Dataset from the given file as ReadTextDataset does.
+// The file is decompressed transparently as OpenDecompressedFile does.
+//
+// This function returns an error in opening or reading the file.
+func ReadTextDatasetFile(filename string) (*Dataset, error) {
+	file, err := OpenDecompressedFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("illegal nfeatures in first line")
+		return nil, err
 	}
-	nlabels, err := strconv.ParseUint(nentriesNfeaturesNlabels[2], 10, 32)
+	defer file.Close()
+	return ReadTextDataset(file)
+}
+
+// ReadTextDatasetWithWorkers is ReadTextDataset parsing the lines with nworkers workers.
+// The lines are split into blocks, and each block is parsed by any worker.
+// The order of the entries is kept, and the returned error is the one at the first illegal line as reading the lines sequentially.
+// If nworkers is 0, then the lines are parsed by one worker.
+//
+// This function returns an error in reading the dataset.
+func ReadTextDatasetWithWorkers(reader io.Reader, nworkers int) (*Dataset, error) {
+	return ReadTextDatasetWithParameters(reader, &TextDatasetParameters{
+		Nworkers: nworkers,
+	})
+}
+
+// ReadTextDatasetWithParameters is ReadTextDatasetWithWorkers reading the text with the given parameters.
+// See TextDatasetParameters for the tolerant format, and ReadTextDatasetWithReport for the issues in the lenient mode.
+//
+// This function returns an error in reading the dataset.
+func ReadTextDatasetWithParameters(reader io.Reader, params *TextDatasetParameters) (*Dataset, error) {
+	ds, _, err := ReadTextDatasetWithReport(reader, params)
+	return ds, err
+}
+
+// ReadTextDatasetWithReport is ReadTextDatasetWithParameters returning the report of the issues found in the lenient mode.
+// The parse error is *TextDatasetParseError, and the lines having the parse errors are skipped in the lenient mode.
+//
+// This function returns an error in reading the dataset.
+func ReadTextDatasetWithReport(reader io.Reader, params *TextDatasetParameters) (*Dataset, *TextDatasetReport, error) {
+	textReader, err := NewTextDatasetReaderWithParameters(reader, params)
 	if err != nil {
-		return nil, fmt.Errorf("illegal nlabels in first line")
-	}
-	X := make(FeatureVectors, nentries)
-	Y := make(LabelVectors, nentries)
-	for i := uint64(0); i < nentries; i++ {
-		line, err := br.ReadString('\n')
-		if err != nil {
-			return nil, fmt.Errorf("L%d: cannot read line", i+1)
-		}
-		line = strings.TrimSpace(line)
-		entry := strings.Split(line, " ")
-		labelStrs := strings.Split(entry[0], ",")
-		entry = entry[1:]
-		y := make(LabelVector, 0, len(labelStrs))
-		for j, labelStr := range labelStrs {
-			label, err := strconv.ParseUint(labelStr, 10, 32)
-			if err != nil {
-				return nil, fmt.Errorf("L%d: illegal #%d label ID", i+1, j+1)
-			}
-			if label >= nlabels {
-				return nil, fmt.Errorf("L%d: too large #%d label ID (>= %d)", i+1, j+1, nlabels)
+		return nil, nil, err
+	}
+	nworkers := params.Nworkers
+	if nworkers < 1 {
+		nworkers = 1
+	}
+	// If the number of the entries is unknown, then each block has its own vectors.
+	// The vectors are concatenated at last if the number is unknown or any line is skipped.
+	n := textReader.nentries
+	var X FeatureVectors
+	var Y LabelVectors
+	var W []float32
+	if textReader.hasHeader {
+		X, Y, W = make(FeatureVectors, n), make(LabelVectors, n), make([]float32, n)
+	}
+	type lineBlock struct {
+		start    uint64
+		lines    []string
+		X        FeatureVectors
+		Y        LabelVectors
+		W        []float32
+		weighted bool
+		skipped  []bool
+		issues   []TextDatasetIssue
+	}
+	blocks := make(chan *lineBlock, nworkers)
+	// Each worker keeps the error at the first illegal line in the parsed blocks.
+	errs, errLines := make([]error, nworkers), make([]uint64, nworkers)
+	var wg sync.WaitGroup
+	for w := 0; w < nworkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for block := range blocks {
+				for k, line := range block.lines {
+					i := block.start + uint64(k) + 1
+					if errs[w] != nil && errLines[w] < i {
+						break
+					}
+					x, y, weight, issues, err := textReader.parseLine(line, i)
+					block.issues = append(block.issues, issues...)
+					if err != nil {
+						if params.Lenient {
+							block.issues = append(block.issues, TextDatasetIssue{TextDatasetSkippedLine, err.(*TextDatasetParseError)})
+							block.skipped[k] = true
+							continue
+						}
+						errs[w], errLines[w] = err, i
+						break
+					}
+					block.X[k], block.Y[k], block.W[k] = x, y, weight
+					if weight != 1.0 {
+						block.weighted = true
+					}
+				}
+				// The parsed lines are released, so only the parsed vectors are kept until the merge.
+				block.lines = nil
 			}
-			y = append(y, uint32(label))
+		}(w)
+	}
+	var readBlocks []*lineBlock
+	var readErr error
+	start := uint64(0)
+	for eof := false; !eof && readErr == nil; {
+		size := uint64(textDatasetBlockSize)
+		if textReader.hasHeader && size > n-start {
+			size = n - start
 		}
-		x := make(FeatureVector, 0, len(entry))
-		for j, featureValueStr := range entry {
-			featureValue := strings.Split(featureValueStr, ":")
-			if len(featureValue) != 2 {
-				return nil, fmt.Errorf("L%d: illegal #%d featureID:value pair", i+1, j+1)
+		if size == 0 {
+			break
+		}
+		lines := make([]string, 0, size)
+		for uint64(len(lines)) < size {
+			line, err := textReader.readLine()
+			if err == io.EOF {
+				eof = true
+				break
 			}
-			feature, err := strconv.ParseUint(featureValue[0], 10, 32)
 			if err != nil {
-				return nil, fmt.Errorf("L%d: illegal #%d feature ID", i+1, j+1)
+				readErr = &TextDatasetParseError{start + uint64(len(lines)) + 1, 0, "line", "cannot read line"}
+				break
 			}
-			if feature >= nfeatures {
-				return nil, fmt.Errorf("L%d: too large #%d feature ID (>= %d)", i+1, j+1, nfeatures)
+			lines = append(lines, line)
+		}
+		block := &lineBlock{start: start, lines: lines, skipped: make([]bool, len(lines))}
+		if textReader.hasHeader {
+			end := start + uint64(len(lines))
+			block.X, block.Y, block.W = X[start:end], Y[start:end], W[start:end]
+		} else {
+			block.X, block.Y, block.W = make(FeatureVectors, len(lines)), make(LabelVectors, len(lines)), make([]float32, len(lines))
+		}
+		readBlocks = append(readBlocks, block)
+		blocks <- block
+		start += uint64(len(lines))
+	}
+	close(blocks)
+	wg.Wait()
+	// The read error occurs after the all lines in the blocks, so the parse error precedes it.
+	err, errLine := readErr, start+1
+	for w, werr := range errs {
+		if werr != nil && errLines[w] < errLine {
+			err, errLine = werr, errLines[w]
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	report := &TextDatasetReport{
+		Nlines: start,
+	}
+	nskippeds, weighted := 0, false
+	for _, block := range readBlocks {
+		report.Issues = append(report.Issues, block.issues...)
+		weighted = weighted || block.weighted
+		for _, skipped := range block.skipped {
+			if skipped {
+				nskippeds++
 			}
-			value, err := strconv.ParseFloat(featureValue[1], 32)
-			if err != nil {
-				return nil, fmt.Errorf("L%d: illegal #%d feature value", i+1, j+1)
+		}
+	}
+	if !textReader.hasHeader || nskippeds > 0 {
+		X, Y, W = make(FeatureVectors, 0, int(start)-nskippeds), make(LabelVectors, 0, int(start)-nskippeds), make([]float32, 0, int(start)-nskippeds)
+		for _, block := range readBlocks {
+			for k, skipped := range block.skipped {
+				if !skipped {
+					X, Y, W = append(X, block.X[k]), append(Y, block.Y[k]), append(W, block.W[k])
+				}
 			}
-			x = append(x, KeyValue32{uint32(feature), float32(value)})
 		}
-		sort.Sort(x)
-		X[i], Y[i] = x, y
 	}
+	if !weighted {
+		W = nil
+	}
+	textReader.i, textReader.issues = start, report.Issues
 	return &Dataset{
 		X: X,
 		Y: Y,
-	}, nil
+		W: W,
+	}, report, nil
+}
+
+// Append appends the entries of other to the dataset.
+// If only one of the datasets has the weights, then the entries of the other one are weighted with 1.0.
+// For efficiency, the appended vectors have references to the ones of other.
+func (ds *Dataset) Append(other *Dataset) {
+	if ds.W != nil || other.W != nil {
+		if ds.W == nil {
+			ds.W = make([]float32, ds.Size(), ds.Size()+other.Size())
+			for i := range ds.W {
+				ds.W[i] = 1.0
+			}
+		}
+		for i := range other.X {
+			ds.W = append(ds.W, other.Weight(i))
+		}
+	}
+	ds.X, ds.Y = append(ds.X, other.X...), append(ds.Y, other.Y...)
 }
 
 // FeatureSubSet returns the sub-set of the dataset whose entry has only features in the given set of features.
-// For efficiency, the label vectors of sub-dataset has references to the one of the dataset.
+// For efficiency, the label vectors and the weights of sub-dataset has references to the one of the dataset.
 func (ds *Dataset) FeatureSubSet(features map[uint32]struct{}) *Dataset {
 	subds := &Dataset{
 		X: FeatureVectors{},
 		Y: LabelVectors{},
+		W: ds.W,
 	}
 	for i, xi := range ds.X {
 		xinew := FeatureVector{}
@@ -524,6 +679,10 @@ func DecodeDatasetWithGobDecoder(ds *Dataset, decoder *gob.Decoder) error {
 	if err := decoder.Decode(&size); err != nil {
 		return fmt.Errorf("DecodeDataset: size: %s", err)
 	}
+	weighted := size < 0
+	if weighted {
+		size = ^size
+	}
 	ds.X = make(FeatureVectors, size)
 	for i := range ds.X {
 		if err := decoder.Decode(&ds.X[i]); err != nil {
@@ -536,6 +695,12 @@ func DecodeDatasetWithGobDecoder(ds *Dataset, decoder *gob.Decoder) error {
 			return fmt.Errorf("DecodeDataset: #%d label vector: %s", i, err)
 		}
 	}
+	ds.W = nil
+	if weighted {
+		if err := decoder.Decode(&ds.W); err != nil {
+			return fmt.Errorf("DecodeDataset: weights: %s", err)
+		}
+	}
 	return nil
 }
 
@@ -549,10 +714,15 @@ func DecodeDataset(ds *Dataset, r io.Reader) error {
 }
 
 // EncodeDatasetWithGobEncoder decodes Dataset using encoder.
+// If the dataset has the weights, then the size is encoded as its bitwise complement and the weights follow the label vectors, so the datasets encoded without the weights can be still decoded.
 //
 // This function returns an error in decoding.
 func EncodeDatasetWithGobEncoder(ds *Dataset, encoder *gob.Encoder) error {
-	if err := encoder.Encode(ds.Size()); err != nil {
+	size := ds.Size()
+	if ds.W != nil {
+		size = ^size
+	}
+	if err := encoder.Encode(size); err != nil {
 		return fmt.Errorf("EncodeDataset: size: %s", err)
 	}
 	for i, xi := range ds.X {
@@ -565,6 +735,11 @@ func EncodeDatasetWithGobEncoder(ds *Dataset, encoder *gob.Encoder) error {
 			return fmt.Errorf("EncodeDataset: #%d label vector: %s", i, err)
 		}
 	}
+	if ds.W != nil {
+		if err := encoder.Encode(ds.W); err != nil {
+			return fmt.Errorf("EncodeDataset: weights: %s", err)
+		}
+	}
 	return nil
 }
 
@@ -599,10 +774,26 @@ func (ds *Dataset) SubSet(indices []int) *Dataset {
 	for ii, i := range indices {
 		subds.X[ii], subds.Y[ii] = ds.X[i], ds.Y[i]
 	}
+	if ds.W != nil {
+		subds.W = make([]float32, len(indices))
+		for ii, i := range indices {
+			subds.W[ii] = ds.W[i]
+		}
+	}
 	return subds
 }
 
+// Weight returns the weight of the i-th entry, which is 1.0 if the dataset has no weights.
+func (ds *Dataset) Weight(i int) float32 {
+	if ds.W == nil {
+		return 1.0
+	}
+	return ds.W[i]
+}
+
 // WriteTextDataset writes the dataset to the given writer as ReadTextDataset supports.
+// The entry having no label is written in the line starting with a space, so such a dataset must be read with TextDatasetParameters.AllowEmptyLabels.
+// The weight of each entry is written after the labels if the dataset has the weights.
 //
 // This function returns an error in writing.
 func (ds *Dataset) WriteTextDataset(w io.Writer) error {
@@ -620,6 +811,11 @@ func (ds *Dataset) WriteTextDataset(w io.Writer) error {
 				return err
 			}
 		}
+		if ds.W != nil {
+			if _, err := fmt.Fprintf(w, " %g", ds.W[i]); err != nil {
+				return err
+			}
+		}
 		for _, xipair := range xi {
 			if _, err := fmt.Fprintf(w, " %d:%f", xipair.Key, xipair.Value); err != nil {
 				return err
@@ -631,3 +827,19 @@ func (ds *Dataset) WriteTextDataset(w io.Writer) error {
 	}
 	return nil
 }
+
+// WriteTextDatasetFile writes the dataset to the given file as WriteTextDataset does.
+// The file is compressed by the extension as CreateCompressedFile does.
+//
+// This function returns an error in creating or writing the file.
+func (ds *Dataset) WriteTextDatasetFile(filename string) error {
+	file, err := CreateCompressedFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := ds.WriteTextDataset(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
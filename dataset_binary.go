@@ -0,0 +1,293 @@
+package sticker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"unsafe"
+)
+
+// BinaryDatasetMagic is the magic bytes at the head of the binary dataset.
+const BinaryDatasetMagic = "STKRCSR\x00"
+
+// BinaryDatasetVersion is the version of the binary dataset format written by WriteBinaryDataset.
+const BinaryDatasetVersion = uint32(1)
+
+// binaryDatasetWeighted is the flag in the header of the binary dataset indicating that the weights follow the label keys.
+const binaryDatasetWeighted = uint32(1)
+
+// binaryDatasetTagShift is the shift of the tag in the flags of the binary dataset (see WriteBinaryDatasetWithTag).
+const binaryDatasetTagShift = 16
+
+// binaryDatasetHeaderSize is the byte size of the header of the binary dataset.
+const binaryDatasetHeaderSize = 8 + 4 + 4 + 8*5
+
+// WriteBinaryDataset writes the dataset to the given writer in the binary format which ReadBinaryDataset and OpenMappedDataset support.
+//
+// The binary format is the little-endian Compressed Sparse Row (CSR) format as follows:
+//   header: magic (8 bytes), version (uint32), flags (uint32), nentries, nfeatures, nlabels, nfeaturePairs, nlabelKeys (uint64 each),
+//   featureOffsets: nentries+1 uint64s, labelOffsets: nentries+1 uint64s,
+//   featurePairs: nfeaturePairs pairs of the uint32 key and the float32 value, labelKeys: nlabelKeys uint32s,
+//   weights: nentries float32s only if the bit 0 of flags is set.
+// The upper 16 bits of flags are the tag (see WriteBinaryDatasetWithTag).
+// The keys and values of the features are interleaved, so each row can be viewed as FeatureVector without copying.
+// nfeatures and nlabels are the dimensions of the feature and label vectors, respectively.
+//
+// This function returns an error in writing.
+func (ds *Dataset) WriteBinaryDataset(w io.Writer) error {
+	return ds.WriteBinaryDatasetWithTag(w, 0)
+}
+
+// WriteBinaryDatasetWithTag is WriteBinaryDataset writing the given tag in the header.
+// The tag is defined by users, for example, for identifying the parameters used for reading the dataset (see MappedDataset.Tag).
+//
+// This function returns an error in writing.
+func (ds *Dataset) WriteBinaryDatasetWithTag(w io.Writer, tag uint16) error {
+	bw := bufio.NewWriter(w)
+	nfeaturePairs, nlabelKeys := uint64(0), uint64(0)
+	for i, xi := range ds.X {
+		nfeaturePairs, nlabelKeys = nfeaturePairs+uint64(len(xi)), nlabelKeys+uint64(len(ds.Y[i]))
+	}
+	if _, err := bw.WriteString(BinaryDatasetMagic); err != nil {
+		return err
+	}
+	var buf [8]byte
+	writeUint32 := func(v uint32) error {
+		binary.LittleEndian.PutUint32(buf[:4], v)
+		_, err := bw.Write(buf[:4])
+		return err
+	}
+	writeUint64 := func(v uint64) error {
+		binary.LittleEndian.PutUint64(buf[:8], v)
+		_, err := bw.Write(buf[:8])
+		return err
+	}
+	flags := uint32(tag) << binaryDatasetTagShift
+	if ds.W != nil {
+		flags |= binaryDatasetWeighted
+	}
+	for _, v := range []uint32{BinaryDatasetVersion, flags} {
+		if err := writeUint32(v); err != nil {
+			return err
+		}
+	}
+	for _, v := range []uint64{uint64(ds.Size()), uint64(ds.X.Dim()), uint64(ds.Y.Dim()), nfeaturePairs, nlabelKeys} {
+		if err := writeUint64(v); err != nil {
+			return err
+		}
+	}
+	offset := uint64(0)
+	for _, xi := range ds.X {
+		if err := writeUint64(offset); err != nil {
+			return err
+		}
+		offset += uint64(len(xi))
+	}
+	if err := writeUint64(offset); err != nil {
+		return err
+	}
+	offset = 0
+	for _, yi := range ds.Y {
+		if err := writeUint64(offset); err != nil {
+			return err
+		}
+		offset += uint64(len(yi))
+	}
+	if err := writeUint64(offset); err != nil {
+		return err
+	}
+	for _, xi := range ds.X {
+		for _, xipair := range xi {
+			if err := writeUint32(xipair.Key); err != nil {
+				return err
+			}
+			if err := writeUint32(math.Float32bits(xipair.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, yi := range ds.Y {
+		for _, label := range yi {
+			if err := writeUint32(label); err != nil {
+				return err
+			}
+		}
+	}
+	for _, wi := range ds.W {
+		if err := writeUint32(math.Float32bits(wi)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadBinaryDataset returns a new Dataset from reader formatted as WriteBinaryDataset does.
+//
+// This function returns an error in reading the dataset.
+func ReadBinaryDataset(reader io.Reader) (*Dataset, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	ds, _, err := viewBinaryDataset(data)
+	return ds, err
+}
+
+// IsBinaryDataset returns true if the given file starts with BinaryDatasetMagic.
+func IsBinaryDataset(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	magic := make([]byte, len(BinaryDatasetMagic))
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return false
+	}
+	return string(magic) == BinaryDatasetMagic
+}
+
+// MappedDataset is the Dataset view on the memory-mapped binary dataset file.
+// The vectors in Dataset refer the mapped memory which is private to the process, so modifying them does not change the file.
+//
+// The vectors must not be used after calling Close.
+type MappedDataset struct {
+	*Dataset
+	// Tag is the tag written by WriteBinaryDatasetWithTag.
+	Tag    uint16
+	data   []byte
+	mapped bool
+}
+
+// OpenMappedDataset returns a new MappedDataset on the binary dataset file formatted as WriteBinaryDataset does.
+// If memory-mapping is not supported, then the file is read into the memory.
+//
+// This function returns an error in opening, mapping or reading the file.
+func OpenMappedDataset(filename string) (*MappedDataset, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, mapped, err := mapFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ds, tag, err := viewBinaryDataset(data)
+	if err != nil {
+		if mapped {
+			unmapFile(data)
+		}
+		return nil, err
+	}
+	return &MappedDataset{
+		Dataset: ds,
+		Tag:     tag,
+		data:    data,
+		mapped:  mapped,
+	}, nil
+}
+
+// Close unmaps the mapped memory.
+//
+// This function returns an error in unmapping.
+func (ds *MappedDataset) Close() error {
+	data, mapped := ds.data, ds.mapped
+	ds.Dataset, ds.data, ds.mapped = &Dataset{}, nil, false
+	if mapped {
+		return unmapFile(data)
+	}
+	return nil
+}
+
+// isLittleEndian is true if the host is little-endian.
+var isLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// viewBinaryDataset returns the Dataset view and the tag on data formatted as WriteBinaryDatasetWithTag does.
+// The vectors refer data without copying if the host is little-endian and data is aligned, otherwise the vectors are decoded.
+func viewBinaryDataset(data []byte) (*Dataset, uint16, error) {
+	if len(data) < binaryDatasetHeaderSize || string(data[:8]) != BinaryDatasetMagic {
+		return nil, 0, fmt.Errorf("illegal binary dataset header")
+	}
+	if version := binary.LittleEndian.Uint32(data[8:12]); version != BinaryDatasetVersion {
+		return nil, 0, fmt.Errorf("unsupported binary dataset version %d (!= %d)", version, BinaryDatasetVersion)
+	}
+	flags := binary.LittleEndian.Uint32(data[12:16])
+	if flags&^(binaryDatasetWeighted|0xffff<<binaryDatasetTagShift) != 0 {
+		return nil, 0, fmt.Errorf("unsupported binary dataset flags 0x%x", flags)
+	}
+	nentries := binary.LittleEndian.Uint64(data[16:24])
+	nfeaturePairs, nlabelKeys := binary.LittleEndian.Uint64(data[40:48]), binary.LittleEndian.Uint64(data[48:56])
+	featureOffsetsStart := uint64(binaryDatasetHeaderSize)
+	labelOffsetsStart := featureOffsetsStart + 8*(nentries+1)
+	featurePairsStart := labelOffsetsStart + 8*(nentries+1)
+	labelKeysStart := featurePairsStart + 8*nfeaturePairs
+	weightsStart, nweights := labelKeysStart+4*nlabelKeys, uint64(0)
+	if flags&binaryDatasetWeighted != 0 {
+		nweights = nentries
+	}
+	if nentries >= 1<<32 || nfeaturePairs >= 1<<40 || nlabelKeys >= 1<<40 || weightsStart+4*nweights != uint64(len(data)) {
+		return nil, 0, fmt.Errorf("illegal binary dataset size")
+	}
+	featureOffset := func(i uint64) uint64 {
+		return binary.LittleEndian.Uint64(data[featureOffsetsStart+8*i:])
+	}
+	labelOffset := func(i uint64) uint64 {
+		return binary.LittleEndian.Uint64(data[labelOffsetsStart+8*i:])
+	}
+	if featureOffset(0) != 0 || featureOffset(nentries) != nfeaturePairs || labelOffset(0) != 0 || labelOffset(nentries) != nlabelKeys {
+		return nil, 0, fmt.Errorf("illegal binary dataset offsets")
+	}
+	var pairs []KeyValue32
+	var labels []uint32
+	var weights []float32
+	if isLittleEndian && uintptr(unsafe.Pointer(&data[0]))%8 == 0 {
+		if nfeaturePairs > 0 {
+			pairs = unsafe.Slice((*KeyValue32)(unsafe.Pointer(&data[featurePairsStart])), nfeaturePairs)
+		}
+		if nlabelKeys > 0 {
+			labels = unsafe.Slice((*uint32)(unsafe.Pointer(&data[labelKeysStart])), nlabelKeys)
+		}
+		if nweights > 0 {
+			weights = unsafe.Slice((*float32)(unsafe.Pointer(&data[weightsStart])), nweights)
+		}
+	} else {
+		pairs, labels, weights = make([]KeyValue32, nfeaturePairs), make([]uint32, nlabelKeys), make([]float32, nweights)
+		for j := range pairs {
+			p := featurePairsStart + 8*uint64(j)
+			pairs[j] = KeyValue32{binary.LittleEndian.Uint32(data[p:]), math.Float32frombits(binary.LittleEndian.Uint32(data[p+4:]))}
+		}
+		for j := range labels {
+			labels[j] = binary.LittleEndian.Uint32(data[labelKeysStart+4*uint64(j):])
+		}
+		for j := range weights {
+			weights[j] = math.Float32frombits(binary.LittleEndian.Uint32(data[weightsStart+4*uint64(j):]))
+		}
+	}
+	if flags&binaryDatasetWeighted == 0 {
+		weights = nil
+	} else if weights == nil {
+		weights = []float32{}
+	}
+	ds := &Dataset{
+		X: make(FeatureVectors, nentries),
+		Y: make(LabelVectors, nentries),
+		W: weights,
+	}
+	for i := uint64(0); i < nentries; i++ {
+		xstart, xend := featureOffset(i), featureOffset(i+1)
+		ystart, yend := labelOffset(i), labelOffset(i+1)
+		if xstart > xend || xend > nfeaturePairs || ystart > yend || yend > nlabelKeys {
+			return nil, 0, fmt.Errorf("illegal binary dataset offsets of #%d entry", i)
+		}
+		ds.X[i], ds.Y[i] = FeatureVector(pairs[xstart:xend:xend]), LabelVector(labels[ystart:yend:yend])
+	}
+	return ds, uint16(flags >> binaryDatasetTagShift), nil
+}
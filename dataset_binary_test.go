@@ -0,0 +1,68 @@
+package sticker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestDatasetWriteBinaryDataset(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{0, 1.0}},
+			FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 3.0}},
+			FeatureVector{},
+			FeatureVector{KeyValue32{10, 7.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{0}, LabelVector{0, 1}, LabelVector{0, 2, 9}, LabelVector{},
+		},
+	}
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(ds.WriteBinaryDataset(&buf))
+	data := buf.Bytes()
+	goassert.New(t, ds).EqualWithoutError(ReadBinaryDataset(bytes.NewReader(data)))
+	goassert.New(t, "illegal binary dataset header").ExpectError(ReadBinaryDataset(bytes.NewReader(data[:7])))
+	goassert.New(t, "illegal binary dataset size").ExpectError(ReadBinaryDataset(bytes.NewReader(data[:len(data)-1])))
+	data2 := append([]byte{}, data...)
+	data2[8] = 2
+	goassert.New(t, "unsupported binary dataset version 2 \\(!= 1\\)").ExpectError(ReadBinaryDataset(bytes.NewReader(data2)))
+	data2 = append([]byte{}, data...)
+	data2[binaryDatasetHeaderSize+8] = 5
+	goassert.New(t, "illegal binary dataset offsets of #0 entry").ExpectError(ReadBinaryDataset(bytes.NewReader(data2)))
+	// Test the memory-mapped dataset.
+	dirname := goassert.New(t).SucceedNew(ioutil.TempDir("", "sticker")).(string)
+	defer os.RemoveAll(dirname)
+	filename := filepath.Join(dirname, "train.txt.csr")
+	goassert.New(t).SucceedWithoutError(ioutil.WriteFile(filename, data, 0644))
+	goassert.New(t, true).Equal(IsBinaryDataset(filename))
+	goassert.New(t, false).Equal(IsBinaryDataset(filepath.Join(dirname, "notfound")))
+	mapped := goassert.New(t).SucceedNew(OpenMappedDataset(filename)).(*MappedDataset)
+	goassert.New(t, ds).Equal(mapped.Dataset)
+	mapped.X[1][0].Value = 10.0
+	goassert.New(t).SucceedWithoutError(mapped.Close())
+	goassert.New(t, data).EqualWithoutError(ioutil.ReadFile(filename))
+	goassert.New(t, 0).Equal(mapped.Size())
+	// The tag should be kept in the upper bits of the flags.
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(ds.WriteBinaryDatasetWithTag(&buf, 0xabcd))
+	goassert.New(t, ds).EqualWithoutError(ReadBinaryDataset(bytes.NewReader(buf.Bytes())))
+	goassert.New(t).SucceedWithoutError(ioutil.WriteFile(filename, buf.Bytes(), 0644))
+	mapped = goassert.New(t).SucceedNew(OpenMappedDataset(filename)).(*MappedDataset)
+	goassert.New(t, ds, uint16(0xabcd)).Equal(mapped.Dataset, mapped.Tag)
+	goassert.New(t).SucceedWithoutError(mapped.Close())
+	// The weights should follow the label keys.
+	ds.W = []float32{1.0, 0.5, 2.0, 0.0}
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(ds.WriteBinaryDataset(&buf))
+	data = buf.Bytes()
+	goassert.New(t, ds).EqualWithoutError(ReadBinaryDataset(bytes.NewReader(data)))
+	goassert.New(t, "illegal binary dataset size").ExpectError(ReadBinaryDataset(bytes.NewReader(data[:len(data)-4])))
+	data2 = append([]byte{}, data...)
+	data2[12] = 3
+	goassert.New(t, "unsupported binary dataset flags 0x3").ExpectError(ReadBinaryDataset(bytes.NewReader(data2)))
+}
@@ -0,0 +1,233 @@
+package sticker
+
+import (
+	"sort"
+	"sync"
+)
+
+// IDMapping is the mapping between the old identifiers and the new dense identifiers of features or labels.
+// The new identifier of OldIDs[i] is i.
+type IDMapping struct {
+	// OldIDs is the old identifier of each new identifier.
+	OldIDs []uint32
+
+	newIDsOnce sync.Once
+	newIDs     map[uint32]uint32
+}
+
+// NewIDMapping returns a new IDMapping with the given old identifiers in order of the new identifiers.
+func NewIDMapping(oldIDs []uint32) *IDMapping {
+	return &IDMapping{
+		OldIDs: oldIDs,
+	}
+}
+
+// NewID returns the new identifier of oldID, and true if found.
+func (mapping *IDMapping) NewID(oldID uint32) (uint32, bool) {
+	mapping.newIDsOnce.Do(func() {
+		mapping.newIDs = make(map[uint32]uint32, len(mapping.OldIDs))
+		for newID, oldID := range mapping.OldIDs {
+			mapping.newIDs[oldID] = uint32(newID)
+		}
+	})
+	newID, ok := mapping.newIDs[oldID]
+	return newID, ok
+}
+
+// OldID returns the old identifier of newID, and true if found.
+func (mapping *IDMapping) OldID(newID uint32) (uint32, bool) {
+	if newID < uint32(len(mapping.OldIDs)) {
+		return mapping.OldIDs[newID], true
+	}
+	return 0, false
+}
+
+// Size returns the number of the new identifiers.
+func (mapping *IDMapping) Size() int {
+	return len(mapping.OldIDs)
+}
+
+// MapFeatureVector returns the feature vector whose features are mapped to the new identifiers.
+// The features not in the mapping are dropped.
+func (mapping *IDMapping) MapFeatureVector(x FeatureVector) FeatureVector {
+	newx := make(FeatureVector, 0, len(x))
+	for _, xpair := range x {
+		if newID, ok := mapping.NewID(xpair.Key); ok {
+			newx = append(newx, KeyValue32{newID, xpair.Value})
+		}
+	}
+	sort.Sort(newx)
+	return newx
+}
+
+// MapLabelVector returns the label vector whose labels are mapped to the new identifiers.
+// The labels not in the mapping are dropped.
+func (mapping *IDMapping) MapLabelVector(y LabelVector) LabelVector {
+	newy := make(LabelVector, 0, len(y))
+	for _, label := range y {
+		if newID, ok := mapping.NewID(label); ok {
+			newy = append(newy, newID)
+		}
+	}
+	return newy
+}
+
+// MapVocabulary returns the vocabulary of the new identifiers from the vocabulary of the old identifiers.
+// The name of the old identifier not in vocab is "".
+func (mapping *IDMapping) MapVocabulary(vocab *Vocabulary) *Vocabulary {
+	names := make([]string, len(mapping.OldIDs))
+	for newID, oldID := range mapping.OldIDs {
+		names[newID], _ = vocab.Name(oldID)
+	}
+	return NewVocabularyFromNames(names)
+}
+
+// UnmapLabelVectors returns the label vectors whose labels are mapped back to the old identifiers.
+// This is useful for translating the labels predicted by the model trained on the compacted dataset.
+// The labels not in the mapping (for example, ^uint32(0) padded by PredictAll) are kept as they are.
+func (mapping *IDMapping) UnmapLabelVectors(Y LabelVectors) LabelVectors {
+	oldY := make(LabelVectors, len(Y))
+	for i, yi := range Y {
+		oldyi := make(LabelVector, len(yi))
+		for j, label := range yi {
+			if oldID, ok := mapping.OldID(label); ok {
+				oldyi[j] = oldID
+			} else {
+				oldyi[j] = label
+			}
+		}
+		oldY[i] = oldyi
+	}
+	return oldY
+}
+
+// CompactFeatures returns the dataset whose features are mapped to the dense range [0, the number of the used features), and the mapping.
+// The new identifiers are assigned in ascending order of the old identifiers.
+// For efficiency, the label vectors and the weights of the returned dataset have references to the ones of the dataset.
+func (ds *Dataset) CompactFeatures() (*Dataset, *IDMapping) {
+	used := make(map[uint32]bool)
+	for _, xi := range ds.X {
+		for _, xipair := range xi {
+			used[xipair.Key] = true
+		}
+	}
+	mapping := NewIDMapping(sortedKeys(used))
+	newds := &Dataset{
+		X: make(FeatureVectors, len(ds.X)),
+		Y: ds.Y,
+		W: ds.W,
+	}
+	for i, xi := range ds.X {
+		newds.X[i] = mapping.MapFeatureVector(xi)
+	}
+	return newds, mapping
+}
+
+// CompactLabels returns the dataset whose labels are mapped to the dense range [0, the number of the used labels), and the mapping.
+// The new identifiers are assigned in ascending order of the old identifiers.
+// For efficiency, the feature vectors and the weights of the returned dataset have references to the ones of the dataset.
+func (ds *Dataset) CompactLabels() (*Dataset, *IDMapping) {
+	used := make(map[uint32]bool)
+	for _, yi := range ds.Y {
+		for _, label := range yi {
+			used[label] = true
+		}
+	}
+	mapping := NewIDMapping(sortedKeys(used))
+	newds := &Dataset{
+		X: ds.X,
+		Y: make(LabelVectors, len(ds.Y)),
+		W: ds.W,
+	}
+	for i, yi := range ds.Y {
+		newds.Y[i] = mapping.MapLabelVector(yi)
+	}
+	return newds, mapping
+}
+
+// FilterFeatures returns the dataset without the features whose document frequencies are less than minDF.
+// For efficiency, the label vectors and the weights of the returned dataset have references to the ones of the dataset.
+func (ds *Dataset) FilterFeatures(minDF uint) *Dataset {
+	dfs := make(map[uint32]uint)
+	for _, xi := range ds.X {
+		for _, xipair := range xi {
+			dfs[xipair.Key]++
+		}
+	}
+	newds := &Dataset{
+		X: make(FeatureVectors, len(ds.X)),
+		Y: ds.Y,
+		W: ds.W,
+	}
+	for i, xi := range ds.X {
+		newxi := make(FeatureVector, 0, len(xi))
+		for _, xipair := range xi {
+			if dfs[xipair.Key] >= minDF {
+				newxi = append(newxi, xipair)
+			}
+		}
+		newds.X[i] = newxi
+	}
+	return newds
+}
+
+// FilterLabels returns the dataset having only the labels whose frequencies are in [minFreq, maxFreq], and the top-ntop frequent ones of them.
+// The frequency of each label is the number of the entries having the label.
+// maxFreq and ntop are not used if 0.
+// The entries having no label after filtering are dropped.
+// For efficiency, the feature vectors of the returned dataset have references to the ones of the dataset.
+func (ds *Dataset) FilterLabels(minFreq, maxFreq, ntop uint) *Dataset {
+	labelFreqs := make(SparseVector)
+	for _, yi := range ds.Y {
+		for _, label := range yi {
+			labelFreqs[label]++
+		}
+	}
+	for label, freq := range labelFreqs {
+		if uint(freq) < minFreq || (maxFreq > 0 && uint(freq) > maxFreq) {
+			delete(labelFreqs, label)
+		}
+	}
+	if ntop > 0 {
+		labelInvRanks := InvertRanks(RankTopK(labelFreqs, ntop))
+		for label := range labelFreqs {
+			if _, ok := labelInvRanks[label]; !ok {
+				delete(labelFreqs, label)
+			}
+		}
+	}
+	newds := &Dataset{
+		X: FeatureVectors{},
+		Y: LabelVectors{},
+	}
+	if ds.W != nil {
+		newds.W = []float32{}
+	}
+	for i, yi := range ds.Y {
+		newyi := make(LabelVector, 0, len(yi))
+		for _, label := range yi {
+			if _, ok := labelFreqs[label]; ok {
+				newyi = append(newyi, label)
+			}
+		}
+		if len(newyi) > 0 {
+			newds.X, newds.Y = append(newds.X, ds.X[i]), append(newds.Y, newyi)
+			if ds.W != nil {
+				newds.W = append(newds.W, ds.W[i])
+			}
+		}
+	}
+	return newds
+}
+
+// sortedKeys returns the keys of the given set in ascending order.
+func sortedKeys(set map[uint32]bool) []uint32 {
+	keys := make([]uint32, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	return keys
+}
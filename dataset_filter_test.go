@@ -0,0 +1,58 @@
+package sticker
+
+import (
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestIDMapping(t *testing.T) {
+	mapping := NewIDMapping([]uint32{5, 2, 9})
+	goassert.New(t, 3).Equal(mapping.Size())
+	goassert.New(t, uint32(1), true).Equal(mapping.NewID(2))
+	goassert.New(t, uint32(0), false).Equal(mapping.NewID(3))
+	goassert.New(t, uint32(9), true).Equal(mapping.OldID(2))
+	goassert.New(t, uint32(0), false).Equal(mapping.OldID(3))
+	goassert.New(t, FeatureVector{KeyValue32{0, 3.0}, KeyValue32{1, 1.0}}).Equal(mapping.MapFeatureVector(FeatureVector{KeyValue32{2, 1.0}, KeyValue32{3, 2.0}, KeyValue32{5, 3.0}}))
+	goassert.New(t, LabelVector{2, 0}).Equal(mapping.MapLabelVector(LabelVector{9, 4, 5}))
+	goassert.New(t, LabelVectors{LabelVector{9, 5, ^uint32(0)}}).Equal(mapping.UnmapLabelVectors(LabelVectors{LabelVector{2, 0, ^uint32(0)}}))
+	goassert.New(t, []string{"", "c", ""}).Equal(mapping.MapVocabulary(NewVocabularyFromNames([]string{"a", "b", "c"})).Names())
+}
+
+func TestDatasetFilter(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{7, 2.0}},
+			FeatureVector{KeyValue32{7, 3.0}},
+			FeatureVector{KeyValue32{3, 4.0}, KeyValue32{7, 5.0}},
+			FeatureVector{},
+		},
+		Y: LabelVectors{
+			LabelVector{4, 8}, LabelVector{4}, LabelVector{4, 6, 8}, LabelVector{},
+		},
+	}
+	goassert.New(t, &Dataset{
+		X: FeatureVectors{ds.X[0], ds.X[2]},
+		Y: LabelVectors{LabelVector{8}, LabelVector{8}},
+	}).Equal(ds.FilterLabels(2, 2, 0))
+	goassert.New(t, &Dataset{
+		X: FeatureVectors{ds.X[0], ds.X[1], ds.X[2]},
+		Y: LabelVectors{LabelVector{4}, LabelVector{4}, LabelVector{4}},
+	}).Equal(ds.FilterLabels(0, 0, 1))
+	goassert.New(t, &Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{7, 2.0}}, FeatureVector{KeyValue32{7, 3.0}}, FeatureVector{KeyValue32{7, 5.0}}, FeatureVector{}},
+		Y: ds.Y,
+	}).Equal(ds.FilterFeatures(2))
+	compactds, featureMapping := ds.CompactFeatures()
+	goassert.New(t, []uint32{1, 3, 7}).Equal(featureMapping.OldIDs)
+	goassert.New(t, FeatureVectors{
+		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{2, 2.0}},
+		FeatureVector{KeyValue32{2, 3.0}},
+		FeatureVector{KeyValue32{1, 4.0}, KeyValue32{2, 5.0}},
+		FeatureVector{},
+	}).Equal(compactds.X)
+	compactds, labelMapping := ds.CompactLabels()
+	goassert.New(t, []uint32{4, 6, 8}).Equal(labelMapping.OldIDs)
+	goassert.New(t, LabelVectors{LabelVector{0, 2}, LabelVector{0}, LabelVector{0, 1, 2}, LabelVector{}}).Equal(compactds.Y)
+	goassert.New(t, ds.Y).Equal(labelMapping.UnmapLabelVectors(compactds.Y))
+}
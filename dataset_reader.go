@@ -0,0 +1,464 @@
+package sticker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DatasetReader is the interface for reading the data entries of a dataset one by one.
+// This is useful for processing the large dataset which does not fit in the memory.
+type DatasetReader interface {
+	// Nentries returns the number of the entries in the dataset.
+	Nentries() int
+	// Read returns the next pair of the feature vector and the label vector.
+	// This returns io.EOF if there is no remaining entry.
+	Read() (FeatureVector, LabelVector, error)
+}
+
+// ReadDatasetChunk returns the sub-dataset of the next at most size entries read from reader.
+//
+// This function returns io.EOF if there is no remaining entry, or an error in reading an entry.
+func ReadDatasetChunk(reader DatasetReader, size int) (*Dataset, error) {
+	chunk := &Dataset{
+		X: make(FeatureVectors, 0, size),
+		Y: make(LabelVectors, 0, size),
+	}
+	for chunk.Size() < size {
+		x, y, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunk.X, chunk.Y = append(chunk.X, x), append(chunk.Y, y)
+	}
+	if chunk.Size() == 0 && size > 0 {
+		return nil, io.EOF
+	}
+	return chunk, nil
+}
+
+// datasetReader is the DatasetReader on the in-memory Dataset.
+type datasetReader struct {
+	ds *Dataset
+	i  int
+}
+
+// NewDatasetReader returns a new DatasetReader reading the entries of the given dataset ds in order.
+// For efficiency, the returned vectors are the references to the ones of ds.
+func NewDatasetReader(ds *Dataset) DatasetReader {
+	return &datasetReader{
+		ds: ds,
+	}
+}
+
+func (reader *datasetReader) Nentries() int {
+	return reader.ds.Size()
+}
+
+func (reader *datasetReader) Read() (FeatureVector, LabelVector, error) {
+	if reader.i >= reader.ds.Size() {
+		return nil, nil, io.EOF
+	}
+	i := reader.i
+	reader.i++
+	return reader.ds.X[i], reader.ds.Y[i], nil
+}
+
+// TextDatasetParameters has the parameters for reading the text datasets tolerantly.
+// The zero value is the strict format which ReadTextDataset supports.
+type TextDatasetParameters struct {
+	// AllowEmptyLabels accepts the entries having no label, whose line starts with a space.
+	AllowEmptyLabels bool
+	// InferHeader accepts the text without the first line having the number of entries, features, and labels.
+	// If the first line is missing, then the entries are read until EOF, and the feature and label IDs are not checked.
+	InferHeader bool
+	// Lenient skips the lines having the parse errors, and repairs the entries having the duplicate feature IDs, the non-finite feature values, the out-of-range IDs or the unsorted labels.
+	// The skipped lines and repaired issues are reported in TextDatasetReport.
+	Lenient bool
+	// Nworkers is the number of the workers parsing the lines in ReadTextDatasetWithParameters.
+	// If Nworkers is 0, then the lines are parsed by one worker.
+	Nworkers int
+}
+
+// NewTextDatasetParameters returns a new TextDatasetParameters accepting the empty label lists and the missing header.
+func NewTextDatasetParameters() *TextDatasetParameters {
+	return &TextDatasetParameters{
+		AllowEmptyLabels: true,
+		InferHeader:      true,
+		Nworkers:         runtime.GOMAXPROCS(0),
+	}
+}
+
+// TextDatasetReader is the DatasetReader on the text formatted as ReadTextDataset supports.
+type TextDatasetReader struct {
+	br                           *bufio.Reader
+	params                       TextDatasetParameters
+	hasHeader                    bool
+	nentries, nfeatures, nlabels uint64
+	i                            uint64
+	pendingLine                  *string
+	issues                       []TextDatasetIssue
+}
+
+// NewTextDatasetReader returns a new TextDatasetReader from reader.
+// This function reads only the first line having the number of entries, features, and labels.
+//
+// This function returns an error in reading the first line.
+func NewTextDatasetReader(reader io.Reader) (*TextDatasetReader, error) {
+	return NewTextDatasetReaderWithParameters(reader, &TextDatasetParameters{})
+}
+
+// NewTextDatasetReaderWithParameters returns a new TextDatasetReader from reader with the given parameters.
+// If params.InferHeader is true and the first line is not a header, then the first line is read as the first entry.
+//
+// This function returns an error in reading the first line.
+func NewTextDatasetReaderWithParameters(reader io.Reader, params *TextDatasetParameters) (*TextDatasetReader, error) {
+	br := bufio.NewReader(reader)
+	line, err := br.ReadString('\n')
+	if params.InferHeader {
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("cannot read first line")
+		}
+		nentries, nfeatures, nlabels, ok := parseTextDatasetHeader(line)
+		if !ok {
+			textReader := &TextDatasetReader{
+				br:        br,
+				params:    *params,
+				nfeatures: 1 << 32,
+				nlabels:   1 << 32,
+			}
+			// The empty text is the empty dataset.
+			if line != "" {
+				textReader.pendingLine = &line
+			}
+			return textReader, nil
+		}
+		return &TextDatasetReader{
+			br:        br,
+			params:    *params,
+			hasHeader: true,
+			nentries:  nentries,
+			nfeatures: nfeatures,
+			nlabels:   nlabels,
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read first line")
+	}
+	line = strings.TrimSpace(line)
+	nentriesNfeaturesNlabels := strings.Split(line, " ")
+	if len(nentriesNfeaturesNlabels) != 3 {
+		return nil, fmt.Errorf("illegal first line")
+	}
+	nentries, err := strconv.ParseUint(nentriesNfeaturesNlabels[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("illegal nentries in first line")
+	}
+	nfeatures, err := strconv.ParseUint(nentriesNfeaturesNlabels[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("illegal nfeatures in first line")
+	}
+	nlabels, err := strconv.ParseUint(nentriesNfeaturesNlabels[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("illegal nlabels in first line")
+	}
+	return &TextDatasetReader{
+		br:        br,
+		params:    *params,
+		hasHeader: true,
+		nentries:  nentries,
+		nfeatures: nfeatures,
+		nlabels:   nlabels,
+	}, nil
+}
+
+// parseTextDatasetHeader returns the number of entries, features, and labels in the given header line.
+// The last returned value is false if the line is not a header.
+//
+// The header is distinguished from any entry, because the entry never has a cell without colon after the second cell, which is the optional weight.
+func parseTextDatasetHeader(line string) (nentries, nfeatures, nlabels uint64, ok bool) {
+	cells := strings.Split(strings.TrimSpace(line), " ")
+	if len(cells) != 3 {
+		return 0, 0, 0, false
+	}
+	var values [3]uint64
+	for j, cell := range cells {
+		value, err := strconv.ParseUint(cell, 10, 32)
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		values[j] = value
+	}
+	return values[0], values[1], values[2], true
+}
+
+// HasHeader returns true if the text has the first line having the number of entries, features, and labels.
+func (reader *TextDatasetReader) HasHeader() bool {
+	return reader.hasHeader
+}
+
+// Nentries returns the number of the entries written in the first line.
+// This returns -1 if the text has no header.
+func (reader *TextDatasetReader) Nentries() int {
+	if !reader.hasHeader {
+		return -1
+	}
+	return int(reader.nentries)
+}
+
+// Nfeatures returns the number of the features written in the first line.
+// This returns -1 if the text has no header.
+func (reader *TextDatasetReader) Nfeatures() int {
+	if !reader.hasHeader {
+		return -1
+	}
+	return int(reader.nfeatures)
+}
+
+// Nlabels returns the number of the labels written in the first line.
+// This returns -1 if the text has no header.
+func (reader *TextDatasetReader) Nlabels() int {
+	if !reader.hasHeader {
+		return -1
+	}
+	return int(reader.nlabels)
+}
+
+// Read returns the next pair of the feature vector and the label vector.
+// The weight of the entry is dropped, so use ReadTextDataset for reading the weights.
+// In the lenient mode, the lines having the parse errors are skipped, and the issues are recorded in Report.
+//
+// This function returns io.EOF if all entries are read, or an error in reading the line.
+func (reader *TextDatasetReader) Read() (FeatureVector, LabelVector, error) {
+	for {
+		if reader.hasHeader && reader.i >= reader.nentries {
+			return nil, nil, io.EOF
+		}
+		line, err := reader.readLine()
+		if err == io.EOF {
+			return nil, nil, io.EOF
+		}
+		reader.i++
+		if err != nil {
+			return nil, nil, &TextDatasetParseError{reader.i, 0, "line", "cannot read line"}
+		}
+		x, y, _, issues, err := reader.parseLine(line, reader.i)
+		reader.issues = append(reader.issues, issues...)
+		if err != nil {
+			if !reader.params.Lenient {
+				return nil, nil, err
+			}
+			reader.issues = append(reader.issues, TextDatasetIssue{TextDatasetSkippedLine, err.(*TextDatasetParseError)})
+			continue
+		}
+		return x, y, nil
+	}
+}
+
+// readLine returns the next raw line.
+// If the text has no header, then the last line may have no newline, and io.EOF is returned at the end of the text.
+func (reader *TextDatasetReader) readLine() (string, error) {
+	if reader.pendingLine != nil {
+		line := *reader.pendingLine
+		reader.pendingLine = nil
+		return line, nil
+	}
+	line, err := reader.br.ReadString('\n')
+	if !reader.hasHeader && err == io.EOF {
+		if line == "" {
+			return "", io.EOF
+		}
+		return line, nil
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return line, err
+}
+
+// TextDatasetParseError is the error in parsing the line of the text dataset.
+type TextDatasetParseError struct {
+	// Line is the line number from 1 excluding the header.
+	Line uint64
+	// Column is the cell number from 1 separated by single space, or 0 if the error is not on any cell.
+	Column int
+	// Field is the name of the illegal field like "line", "label", "featureID:value", "feature" or "value".
+	Field string
+	// Reason is the human-readable reason of the error.
+	Reason string
+}
+
+func (err *TextDatasetParseError) Error() string {
+	return fmt.Sprintf("L%d: %s", err.Line, err.Reason)
+}
+
+// TextDatasetIssueKind is the kind of TextDatasetIssue.
+type TextDatasetIssueKind string
+
+// The following kinds are the issues reported in the lenient mode.
+const (
+	// TextDatasetSkippedLine is the line skipped because of the parse error.
+	TextDatasetSkippedLine = TextDatasetIssueKind("skipped line")
+	// TextDatasetDuplicateFeature is the feature ID appearing again in the entry, which is dropped.
+	TextDatasetDuplicateFeature = TextDatasetIssueKind("duplicate feature ID")
+	// TextDatasetNonFiniteValue is the NaN or infinite feature value, which is dropped.
+	TextDatasetNonFiniteValue = TextDatasetIssueKind("non-finite feature value")
+	// TextDatasetOutOfRangeID is the feature or label ID out of the range specified in the header, which is dropped.
+	TextDatasetOutOfRangeID = TextDatasetIssueKind("out-of-range ID")
+	// TextDatasetUnsortedLabels is the label list not sorted in ascending order, which is sorted.
+	TextDatasetUnsortedLabels = TextDatasetIssueKind("unsorted labels")
+)
+
+// TextDatasetIssue is the issue found in reading the text dataset in the lenient mode.
+type TextDatasetIssue struct {
+	Kind TextDatasetIssueKind
+	*TextDatasetParseError
+}
+
+// TextDatasetReport is the report of the issues found in reading the text dataset in the lenient mode.
+type TextDatasetReport struct {
+	// Nlines is the number of the read lines excluding the header.
+	Nlines uint64
+	// Issues is the issues in order of the lines.
+	Issues []TextDatasetIssue
+}
+
+// Counts returns the number of the issues for each kind.
+func (report *TextDatasetReport) Counts() map[TextDatasetIssueKind]int {
+	counts := make(map[TextDatasetIssueKind]int)
+	for _, issue := range report.Issues {
+		counts[issue.Kind]++
+	}
+	return counts
+}
+
+// Report returns the report of the issues found until now in the lenient mode.
+func (reader *TextDatasetReader) Report() *TextDatasetReport {
+	return &TextDatasetReport{
+		Nlines: reader.i,
+		Issues: reader.issues,
+	}
+}
+
+// parseLine returns the feature vector, the label vector and the weight parsed from the given i-th line.
+// The weight is 1.0 if the line has no weight cell.
+// In the lenient mode, the repaired issues are also returned.
+// This is safe for concurrent use, because this does not modify reader.
+//
+// This function returns a *TextDatasetParseError in parsing the line.
+func (reader *TextDatasetReader) parseLine(line string, i uint64) (FeatureVector, LabelVector, float32, []TextDatasetIssue, error) {
+	var labelStrs []string
+	if reader.params.AllowEmptyLabels {
+		// The leading space means the empty label list, so it must be kept.
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+	} else {
+		line = strings.TrimSpace(line)
+	}
+	entry := strings.Split(line, " ")
+	if entry[0] != "" || !reader.params.AllowEmptyLabels {
+		labelStrs = strings.Split(entry[0], ",")
+	}
+	entry = entry[1:]
+	lenient := reader.params.Lenient
+	var issues []TextDatasetIssue
+	y := make(LabelVector, 0, len(labelStrs))
+	for j, labelStr := range labelStrs {
+		label, err := strconv.ParseUint(labelStr, 10, 32)
+		if err != nil {
+			return nil, nil, 0, issues, &TextDatasetParseError{i, 1, "label", fmt.Sprintf("illegal #%d label ID", j+1)}
+		}
+		if label >= reader.nlabels {
+			perr := &TextDatasetParseError{i, 1, "label", fmt.Sprintf("too large #%d label ID (>= %d)", j+1, reader.nlabels)}
+			if !lenient {
+				return nil, nil, 0, issues, perr
+			}
+			issues = append(issues, TextDatasetIssue{TextDatasetOutOfRangeID, perr})
+			continue
+		}
+		y = append(y, uint32(label))
+	}
+	if lenient && !sort.SliceIsSorted(y, func(j, k int) bool { return y[j] < y[k] }) {
+		issues = append(issues, TextDatasetIssue{TextDatasetUnsortedLabels, &TextDatasetParseError{i, 1, "label", "labels are not sorted in ascending order"}})
+		sort.Slice(y, func(j, k int) bool { return y[j] < y[k] })
+	}
+	weight, column := float32(1.0), 2
+	// The cell without colon is the weight if it is a number, otherwise it is reported as an illegal featureID:value pair.
+	if len(entry) > 0 && !strings.Contains(entry[0], ":") {
+		if value, err := strconv.ParseFloat(entry[0], 32); err == nil {
+			if math.IsNaN(value) || math.IsInf(value, 0) || value < 0.0 {
+				return nil, nil, 0, issues, &TextDatasetParseError{i, 2, "weight", "illegal weight"}
+			}
+			weight, column, entry = float32(value), 3, entry[1:]
+		}
+	}
+	x, featureIssues, err := parseFeatureValues(entry, reader.nfeatures, column, lenient)
+	for _, issue := range featureIssues {
+		issue.Line = i
+	}
+	issues = append(issues, featureIssues...)
+	if err != nil {
+		err.Line = i
+		return nil, nil, 0, issues, err
+	}
+	return x, y, weight, issues, nil
+}
+
+// parseFeatureValues returns the feature vector parsed from the given featureID:value cells starting at the given column.
+// The feature IDs must be less than nfeatures.
+// In the lenient mode, the pairs having the out-of-range IDs, non-finite values, or duplicate IDs are dropped and returned as the issues.
+// The line numbers of the returned issues and error are 0.
+//
+// This function returns an error in parsing the cells.
+func parseFeatureValues(cells []string, nfeatures uint64, column int, lenient bool) (FeatureVector, []TextDatasetIssue, *TextDatasetParseError) {
+	var issues []TextDatasetIssue
+	var seen map[uint32]bool
+	if lenient {
+		seen = make(map[uint32]bool, len(cells))
+	}
+	x := make(FeatureVector, 0, len(cells))
+	for j, featureValueStr := range cells {
+		featureValue := strings.Split(featureValueStr, ":")
+		if len(featureValue) != 2 {
+			return nil, issues, &TextDatasetParseError{0, column + j, "featureID:value", fmt.Sprintf("illegal #%d featureID:value pair", j+1)}
+		}
+		feature, err := strconv.ParseUint(featureValue[0], 10, 32)
+		if err != nil {
+			return nil, issues, &TextDatasetParseError{0, column + j, "feature", fmt.Sprintf("illegal #%d feature ID", j+1)}
+		}
+		if feature >= nfeatures {
+			perr := &TextDatasetParseError{0, column + j, "feature", fmt.Sprintf("too large #%d feature ID (>= %d)", j+1, nfeatures)}
+			if !lenient {
+				return nil, issues, perr
+			}
+			issues = append(issues, TextDatasetIssue{TextDatasetOutOfRangeID, perr})
+			continue
+		}
+		value, err := strconv.ParseFloat(featureValue[1], 32)
+		if err != nil && !(lenient && err.(*strconv.NumError).Err == strconv.ErrRange) {
+			return nil, issues, &TextDatasetParseError{0, column + j, "value", fmt.Sprintf("illegal #%d feature value", j+1)}
+		}
+		if lenient {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				issues = append(issues, TextDatasetIssue{TextDatasetNonFiniteValue, &TextDatasetParseError{0, column + j, "value", fmt.Sprintf("non-finite #%d feature value", j+1)}})
+				continue
+			}
+			if seen[uint32(feature)] {
+				issues = append(issues, TextDatasetIssue{TextDatasetDuplicateFeature, &TextDatasetParseError{0, column + j, "feature", fmt.Sprintf("duplicate #%d feature ID %d", j+1, feature)}})
+				continue
+			}
+			seen[uint32(feature)] = true
+		}
+		x = append(x, KeyValue32{uint32(feature), float32(value)})
+	}
+	sort.Sort(x)
+	return x, issues, nil
+}
@@ -0,0 +1,64 @@
+package sticker
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestReadDatasetChunk(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{0, 1.0}},
+			FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 3.0}},
+			FeatureVector{KeyValue32{0, 4.0}, KeyValue32{2, 5.0}, KeyValue32{9, 6.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{0}, LabelVector{0, 1}, LabelVector{0, 2, 9},
+		},
+	}
+	reader := NewDatasetReader(ds)
+	goassert.New(t, 3).Equal(reader.Nentries())
+	goassert.New(t, ds.SubSet([]int{0, 1})).EqualWithoutError(ReadDatasetChunk(reader, 2))
+	goassert.New(t, ds.SubSet([]int{2})).EqualWithoutError(ReadDatasetChunk(reader, 2))
+	_, err := ReadDatasetChunk(reader, 2)
+	goassert.New(t, io.EOF).Equal(err)
+}
+
+func TestTextDatasetReader(t *testing.T) {
+	reader := goassert.New(t).SucceedNew(NewTextDatasetReader(strings.NewReader("3 10 10\r\n0 0:1\n0,1 0:2 1:3\r\n0,2,9 9:6 0:4 2:5\n"))).(*TextDatasetReader)
+	goassert.New(t, 3, 10, 10).Equal(reader.Nentries(), reader.Nfeatures(), reader.Nlabels())
+	goassert.New(t, FeatureVector{KeyValue32{0, 1.0}}, LabelVector{0}).EqualWithoutError(reader.Read())
+	goassert.New(t, FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 3.0}}, LabelVector{0, 1}).EqualWithoutError(reader.Read())
+	goassert.New(t, FeatureVector{KeyValue32{0, 4.0}, KeyValue32{2, 5.0}, KeyValue32{9, 6.0}}, LabelVector{0, 2, 9}).EqualWithoutError(reader.Read())
+	_, _, err := reader.Read()
+	goassert.New(t, io.EOF).Equal(err)
+	goassert.New(t, "cannot read first line").ExpectError(NewTextDatasetReader(strings.NewReader("")))
+	reader = goassert.New(t).SucceedNew(NewTextDatasetReader(strings.NewReader("2 10 10\n0 0:1\n"))).(*TextDatasetReader)
+	goassert.New(t, &Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{0, 1.0}}},
+		Y: LabelVectors{LabelVector{0}},
+	}).EqualWithoutError(ReadDatasetChunk(reader, 1))
+	goassert.New(t, "L2: cannot read line").ExpectError(ReadDatasetChunk(reader, 1))
+}
+
+func TestTextDatasetReaderWithParameters(t *testing.T) {
+	params := NewTextDatasetParameters()
+	reader := goassert.New(t).SucceedNew(NewTextDatasetReaderWithParameters(strings.NewReader("0 0:1\n 0:2 1:3\n0,2"), params)).(*TextDatasetReader)
+	goassert.New(t, false, -1, -1, -1).Equal(reader.HasHeader(), reader.Nentries(), reader.Nfeatures(), reader.Nlabels())
+	goassert.New(t, FeatureVector{KeyValue32{0, 1.0}}, LabelVector{0}).EqualWithoutError(reader.Read())
+	goassert.New(t, FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 3.0}}, LabelVector{}).EqualWithoutError(reader.Read())
+	goassert.New(t, FeatureVector{}, LabelVector{0, 2}).EqualWithoutError(reader.Read())
+	_, _, err := reader.Read()
+	goassert.New(t, io.EOF).Equal(err)
+	reader = goassert.New(t).SucceedNew(NewTextDatasetReaderWithParameters(strings.NewReader("1 10 10\n 0:1\n"), params)).(*TextDatasetReader)
+	goassert.New(t, true, 1, 10, 10).Equal(reader.HasHeader(), reader.Nentries(), reader.Nfeatures(), reader.Nlabels())
+	goassert.New(t, FeatureVector{KeyValue32{0, 1.0}}, LabelVector{}).EqualWithoutError(reader.Read())
+	_, _, err = reader.Read()
+	goassert.New(t, io.EOF).Equal(err)
+	reader = goassert.New(t).SucceedNew(NewTextDatasetReaderWithParameters(strings.NewReader(""), params)).(*TextDatasetReader)
+	_, _, err = reader.Read()
+	goassert.New(t, io.EOF).Equal(err)
+}
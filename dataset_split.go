@@ -0,0 +1,168 @@
+package sticker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ReadSplitTextDataset returns a new Dataset from the feature text xreader and the label text yreader.
+//
+// This is the split format used in The Extreme Classification Repository (like trn_X_Xf.txt and trn_X_Y.txt).
+// Each text is a sparse matrix whose first line has the number of rows and columns.
+// The remaining lines have the rows, and each row is encoded in one line like (column:value)*.
+// The values in the label text are ignored, and the label text may have only the columns.
+// The i-th lines in the both texts are the i-th entry.
+// The empty line is the empty feature vector, or the empty label vector if params.AllowEmptyLabels is true.
+// If params.InferHeader is true, then the first lines may be missing.
+// The first line having two integers is always the header, so the label text without header must have the values.
+//
+// This function returns an error in reading the dataset.
+func ReadSplitTextDataset(xreader, yreader io.Reader, params *TextDatasetParameters) (*Dataset, error) {
+	X, err := readSparseTextMatrix(xreader, params, true)
+	if err != nil {
+		return nil, fmt.Errorf("X: %s", err)
+	}
+	Xy, err := readSparseTextMatrix(yreader, params, false)
+	if err != nil {
+		return nil, fmt.Errorf("Y: %s", err)
+	}
+	if len(X) != len(Xy) {
+		return nil, fmt.Errorf("X has %d entries, but Y has %d entries", len(X), len(Xy))
+	}
+	Y := make(LabelVectors, len(Xy))
+	for i, yi := range Xy {
+		Y[i] = make(LabelVector, 0, len(yi))
+		for _, yipair := range yi {
+			Y[i] = append(Y[i], yipair.Key)
+		}
+	}
+	return &Dataset{
+		X: X,
+		Y: Y,
+	}, nil
+}
+
+// readSparseTextMatrix returns the rows of the sparse matrix text in ReadSplitTextDataset.
+// If isFeature is false, then the rows are label vectors whose values are ignored and order is kept.
+//
+// This function returns an error in reading the matrix.
+func readSparseTextMatrix(reader io.Reader, params *TextDatasetParameters, isFeature bool) (FeatureVectors, error) {
+	br := bufio.NewReader(reader)
+	line, err := br.ReadString('\n')
+	if err != nil && (err != io.EOF || !params.InferHeader) {
+		return nil, fmt.Errorf("cannot read first line")
+	}
+	hasHeader, nrows, ncolumns := true, uint64(0), uint64(0)
+	cells := strings.Split(strings.TrimSpace(line), " ")
+	var nrowsErr, ncolumnsErr error
+	if len(cells) == 2 {
+		nrows, nrowsErr = strconv.ParseUint(cells[0], 10, 32)
+		ncolumns, ncolumnsErr = strconv.ParseUint(cells[1], 10, 32)
+	}
+	switch {
+	case len(cells) == 2 && nrowsErr == nil && ncolumnsErr == nil:
+	case params.InferHeader:
+		hasHeader, ncolumns = false, 1<<32
+	case len(cells) != 2:
+		return nil, fmt.Errorf("illegal first line")
+	case nrowsErr != nil:
+		return nil, fmt.Errorf("illegal nrows in first line")
+	default:
+		return nil, fmt.Errorf("illegal ncolumns in first line")
+	}
+	var rows FeatureVectors
+	for i := uint64(1); !hasHeader || i <= nrows; i++ {
+		if hasHeader || i > 1 {
+			line, err = br.ReadString('\n')
+			if !hasHeader && err == io.EOF {
+				err = nil
+			}
+			if err != nil {
+				return nil, &TextDatasetParseError{i, 0, "line", "cannot read line"}
+			}
+		}
+		if !hasHeader && line == "" {
+			break
+		}
+		cells = strings.Split(strings.TrimRightFunc(line, unicode.IsSpace), " ")
+		if cells[0] == "" && len(cells) == 1 {
+			cells = nil
+		}
+		if isFeature {
+			row, _, err := parseFeatureValues(cells, ncolumns, 1, false)
+			if err != nil {
+				err.Line = i
+				return nil, err
+			}
+			rows = append(rows, row)
+			continue
+		}
+		if len(cells) == 0 && !params.AllowEmptyLabels {
+			return nil, &TextDatasetParseError{i, 1, "label", "illegal #1 label ID"}
+		}
+		row := make(FeatureVector, 0, len(cells))
+		for j, cell := range cells {
+			if k := strings.IndexByte(cell, ':'); k >= 0 {
+				cell = cell[:k]
+			}
+			label, err := strconv.ParseUint(cell, 10, 32)
+			if err != nil {
+				return nil, &TextDatasetParseError{i, j + 1, "label", fmt.Sprintf("illegal #%d label ID", j+1)}
+			}
+			if label >= ncolumns {
+				return nil, &TextDatasetParseError{i, j + 1, "label", fmt.Sprintf("too large #%d label ID (>= %d)", j+1, ncolumns)}
+			}
+			row = append(row, KeyValue32{uint32(label), 1.0})
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// WriteSplitTextDataset writes the dataset to the given feature writer xw and label writer yw as ReadSplitTextDataset supports.
+// Each label is written with value 1.
+//
+// This function returns an error in writing.
+func (ds *Dataset) WriteSplitTextDataset(xw, yw io.Writer) error {
+	xbw, ybw := bufio.NewWriter(xw), bufio.NewWriter(yw)
+	if _, err := fmt.Fprintf(xbw, "%d %d\n", ds.Size(), ds.X.Dim()); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(ybw, "%d %d\n", ds.Size(), ds.Y.Dim()); err != nil {
+		return err
+	}
+	for i, xi := range ds.X {
+		for j, xipair := range xi {
+			sep := " "
+			if j == 0 {
+				sep = ""
+			}
+			if _, err := fmt.Fprintf(xbw, "%s%d:%f", sep, xipair.Key, xipair.Value); err != nil {
+				return err
+			}
+		}
+		if _, err := xbw.WriteString("\n"); err != nil {
+			return err
+		}
+		for j, label := range ds.Y[i] {
+			sep := " "
+			if j == 0 {
+				sep = ""
+			}
+			if _, err := fmt.Fprintf(ybw, "%s%d:1", sep, label); err != nil {
+				return err
+			}
+		}
+		if _, err := ybw.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+	if err := xbw.Flush(); err != nil {
+		return err
+	}
+	return ybw.Flush()
+}
@@ -0,0 +1,38 @@
+package sticker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestReadSplitTextDataset(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{0, 1.0}},
+			FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 3.0}},
+			FeatureVector{},
+			FeatureVector{KeyValue32{10, 7.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{0}, LabelVector{9, 2}, LabelVector{}, LabelVector{10},
+		},
+	}
+	params := NewTextDatasetParameters()
+	goassert.New(t, ds).EqualWithoutError(ReadSplitTextDataset(strings.NewReader("4 11\n0:1\n1:3 0:2\n\n10:7\n"), strings.NewReader("4 11\n0:1\n9 2:1\n\n10:1\n"), params))
+	goassert.New(t, ds).EqualWithoutError(ReadSplitTextDataset(strings.NewReader("0:1\n1:3 0:2\n\n10:7"), strings.NewReader("0:1\n9:1 2:1\n\n10:1\n"), params))
+	goassert.New(t, "X has 4 entries, but Y has 3 entries").ExpectError(ReadSplitTextDataset(strings.NewReader("0:1\n1:3 0:2\n\n10:7"), strings.NewReader("0:1\n9:1 2:1\n\n"), params))
+	goassert.New(t, "X: L2: too large #1 feature ID \\(>= 2\\)").ExpectError(ReadSplitTextDataset(strings.NewReader("2 2\n0:1\n2:1\n"), strings.NewReader("2 2\n0:1\n1:1\n"), params))
+	goassert.New(t, "Y: L2: illegal #2 label ID").ExpectError(ReadSplitTextDataset(strings.NewReader("2 2\n0:1\n1:1\n"), strings.NewReader("2 2\n0:1\n1:1 x\n"), params))
+	goassert.New(t, "Y: L1: cannot read line").ExpectError(ReadSplitTextDataset(strings.NewReader("1 2\n0:1\n"), strings.NewReader("1 2\n"), params))
+	goassert.New(t, "X: illegal first line").ExpectError(ReadSplitTextDataset(strings.NewReader("0:1\n"), strings.NewReader("0:1\n"), &TextDatasetParameters{}))
+	goassert.New(t, "Y: L3: illegal #1 label ID").ExpectError(ReadSplitTextDataset(strings.NewReader("4 11\n0:1\n1:3 0:2\n\n10:7\n"), strings.NewReader("4 11\n0:1\n9 2:1\n\n10:1\n"), &TextDatasetParameters{}))
+	// WriteSplitTextDataset should write the dataset which can be read again.
+	var xbuf, ybuf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(ds.WriteSplitTextDataset(&xbuf, &ybuf))
+	goassert.New(t, "4 11\n0:1.000000\n0:2.000000 1:3.000000\n\n10:7.000000\n").Equal(xbuf.String())
+	goassert.New(t, "4 11\n0:1\n9:1 2:1\n\n10:1\n").Equal(ybuf.String())
+	goassert.New(t, ds).EqualWithoutError(ReadSplitTextDataset(&xbuf, &ybuf, params))
+}
@@ -0,0 +1,192 @@
+package sticker
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+)
+
+// stratificationItem is the label (order 1) or the label pair (order 2) in the iterative stratification.
+// The label is represented as label<<32|label, and the label pair (a, b) with a < b is represented as a<<32|b.
+type stratificationItem uint64
+
+// stratificationItems returns the items of the given label vector up to the given order.
+// The duplicate labels in y are counted once, and the items are sorted in ascending order.
+// The number of the items of order 2 is O(|y|^2).
+func stratificationItems(y LabelVector, order uint) []stratificationItem {
+	labels := make(LabelVector, len(y))
+	copy(labels, y)
+	sort.Sort(labels)
+	n := 0
+	for j, a := range labels {
+		if j == 0 || a != labels[n-1] {
+			labels[n] = a
+			n++
+		}
+	}
+	labels = labels[:n]
+	items := make([]stratificationItem, 0, len(labels))
+	for j, a := range labels {
+		items = append(items, stratificationItem(a)<<32|stratificationItem(a))
+		if order >= 2 {
+			for _, b := range labels[j+1:] {
+				items = append(items, stratificationItem(a)<<32|stratificationItem(b))
+			}
+		}
+	}
+	return items
+}
+
+// stratificationItemCount is the pair of the item and its count of the unassigned entries.
+type stratificationItemCount struct {
+	item  stratificationItem
+	count int
+}
+
+// stratificationItemHeap is the min-heap of stratificationItemCount ordered by the count and the item.
+type stratificationItemHeap []stratificationItemCount
+
+func (h stratificationItemHeap) Len() int {
+	return len(h)
+}
+
+func (h stratificationItemHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].item < h[j].item
+}
+
+func (h stratificationItemHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *stratificationItemHeap) Push(x interface{}) {
+	*h = append(*h, x.(stratificationItemCount))
+}
+
+func (h *stratificationItemHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// StratifyLabelVectors returns the indices of the entries in each of S splits of the given label vectors by the iterative stratification (Sechidis+ 2011).
+// If order is 2, then the label pairs are also stratified as the second-order iterative stratification (Szymański+ 2017), otherwise only the labels are stratified.
+// The order 2 costs O(|y|^2) time and memory on each label vector y, so it is too expensive on the datasets having the long label vectors.
+// The duplicate labels in each label vector are counted once.
+// The indices in each split are in ascending order, and rng is used for breaking ties.
+//
+// The iterative stratification repeats the following steps: select the label (or label pair) having the fewest unassigned entries, and assign each of them to the split which desires the label most.
+// The desired count of each label on each split is its count divided by S, so the splits keep the label proportions even on the tail labels.
+// The entries having no label are assigned to the splits desiring entries most at last.
+//
+// This function panics if S is zero.
+//
+// References:
+//
+// (Sechidis+ 2011) K. Sechidis, G. Tsoumakas, and I. Vlahavas. "On the Stratification of Multi-Label Data." Machine Learning and Knowledge Discovery in Databases, pp. 145-158, 2011.
+//
+// (Szymański+ 2017) P. Szymański, and T. Kajdanowicz. "A Network Perspective on Stratification of Multi-Label Data." Proceedings of the First International Workshop on Learning with Imbalanced Domains: Theory and Applications, PMLR 74, pp. 22-35, 2017.
+func StratifyLabelVectors(Y LabelVectors, S uint, order uint, rng *rand.Rand) [][]int {
+	if S == 0 {
+		panic("S must be positive")
+	}
+	itemEntries := make(map[stratificationItem][]int)
+	for i, yi := range Y {
+		for _, item := range stratificationItems(yi, order) {
+			itemEntries[item] = append(itemEntries[item], i)
+		}
+	}
+	counts := make(map[stratificationItem]int, len(itemEntries))
+	desiredItems := make(map[stratificationItem][]float32, len(itemEntries))
+	h := make(stratificationItemHeap, 0, len(itemEntries))
+	for item, entries := range itemEntries {
+		counts[item] = len(entries)
+		desired := make([]float32, S)
+		for s := range desired {
+			desired[s] = float32(len(entries)) / float32(S)
+		}
+		desiredItems[item] = desired
+		h = append(h, stratificationItemCount{item, len(entries)})
+	}
+	heap.Init(&h)
+	desiredEntries := make([]float32, S)
+	for s := range desiredEntries {
+		desiredEntries[s] = float32(len(Y)) / float32(S)
+	}
+	assigneds := make([]bool, len(Y))
+	splits := make([][]int, S)
+	candidates := make([]int, 0, S)
+	// selectSplit returns the split maximizing the desired count in desired, and breaks ties by desiredEntries and rng.
+	selectSplit := func(desired []float32) int {
+		candidates = candidates[:0]
+		for s := range desiredEntries {
+			if len(candidates) == 0 {
+				candidates = append(candidates, s)
+				continue
+			}
+			best := candidates[0]
+			if desired != nil && desired[s] != desired[best] {
+				if desired[s] > desired[best] {
+					candidates = append(candidates[:0], s)
+				}
+				continue
+			}
+			if desiredEntries[s] > desiredEntries[best] {
+				candidates = append(candidates[:0], s)
+			} else if desiredEntries[s] == desiredEntries[best] {
+				candidates = append(candidates, s)
+			}
+		}
+		return candidates[rng.Intn(len(candidates))]
+	}
+	assign := func(i, s int) {
+		assigneds[i] = true
+		splits[s] = append(splits[s], i)
+		desiredEntries[s]--
+		for _, item := range stratificationItems(Y[i], order) {
+			desiredItems[item][s]--
+			counts[item]--
+			if counts[item] > 0 {
+				heap.Push(&h, stratificationItemCount{item, counts[item]})
+			}
+		}
+	}
+	for h.Len() > 0 {
+		top := heap.Pop(&h).(stratificationItemCount)
+		if top.count != counts[top.item] || top.count == 0 {
+			// The count is outdated, because the newer count has been pushed.
+			continue
+		}
+		entries := make([]int, 0, top.count)
+		for _, i := range itemEntries[top.item] {
+			if !assigneds[i] {
+				entries = append(entries, i)
+			}
+		}
+		rng.Shuffle(len(entries), func(j, k int) {
+			entries[j], entries[k] = entries[k], entries[j]
+		})
+		for _, i := range entries {
+			assign(i, selectSplit(desiredItems[top.item]))
+		}
+	}
+	unassigneds := make([]int, 0)
+	for i, assigned := range assigneds {
+		if !assigned {
+			unassigneds = append(unassigneds, i)
+		}
+	}
+	rng.Shuffle(len(unassigneds), func(j, k int) {
+		unassigneds[j], unassigneds[k] = unassigneds[k], unassigneds[j]
+	})
+	for _, i := range unassigneds {
+		assign(i, selectSplit(nil))
+	}
+	for _, split := range splits {
+		sort.Ints(split)
+	}
+	return splits
+}
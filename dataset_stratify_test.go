@@ -0,0 +1,57 @@
+package sticker
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestStratifyLabelVectors(t *testing.T) {
+	goassert.New(t, []stratificationItem{0<<32 | 0, 0<<32 | 2, 2<<32 | 2}).Equal(stratificationItems(LabelVector{2, 0}, 2))
+	goassert.New(t, []stratificationItem{0<<32 | 0, 2<<32 | 2}).Equal(stratificationItems(LabelVector{2, 0}, 1))
+	goassert.New(t, []stratificationItem{0<<32 | 0, 0<<32 | 2, 2<<32 | 2}).Equal(stratificationItems(LabelVector{2, 0, 2, 0}, 2))
+	// Each of the rare labels and label pairs should be spread over the splits.
+	Y := LabelVectors{}
+	for i := 0; i < 90; i++ {
+		Y = append(Y, LabelVector{0})
+	}
+	for i := 0; i < 3; i++ {
+		Y = append(Y, LabelVector{1}, LabelVector{2, 3}, LabelVector{2, 4}, LabelVector{})
+	}
+	for _, order := range []uint{1, 2} {
+		splits := StratifyLabelVectors(Y, 3, order, rand.New(rand.NewSource(0)))
+		goassert.New(t, 3).Equal(len(splits))
+		indices := []int{}
+		for _, split := range splits {
+			goassert.New(t, 34).Equal(len(split))
+			goassert.New(t, true).Equal(sort.IntsAreSorted(split))
+			labelCounts := make(map[uint32]int)
+			for _, i := range split {
+				for _, label := range Y[i] {
+					labelCounts[label]++
+				}
+			}
+			goassert.New(t, 30, 1, 2).Equal(labelCounts[0], labelCounts[1], labelCounts[2])
+			if order == 2 {
+				goassert.New(t, 1, 1).Equal(labelCounts[3], labelCounts[4])
+			}
+			indices = append(indices, split...)
+		}
+		sort.Ints(indices)
+		for i, index := range indices {
+			goassert.New(t, i).Equal(index)
+		}
+	}
+	// The duplicate labels should not assign the entry to multiple splits.
+	Y = LabelVectors{LabelVector{0, 0}, LabelVector{1, 0, 1}, LabelVector{0}, LabelVector{1, 1}}
+	for _, order := range []uint{1, 2} {
+		indices := []int{}
+		for _, split := range StratifyLabelVectors(Y, 2, order, rand.New(rand.NewSource(0))) {
+			indices = append(indices, split...)
+		}
+		sort.Ints(indices)
+		goassert.New(t, []int{0, 1, 2, 3}).Equal(indices)
+	}
+}
@@ -66,6 +66,9 @@ func TestKeyCountMap32(t *testing.T) {
 	goassert.New(t, KeyCount32{31, 1}).Equal(m.Get(31))
 	goassert.New(t, KeyCount32{0, 0}).Equal(m.Get(0))
 	goassert.New(t, KeyCount32{0, 0}).Equal(m.Get(63))
+	goassert.New(t, KeyCount32{1, 5}).Equal(m.Add(1, 3))
+	goassert.New(t, KeyCount32{63, 4}).Equal(m.Add(63, 4))
+	goassert.New(t, map[uint32]uint32{1: 5, 2: 1, 31: 1, 63: 4}).Equal(m.Map())
 }
 
 func TestKeyCounts32ExtractLargestCountsByInsert(t *testing.T) {
@@ -165,6 +168,15 @@ func TestDecodeEncodeDataset(t *testing.T) {
 	var decodedDs Dataset
 	goassert.New(t).SucceedWithoutError(DecodeDataset(&decodedDs, &buf))
 	goassert.New(t, ds).Equal(&decodedDs)
+	// The weights should be decoded, and the dataset without the weights should be decoded as before.
+	ds.W = []float32{1.0, 2.0, 0.5, 0.0}
+	goassert.New(t).SucceedWithoutError(EncodeDataset(ds, &buf))
+	goassert.New(t).SucceedWithoutError(DecodeDataset(&decodedDs, &buf))
+	goassert.New(t, ds).Equal(&decodedDs)
+	ds.W = nil
+	goassert.New(t).SucceedWithoutError(EncodeDataset(ds, &buf))
+	goassert.New(t).SucceedWithoutError(DecodeDataset(&decodedDs, &buf))
+	goassert.New(t, ds).Equal(&decodedDs)
 	// gob.Decoder.Decode won't call Dataset.GobDecode, because the encoder did not encode Dataset.
 	goassert.New(t, "Dataset should be encoded with EncodeDataset").ExpectError(gob.NewEncoder(&buf).Encode(&decodedDs))
 }
@@ -224,6 +236,40 @@ func TestDatasetSubSet(t *testing.T) {
 		X: FeatureVectors{ds.X[1], ds.X[1], ds.X[0]},
 		Y: LabelVectors{ds.Y[1], ds.Y[1], ds.Y[0]},
 	}).Equal(ds.SubSet([]int{1, 1, 0}))
+	ds.W = []float32{1.0, 2.0, 3.0}
+	goassert.New(t, &Dataset{
+		X: FeatureVectors{ds.X[1], ds.X[2]},
+		Y: LabelVectors{ds.Y[1], ds.Y[2]},
+		W: []float32{2.0, 3.0},
+	}).Equal(ds.SubSet([]int{1, 2}))
+}
+
+func TestDatasetAppend(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{0, 1.0}}},
+		Y: LabelVectors{LabelVector{0}},
+	}
+	ds.Append(&Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{1, 1.0}}},
+		Y: LabelVectors{LabelVector{1}},
+	})
+	goassert.New(t, &Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{0, 1.0}}, FeatureVector{KeyValue32{1, 1.0}}},
+		Y: LabelVectors{LabelVector{0}, LabelVector{1}},
+	}).Equal(ds)
+	// The entries without the weights should be weighted with 1.0.
+	ds.Append(&Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{2, 1.0}}},
+		Y: LabelVectors{LabelVector{2}},
+		W: []float32{0.5},
+	})
+	goassert.New(t, []float32{1.0, 1.0, 0.5}).Equal(ds.W)
+	ds.Append(&Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{3, 1.0}}},
+		Y: LabelVectors{LabelVector{3}},
+	})
+	goassert.New(t, 4, []float32{1.0, 1.0, 0.5, 1.0}).Equal(ds.Size(), ds.W)
+	goassert.New(t, float32(0.5), float32(1.0)).Equal(ds.Weight(2), (&Dataset{}).Weight(2))
 }
 
 func TestDatasetWriteToText(t *testing.T) {
@@ -242,6 +288,13 @@ func TestDatasetWriteToText(t *testing.T) {
 	goassert.New(t).SucceedWithoutError(ds.WriteTextDataset(&buf))
 	ds2 := goassert.New(t).SucceedNew(ReadTextDataset(&buf)).(*Dataset)
 	goassert.New(t, ds).Equal(ds2)
+	// The weights should be written after the labels.
+	ds.W = []float32{1.0, 2.0, 0.25, 0.0}
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(ds.WriteTextDataset(&buf))
+	goassert.New(t, "4 11 11\n0 1 0:1.000000\n").Equal(buf.String()[:len("4 11 11\n0 1 0:1.000000\n")])
+	ds2 = goassert.New(t).SucceedNew(ReadTextDataset(&buf)).(*Dataset)
+	goassert.New(t, ds).Equal(ds2)
 }
 
 func TestReadTextDataset(t *testing.T) {
@@ -268,6 +321,126 @@ func TestReadTextDataset(t *testing.T) {
 	goassert.New(t, "L1: illegal #1 feature ID").ExpectError(ReadTextDataset(strings.NewReader("3 10 10\n0 x:y\n")))
 	goassert.New(t, "L1: too large #1 feature ID \\(>= 10\\)").ExpectError(ReadTextDataset(strings.NewReader("3 10 10\n0 10:y\n")))
 	goassert.New(t, "L1: illegal #1 feature value").ExpectError(ReadTextDataset(strings.NewReader("3 10 10\n0 0:y\n")))
+	// The optional weight follows the labels.
+	ds.W = []float32{1.0, 0.5, 2.0}
+	goassert.New(t, ds).EqualWithoutError(ReadTextDataset(strings.NewReader("3 10 10\n0 0:1\n0,1 0.5 0:2 1:3\n0,2,9 2 0:4 2:5 9:6\n")))
+	goassert.New(t, "L1: illegal weight").ExpectError(ReadTextDataset(strings.NewReader("3 10 10\n0 -1 0:1\n")))
+	goassert.New(t, "L1: illegal weight").ExpectError(ReadTextDataset(strings.NewReader("3 10 10\n0 NaN 0:1\n")))
+}
+
+func TestReadTextDatasetWithWorkers(t *testing.T) {
+	defer func(blockSize int) {
+		textDatasetBlockSize = blockSize
+	}(textDatasetBlockSize)
+	textDatasetBlockSize = 2
+	n := 11
+	ds := &Dataset{
+		X: make(FeatureVectors, n),
+		Y: make(LabelVectors, n),
+	}
+	text := fmt.Sprintf("%d 20 20\n", n)
+	for i := 0; i < n; i++ {
+		ds.X[i], ds.Y[i] = FeatureVector{KeyValue32{uint32(i), float32(i)}, KeyValue32{uint32(i + 1), 1.0}}, LabelVector{uint32(i)}
+		text += fmt.Sprintf("%d %d:1 %d:%d\n", i, i+1, i, i)
+	}
+	for _, nworkers := range []int{0, 1, 3, 16} {
+		goassert.New(t, ds).EqualWithoutError(ReadTextDatasetWithWorkers(strings.NewReader(text), nworkers))
+	}
+	// The error at the first illegal line should be returned.
+	lines := strings.Split(text, "\n")
+	badLines := append([]string{}, lines...)
+	badLines[4], badLines[9] = "x", "0 x"
+	for _, nworkers := range []int{1, 3, 16} {
+		goassert.New(t, "L4: illegal #1 label ID").ExpectError(ReadTextDatasetWithWorkers(strings.NewReader(strings.Join(badLines, "\n")), nworkers))
+		goassert.New(t, "L9: illegal #1 featureID:value pair").ExpectError(ReadTextDatasetWithWorkers(strings.NewReader(strings.Join(lines[:9], "\n")+"\n"+strings.Join(badLines[9:], "\n")), nworkers))
+		goassert.New(t, "L4: illegal #1 label ID").ExpectError(ReadTextDatasetWithWorkers(strings.NewReader(strings.Join(badLines[:7], "\n")+"\n"), nworkers))
+		goassert.New(t, "L7: cannot read line").ExpectError(ReadTextDatasetWithWorkers(strings.NewReader(strings.Join(lines[:7], "\n")+"\n"), nworkers))
+	}
+}
+
+func TestReadTextDatasetWithParameters(t *testing.T) {
+	defer func(blockSize int) {
+		textDatasetBlockSize = blockSize
+	}(textDatasetBlockSize)
+	textDatasetBlockSize = 2
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{0, 1.0}},
+			FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 3.0}},
+			FeatureVector{},
+			FeatureVector{KeyValue32{0, 4.0}, KeyValue32{2, 5.0}, KeyValue32{9, 6.0}},
+			FeatureVector{KeyValue32{10, 7.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{0}, LabelVector{}, LabelVector{}, LabelVector{0, 2, 9}, LabelVector{10},
+		},
+	}
+	body := "0 0:1\n 0:2 1:3\r\n\n0,2,9 9:6 0:4 2:5\n10 10:7"
+	for _, nworkers := range []int{1, 3} {
+		params := NewTextDatasetParameters()
+		params.Nworkers = nworkers
+		goassert.New(t, ds).EqualWithoutError(ReadTextDatasetWithParameters(strings.NewReader("5 11 11\n"+body+"\n"), params))
+		goassert.New(t, ds).EqualWithoutError(ReadTextDatasetWithParameters(strings.NewReader(body), params))
+		goassert.New(t, ds).EqualWithoutError(ReadTextDatasetWithParameters(strings.NewReader(body+"\n"), params))
+		goassert.New(t, &Dataset{X: FeatureVectors{}, Y: LabelVectors{}}).EqualWithoutError(ReadTextDatasetWithParameters(strings.NewReader(""), params))
+		goassert.New(t, "L4: too large #1 feature ID \\(>= 11\\)").ExpectError(ReadTextDatasetWithParameters(strings.NewReader("5 11 11\n"+strings.Replace(body, "9:6", "11:6", 1)+"\n"), params))
+		goassert.New(t, "L6: illegal #1 label ID").ExpectError(ReadTextDatasetWithParameters(strings.NewReader(body+"\nx\n"), params))
+		goassert.New(t, "L2: illegal #1 label ID").ExpectError(ReadTextDatasetWithParameters(strings.NewReader("5 11 11\n"+body+"\n"), &TextDatasetParameters{Nworkers: nworkers}))
+	}
+	// WriteTextDataset should write the dataset which can be read again.
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(ds.WriteTextDataset(&buf))
+	goassert.New(t, ds).EqualWithoutError(ReadTextDatasetWithParameters(&buf, NewTextDatasetParameters()))
+}
+
+func TestReadTextDatasetWithReport(t *testing.T) {
+	defer func(blockSize int) {
+		textDatasetBlockSize = blockSize
+	}(textDatasetBlockSize)
+	textDatasetBlockSize = 2
+	text := "6 10 10\n0 0:1\n1,0 1:2 1:3\n0 0:NaN 2:Inf 3:1e40 4:1\nx 0:1\n10,2 10:1 9:1\n0 0:x\n"
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{0, 1.0}},
+			FeatureVector{KeyValue32{1, 2.0}},
+			FeatureVector{KeyValue32{4, 1.0}},
+			FeatureVector{KeyValue32{9, 1.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{0}, LabelVector{0, 1}, LabelVector{0}, LabelVector{2},
+		},
+	}
+	issues := []TextDatasetIssue{
+		{TextDatasetUnsortedLabels, &TextDatasetParseError{2, 1, "label", "labels are not sorted in ascending order"}},
+		{TextDatasetDuplicateFeature, &TextDatasetParseError{2, 3, "feature", "duplicate #2 feature ID 1"}},
+		{TextDatasetNonFiniteValue, &TextDatasetParseError{3, 2, "value", "non-finite #1 feature value"}},
+		{TextDatasetNonFiniteValue, &TextDatasetParseError{3, 3, "value", "non-finite #2 feature value"}},
+		{TextDatasetNonFiniteValue, &TextDatasetParseError{3, 4, "value", "non-finite #3 feature value"}},
+		{TextDatasetSkippedLine, &TextDatasetParseError{4, 1, "label", "illegal #1 label ID"}},
+		{TextDatasetOutOfRangeID, &TextDatasetParseError{5, 1, "label", "too large #1 label ID (>= 10)"}},
+		{TextDatasetOutOfRangeID, &TextDatasetParseError{5, 2, "feature", "too large #1 feature ID (>= 10)"}},
+		{TextDatasetSkippedLine, &TextDatasetParseError{6, 2, "value", "illegal #1 feature value"}},
+	}
+	for _, nworkers := range []int{1, 3} {
+		params := &TextDatasetParameters{Lenient: true, Nworkers: nworkers}
+		goassert.New(t, ds, &TextDatasetReport{Nlines: 6, Issues: issues}).EqualWithoutError(ReadTextDatasetWithReport(strings.NewReader(text), params))
+	}
+	report := &TextDatasetReport{Issues: issues}
+	goassert.New(t, map[TextDatasetIssueKind]int{
+		TextDatasetSkippedLine:      2,
+		TextDatasetDuplicateFeature: 1,
+		TextDatasetNonFiniteValue:   3,
+		TextDatasetOutOfRangeID:     2,
+		TextDatasetUnsortedLabels:   1,
+	}).Equal(report.Counts())
+	// The strict mode should return the typed error at the first illegal line.
+	_, _, err := ReadTextDatasetWithReport(strings.NewReader(text), &TextDatasetParameters{})
+	goassert.New(t, &TextDatasetParseError{3, 4, "value", "illegal #3 feature value"}).Equal(err)
+	goassert.New(t, "L3: illegal #3 feature value").Equal(err.Error())
+	// The lenient TextDatasetReader should skip the illegal lines.
+	reader := goassert.New(t).SucceedNew(NewTextDatasetReaderWithParameters(strings.NewReader(text), &TextDatasetParameters{Lenient: true})).(*TextDatasetReader)
+	goassert.New(t, ds).EqualWithoutError(ReadDatasetChunk(reader, 10))
+	goassert.New(t, &TextDatasetReport{Nlines: 6, Issues: issues}).Equal(reader.Report())
 }
 
 func createBenchmarkAvgTotalVariationAmongSparseVectors() SparseVectors {
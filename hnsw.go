@@ -0,0 +1,450 @@
+package sticker
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// HNSW is the Hierarchical Navigable Small World graph for finding the near entries of the sparse feature vectors (Malkov+ 2016).
+// The similarity of two feature vectors is their dot product multiplied by their Jaccard similarity, which is the one of LabelNearest with beta=1 for the normalized feature vectors.
+// HNSW resolves the feature vectors of the nodes by their indices in the dataset given to NewHNSW or SetDataset, so the dataset must be kept consistent with the added, removed and reindexed indices.
+// The removed nodes are only marked as removed, because they are still used for navigating the graph, so only their feature vectors are kept in HNSW.
+//
+// References:
+//
+// (Malkov+ 2016) Y. A. Malkov and D. A. Yashunin. "Efficient and Robust Approximate Nearest Neighbor Search Using Hierarchical Navigable Small World Graphs." arXiv preprint arXiv:1603.09320, 2016.
+type HNSW struct {
+	m, efConstruction, ef uint
+	entry                 uint32
+	maxLevel              int
+	nodes                 []hnswNode
+	// nodeIDs is the node of each index.
+	nodeIDs []uint32
+	// dataset is the dataset having the feature vector of each index.
+	dataset *Dataset
+	rng     *rand.Rand
+}
+
+// hnswNode is the node of HNSW.
+type hnswNode struct {
+	Index   uint32
+	Removed bool
+	// RemovedX is the feature vector of the removed node, which is no longer in the dataset.
+	RemovedX FeatureVector
+	// Neighbors is the neighbor nodes in each layer from the bottom to the level of the node.
+	Neighbors [][]uint32
+}
+
+// NewHNSW returns an new HNSW.
+// M is the number of the neighbors of each added node in each layer, and efConstruction is the size of the dynamic candidate list in adding.
+// The size ef of the dynamic candidate list in searching is efConstruction (see SetEf).
+// Recommended M and efConstruction are 16 and 100, respectively, and M must be at least 2.
+// The feature vector of each index is resolved by ds.X (see SetDataset).
+func NewHNSW(M, efConstruction uint, ds *Dataset) *HNSW {
+	return &HNSW{
+		m:              M,
+		efConstruction: efConstruction,
+		ef:             efConstruction,
+		dataset:        ds,
+		rng:            rand.New(rand.NewSource(0)),
+	}
+}
+
+// Add adds the given feature vector as i-th index to the graph.
+// The feature vector must be already the i-th feature vector in the dataset.
+// The feature vector is connected to its M nearest nodes in each layer up to its random level, and the neighbors of each node are limited to the nearest M (2M in the bottom layer).
+func (index *HNSW) Add(vec FeatureVector, i uint32) {
+	level := int(-math.Log(1.0-index.rng.Float64()) / math.Log(float64(index.m)))
+	node := uint32(len(index.nodes))
+	index.nodes = append(index.nodes, hnswNode{
+		Index:     i,
+		Neighbors: make([][]uint32, level+1),
+	})
+	for uint32(len(index.nodeIDs)) <= i {
+		index.nodeIDs = append(index.nodeIDs, 0)
+	}
+	index.nodeIDs[i] = node
+	if node == 0 {
+		index.entry, index.maxLevel = node, level
+		return
+	}
+	eps := KeyValues32{KeyValue32{index.entry, index.similarity(vec, index.entry)}}
+	for l := index.maxLevel; l > level; l-- {
+		eps = index.searchLayer(vec, eps, 1, l)
+	}
+	for l := level; l >= 0; l-- {
+		if l > index.maxLevel {
+			continue
+		}
+		eps = index.searchLayer(vec, eps, index.efConstruction, l)
+		neighbors := make([]uint32, 0, index.m)
+		for _, ep := range eps {
+			if uint(len(neighbors)) >= index.m {
+				break
+			}
+			neighbors = append(neighbors, ep.Key)
+		}
+		index.nodes[node].Neighbors[l] = neighbors
+		for _, neighbor := range neighbors {
+			index.connect(neighbor, node, l)
+		}
+	}
+	if level > index.maxLevel {
+		index.entry, index.maxLevel = node, level
+	}
+}
+
+// DecodeHNSWWithGobDecoder decodes HNSW using decoder.
+// The dataset is not encoded, so users should set it by SetDataset before using the decoded HNSW.
+//
+// This function returns an error in decoding.
+func DecodeHNSWWithGobDecoder(index *HNSW, decoder *gob.Decoder) error {
+	var header uint
+	if err := decoder.Decode(&header); err != nil {
+		return fmt.Errorf("DecodeHNSW: header: %s", err)
+	}
+	if name := nearIndexName(header); name != "hnsw" {
+		return fmt.Errorf("DecodeHNSW: NearIndex %q is encoded", name)
+	}
+	if err := index.decodeWithHeader(decoder, header); err != nil {
+		return fmt.Errorf("DecodeHNSW: %s", err)
+	}
+	return nil
+}
+
+// DecodeHNSW decodes HNSW from r.
+// Directly passing *os.File used by a gob.Decoder to this function causes mysterious errors.
+// Thus, if users use gob.Decoder, then they should call DecodeHNSWWithGobDecoder.
+//
+// This function returns an error in decoding.
+func DecodeHNSW(index *HNSW, r io.Reader) error {
+	return DecodeHNSWWithGobDecoder(index, gob.NewDecoder(r))
+}
+
+// hnswParameters is the parameters of HNSW encoded after the header.
+type hnswParameters struct {
+	M, EfConstruction, Ef uint
+	Entry                 uint32
+	MaxLevel              int
+	Nnodes                int
+}
+
+// decodeWithHeader decodes HNSW following the already decoded header using decoder.
+func (index *HNSW) decodeWithHeader(decoder *gob.Decoder, header uint) error {
+	var params hnswParameters
+	if err := decoder.Decode(&params); err != nil {
+		return fmt.Errorf("parameters: %s", err)
+	}
+	index.m, index.efConstruction, index.ef = params.M, params.EfConstruction, params.Ef
+	index.entry, index.maxLevel = params.Entry, params.MaxLevel
+	index.nodes = make([]hnswNode, params.Nnodes)
+	for node := range index.nodes {
+		if err := decoder.Decode(&index.nodes[node]); err != nil {
+			return fmt.Errorf("#%d node: %s", node, err)
+		}
+	}
+	index.nodeIDs = nil
+	if err := decoder.Decode(&index.nodeIDs); err != nil {
+		return fmt.Errorf("nodeIDs: %s", err)
+	}
+	index.ResetRng()
+	return nil
+}
+
+// EncodeHNSWWithGobEncoder encodes HNSW using encoder.
+//
+// This function returns an error in encoding.
+func EncodeHNSWWithGobEncoder(index *HNSW, encoder *gob.Encoder) error {
+	if err := encoder.Encode(hnswFlag); err != nil {
+		return fmt.Errorf("EncodeHNSW: header: %s", err)
+	}
+	if err := encoder.Encode(hnswParameters{
+		M:              index.m,
+		EfConstruction: index.efConstruction,
+		Ef:             index.ef,
+		Entry:          index.entry,
+		MaxLevel:       index.maxLevel,
+		Nnodes:         len(index.nodes),
+	}); err != nil {
+		return fmt.Errorf("EncodeHNSW: parameters: %s", err)
+	}
+	for node, nodeData := range index.nodes {
+		if err := encoder.Encode(nodeData); err != nil {
+			return fmt.Errorf("EncodeHNSW: #%d node: %s", node, err)
+		}
+	}
+	if err := encoder.Encode(index.nodeIDs); err != nil {
+		return fmt.Errorf("EncodeHNSW: nodeIDs: %s", err)
+	}
+	index.ResetRng()
+	return nil
+}
+
+// EncodeHNSW encodes HNSW to w.
+// Directly passing *os.File used by a gob.Encoder to this function causes mysterious errors.
+// Thus, if users use gob.Encoder, then they should call EncodeHNSWWithGobEncoder.
+//
+// This function returns an error in encoding.
+func EncodeHNSW(index *HNSW, w io.Writer) error {
+	return EncodeHNSWWithGobEncoder(index, gob.NewEncoder(w))
+}
+
+// Encode encodes the index using encoder (see EncodeHNSWWithGobEncoder).
+func (index *HNSW) Encode(encoder *gob.Encoder) error {
+	return EncodeHNSWWithGobEncoder(index, encoder)
+}
+
+// GobEncode returns the error always, because users should encode large HNSW objects with EncodeHNSW.
+func (index *HNSW) GobEncode() ([]byte, error) {
+	return nil, fmt.Errorf("HNSW should be encoded with EncodeHNSW")
+}
+
+// Ef returns the size of the dynamic candidate list in searching.
+func (index *HNSW) Ef() uint {
+	return index.ef
+}
+
+// EfConstruction returns the size of the dynamic candidate list in adding.
+func (index *HNSW) EfConstruction() uint {
+	return index.efConstruction
+}
+
+// FindNears returns the histogram of at most ef near indices from the given feature vector, where the count of the nearest index is ef and the count of the farther index is smaller.
+func (index *HNSW) FindNears(vec FeatureVector) KeyCountMap32 {
+	if len(index.nodes) == 0 {
+		return NewKeyCountMap32(0)
+	}
+	eps := KeyValues32{KeyValue32{index.entry, index.similarity(vec, index.entry)}}
+	for l := index.maxLevel; l > 0; l-- {
+		eps = index.searchLayer(vec, eps, 1, l)
+	}
+	eps = index.searchLayer(vec, eps, index.ef, 0)
+	nears := NewKeyCountMap32(uint(len(eps)))
+	for rank, ep := range eps {
+		if nodeData := index.nodes[ep.Key]; !nodeData.Removed {
+			nears.Add(nodeData.Index, uint32(len(eps)-rank))
+		}
+	}
+	return nears
+}
+
+// FindNearsWithProbes is FindNears, because the number of the candidates is determined by ef (see SetEf) instead of probes.
+func (index *HNSW) FindNearsWithProbes(vec FeatureVector, probes uint) KeyCountMap32 {
+	return index.FindNears(vec)
+}
+
+// M returns the number of the neighbors of each added node in each layer.
+func (index *HNSW) M() uint {
+	return index.m
+}
+
+// Name returns "hnsw".
+func (index *HNSW) Name() string {
+	return "hnsw"
+}
+
+// Reindex changes the index oldIdx of the given feature vector to newIdx in the graph.
+func (index *HNSW) Reindex(vec FeatureVector, oldIdx, newIdx uint32) {
+	node := index.nodeIDs[oldIdx]
+	index.nodes[node].Index = newIdx
+	for uint32(len(index.nodeIDs)) <= newIdx {
+		index.nodeIDs = append(index.nodeIDs, 0)
+	}
+	index.nodeIDs[newIdx] = node
+	if oldIdx == uint32(len(index.nodeIDs)-1) {
+		index.nodeIDs = index.nodeIDs[:oldIdx]
+	}
+}
+
+// Remove removes the given feature vector added as i-th index from the graph.
+// The node of the feature vector is kept with the feature vector for navigating the graph, but it is never found again.
+func (index *HNSW) Remove(vec FeatureVector, i uint32) {
+	nodeData := &index.nodes[index.nodeIDs[i]]
+	nodeData.Removed, nodeData.RemovedX = true, vec
+	if i == uint32(len(index.nodeIDs)-1) {
+		index.nodeIDs = index.nodeIDs[:i]
+	}
+}
+
+// ResetRng resets the internal random number generator.
+func (index *HNSW) ResetRng() {
+	index.rng = rand.New(rand.NewSource(0))
+}
+
+// SetDataset sets the dataset resolving the feature vector of each index.
+func (index *HNSW) SetDataset(ds *Dataset) {
+	index.dataset = ds
+}
+
+// SetEf sets the size of the dynamic candidate list in searching.
+// The larger ef achieves the higher recall with the more computation, and ef should be at least the number of the required candidates.
+// This must not be called concurrently with FindNears.
+func (index *HNSW) SetEf(ef uint) {
+	index.ef = ef
+}
+
+// String returns the name with M, efConstruction and ef.
+func (index *HNSW) String() string {
+	return fmt.Sprintf("hnsw(M=%d,efConstruction=%d,ef=%d)", index.m, index.efConstruction, index.ef)
+}
+
+// Summary returns the slice of the numbers of the nodes in each layer from the bottom and the histogram of the numbers of the neighbors in the bottom layer, whose i-th element is the number of the nodes having i+1 neighbors.
+func (index *HNSW) Summary() (layerSizes []int, degreeHist []int) {
+	layerSizes = make([]int, index.maxLevel+1)
+	degreeHist = make([]int, 2*index.m)
+	for _, nodeData := range index.nodes {
+		for l := range nodeData.Neighbors {
+			layerSizes[l]++
+		}
+		if degree := len(nodeData.Neighbors[0]); degree > 0 {
+			degreeHist[degree-1]++
+		}
+	}
+	return
+}
+
+// connect adds the edge from the node from to the node to in the layer l.
+// If the node from has too many neighbors, then the farthest neighbor is dropped.
+func (index *HNSW) connect(from, to uint32, l int) {
+	neighbors := append(index.nodes[from].Neighbors[l], to)
+	maxNeighbors := int(index.m)
+	if l == 0 {
+		maxNeighbors *= 2
+	}
+	if len(neighbors) > maxNeighbors {
+		x := index.vector(from)
+		neighborSims := make(KeyValues32, len(neighbors))
+		for j, neighbor := range neighbors {
+			neighborSims[j] = KeyValue32{neighbor, index.similarity(x, neighbor)}
+		}
+		sortHNSWCandidates(neighborSims)
+		neighbors = neighbors[:maxNeighbors]
+		for j := range neighbors {
+			neighbors[j] = neighborSims[j].Key
+		}
+	}
+	index.nodes[from].Neighbors[l] = neighbors
+}
+
+// searchLayer returns at most ef nearest nodes with their similarities to the given feature vector in descending order of similarity in the layer l, which are found greedily from the entry points eps.
+func (index *HNSW) searchLayer(vec FeatureVector, eps KeyValues32, ef uint, l int) KeyValues32 {
+	visited := make(map[uint32]bool)
+	candidates, results := hnswCandidateHeap{}, hnswResultHeap{}
+	for _, ep := range eps {
+		visited[ep.Key] = true
+		heap.Push(&candidates, ep)
+		heap.Push(&results, ep)
+		if uint(results.Len()) > ef {
+			heap.Pop(&results)
+		}
+	}
+	for candidates.Len() > 0 {
+		candidate := heap.Pop(&candidates).(KeyValue32)
+		if uint(results.Len()) >= ef && candidate.Value < results[0].Value {
+			break
+		}
+		for _, neighbor := range index.nodes[candidate.Key].Neighbors[l] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			sim := index.similarity(vec, neighbor)
+			if uint(results.Len()) < ef || sim > results[0].Value {
+				heap.Push(&candidates, KeyValue32{neighbor, sim})
+				heap.Push(&results, KeyValue32{neighbor, sim})
+				if uint(results.Len()) > ef {
+					heap.Pop(&results)
+				}
+			}
+		}
+	}
+	nears := KeyValues32(results)
+	sortHNSWCandidates(nears)
+	return nears
+}
+
+// similarity returns the similarity of the given feature vector and the feature vector of the node.
+func (index *HNSW) similarity(vec FeatureVector, node uint32) float32 {
+	x := index.vector(node)
+	dot, count := DotCount(vec, x)
+	if count == 0 {
+		return 0.0
+	}
+	return dot * float32(count) / float32(len(vec)+len(x)-count)
+}
+
+// vector returns the feature vector of the node.
+func (index *HNSW) vector(node uint32) FeatureVector {
+	nodeData := &index.nodes[node]
+	if nodeData.Removed {
+		return nodeData.RemovedX
+	}
+	return index.dataset.X[nodeData.Index]
+}
+
+// sortHNSWCandidates sorts the nodes in descending order of similarity, where the ties are broken by the node.
+func sortHNSWCandidates(nodeSims KeyValues32) {
+	sort.Slice(nodeSims, func(i, j int) bool {
+		if nodeSims[i].Value != nodeSims[j].Value {
+			return nodeSims[i].Value > nodeSims[j].Value
+		}
+		return nodeSims[i].Key < nodeSims[j].Key
+	})
+}
+
+// hnswCandidateHeap is the max-heap of the nodes ordered by the similarity.
+type hnswCandidateHeap KeyValues32
+
+func (h hnswCandidateHeap) Len() int {
+	return len(h)
+}
+
+func (h hnswCandidateHeap) Less(i, j int) bool {
+	return h[i].Value > h[j].Value
+}
+
+func (h hnswCandidateHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *hnswCandidateHeap) Push(x interface{}) {
+	*h = append(*h, x.(KeyValue32))
+}
+
+func (h *hnswCandidateHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// hnswResultHeap is the min-heap of the nodes ordered by the similarity.
+type hnswResultHeap KeyValues32
+
+func (h hnswResultHeap) Len() int {
+	return len(h)
+}
+
+func (h hnswResultHeap) Less(i, j int) bool {
+	return h[i].Value < h[j].Value
+}
+
+func (h hnswResultHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *hnswResultHeap) Push(x interface{}) {
+	*h = append(*h, x.(KeyValue32))
+}
+
+func (h *hnswResultHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
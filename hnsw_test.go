@@ -0,0 +1,92 @@
+package sticker
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestHNSW(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	X := make(FeatureVectors, 500)
+	for i := range X {
+		keys := make(map[uint32]bool)
+		for len(keys) < 8 {
+			keys[uint32(rng.Intn(128))] = true
+		}
+		xi := FeatureVector{}
+		for _, key := range sortedKeys(keys) {
+			xi = append(xi, KeyValue32{key, rng.Float32() + 0.5})
+		}
+		X[i] = xi
+	}
+	ds := &Dataset{X: append(FeatureVectors{}, X...)}
+	index := NewHNSW(8, 50, ds)
+	goassert.New(t, uint(8), uint(50), uint(50), "hnsw").Equal(index.M(), index.EfConstruction(), index.Ef(), index.Name())
+	goassert.New(t, map[uint32]uint32{}).Equal(index.FindNears(X[0]).Map())
+	for i, xi := range X {
+		index.Add(xi, uint32(i))
+	}
+	// The found indices should cover the most of the exact 10-nearest indices.
+	index.SetEf(20)
+	recalls := 0
+	for _, x := range X[:100] {
+		exacts := make(KeyValues32, len(X))
+		for i, xi := range X {
+			dot, count := DotCount(x, xi)
+			exacts[i] = KeyValue32{uint32(i), dot * float32(count) / float32(len(x)+len(xi)-count)}
+		}
+		sortHNSWCandidates(exacts)
+		nears := index.FindNears(x).Map()
+		goassert.New(t, uint32(20)).Equal(nears[exacts[0].Key])
+		for _, exact := range exacts[:10] {
+			if _, found := nears[exact.Key]; found {
+				recalls++
+			}
+		}
+	}
+	goassert.New(t, 986).Equal(recalls) // Answer: 1000
+	layerSizes, degreeHist := index.Summary()
+	goassert.New(t, 500).Equal(layerSizes[0])
+	goassert.New(t, 16).Equal(len(degreeHist))
+	// The removed index should not be found, and the reindexed index should be found.
+	// The dataset is updated as LabelNear.Remove.
+	index.Remove(X[0], 0)
+	index.Reindex(X[499], 499, 0)
+	ds.X[0] = X[499]
+	nears := index.FindNears(X[499]).Map()
+	goassert.New(t, uint32(20)).Equal(nears[0])
+	_, found := nears[499]
+	goassert.New(t, false).Equal(found)
+	_, found = index.FindNears(X[0]).Map()[0]
+	goassert.New(t, false).Equal(found)
+	ds.X[499] = X[0]
+	index.Add(X[0], 499)
+	// The removed node of the same feature vector is still the nearest node.
+	goassert.New(t, uint32(19)).Equal(index.FindNears(X[0]).Map()[499])
+	// Check gob encoding/decoding.
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeHNSW(index, &buf))
+	var decodedIndex HNSW
+	goassert.New(t).SucceedWithoutError(DecodeHNSW(&decodedIndex, &buf))
+	// The dataset is not encoded, and the removed nodes only have their feature vectors.
+	goassert.New(t, (*Dataset)(nil)).Equal(decodedIndex.dataset)
+	for _, nodeData := range decodedIndex.nodes {
+		goassert.New(t, nodeData.Removed).Equal(nodeData.RemovedX != nil)
+	}
+	decodedIndex.SetDataset(ds)
+	goassert.New(t, index).Equal(&decodedIndex)
+	// gob.Decoder.Decode won't call HNSW.GobDecode, because the encoder did not encode HNSW.
+	goassert.New(t, "HNSW should be encoded with EncodeHNSW").ExpectError(gob.NewEncoder(&buf).Encode(&decodedIndex))
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(index.Encode(gob.NewEncoder(&buf)))
+	decodedNearIndex := goassert.New(t).SucceedNew(DecodeNearIndex(&buf)).(*HNSW)
+	decodedNearIndex.SetDataset(ds)
+	goassert.New(t, index).Equal(decodedNearIndex)
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(EncodeJaccardHashing(NewJaccardHashing(16, 10, 8), &buf))
+	goassert.New(t, "DecodeHNSW: NearIndex \"jaccard\" is encoded").ExpectError(DecodeHNSW(&HNSW{}, &buf))
+}
@@ -6,4 +6,11 @@ import (
 
 func init() {
 	gob.Register(map[string]int(nil))
+	gob.Register(Transformers(nil))
+	gob.Register(&BM25Transformer{})
+	gob.Register(&FeatureHashingTransformer{})
+	gob.Register(&IDFTransformer{})
+	gob.Register(&L2Transformer{})
+	gob.Register(&MaxAbsTransformer{})
+	gob.Register(&SublinearTFTransformer{})
 }
@@ -4,77 +4,59 @@ import (
 	"encoding/gob"
 	"fmt"
 	"io"
-	"math/bits"
-	"math/rand"
 )
 
 // JaccardHashing is the optimal Densified One Permutation Hashing (DOPH) for estimating Jaccard similarity (Wang+ 2017).
+// The weighted JaccardHashing uses Improved Consistent Weighted Sampling (ICWS) for estimating the weighted Jaccard similarity instead (Ioffe 2010), which takes the feature values into account.
 //
 // References:
 //
+// (Ioffe 2010) S. Ioffe. "Improved Consistent Sampling, Weighted Minhash and L1 Sketching." In ICDM, pp. 246-255, 2010.
+//
 // (Wang+ 2017) Y. Wang, A. Shrivastava, and J. Ryu. "FLASH: Randomized Algorithms Accelerated over CPU-GPU for Ultra-High Dimensional Similarity Search." arXiv preprint arXiv:1709.01190, 2017.
+//
+// (Gemulla+ 2006) R. Gemulla, W. Lehner, and P. J. Haas. "A Dip in the Reservoir: Maintaining Sample Synopses of Evolving Datasets." In VLDB, pp. 595-606, 2006.
 type JaccardHashing struct {
-	_R        uint
-	tables    [][][]uint32
-	tableHits [][]uint32
-	rng       *rand.Rand
+	hashTables
+	weighted bool
 }
 
 // NewJaccardHashing returns an new JaccardHashing.
 // Recommended K, L and R are 64, 16 and 64, respectively.
 func NewJaccardHashing(K, L, R uint) *JaccardHashing {
-	rng := rand.New(rand.NewSource(0))
-	tables, tableHits := make([][][]uint32, K), make([][]uint32, K)
-	for k := range tables {
-		tables[k], tableHits[k] = make([][]uint32, 1<<L), make([]uint32, 1<<L)
-	}
-	hashing := &JaccardHashing{
-		_R:        R,
-		tables:    tables,
-		tableHits: tableHits,
-		rng:       rng,
+	return &JaccardHashing{
+		hashTables: newHashTables(K, L, R),
 	}
+}
+
+// NewWeightedJaccardHashing returns an new weighted JaccardHashing using ICWS.
+// See NewJaccardHashing for K, L and R.
+func NewWeightedJaccardHashing(K, L, R uint) *JaccardHashing {
+	hashing := NewJaccardHashing(K, L, R)
+	hashing.weighted = true
 	return hashing
 }
 
 // Add adds the given feature vector as i-th index to the hash tables.
+// If some indices were removed from the bucket, then the index is added to the reservoir with the probability compensating the deletions by the random pairing (Gemulla+ 2006).
 func (hashing *JaccardHashing) Add(vec FeatureVector, i uint32) {
-	hvec := hashing.Hash(vec)
-	for k, hveck := range hvec {
-		if uint(len(hashing.tables[k][hveck])) < hashing._R {
-			hashing.tables[k][hveck] = append(hashing.tables[k][hveck], uint32(i))
-		} else {
-			j := hashing.rng.Intn(int(hashing.tableHits[k][hveck]))
-			if uint(j) < hashing._R {
-				hashing.tables[k][hveck][j] = i
-			}
-		}
-		hashing.tableHits[k][hveck]++
-	}
+	hashing.add(hashing.Hash(vec), i)
 }
 
 // DecodeJaccardHashingWithGobDecoder decodes JaccardHashing using decoder.
 //
 // This function returns an error in decoding.
 func DecodeJaccardHashingWithGobDecoder(hashing *JaccardHashing, decoder *gob.Decoder) error {
-	if err := decoder.Decode(&hashing._R); err != nil {
+	var header uint
+	if err := decoder.Decode(&header); err != nil {
 		return fmt.Errorf("DecodeJaccardHashing: _R: %s", err)
 	}
-	var K uint
-	if err := decoder.Decode(&K); err != nil {
-		return fmt.Errorf("DecodeJaccardHashing: K: %s", err)
+	if name := nearIndexName(header); name != "jaccard" {
+		return fmt.Errorf("DecodeJaccardHashing: NearIndex %q is encoded", name)
 	}
-	hashing.tables = make([][][]uint32, K)
-	for k := range hashing.tables {
-		if err := decoder.Decode(&hashing.tables[k]); err != nil {
-			return fmt.Errorf("DecodeJaccardHashing: #%d table: %s", k, err)
-		}
-	}
-	hashing.tableHits = make([][]uint32, K)
-	if err := decoder.Decode(&hashing.tableHits); err != nil {
-		return fmt.Errorf("DecodeJaccardHashing: tableHits: %s", err)
+	if err := hashing.decodeWithHeader(decoder, header); err != nil {
+		return fmt.Errorf("DecodeJaccardHashing: %s", err)
 	}
-	hashing.ResetRng()
 	return nil
 }
 
@@ -87,25 +69,24 @@ func DecodeJaccardHashing(hashing *JaccardHashing, r io.Reader) error {
 	return DecodeJaccardHashingWithGobDecoder(hashing, gob.NewDecoder(r))
 }
 
+// decodeWithHeader decodes JaccardHashing following the already decoded header using decoder.
+func (hashing *JaccardHashing) decodeWithHeader(decoder *gob.Decoder, header uint) error {
+	hashing.weighted = header&jaccardHashingWeightedFlag != 0
+	return hashing.hashTables.decodeWithHeader(decoder, header)
+}
+
 // EncodeJaccardHashingWithGobEncoder decodes JaccardHashing using encoder.
+// The deletions of the removed indices are also encoded.
 //
 // This function returns an error in decoding.
 func EncodeJaccardHashingWithGobEncoder(hashing *JaccardHashing, encoder *gob.Encoder) error {
-	if err := encoder.Encode(hashing._R); err != nil {
-		return fmt.Errorf("EncodeJaccardHashing: _R: %s", err)
+	header := hashing._R
+	if hashing.weighted {
+		header |= jaccardHashingWeightedFlag
 	}
-	if err := encoder.Encode(hashing.K()); err != nil {
-		return fmt.Errorf("EncodeJaccardHashing: K: %s", err)
+	if err := hashing.encodeWithHeader(encoder, header); err != nil {
+		return fmt.Errorf("EncodeJaccardHashing: %s", err)
 	}
-	for k, table := range hashing.tables {
-		if err := encoder.Encode(table); err != nil {
-			return fmt.Errorf("EncodeJaccardHashing: #%d table: %s", k, err)
-		}
-	}
-	if err := encoder.Encode(hashing.tableHits); err != nil {
-		return fmt.Errorf("EncodeJaccardHashing: tableHits: %s", err)
-	}
-	hashing.ResetRng()
 	return nil
 }
 
@@ -118,6 +99,11 @@ func EncodeJaccardHashing(hashing *JaccardHashing, w io.Writer) error {
 	return EncodeJaccardHashingWithGobEncoder(hashing, gob.NewEncoder(w))
 }
 
+// Encode encodes the hashing using encoder (see EncodeJaccardHashingWithGobEncoder).
+func (hashing *JaccardHashing) Encode(encoder *gob.Encoder) error {
+	return EncodeJaccardHashingWithGobEncoder(hashing, encoder)
+}
+
 // GobEncode returns the error always, because users should encode large JaccardHashing objects with EncodeJaccardHashing.
 func (hashing *JaccardHashing) GobEncode() ([]byte, error) {
 	return nil, fmt.Errorf("JaccardHashing should be encoded with EncodeJaccardHashing")
@@ -125,19 +111,30 @@ func (hashing *JaccardHashing) GobEncode() ([]byte, error) {
 
 // FindNears returns the histogram of the neighbors from the given feature vector.
 func (hashing *JaccardHashing) FindNears(vec FeatureVector) KeyCountMap32 {
-	hvec := hashing.Hash(vec)
-	nears := NewKeyCountMap32(hashing.K() * hashing.R())
-	for k, hveck := range hvec {
-		for _, idx := range hashing.tables[k][hveck] {
-			nears.Inc(idx)
-		}
+	return hashing.findNears(hashing.Hash(vec))
+}
+
+// FindNearsWithProbes is FindNears probing at most probes buckets in each hash table (see HashWithProbes).
+// Only the exact bucket is probed as FindNears if probes is less than 2.
+// Each index is counted at most once in each hash table, because it is in exactly one bucket of the hash table.
+func (hashing *JaccardHashing) FindNearsWithProbes(vec FeatureVector, probes uint) KeyCountMap32 {
+	if probes < 2 {
+		return hashing.FindNears(vec)
 	}
-	return nears
+	return hashing.findNearsWithProbes(hashing.HashWithProbes(vec, probes), probes)
 }
 
 // Hash returns the K hashed values of the given feature vector.
+// The weighted JaccardHashing ignores the features whose values are not positive.
 func (hashing *JaccardHashing) Hash(vec FeatureVector) []uint32 {
 	K, L := hashing.K(), hashing.L()
+	if hashing.weighted {
+		H := make([]uint32, K)
+		for k, hvecks := range hashing.hashICWS(vec, 1) {
+			H[k] = hvecks[0]
+		}
+		return H
+	}
 	if len(vec) == 0 {
 		// Adhoc solution.
 		return make([]uint32, K)
@@ -169,39 +166,137 @@ func (hashing *JaccardHashing) Hash(vec FeatureVector) []uint32 {
 	return H
 }
 
-// K returns the number of the hash tables.
-func (hashing *JaccardHashing) K() uint {
-	return uint(len(hashing.tables))
+// HashWithProbes returns at most probes hashed values of the given feature vector for each hash table, which are used for multi-probe querying.
+// The first value of each hash table is the one returned by Hash, and the following values are the next smallest hashed values in the same bin.
+// Thus, the i-th value is the hashed value of the feature vector without the features having the smaller hashed values, so the bucket of the i-th value has the entries similar to the feature vector.
+// The hash table whose bin has only a few hashed values has fewer values.
+// The weighted JaccardHashing returns the hashed values of the samples having the smallest ICWS values in the same way.
+func (hashing *JaccardHashing) HashWithProbes(vec FeatureVector, probes uint) [][]uint32 {
+	K, L := hashing.K(), hashing.L()
+	if hashing.weighted {
+		return hashing.hashICWS(vec, probes)
+	}
+	if probes < 2 || len(vec) == 0 {
+		H := make([][]uint32, K)
+		for k, hveck := range hashing.Hash(vec) {
+			H[k] = []uint32{hveck}
+		}
+		return H
+	}
+	shiftL := 32 - L
+	binSize := uint32(((1 << L) + (K - 1)) / K)
+	// H0[id*probes:id*probes+nH0[id]] is the sorted smallest hashed values in the id-th bin.
+	H0, nH0 := make([]uint32, K*probes), make([]uint, K)
+	for _, vecpair := range vec {
+		key := vecpair.Key
+		h := HashUint32(key) >> shiftL
+		id := h / binSize
+		values := H0[uint(id)*probes : uint(id)*probes+nH0[id]]
+		pos := uint(len(values))
+		for pos > 0 && values[pos-1] > h {
+			pos--
+		}
+		if (pos > 0 && values[pos-1] == h) || pos == probes {
+			continue
+		}
+		if nH0[id] < probes {
+			nH0[id]++
+		}
+		values = H0[uint(id)*probes : uint(id)*probes+nH0[id]]
+		copy(values[pos+1:], values[pos:])
+		values[pos] = h
+	}
+	H := make([][]uint32, K)
+	for k := range H {
+		id := uint32(k)
+		if nH0[id] == 0 {
+			for count := uint32(0); nH0[id] == 0; count++ {
+				id = (HashUint32((uint32(k)<<8)+count) >> shiftL) / binSize
+			}
+		}
+		H[k] = H0[uint(id)*probes : uint(id)*probes+nH0[id]]
+	}
+	return H
+}
+
+// hashICWS returns at most probes hashed values of the samples having the smallest ICWS values of the given feature vector for each hash table (Ioffe 2010).
+// The sample of each hash table is the pair of the feature and the quantized level of its value, so the probability of the same sample of two feature vectors is their weighted Jaccard similarity.
+func (hashing *JaccardHashing) hashICWS(vec FeatureVector, probes uint) [][]uint32 {
+	K, L := hashing.K(), hashing.L()
+	if probes < 1 {
+		probes = 1
+	}
+	shiftL := 32 - L
+	H := make([][]uint32, K)
+	lnas := make([]float32, 0, probes)
+	for k := range H {
+		hveck := make([]uint32, 0, probes)
+		lnas = lnas[:0]
+		for _, vecpair := range vec {
+			key, value := vecpair.Key, vecpair.Value
+			if value <= 0.0 {
+				continue
+			}
+			r := -Log32(icwsUniform(uint32(k), key, 0) * icwsUniform(uint32(k), key, 1))
+			c := -Log32(icwsUniform(uint32(k), key, 2) * icwsUniform(uint32(k), key, 3))
+			beta := icwsUniform(uint32(k), key, 4)
+			t := Floor32(Log32(value)/r + beta)
+			lna := Log32(c) - r*(t-beta) - r
+			pos := len(lnas)
+			for pos > 0 && lnas[pos-1] > lna {
+				pos--
+			}
+			if uint(pos) == probes {
+				continue
+			}
+			if uint(len(lnas)) < probes {
+				lnas, hveck = append(lnas, 0), append(hveck, 0)
+			}
+			copy(lnas[pos+1:], lnas[pos:])
+			copy(hveck[pos+1:], hveck[pos:])
+			// The quantized levels are spread by the golden ratio, because HashUint32 does not separate the adjacent keys well in the higher bits.
+			lnas[pos], hveck[pos] = lna, HashUint32(HashUint32(key)+0x9e3779b9*uint32(int32(t)))>>shiftL
+		}
+		if len(hveck) == 0 {
+			// Adhoc solution as Hash.
+			hveck = append(hveck, 0)
+		}
+		H[k] = hveck
+	}
+	return H
 }
 
-// L returns the bit-width for backet indices in each hash table.
-func (hashing *JaccardHashing) L() uint {
-	return uint(bits.Len(uint(len(hashing.tables[0]))) - 1)
+// icwsUniform returns the uniform random number in (0, 1) determined by the hash table k, the feature key and the index i in [0, 8).
+func icwsUniform(k, key, i uint32) float32 {
+	h := HashUint32(HashUint32(key) ^ (k<<3 | i))
+	return (float32(h>>8) + 0.5) / float32(1<<24)
 }
 
-// R returns the size of a reservoir of each backet.
-func (hashing *JaccardHashing) R() uint {
-	return hashing._R
+// Name returns "jaccard".
+func (hashing *JaccardHashing) Name() string {
+	return "jaccard"
 }
 
-// ResetRng resets the internal random number generator.
-func (hashing *JaccardHashing) ResetRng() {
-	hashing.rng = rand.New(rand.NewSource(0))
+// Reindex changes the index oldIdx of the given feature vector to newIdx in the hash tables.
+func (hashing *JaccardHashing) Reindex(vec FeatureVector, oldIdx, newIdx uint32) {
+	hashing.reindex(hashing.Hash(vec), oldIdx, newIdx)
 }
 
-// Summary returns the slice of bucket usage and the bucket size (size of each reservoir) histogram.
-func (hashing *JaccardHashing) Summary() (backetUsage []int, backetHist []int) {
-	backetUsage = make([]int, len(hashing.tables))
-	backetHist = make([]int, hashing._R)
-	for t, tblk := range hashing.tables {
-		count := 0
-		for _, entry := range tblk {
-			if len(entry) > 0 {
-				backetHist[len(entry)-1]++
-				count++
-			}
-		}
-		backetUsage[t] = count
+// Remove removes the given feature vector added as i-th index from the hash tables.
+// The reservoir of each bucket stays the uniform sample of the indices in the bucket, because the deletions are compensated by the following additions (see Add).
+func (hashing *JaccardHashing) Remove(vec FeatureVector, i uint32) {
+	hashing.remove(hashing.Hash(vec), i)
+}
+
+// String returns the name with K, L and R.
+func (hashing *JaccardHashing) String() string {
+	if hashing.weighted {
+		return fmt.Sprintf("jaccard(K=%d,L=%d,R=%d,weighted)", hashing.K(), hashing.L(), hashing.R())
 	}
-	return
+	return fmt.Sprintf("jaccard(K=%d,L=%d,R=%d)", hashing.K(), hashing.L(), hashing.R())
+}
+
+// Weighted returns true if the hashing is the weighted JaccardHashing.
+func (hashing *JaccardHashing) Weighted() bool {
+	return hashing.weighted
 }
@@ -90,3 +90,131 @@ func TestJaccardHashing(t *testing.T) {
 	goassert.New(t, []uint32{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}).Equal(hashing0.Hash(FeatureVector{}))
 	goassert.New(t, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, []int{16, 0, 0, 0, 0, 0, 0, 0}).Equal(hashing0.Summary())
 }
+
+func TestJaccardHashingRemove(t *testing.T) {
+	x1 := FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}}
+	x2 := FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}, KeyValue32{5, 1.0}, KeyValue32{6, 1.0}}
+	hashing := NewJaccardHashing(16, 10, 2)
+	for i := uint32(0); i < 4; i++ {
+		hashing.Add(x1, i)
+	}
+	hashing.Add(x2, 4)
+	_, bucketSizeHist := hashing.Summary()
+	goassert.New(t, []int{10, 16}).Equal(bucketSizeHist)
+	tableHits := make([]uint32, hashing.K())
+	for k, hveck := range hashing.Hash(x1) {
+		tableHits[k] = hashing.tableHits[k][hveck]
+	}
+	// The removed index should not be found, and the deletions should be counted.
+	hashing.Remove(x2, 4)
+	_, found := hashing.FindNears(x1).Map()[4]
+	goassert.New(t, false).Equal(found)
+	for k, hveck := range hashing.Hash(x2) {
+		goassert.New(t, false).Equal(hashing.deletions[uint64(k)<<32|uint64(hveck)] == [2]uint32{})
+	}
+	hashing.Remove(x1, 0)
+	hx2 := hashing.Hash(x2)
+	for k, hveck := range hashing.Hash(x1) {
+		if hveck == hx2[k] {
+			goassert.New(t, tableHits[k]-2).Equal(hashing.tableHits[k][hveck])
+		} else {
+			goassert.New(t, tableHits[k]-1).Equal(hashing.tableHits[k][hveck])
+		}
+	}
+	// The deletions should be encoded with the hash tables.
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeJaccardHashing(hashing, &buf))
+	var decodedHashing JaccardHashing
+	goassert.New(t).SucceedWithoutError(DecodeJaccardHashing(&decodedHashing, &buf))
+	goassert.New(t, hashing.deletions, hashing._R).Equal(decodedHashing.deletions, decodedHashing._R)
+	// The following additions should compensate the deletions, so the reservoirs should be refilled.
+	hashing.Add(x1, 5)
+	hashing.Add(x2, 6)
+	goassert.New(t, map[uint64][2]uint32(nil)).Equal(hashing.deletions)
+	_, bucketSizeHist = hashing.Summary()
+	goassert.New(t, []int{10, 16}).Equal(bucketSizeHist)
+	for k, hveck := range hashing.Hash(x1) {
+		goassert.New(t, tableHits[k]).Equal(hashing.tableHits[k][hveck])
+		for _, idx := range hashing.tables[k][hveck] {
+			goassert.New(t, true).Equal(idx != 0 && idx != 4)
+		}
+	}
+	// The reindexed index should be found.
+	count := hashing.FindNears(x2).Map()[6]
+	hashing.Reindex(x2, 6, 0)
+	goassert.New(t, uint32(0), count).Equal(hashing.FindNears(x2).Map()[6], hashing.FindNears(x2).Map()[0])
+}
+
+func TestJaccardHashingMultiProbe(t *testing.T) {
+	hashing := NewJaccardHashing(16, 10, 8)
+	x := FeatureVector{}
+	for key := uint32(0); key < 64; key++ {
+		x = append(x, KeyValue32{key, 1.0})
+	}
+	H, H1, H3 := hashing.Hash(x), hashing.HashWithProbes(x, 1), hashing.HashWithProbes(x, 3)
+	for k, hvecks := range H3 {
+		goassert.New(t, []uint32{H[k]}).Equal(H1[k])
+		goassert.New(t, true).Equal(len(hvecks) >= 1 && len(hvecks) <= 3)
+		goassert.New(t, H[k]).Equal(hvecks[0])
+		for j := 1; j < len(hvecks); j++ {
+			goassert.New(t, true).Equal(hvecks[j-1] < hvecks[j])
+		}
+	}
+	goassert.New(t, [][]uint32{{0}, {0}}).Equal(NewJaccardHashing(2, 10, 8).HashWithProbes(FeatureVector{}, 3))
+	// The entry without the feature having the smallest hashed value in the first bin should be found only by multi-probe querying.
+	xnear := FeatureVector{}
+	for _, xpair := range x {
+		if HashUint32(xpair.Key)>>(32-hashing.L()) != H[0] {
+			xnear = append(xnear, xpair)
+		}
+	}
+	hashing.Add(xnear, 0)
+	goassert.New(t, H3[0][1]).Equal(hashing.Hash(xnear)[0])
+	exactCount, probedCount := hashing.FindNears(x).Map()[0], hashing.FindNearsWithProbes(x, 3).Map()[0]
+	goassert.New(t, true).Equal(exactCount < probedCount)
+	goassert.New(t, uint32(16)).Equal(probedCount)
+}
+
+func TestJaccardHashingWeighted(t *testing.T) {
+	countMatches := func(hashing *JaccardHashing, x, y FeatureVector) int {
+		hx, hy := hashing.Hash(x), hashing.Hash(y)
+		count := 0
+		for k := range hx {
+			if hx[k] == hy[k] {
+				count++
+			}
+		}
+		return count
+	}
+	x := FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}, KeyValue32{3, 2.0}}
+	y := FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}, KeyValue32{3, 1.0}}
+	// DOPH ignores the feature values, so x and y are always collided.
+	goassert.New(t, 4096).Equal(countMatches(NewJaccardHashing(4096, 10, 8), x, y))
+	// The weighted Jaccard similarity of x and y is 3/4.
+	hashing := NewWeightedJaccardHashing(4096, 10, 8)
+	goassert.New(t, true).Equal(hashing.Weighted())
+	goassert.New(t, false).Equal(NewJaccardHashing(16, 10, 8).Weighted())
+	goassert.New(t, 3063).Equal(countMatches(hashing, x, y)) // Answer: 3072
+	goassert.New(t, 4096).Equal(countMatches(hashing, x, x))
+	// The weighted Jaccard similarity of x and the half of x is 1/2, so the feature vectors should be normalized before hashing.
+	goassert.New(t, 2056).Equal(countMatches(hashing, x, FeatureVector{KeyValue32{1, 0.5}, KeyValue32{2, 0.5}, KeyValue32{3, 1.0}})) // Answer: 2048
+	// The non-positive feature values should be ignored.
+	goassert.New(t, 4096).Equal(countMatches(hashing, y, FeatureVector{KeyValue32{0, -1.0}, KeyValue32{1, 1.0}, KeyValue32{2, 1.0}, KeyValue32{3, 1.0}, KeyValue32{4, 0.0}}))
+	goassert.New(t, []uint32{0, 0}).Equal(NewWeightedJaccardHashing(2, 10, 8).Hash(FeatureVector{}))
+	// The first probe should be the hashed value of Hash.
+	H, H3 := hashing.Hash(x), hashing.HashWithProbes(x, 3)
+	for k, hvecks := range H3 {
+		goassert.New(t, true).Equal(len(hvecks) >= 1 && len(hvecks) <= 3)
+		goassert.New(t, H[k]).Equal(hvecks[0])
+	}
+	// Check gob encoding/decoding keeps the weighted hashing.
+	hashing.Add(x, 0)
+	hashing.Add(y, 1)
+	goassert.New(t, map[uint32]uint32{0: 4096, 1: 3063}).Equal(hashing.FindNears(x).Map())
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeJaccardHashing(hashing, &buf))
+	var decodedHashing JaccardHashing
+	goassert.New(t).SucceedWithoutError(DecodeJaccardHashing(&decodedHashing, &buf))
+	goassert.New(t, hashing).Equal(&decodedHashing)
+	goassert.New(t, uint(8)).Equal(decodedHashing.R())
+}
@@ -12,6 +12,10 @@ type LabelConst struct {
 	// LabelList and LabelFreqList are the label and its frequency list in descending order in the training set occurrences.
 	LabelList     LabelVector
 	LabelFreqList []float32
+	// The following members are not required.
+	//
+	// FeatureVocabulary and LabelVocabulary are the vocabularies of the features and labels in training, or nil if unknown.
+	FeatureVocabulary, LabelVocabulary *Vocabulary
 }
 
 // TrainLabelConst returns an trained LabelConst on the given dataset ds.
@@ -43,6 +47,10 @@ func DecodeLabelConstWithGobDecoder(model *LabelConst, decoder *gob.Decoder) err
 	if err := decoder.Decode(&model.LabelFreqList); err != nil {
 		return fmt.Errorf("DecodeLabelBoost: LabelFreqList: %s", err)
 	}
+	var err error
+	if model.FeatureVocabulary, model.LabelVocabulary, err = DecodeModelVocabulariesWithGobDecoder(decoder); err != nil {
+		return fmt.Errorf("DecodeLabelConst: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -65,6 +73,9 @@ func EncodeLabelConstWithGobEncoder(model *LabelConst, encoder *gob.Encoder) err
 	if err := encoder.Encode(model.LabelFreqList); err != nil {
 		return fmt.Errorf("EncodeLabelBoost: LabelFreqList: %s", err)
 	}
+	if err := EncodeModelVocabulariesWithGobEncoder(model.FeatureVocabulary, model.LabelVocabulary, encoder); err != nil {
+		return fmt.Errorf("EncodeLabelConst: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -101,3 +112,48 @@ func (model *LabelConst) PredictAll(X FeatureVectors, K uint) LabelVectors {
 	}
 	return Y
 }
+
+// PredictAllWithScores returns the top-K labels with their frequencies in the training dataset for each data entry in X.
+func (model *LabelConst) PredictAllWithScores(X FeatureVectors, K uint) []KeyValues32 {
+	Kmax := K
+	if Kmax > uint(len(model.LabelList)) {
+		Kmax = uint(len(model.LabelList))
+	}
+	labelScores := make(KeyValues32, Kmax)
+	for rank := range labelScores {
+		labelScores[rank] = KeyValue32{model.LabelList[rank], model.LabelFreqList[rank]}
+	}
+	Yhat := make([]KeyValues32, 0, len(X))
+	for range X {
+		yihat := make(KeyValues32, Kmax)
+		copy(yihat, labelScores)
+		Yhat = append(Yhat, yihat)
+	}
+	return Yhat
+}
+
+// LabelConstPredictor is the Predictor of LabelConst.
+type LabelConstPredictor struct {
+	Model *LabelConst
+}
+
+// NewLabelConstPredictor returns a new LabelConstPredictor.
+func NewLabelConstPredictor(model *LabelConst) *LabelConstPredictor {
+	return &LabelConstPredictor{
+		Model: model,
+	}
+}
+
+// Predict returns the top-K labels with their frequencies in the training dataset, which are independent of x.
+func (predictor *LabelConstPredictor) Predict(x FeatureVector, K uint) ([]LabelScore, error) {
+	model := predictor.Model
+	Kmax := K
+	if Kmax > uint(len(model.LabelList)) {
+		Kmax = uint(len(model.LabelList))
+	}
+	labelScores := make([]LabelScore, Kmax)
+	for rank := range labelScores {
+		labelScores[rank] = LabelScore{model.LabelList[rank], model.LabelFreqList[rank]}
+	}
+	return labelScores, nil
+}
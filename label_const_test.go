@@ -28,6 +28,11 @@ func TestTrainLabelConst(t *testing.T) {
 		Yhat[2*i], Yhat[2*i+1] = LabelVector{1, 0, 2, ^uint32(0)}, LabelVector{1, 0, 2, ^uint32(0)}
 	}
 	goassert.New(t, Yhat).Equal(model.PredictAll(ds.X, 4))
+	YhatWithScores := make([]KeyValues32, 2*n)
+	for i := range YhatWithScores {
+		YhatWithScores[i] = KeyValues32{KeyValue32{1, 200}, KeyValue32{0, 100}}
+	}
+	goassert.New(t, YhatWithScores).Equal(model.PredictAllWithScores(ds.X, 2))
 	// Test encoder/decoder.
 	var buf bytes.Buffer
 	goassert.New(t, "LabelConst should be encoded with EncodeLabelConst").ExpectError(gob.NewEncoder(&buf).Encode(model))
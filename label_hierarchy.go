@@ -0,0 +1,196 @@
+package sticker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LabelHierarchy is the taxonomy of the labels represented as the directed acyclic graph from each parent label to its child labels.
+// Each label can have multiple parents, and the label without parent is a root.
+type LabelHierarchy struct {
+	parents map[uint32]LabelVector
+}
+
+// NewLabelHierarchy returns a new empty LabelHierarchy.
+func NewLabelHierarchy() *LabelHierarchy {
+	return &LabelHierarchy{
+		parents: make(map[uint32]LabelVector),
+	}
+}
+
+// ReadLabelHierarchy returns a new LabelHierarchy from reader having the space-separated parent and child label pair per line.
+// The empty lines are ignored.
+//
+// This function returns an error in reading or parsing, or if the pairs make a cycle.
+func ReadLabelHierarchy(reader io.Reader) (*LabelHierarchy, error) {
+	br := bufio.NewReader(reader)
+	hierarchy := NewLabelHierarchy()
+	for lineno := 1; ; lineno++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("L%d: cannot read line", lineno)
+		}
+		if cells := strings.Fields(line); len(cells) > 0 {
+			if len(cells) != 2 {
+				return nil, fmt.Errorf("L%d: illegal parent and child label pair", lineno)
+			}
+			var labels [2]uint32
+			for j, cell := range cells {
+				label, err := strconv.ParseUint(cell, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("L%d: illegal label %q", lineno, cell)
+				}
+				labels[j] = uint32(label)
+			}
+			if err := hierarchy.AddEdge(labels[0], labels[1]); err != nil {
+				return nil, fmt.Errorf("L%d: %s", lineno, err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return hierarchy, nil
+}
+
+// AddEdge adds the edge from parent to child.
+// The existing edge is ignored.
+//
+// This function returns an error if the edge makes a cycle.
+func (hierarchy *LabelHierarchy) AddEdge(parent, child uint32) error {
+	if parent == child {
+		return fmt.Errorf("label %d cannot be the parent of itself", child)
+	}
+	for _, label := range hierarchy.parents[child] {
+		if label == parent {
+			return nil
+		}
+	}
+	for _, label := range hierarchy.Ancestors(parent) {
+		if label == child {
+			return fmt.Errorf("edge from %d to %d makes a cycle", parent, child)
+		}
+	}
+	parents := append(hierarchy.parents[child], parent)
+	sort.Slice(parents, func(i, j int) bool {
+		return parents[i] < parents[j]
+	})
+	hierarchy.parents[child] = parents
+	return nil
+}
+
+// Ancestors returns the ancestors of label in ascending order.
+func (hierarchy *LabelHierarchy) Ancestors(label uint32) LabelVector {
+	visited := make(map[uint32]bool)
+	stack := append(LabelVector{}, hierarchy.parents[label]...)
+	for len(stack) > 0 {
+		label, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		if !visited[label] {
+			visited[label] = true
+			stack = append(stack, hierarchy.parents[label]...)
+		}
+	}
+	return LabelVector(sortedKeys(visited))
+}
+
+// Closure returns the ancestor closure of y, which is the labels in y and their ancestors in ascending order.
+// The padding label ^uint32(0) is ignored.
+func (hierarchy *LabelHierarchy) Closure(y LabelVector) LabelVector {
+	labelSet := make(map[uint32]bool, len(y))
+	for _, label := range y {
+		if label == ^uint32(0) || labelSet[label] {
+			continue
+		}
+		labelSet[label] = true
+		for _, ancestor := range hierarchy.Ancestors(label) {
+			labelSet[ancestor] = true
+		}
+	}
+	return LabelVector(sortedKeys(labelSet))
+}
+
+// ClosureAll returns the ancestor closure of each label vector in Y.
+func (hierarchy *LabelHierarchy) ClosureAll(Y LabelVectors) LabelVectors {
+	closures := make(LabelVectors, len(Y))
+	for i, yi := range Y {
+		closures[i] = hierarchy.Closure(yi)
+	}
+	return closures
+}
+
+// ConsistentRanks returns the top-K labels of the ranking list labelRanks made consistent with the hierarchy.
+// Each label is preceded by its ancestors, so the missing ancestors are inserted just before the label, and the labels already ranked are skipped.
+// The returned label vector is padded with ^uint32(0) like RankTopK.
+func (hierarchy *LabelHierarchy) ConsistentRanks(labelRanks LabelVector, K uint) LabelVector {
+	y := make(LabelVector, 0, K)
+	ranked := make(map[uint32]bool, K)
+	var rank func(label uint32)
+	rank = func(label uint32) {
+		for _, parent := range hierarchy.parents[label] {
+			if !ranked[parent] {
+				rank(parent)
+			}
+		}
+		if uint(len(y)) < K {
+			y = append(y, label)
+		}
+		ranked[label] = true
+	}
+	for _, label := range labelRanks {
+		if uint(len(y)) >= K {
+			break
+		}
+		if label != ^uint32(0) && !ranked[label] {
+			rank(label)
+		}
+	}
+	for uint(len(y)) < K {
+		y = append(y, ^uint32(0))
+	}
+	return y
+}
+
+// ConsistentRanksAll returns the label vectors in Yhat made consistent with the hierarchy by ConsistentRanks without changing their lengths.
+func (hierarchy *LabelHierarchy) ConsistentRanksAll(Yhat LabelVectors) LabelVectors {
+	newYhat := make(LabelVectors, len(Yhat))
+	for i, yihat := range Yhat {
+		newYhat[i] = hierarchy.ConsistentRanks(yihat, uint(len(yihat)))
+	}
+	return newYhat
+}
+
+// Parents returns the parents of label in ascending order.
+// The returned slice must not be modified.
+func (hierarchy *LabelHierarchy) Parents(label uint32) LabelVector {
+	return hierarchy.parents[label]
+}
+
+// PropagateScores returns the label distribution whose score of each label is the maximum of its own score and the scores of its descendants in labelDist.
+// Thus, each label has the score at least the ones of its descendants.
+func (hierarchy *LabelHierarchy) PropagateScores(labelDist SparseVector) SparseVector {
+	newLabelDist := make(SparseVector, len(labelDist))
+	for label, score := range labelDist {
+		if oldScore, ok := newLabelDist[label]; !ok || oldScore < score {
+			newLabelDist[label] = score
+		}
+		for _, ancestor := range hierarchy.Ancestors(label) {
+			if oldScore, ok := newLabelDist[ancestor]; !ok || oldScore < score {
+				newLabelDist[ancestor] = score
+			}
+		}
+	}
+	return newLabelDist
+}
+
+// Size returns the number of the edges.
+func (hierarchy *LabelHierarchy) Size() int {
+	size := 0
+	for _, parents := range hierarchy.parents {
+		size += len(parents)
+	}
+	return size
+}
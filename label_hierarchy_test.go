@@ -0,0 +1,60 @@
+package sticker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+// newTestLabelHierarchy returns the hierarchy: 0 -> {1, 2}, 1 -> 3, {2, 4} -> 5.
+func newTestLabelHierarchy(t *testing.T) *LabelHierarchy {
+	t.Helper()
+	return goassert.New(t).SucceedNew(ReadLabelHierarchy(strings.NewReader("0 1\n0 2\n\n1 3\r\n4 5\n2 5"))).(*LabelHierarchy)
+}
+
+func TestReadLabelHierarchy(t *testing.T) {
+	hierarchy := newTestLabelHierarchy(t)
+	goassert.New(t, 5).Equal(hierarchy.Size())
+	goassert.New(t, LabelVector{2, 4}).Equal(hierarchy.Parents(5))
+	goassert.New(t, LabelVector(nil)).Equal(hierarchy.Parents(0))
+	goassert.New(t, NewLabelHierarchy()).EqualWithoutError(ReadLabelHierarchy(strings.NewReader("")))
+	goassert.New(t, "L2: illegal parent and child label pair").ExpectError(ReadLabelHierarchy(strings.NewReader("0 1\n0 1 2\n")))
+	goassert.New(t, "L1: illegal label \"x\"").ExpectError(ReadLabelHierarchy(strings.NewReader("0 x\n")))
+	goassert.New(t, "L1: label 1 cannot be the parent of itself").ExpectError(ReadLabelHierarchy(strings.NewReader("1 1\n")))
+	goassert.New(t, "L3: edge from 2 to 0 makes a cycle").ExpectError(ReadLabelHierarchy(strings.NewReader("0 1\n1 2\n2 0\n")))
+	// The existing edge should be ignored.
+	goassert.New(t).SucceedWithoutError(hierarchy.AddEdge(0, 1))
+	goassert.New(t, 5).Equal(hierarchy.Size())
+}
+
+func TestLabelHierarchyClosure(t *testing.T) {
+	hierarchy := newTestLabelHierarchy(t)
+	goassert.New(t, LabelVector{}).Equal(hierarchy.Ancestors(0))
+	goassert.New(t, LabelVector{0, 1}).Equal(hierarchy.Ancestors(3))
+	goassert.New(t, LabelVector{0, 2, 4}).Equal(hierarchy.Ancestors(5))
+	goassert.New(t, LabelVectors{
+		LabelVector{0, 1, 3, 9},
+		LabelVector{0, 2, 4, 5},
+		LabelVector{},
+	}).Equal(hierarchy.ClosureAll(LabelVectors{
+		LabelVector{9, 3, 1},
+		LabelVector{5, ^uint32(0)},
+		LabelVector{},
+	}))
+}
+
+func TestLabelHierarchyConsistentRanks(t *testing.T) {
+	hierarchy := newTestLabelHierarchy(t)
+	goassert.New(t, LabelVector{0, 1, 3, 9}).Equal(hierarchy.ConsistentRanks(LabelVector{3, 9, 1, 0}, 4))
+	goassert.New(t, LabelVector{0, 2, 4}).Equal(hierarchy.ConsistentRanks(LabelVector{5, 9}, 3))
+	goassert.New(t, LabelVector{9, 0, 1, ^uint32(0)}).Equal(hierarchy.ConsistentRanks(LabelVector{9, 1, ^uint32(0)}, 4))
+	goassert.New(t, LabelVectors{
+		LabelVector{0, 1},
+		LabelVector{},
+	}).Equal(hierarchy.ConsistentRanksAll(LabelVectors{
+		LabelVector{3, 1},
+		LabelVector{},
+	}))
+	goassert.New(t, SparseVector{0: 3, 1: 3, 2: 2, 3: 3, 4: 2, 5: 2}).Equal(hierarchy.PropagateScores(SparseVector{0: 1, 2: 2, 3: 3, 5: 2}))
+}
@@ -9,47 +9,91 @@ import (
 
 // LabelNearParameters is the parameters for LabelNear.
 type LabelNearParameters struct {
+	// Hashing is the name of NearIndex used for sieving the candidates: "jaccard" (JaccardHashing), "simhash" (SimHashing) or "hnsw" (HNSW).
+	Hashing string
+	// EfConstruction is the size of the dynamic candidate list in adding for "hnsw".
+	EfConstruction uint
 	// K is the number of the hash tables.
 	K uint
 	// L is the bit-width of bucket indices in each hash table.
 	L uint
+	// M is the number of the neighbors of each added node in each layer for "hnsw".
+	M uint
 	// R is the size of a reservoir of each bucket.
 	R uint
+	// Weighted is true if the weighted JaccardHashing using ICWS is used for "jaccard", which sieves the candidates by the weighted Jaccard similarity of the normalized feature vectors.
+	Weighted bool
 }
 
 // NewLabelNearParameters returns an new default LabelNearParameters.
 func NewLabelNearParameters() *LabelNearParameters {
 	return &LabelNearParameters{
-		K: 64,
-		L: 16,
-		R: 64,
+		Hashing:        "jaccard",
+		EfConstruction: 100,
+		K:              64,
+		L:              16,
+		M:              16,
+		R:              64,
+		Weighted:       false,
 	}
 }
 
 // LabelNear is a faster implementation of LabelNearest which uses the optimal Densified One Permutation Hashing (DOPH) and the reservoir sampling (Wang+ 2017).
+// The index for sieving the candidates can be also SimHashing for cosine similarity, or HNSW searching the neighbor graph of the entries (see LabelNearParameters.Hashing).
+// The entries can be added and removed without re-training (see Add and Remove), but these mutations must not be done concurrently with the inference.
 //
 // References:
 //
 // (Wang+ 2017) Y. Wang, A. Shrivastava, and J. Ryu. "FLASH: Randomized Algorithms Accelerated over CPU-GPU for Ultra-High Dimensional Similarity Search." arXiv preprint arXiv:1709.01190, 2017.
 type LabelNear struct {
 	// Dataset is the training dataset.
+	// The weights of the entries in Dataset are multiplied to their votes.
 	Dataset *Dataset
-	// Hashing is the Jaccard hashing.
-	Hashing *JaccardHashing
+	// Hashing is the index for sieving the candidates of the near entries.
+	Hashing NearIndex
+	// The following members are not required.
+	//
+	// FeatureVocabulary and LabelVocabulary are the vocabularies of the features and labels in training, or nil if unknown.
+	FeatureVocabulary, LabelVocabulary *Vocabulary
 }
 
 // TrainLabelNear returns an trained LabelNear on the given training dataset ds.
+// The weights of the entries in ds are used for weighting their votes.
 //
-// Currently, this function returns no error.
+// This function returns an error if params.Hashing is unknown, or params.M is less than 2 or params.EfConstruction is 0 for hnsw.
 func TrainLabelNear(ds *Dataset, params *LabelNearParameters, debug *log.Logger) (*LabelNear, error) {
 	newds := &Dataset{
 		X: make(FeatureVectors, len(ds.X)),
 		Y: make(LabelVectors, len(ds.Y)),
 	}
+	if ds.W != nil {
+		newds.W = make([]float32, len(ds.W))
+		copy(newds.W, ds.W)
+	}
+	var hashing NearIndex
+	switch params.Hashing {
+	case "jaccard":
+		if params.Weighted {
+			hashing = NewWeightedJaccardHashing(params.K, params.L, params.R)
+		} else {
+			hashing = NewJaccardHashing(params.K, params.L, params.R)
+		}
+	case "simhash":
+		hashing = NewSimHashing(params.K, params.L, params.R)
+	case "hnsw":
+		if params.M < 2 {
+			return nil, fmt.Errorf("TrainLabelNear: M must be at least 2 for hnsw")
+		}
+		if params.EfConstruction < 1 {
+			return nil, fmt.Errorf("TrainLabelNear: efConstruction must be positive for hnsw")
+		}
+		hashing = NewHNSW(params.M, params.EfConstruction, newds)
+	default:
+		return nil, fmt.Errorf("TrainLabelNear: unknown hashing %q", params.Hashing)
+	}
 	if debug != nil {
-		debug.Printf("constructing JaccardHashing ...")
+		debug.Printf("constructing NearIndex %q ...", hashing.Name())
 	}
-	hashing := NewJaccardHashing(params.K, params.L, params.R)
 	for i, xi := range ds.X {
 		yi := ds.Y[i]
 		lenxi := float32(0.0)
@@ -64,12 +108,12 @@ func TrainLabelNear(ds *Dataset, params *LabelNearParameters, debug *log.Logger)
 		newyi := make(LabelVector, len(yi))
 		copy(newyi, yi)
 		newds.X[i], newds.Y[i] = newxi, newyi
-		hashing.Add(xi, uint32(i))
+		hashing.Add(newxi, uint32(i))
 	}
 	if debug != nil {
 		bucketUsage, bucketSizeHist := hashing.Summary()
-		debug.Printf("JaccardHashing(K=%d,L=%d,R=%d): bucketUsage=%d", hashing.K(), hashing.L(), hashing.R(), bucketUsage)
-		debug.Printf("JaccardHashing(K=%d,L=%d,R=%d): bucketSizeHist=%d", hashing.K(), hashing.L(), hashing.R(), bucketSizeHist)
+		debug.Printf("%s: bucketUsage=%d", hashing, bucketUsage)
+		debug.Printf("%s: bucketSizeHist=%d", hashing, bucketSizeHist)
 	}
 	return &LabelNear{
 		Dataset: newds,
@@ -85,10 +129,17 @@ func DecodeLabelNearWithGobDecoder(model *LabelNear, decoder *gob.Decoder) error
 	if err := DecodeDatasetWithGobDecoder(model.Dataset, decoder); err != nil {
 		return fmt.Errorf("DecodeLabelNear: Dataset: %s", err)
 	}
-	model.Hashing = &JaccardHashing{}
-	if err := DecodeJaccardHashingWithGobDecoder(model.Hashing, decoder); err != nil {
+	var err error
+	if model.Hashing, err = DecodeNearIndexWithGobDecoder(decoder); err != nil {
 		return fmt.Errorf("DecodeLabelNear: Hashing: %s", err)
 	}
+	// HNSW resolves the feature vectors by Dataset, which is not encoded with HNSW.
+	if index, ok := model.Hashing.(*HNSW); ok {
+		index.SetDataset(model.Dataset)
+	}
+	if model.FeatureVocabulary, model.LabelVocabulary, err = DecodeModelVocabulariesWithGobDecoder(decoder); err != nil {
+		return fmt.Errorf("DecodeLabelNear: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -108,9 +159,12 @@ func EncodeLabelNearWithGobEncoder(model *LabelNear, encoder *gob.Encoder) error
 	if err := EncodeDatasetWithGobEncoder(model.Dataset, encoder); err != nil {
 		return fmt.Errorf("EncodeLabelNear: Dataset: %s", err)
 	}
-	if err := EncodeJaccardHashingWithGobEncoder(model.Hashing, encoder); err != nil {
+	if err := model.Hashing.Encode(encoder); err != nil {
 		return fmt.Errorf("EncodeLabelNear: Hashing: %s", err)
 	}
+	if err := EncodeModelVocabulariesWithGobEncoder(model.FeatureVocabulary, model.LabelVocabulary, encoder); err != nil {
+		return fmt.Errorf("EncodeLabelNear: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -128,12 +182,86 @@ func (model *LabelNear) GobEncode() ([]byte, error) {
 	return nil, fmt.Errorf("LabelNear should be encoded with EncodeLabelNear")
 }
 
+// Add adds the entry with the feature vector x and the label vector y to Dataset and Hashing, and returns the index of the added entry.
+// The weight of the added entry is 1.0 (see AddWithWeight).
+func (model *LabelNear) Add(x FeatureVector, y LabelVector) uint32 {
+	return model.AddWithWeight(x, y, 1.0)
+}
+
+// AddWithWeight is Add with the weight of the added entry multiplied to its votes.
+// If Dataset has no weights and weight is not 1.0, then the weights of the existing entries are set to 1.0.
+func (model *LabelNear) AddWithWeight(x FeatureVector, y LabelVector, weight float32) uint32 {
+	ds, i := model.Dataset, uint32(model.Dataset.Size())
+	lenx := float32(0.0)
+	for _, xpair := range x {
+		lenx += xpair.Value * xpair.Value
+	}
+	lenx = Sqrt32(lenx)
+	newx := make(FeatureVector, len(x))
+	for j, xpair := range x {
+		newx[j] = KeyValue32{xpair.Key, xpair.Value / lenx}
+	}
+	ds.X, ds.Y = append(ds.X, newx), append(ds.Y, append(LabelVector{}, y...))
+	if ds.W == nil && weight != 1.0 {
+		ds.W = make([]float32, i)
+		for j := range ds.W {
+			ds.W[j] = 1.0
+		}
+	}
+	if ds.W != nil {
+		ds.W = append(ds.W, weight)
+	}
+	model.Hashing.Add(newx, i)
+	return i
+}
+
+// Remove removes the i-th entry from Dataset and Hashing.
+// For keeping Dataset dense, the last entry is moved to the i-th entry, so the index of the last entry is changed to i.
+//
+// This function returns an error if the entry does not exist.
+func (model *LabelNear) Remove(i uint32) error {
+	ds := model.Dataset
+	if i >= uint32(ds.Size()) {
+		return fmt.Errorf("Remove: entry #%d does not exist", i)
+	}
+	last := uint32(ds.Size() - 1)
+	model.Hashing.Remove(ds.X[i], i)
+	if i != last {
+		model.Hashing.Reindex(ds.X[last], last, i)
+		ds.X[i], ds.Y[i] = ds.X[last], ds.Y[last]
+		if ds.W != nil {
+			ds.W[i] = ds.W[last]
+		}
+	}
+	ds.X, ds.Y = ds.X[:last], ds.Y[:last]
+	if ds.W != nil {
+		ds.W = ds.W[:last]
+	}
+	return nil
+}
+
 // FindNears returns the S near entries with each similarity for the given entry.
 // The quantity c*S is used for sieving the candidates by hashing.
 // See Predict for hyper-parameter details.
 func (model *LabelNear) FindNears(x FeatureVector, c, S uint, beta float32) KeyValues32 {
+	return model.FindNearsWithProbes(x, c, S, 1, beta)
+}
+
+// FindNearsWithProbes is FindNears probing at most probes buckets in each hash table (see NearIndex.FindNearsWithProbes).
+// The larger probes achieves the higher recall of the near entries with the more computation, and probes less than 2 is same as FindNears.
+func (model *LabelNear) FindNearsWithProbes(x FeatureVector, c, S, probes uint, beta float32) KeyValues32 {
 	indexSimsTopS := make(KeyValues32, 0, S)
-	nears := KeyCounts32(model.Hashing.FindNears(x))
+	// Hashing has the normalized feature vectors in Dataset, so x is also normalized for the hashing depending on the feature values like the weighted JaccardHashing.
+	lenx := float32(0.0)
+	for _, xpair := range x {
+		lenx += xpair.Value * xpair.Value
+	}
+	lenx = Sqrt32(lenx)
+	hashx := make(FeatureVector, len(x))
+	for j, xpair := range x {
+		hashx[j] = KeyValue32{xpair.Key, xpair.Value / lenx}
+	}
+	nears := KeyCounts32(model.Hashing.FindNearsWithProbes(hashx, probes))
 	nears = nears.SortLargestCountsWithHeap(c * S)
 	for _, nearPair := range nears {
 		protoidx, count := nearPair.Key, nearPair.Count
@@ -178,8 +306,20 @@ func (model *LabelNear) FindNears(x FeatureVector, c, S uint, beta float32) KeyV
 //
 // alpha is the smoothing parameter for weighting the votes by each neighbor.
 // beta is the smoothing parameter for balancing the Jaccard similarity and the cosine similarity.
+// The votes by each neighbor are also multiplied by its weight in Dataset.
 func (model *LabelNear) Predict(x FeatureVector, K, c, S uint, alpha, beta float32) (LabelVector, map[uint32]float32, KeyValues32) {
-	indexSimsTopS := model.FindNears(x, c, S, beta)
+	return model.PredictWithProbes(x, K, c, S, 1, alpha, beta)
+}
+
+// PredictWithProbes is Predict finding the near entries with FindNearsWithProbes.
+func (model *LabelNear) PredictWithProbes(x FeatureVector, K, c, S, probes uint, alpha, beta float32) (LabelVector, map[uint32]float32, KeyValues32) {
+	labelHist, indexSimsTopS := model.predictLabelHist(x, c, S, probes, alpha, beta)
+	return RankTopK(labelHist, K), labelHist, indexSimsTopS
+}
+
+// predictLabelHist returns the label histogram voted by the sparse S-near neighborhood of x, and the slice of the data entry index and its similarity.
+func (model *LabelNear) predictLabelHist(x FeatureVector, c, S, probes uint, alpha, beta float32) (map[uint32]float32, KeyValues32) {
+	indexSimsTopS := model.FindNearsWithProbes(x, c, S, probes, beta)
 	labelHist := make(map[uint32]float32)
 	xlen := float32(0.0)
 	for _, xpair := range x {
@@ -187,21 +327,70 @@ func (model *LabelNear) Predict(x FeatureVector, K, c, S uint, alpha, beta float
 	}
 	xlen = Sqrt32(xlen)
 	for _, indexSim := range indexSimsTopS {
-		value := Pow32(indexSim.Value/xlen, alpha)
+		value := Pow32(indexSim.Value/xlen, alpha) * model.Dataset.Weight(int(indexSim.Key))
 		for _, label := range model.Dataset.Y[indexSim.Key] {
 			labelHist[label] += value
 		}
 	}
-	return RankTopK(labelHist, K), labelHist, indexSimsTopS
+	return labelHist, indexSimsTopS
 }
 
 // PredictAll returns the top-K labels for each data entry in X with the sparse S-near neighborhood.
+// The data entries are predicted by runtime.GOMAXPROCS workers (see PredictAllWithWorkers).
 // See Predict for hyper-parameter details.
 func (model *LabelNear) PredictAll(X FeatureVectors, K, c, S uint, alpha, beta float32) LabelVectors {
-	Yhat := make(LabelVectors, 0, len(X))
-	for _, xi := range X {
-		yihat, _, _ := model.Predict(xi, K, c, S, alpha, beta)
-		Yhat = append(Yhat, yihat)
-	}
+	return model.PredictAllWithWorkers(X, K, c, S, alpha, beta, 0)
+}
+
+// PredictAllWithScores returns the top-K labels with their votes for each data entry in X with the sparse S-near neighborhood.
+// The data entries are predicted by runtime.GOMAXPROCS workers.
+// See Predict for hyper-parameter details.
+func (model *LabelNear) PredictAllWithScores(X FeatureVectors, K, c, S uint, alpha, beta float32) []KeyValues32 {
+	Yhat := make([]KeyValues32, len(X))
+	ParallelizeEntries(len(X), 0, func() func(i int) {
+		return func(i int) {
+			labelHist, _ := model.predictLabelHist(X[i], c, S, 1, alpha, beta)
+			Yhat[i] = RankTopKWithScores(labelHist, K)
+		}
+	})
 	return Yhat
 }
+
+// PredictAllWithWorkers is PredictAll with nworkers workers (see ParallelizeEntries).
+func (model *LabelNear) PredictAllWithWorkers(X FeatureVectors, K, c, S uint, alpha, beta float32, nworkers int) LabelVectors {
+	Yhat := make(LabelVectors, len(X))
+	ParallelizeEntries(len(X), nworkers, func() func(i int) {
+		return func(i int) {
+			Yhat[i], _, _ = model.Predict(X[i], K, c, S, alpha, beta)
+		}
+	})
+	return Yhat
+}
+
+// LabelNearPredictor is the Predictor of LabelNear with the bound hyper-parameters.
+// See LabelNear.Predict for hyper-parameter details.
+// If Probes is at least 2, then the near entries are found with LabelNear.FindNearsWithProbes.
+type LabelNearPredictor struct {
+	Model       *LabelNear
+	C, S        uint
+	Probes      uint
+	Alpha, Beta float32
+}
+
+// NewLabelNearPredictor returns a new LabelNearPredictor probing only the exact bucket in each hash table.
+func NewLabelNearPredictor(model *LabelNear, c, S uint, alpha, beta float32) *LabelNearPredictor {
+	return &LabelNearPredictor{
+		Model:  model,
+		C:      c,
+		S:      S,
+		Probes: 1,
+		Alpha:  alpha,
+		Beta:   beta,
+	}
+}
+
+// Predict returns the top-K labels with their votes for x.
+func (predictor *LabelNearPredictor) Predict(x FeatureVector, K uint) ([]LabelScore, error) {
+	y, labelHist, _ := predictor.Model.PredictWithProbes(x, K, predictor.C, predictor.S, predictor.Probes, predictor.Alpha, predictor.Beta)
+	return MakeLabelScores(y, labelHist), nil
+}
@@ -35,6 +35,44 @@ func TestDecodeEncodeLabelNear(t *testing.T) {
 	goassert.New(t, model).Equal(&decodedModel)
 	// gob.Decoder.Decode won't call LabelNear.GobDecode, because the encoder did not encode LabelNear.
 	goassert.New(t, "LabelNear should be encoded with EncodeLabelNear").ExpectError(gob.NewEncoder(&buf).Encode(&decodedModel))
+	// The weights should be also encoded.
+	buf.Reset()
+	ds.W = []float32{1.0, 2.0, 3.0, 4.0, 5.0, 6.0}
+	model = goassert.New(t).SucceedNew(TrainLabelNear(ds, params, nil)).(*LabelNear)
+	goassert.New(t).SucceedWithoutError(EncodeLabelNear(model, &buf))
+	decodedModel = LabelNear{}
+	goassert.New(t).SucceedWithoutError(DecodeLabelNear(&decodedModel, &buf))
+	goassert.New(t, ds.W).Equal(decodedModel.Dataset.W)
+	// SimHashing should be also encoded.
+	buf.Reset()
+	params.Hashing = "simhash"
+	model = goassert.New(t).SucceedNew(TrainLabelNear(ds, params, nil)).(*LabelNear)
+	goassert.New(t, "simhash").Equal(model.Hashing.Name())
+	goassert.New(t).SucceedWithoutError(model.Remove(1))
+	goassert.New(t).SucceedWithoutError(EncodeLabelNear(model, &buf))
+	decodedModel = LabelNear{}
+	goassert.New(t).SucceedWithoutError(DecodeLabelNear(&decodedModel, &buf))
+	goassert.New(t, model).Equal(&decodedModel)
+	// HNSW should be also encoded.
+	buf.Reset()
+	params.Hashing = "hnsw"
+	model = goassert.New(t).SucceedNew(TrainLabelNear(ds, params, nil)).(*LabelNear)
+	goassert.New(t, "hnsw(M=16,efConstruction=100,ef=100)").Equal(model.Hashing.String())
+	goassert.New(t, KeyValues32{KeyValue32{3, 4.0}}).Equal(model.FindNears(ds.X[3], 1, 1, 0.0))
+	goassert.New(t).SucceedWithoutError(model.Remove(1))
+	goassert.New(t).SucceedWithoutError(EncodeLabelNear(model, &buf))
+	decodedModel = LabelNear{}
+	goassert.New(t).SucceedWithoutError(DecodeLabelNear(&decodedModel, &buf))
+	goassert.New(t, model).Equal(&decodedModel)
+	// HNSW needs at least 2 neighbors and the positive size of the candidate list.
+	params.M = 1
+	goassert.New(t, "TrainLabelNear: M must be at least 2 for hnsw").ExpectError(TrainLabelNear(ds, params, nil))
+	params.M, params.EfConstruction = 0, 100
+	goassert.New(t, "TrainLabelNear: M must be at least 2 for hnsw").ExpectError(TrainLabelNear(ds, params, nil))
+	params.M, params.EfConstruction = 2, 0
+	goassert.New(t, "TrainLabelNear: efConstruction must be positive for hnsw").ExpectError(TrainLabelNear(ds, params, nil))
+	params.Hashing = "unknown"
+	goassert.New(t, "TrainLabelNear: unknown hashing \"unknown\"").ExpectError(TrainLabelNear(ds, params, nil))
 }
 
 func TestLabelNearFindNears(t *testing.T) {
@@ -48,6 +86,22 @@ func TestLabelNearFindNears(t *testing.T) {
 	goassert.New(t, KeyValues32{KeyValue32{0, 0.5}}).Equal(model.FindNears(FeatureVector{KeyValue32{1, 1.0}}, 5, 1, 0.0))
 	goassert.New(t, KeyValues32{KeyValue32{0, 0.125}}).Equal(model.FindNears(FeatureVector{KeyValue32{1, 1.0}}, 5, 1, 1.0))
 	goassert.New(t, KeyValues32{KeyValue32{0, 0.03125}}).Equal(model.FindNears(FeatureVector{KeyValue32{1, 1.0}}, 5, 1, 2.0))
+	// The weighted JaccardHashing should find the entry with the scaled query, because the query is normalized before hashing.
+	params.Weighted = true
+	model = goassert.New(t).SucceedNew(TrainLabelNear(ds, params, nil)).(*LabelNear)
+	goassert.New(t, true).Equal(model.Hashing.(*JaccardHashing).Weighted())
+	goassert.New(t, uint32(params.K)).Equal(model.Hashing.FindNears(FeatureVector{KeyValue32{1, 0.5}, KeyValue32{2, 0.5}, KeyValue32{3, 0.5}, KeyValue32{4, 0.5}}).Map()[0])
+	goassert.New(t, KeyValues32{KeyValue32{0, 6.0}}).Equal(model.FindNears(FeatureVector{KeyValue32{1, 3.0}, KeyValue32{2, 3.0}, KeyValue32{3, 3.0}, KeyValue32{4, 3.0}}, 5, 1, 0.0))
+	// The multi-probe querying should find the exact bucket at least, and probes less than 2 should be same as FindNears.
+	x := FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}}
+	nears := model.FindNears(x, 5, 1, 1.0)
+	goassert.New(t, KeyValues32{KeyValue32{0, 0.5}}).Equal(nears)
+	goassert.New(t, nears, nears, nears).Equal(model.FindNearsWithProbes(x, 5, 1, 0, 1.0), model.FindNearsWithProbes(x, 5, 1, 1, 1.0), model.FindNearsWithProbes(x, 5, 1, 3, 1.0))
+	predictor := NewLabelNearPredictor(model, 5, 1, 1.0, 1.0)
+	goassert.New(t, uint(1)).Equal(predictor.Probes)
+	predictor.Probes = 3
+	y, labelHist, _ := model.PredictWithProbes(x, 1, 5, 1, 3, 1.0, 1.0)
+	goassert.New(t, MakeLabelScores(y, labelHist)).EqualWithoutError(predictor.Predict(x, 1))
 }
 
 func TestLabelNearPredictAll(t *testing.T) {
@@ -112,4 +166,63 @@ func TestLabelNearPredictAll(t *testing.T) {
 	}).Equal(model2.PredictAll(FeatureVectors{
 		FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}, KeyValue32{3, 1.0}, KeyValue32{4, 1.0}, KeyValue32{5, 1.0}},
 	}, 5, 5, 5, 1.0, 1.0))
+	// The votes should be weighted with the weights of the entries.
+	ds3 := &Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{1, 1.0}}, FeatureVector{KeyValue32{1, 1.0}}},
+		Y: LabelVectors{LabelVector{1}, LabelVector{2}},
+		W: []float32{1.0, 2.0},
+	}
+	model = goassert.New(t).SucceedNew(TrainLabelNear(ds3, params, nil)).(*LabelNear)
+	goassert.New(t, LabelVectors{LabelVector{2, 1}}).Equal(model.PredictAll(FeatureVectors{FeatureVector{KeyValue32{1, 1.0}}}, 2, 5, 2, 1.0, 1.0))
+	ds3.W = []float32{2.0, 1.0}
+	model = goassert.New(t).SucceedNew(TrainLabelNear(ds3, params, nil)).(*LabelNear)
+	goassert.New(t, LabelVectors{LabelVector{1, 2}}).Equal(model.PredictAll(FeatureVectors{FeatureVector{KeyValue32{1, 1.0}}}, 2, 5, 2, 1.0, 1.0))
+	// The predictor should predict the same labels with their votes.
+	goassert.New(t, []KeyValues32{KeyValues32{KeyValue32{1, 2.0}}}).Equal(model.PredictAllWithScores(FeatureVectors{FeatureVector{KeyValue32{1, 1.0}}}, 1, 5, 2, 1.0, 1.0))
+	goassert.New(t, []LabelScore{{1, 2.0}, {2, 1.0}}).EqualWithoutError(NewLabelNearPredictor(model, 5, 2, 1.0, 1.0).Predict(FeatureVector{KeyValue32{1, 1.0}}, 3))
+}
+
+func TestLabelNearAddRemove(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{2, 2.0}}, FeatureVector{KeyValue32{1, 1.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}}, FeatureVector{KeyValue32{4, 4.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}, KeyValue32{5, 5.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}, KeyValue32{5, 5.0}, KeyValue32{6, 6.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{2}, LabelVector{1},
+			LabelVector{3}, LabelVector{4},
+			LabelVector{5},
+			LabelVector{6},
+		},
+	}
+	params := NewLabelNearParameters()
+	// The added entries should be indexed as trained.
+	fullModel := goassert.New(t).SucceedNew(TrainLabelNear(ds, params, nil)).(*LabelNear)
+	model := goassert.New(t).SucceedNew(TrainLabelNear(ds.SubSet([]int{0, 1, 2, 3}), params, nil)).(*LabelNear)
+	goassert.New(t, uint32(4)).Equal(model.Add(ds.X[4], ds.Y[4]))
+	goassert.New(t, uint32(5)).Equal(model.Add(ds.X[5], ds.Y[5]))
+	goassert.New(t, fullModel).Equal(model)
+	// The last entry should be moved to the removed entry.
+	goassert.New(t).SucceedWithoutError(model.Remove(1))
+	goassert.New(t, "Remove: entry #5 does not exist").ExpectError(model.Remove(5))
+	goassert.New(t, 5, ds.Y[5]).Equal(model.Dataset.Size(), model.Dataset.Y[1])
+	indices := []uint32{}
+	for _, indexSim := range model.FindNears(ds.X[5], 5, 6, 0.0) {
+		indices = append(indices, indexSim.Key)
+	}
+	goassert.New(t, []uint32{1, 4, 2}).Equal(indices)
+	goassert.New(t, LabelVectors{LabelVector{6, 5, 3}}).Equal(model.PredictAll(FeatureVectors{ds.X[5]}, 3, 5, 6, 1.0, 1.0))
+	// The mutated model should be encoded with the deletions of the hashing.
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeLabelNear(model, &buf))
+	var decodedModel LabelNear
+	goassert.New(t).SucceedWithoutError(DecodeLabelNear(&decodedModel, &buf))
+	goassert.New(t, model).Equal(&decodedModel)
+	goassert.New(t, true).Equal(len(decodedModel.Hashing.(*JaccardHashing).deletions) > 0)
+	// The weights of the existing entries should be 1.0 if an entry with the weight is added.
+	goassert.New(t, uint32(5)).Equal(model.AddWithWeight(ds.X[1], ds.Y[1], 2.0))
+	goassert.New(t, []float32{1.0, 1.0, 1.0, 1.0, 1.0, 2.0}).Equal(model.Dataset.W)
+	goassert.New(t, LabelVectors{LabelVector{1}}).Equal(model.PredictAll(FeatureVectors{ds.X[1]}, 1, 5, 6, 1.0, 1.0))
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sync"
 )
 
 // SimCountPair is the data structure for float32 similarity and uint32 count.
@@ -24,6 +25,7 @@ type LabelNearestContext []SimCountPair
 //   (iii) The entries in the training dataset whose inner-product is not positive are not used.
 //
 // LabelNearest is only the optimized data structure of the training dataset for searching nearest neighborhood.
+// Thus, the entries can be added, removed and updated without re-training (see Add, Remove and Update), but these mutations must not be done concurrently with the inference.
 type LabelNearest struct {
 	// NfeaturesList is the slice of the number of features contained in each training data entry.
 	NfeaturesList []uint32
@@ -33,9 +35,28 @@ type LabelNearest struct {
 	FeatureIndexList map[uint32]KeyValues32
 	// LabelVectors is the label vectors in the training dataset.
 	LabelVectors LabelVectors
+	// The following members are not required.
+	//
+	// FeatureVocabulary and LabelVocabulary are the vocabularies of the features and labels in training, or nil if unknown.
+	FeatureVocabulary, LabelVocabulary *Vocabulary
+	// Weights is the weight of each entry in the training dataset multiplied to its votes, or nil if the entries are weighted uniformly.
+	Weights []float32
+	// Tombstones is the flag of each entry in the training dataset removed by Remove, or nil if no entry is removed since the last Compact.
+	Tombstones []bool
+	// QuantizationBits is the number of bits of the quantized values in CompressedFeatureIndexList, or 0 if the model is not compressed.
+	// CompressedFeatureIndexList is the map from the feature to the compressed feature index used instead of FeatureIndexList, or nil if the model is not compressed (see Compress).
+	QuantizationBits           uint
+	CompressedFeatureIndexList map[uint32]*CompressedFeatureIndex
+
+	deltas []LabelNearestDelta
+	// deltasBase is the state of the model before the recorded deltas.
+	deltasBase labelNearestDeltaChunkHeader
+	// featureBounds is the pair of the minimum and maximum values in each feature index used by FindNearestsWithPruning, or nil if unknown.
+	featureBounds map[uint32][2]float32
 }
 
 // TrainLabelNearest returns an trained LabelNearest on the given training dataset ds.
+// The weights of the entries in ds are used for weighting their votes.
 //
 // Currently, this function returns no error.
 func TrainLabelNearest(ds *Dataset, debug *log.Logger) (*LabelNearest, error) {
@@ -68,10 +89,17 @@ func TrainLabelNearest(ds *Dataset, debug *log.Logger) (*LabelNearest, error) {
 		copy(yihat, yi)
 		labelVectors = append(labelVectors, yihat)
 	}
+	var weights []float32
+	if ds.W != nil {
+		weights = make([]float32, len(ds.W))
+		copy(weights, ds.W)
+	}
 	return &LabelNearest{
 		NfeaturesList:    nfeaturesList,
 		FeatureIndexList: featureIndexList,
 		LabelVectors:     labelVectors,
+		Weights:          weights,
+		featureBounds:    computeFeatureBounds(featureIndexList),
 	}, nil
 }
 
@@ -107,6 +135,55 @@ func DecodeLabelNearestWithGobDecoder(model *LabelNearest, decoder *gob.Decoder)
 		}
 		model.LabelVectors = append(model.LabelVectors, labelVector)
 	}
+	var err error
+	if model.FeatureVocabulary, model.LabelVocabulary, err = DecodeModelVocabulariesWithGobDecoder(decoder); err != nil {
+		return fmt.Errorf("DecodeLabelNearest: vocabularies: %s", err)
+	}
+	// The models encoded before the weights were introduced have no weights.
+	var weights struct {
+		Weights []float32
+	}
+	if err := decoder.Decode(&weights); err != nil && err != io.EOF {
+		return fmt.Errorf("DecodeLabelNearest: Weights: %s", err)
+	}
+	model.Weights = weights.Weights
+	// The models encoded before the tombstones were introduced have no tombstones.
+	var tombstones struct {
+		Tombstones []bool
+	}
+	if err := decoder.Decode(&tombstones); err != nil && err != io.EOF {
+		return fmt.Errorf("DecodeLabelNearest: Tombstones: %s", err)
+	}
+	model.Tombstones = tombstones.Tombstones
+	// The models encoded before the compression was introduced are not compressed.
+	var compressed struct {
+		QuantizationBits uint
+		Nfeatures        uint32
+	}
+	if err := decoder.Decode(&compressed); err != nil && err != io.EOF {
+		return fmt.Errorf("DecodeLabelNearest: compressed: %s", err)
+	}
+	model.QuantizationBits, model.CompressedFeatureIndexList = compressed.QuantizationBits, nil
+	if model.QuantizationBits != 0 {
+		model.FeatureIndexList = nil
+		model.CompressedFeatureIndexList = make(map[uint32]*CompressedFeatureIndex, compressed.Nfeatures)
+		for j := uint32(0); j < compressed.Nfeatures; j++ {
+			var feature uint32
+			if err := decoder.Decode(&feature); err != nil {
+				return fmt.Errorf("DecodeLabelNearest: #%d compressed feature: %s", j, err)
+			}
+			index := &CompressedFeatureIndex{}
+			if err := decoder.Decode(index); err != nil {
+				return fmt.Errorf("DecodeLabelNearest: CompressedFeatureIndexList[%d]: %s", feature, err)
+			}
+			model.CompressedFeatureIndexList[feature] = index
+		}
+	}
+	model.discardDeltas()
+	model.featureBounds = nil
+	if model.QuantizationBits == 0 {
+		model.featureBounds = computeFeatureBounds(model.FeatureIndexList)
+	}
 	return nil
 }
 
@@ -120,6 +197,8 @@ func DecodeLabelNearest(model *LabelNearest, r io.Reader) error {
 }
 
 // EncodeLabelNearestWithGobEncoder decodes LabelNearest using encoder.
+// The deltas recorded for EncodeLabelNearestDeltas are discarded, because the encoded model contains them.
+// Thus, the delta file of the previously encoded model must be truncated, otherwise DecodeLabelNearestDeltas rejects or wrongly replays the stale deltas.
 //
 // This function returns an error in decoding.
 func EncodeLabelNearestWithGobEncoder(model *LabelNearest, encoder *gob.Encoder) error {
@@ -145,6 +224,36 @@ func EncodeLabelNearestWithGobEncoder(model *LabelNearest, encoder *gob.Encoder)
 			return fmt.Errorf("EncodeLabelNearest: LabelVectors[%d]: %s", i, err)
 		}
 	}
+	if err := EncodeModelVocabulariesWithGobEncoder(model.FeatureVocabulary, model.LabelVocabulary, encoder); err != nil {
+		return fmt.Errorf("EncodeLabelNearest: vocabularies: %s", err)
+	}
+	if err := encoder.Encode(struct {
+		Weights []float32
+	}{model.Weights}); err != nil {
+		return fmt.Errorf("EncodeLabelNearest: Weights: %s", err)
+	}
+	if err := encoder.Encode(struct {
+		Tombstones []bool
+	}{model.Tombstones}); err != nil {
+		return fmt.Errorf("EncodeLabelNearest: Tombstones: %s", err)
+	}
+	if err := encoder.Encode(struct {
+		QuantizationBits uint
+		Nfeatures        uint32
+	}{model.QuantizationBits, uint32(len(model.CompressedFeatureIndexList))}); err != nil {
+		return fmt.Errorf("EncodeLabelNearest: compressed: %s", err)
+	}
+	j = 0
+	for feature, index := range model.CompressedFeatureIndexList {
+		if err := encoder.Encode(feature); err != nil {
+			return fmt.Errorf("EncodeLabelNearest: #%d compressed feature: %s", j, err)
+		}
+		if err := encoder.Encode(index); err != nil {
+			return fmt.Errorf("EncodeLabelNearest: CompressedFeatureIndexList[%d]: %s", feature, err)
+		}
+		j++
+	}
+	model.discardDeltas()
 	return nil
 }
 
@@ -163,6 +272,7 @@ func (model *LabelNearest) GobEncode() ([]byte, error) {
 }
 
 // FindNearests returns the S nearest entries with each similarity for the given entry.
+// The removed entries are not returned.
 // See Predict for hyper-parameter details.
 func (model *LabelNearest) FindNearests(x FeatureVector, S uint, beta float32) KeyValues32 {
 	return model.FindNearestsWithContext(x, S, beta, model.NewContext())
@@ -172,6 +282,12 @@ func (model *LabelNearest) FindNearests(x FeatureVector, S uint, beta float32) K
 func (model *LabelNearest) FindNearestsWithContext(x FeatureVector, S uint, beta float32, ctx LabelNearestContext) KeyValues32 {
 	simCounts := []SimCountPair(ctx)
 	for _, xpair := range x {
+		if model.QuantizationBits != 0 {
+			if index := model.CompressedFeatureIndexList[xpair.Key]; index != nil {
+				index.accumulate(simCounts, xpair.Value, model.QuantizationBits)
+			}
+			continue
+		}
 		featureIndex := model.FeatureIndexList[xpair.Key]
 		for _, indexValue := range featureIndex {
 			simCounts[indexValue.Key].Sim += indexValue.Value * xpair.Value
@@ -181,33 +297,8 @@ func (model *LabelNearest) FindNearestsWithContext(x FeatureVector, S uint, beta
 	indexSimsTopS := make(KeyValues32, 0, S)
 	for i, simCount := range simCounts {
 		if sim, count := simCount.Sim, simCount.Count; count > 0 {
-			if sim > 0.0 {
-				// For efficiency, call Pow32 as short-cut style.
-				jaccard := float32(count) / float32(uint32(len(x))+model.NfeaturesList[i]-count)
-				if beta == 0 {
-				} else if beta == 1 {
-					sim *= jaccard
-				} else {
-					sim *= Pow32(jaccard, beta)
-				}
-				if len(indexSimsTopS) == 0 {
-					indexSimsTopS = append(indexSimsTopS, KeyValue32{uint32(i), sim})
-				} else if indexSimsTopS[len(indexSimsTopS)-1].Value > sim {
-					if len(indexSimsTopS) < cap(indexSimsTopS) {
-						indexSimsTopS = append(indexSimsTopS, KeyValue32{uint32(i), sim})
-					}
-				} else {
-					for rank := 0; rank < len(indexSimsTopS); rank++ {
-						if sim >= indexSimsTopS[rank].Value {
-							if len(indexSimsTopS) < cap(indexSimsTopS) {
-								indexSimsTopS = append(indexSimsTopS, KeyValue32{0, 0})
-							}
-							copy(indexSimsTopS[rank+1:], indexSimsTopS[rank:])
-							indexSimsTopS[rank] = KeyValue32{uint32(i), sim}
-							break
-						}
-					}
-				}
+			if sim > 0.0 && !model.isRemoved(uint32(i)) {
+				indexSimsTopS = model.insertIndexSim(indexSimsTopS, x, uint32(i), sim, count, beta)
 			}
 			simCounts[i] = SimCountPair{0.0, 0}
 		}
@@ -215,6 +306,38 @@ func (model *LabelNearest) FindNearestsWithContext(x FeatureVector, S uint, beta
 	return indexSimsTopS
 }
 
+// insertIndexSim inserts the i-th entry whose similarity to x is sim on count common features into the sorted indexSimsTopS, and returns the updated slice.
+// The similarity is multiplied by the Jaccard similarity to the power of beta, and the entry is dropped if indexSimsTopS is full and the similarity is smaller than the ones in it.
+func (model *LabelNearest) insertIndexSim(indexSimsTopS KeyValues32, x FeatureVector, i uint32, sim float32, count uint32, beta float32) KeyValues32 {
+	// For efficiency, call Pow32 as short-cut style.
+	jaccard := float32(count) / float32(uint32(len(x))+model.NfeaturesList[i]-count)
+	if beta == 0 {
+	} else if beta == 1 {
+		sim *= jaccard
+	} else {
+		sim *= Pow32(jaccard, beta)
+	}
+	if len(indexSimsTopS) == 0 {
+		indexSimsTopS = append(indexSimsTopS, KeyValue32{i, sim})
+	} else if indexSimsTopS[len(indexSimsTopS)-1].Value > sim {
+		if len(indexSimsTopS) < cap(indexSimsTopS) {
+			indexSimsTopS = append(indexSimsTopS, KeyValue32{i, sim})
+		}
+	} else {
+		for rank := 0; rank < len(indexSimsTopS); rank++ {
+			if sim >= indexSimsTopS[rank].Value {
+				if len(indexSimsTopS) < cap(indexSimsTopS) {
+					indexSimsTopS = append(indexSimsTopS, KeyValue32{0, 0})
+				}
+				copy(indexSimsTopS[rank+1:], indexSimsTopS[rank:])
+				indexSimsTopS[rank] = KeyValue32{i, sim}
+				break
+			}
+		}
+	}
+	return indexSimsTopS
+}
+
 // NewContext returns a new context for some inference memory.
 func (model *LabelNearest) NewContext() LabelNearestContext {
 	return make([]SimCountPair, len(model.LabelVectors))
@@ -225,13 +348,25 @@ func (model *LabelNearest) NewContext() LabelNearestContext {
 //
 // alpha is the smoothing parameter for weighting the votes by each neighbor.
 // beta is the smoothing parameter for balancing the Jaccard similarity and the cosine similarity.
+// The votes by each neighbor are also multiplied by its weight in Weights.
 func (model *LabelNearest) Predict(x FeatureVector, K, S uint, alpha, beta float32) (LabelVector, map[uint32]float32, KeyValues32) {
 	return model.PredictWithContext(x, K, S, alpha, beta, model.NewContext())
 }
 
 // PredictWithContext is Predict with the specified LabelNearestContext.
 func (model *LabelNearest) PredictWithContext(x FeatureVector, K, S uint, alpha, beta float32, ctx LabelNearestContext) (LabelVector, map[uint32]float32, KeyValues32) {
+	labelHist, indexSimsTopS := model.predictLabelHistWithContext(x, S, alpha, beta, ctx)
+	return RankTopK(labelHist, K), labelHist, indexSimsTopS
+}
+
+// predictLabelHistWithContext returns the label histogram voted by the sparse S-nearest neighborhood of x, and the slice of the data entry index and its similarity.
+func (model *LabelNearest) predictLabelHistWithContext(x FeatureVector, S uint, alpha, beta float32, ctx LabelNearestContext) (map[uint32]float32, KeyValues32) {
 	indexSimsTopS := model.FindNearestsWithContext(x, S, beta, ctx)
+	return model.voteLabelHist(x, alpha, indexSimsTopS), indexSimsTopS
+}
+
+// voteLabelHist returns the label histogram voted by the neighbors indexSimsTopS of x.
+func (model *LabelNearest) voteLabelHist(x FeatureVector, alpha float32, indexSimsTopS KeyValues32) map[uint32]float32 {
 	labelHist := make(map[uint32]float32)
 	xlen := float32(0.0)
 	for _, xpair := range x {
@@ -240,21 +375,91 @@ func (model *LabelNearest) PredictWithContext(x FeatureVector, K, S uint, alpha,
 	xlen = Sqrt32(xlen)
 	for _, indexSim := range indexSimsTopS {
 		value := Pow32(indexSim.Value/xlen, alpha)
+		if model.Weights != nil {
+			value *= model.Weights[indexSim.Key]
+		}
 		for _, label := range model.LabelVectors[indexSim.Key] {
 			labelHist[label] += value
 		}
 	}
-	return RankTopK(labelHist, K), labelHist, indexSimsTopS
+	return labelHist
 }
 
 // PredictAll returns the top-K labels for each data entry in X with the sparse S-nearest neighborhood.
+// The data entries are predicted by runtime.GOMAXPROCS workers (see PredictAllWithWorkers).
 // See Predict for hyper-parameter details.
 func (model *LabelNearest) PredictAll(X FeatureVectors, K, S uint, alpha, beta float32) LabelVectors {
-	Yhat := make(LabelVectors, 0, len(X))
-	ctx := model.NewContext()
-	for _, xi := range X {
-		yihat, _, _ := model.PredictWithContext(xi, K, S, alpha, beta, ctx)
-		Yhat = append(Yhat, yihat)
-	}
+	return model.PredictAllWithWorkers(X, K, S, alpha, beta, 0)
+}
+
+// PredictAllWithScores returns the top-K labels with their votes for each data entry in X with the sparse S-nearest neighborhood.
+// The data entries are predicted by runtime.GOMAXPROCS workers.
+// See Predict for hyper-parameter details.
+func (model *LabelNearest) PredictAllWithScores(X FeatureVectors, K, S uint, alpha, beta float32) []KeyValues32 {
+	Yhat := make([]KeyValues32, len(X))
+	ParallelizeEntries(len(X), 0, func() func(i int) {
+		ctx := model.NewContext()
+		return func(i int) {
+			labelHist, _ := model.predictLabelHistWithContext(X[i], S, alpha, beta, ctx)
+			Yhat[i] = RankTopKWithScores(labelHist, K)
+		}
+	})
 	return Yhat
 }
+
+// PredictAllWithWorkers is PredictAll with nworkers workers (see ParallelizeEntries).
+// Each worker has its own LabelNearestContext.
+func (model *LabelNearest) PredictAllWithWorkers(X FeatureVectors, K, S uint, alpha, beta float32, nworkers int) LabelVectors {
+	Yhat := make(LabelVectors, len(X))
+	ParallelizeEntries(len(X), nworkers, func() func(i int) {
+		ctx := model.NewContext()
+		return func(i int) {
+			Yhat[i], _, _ = model.PredictWithContext(X[i], K, S, alpha, beta, ctx)
+		}
+	})
+	return Yhat
+}
+
+// LabelNearestPredictor is the Predictor of LabelNearest with the bound hyper-parameters.
+// See LabelNearest.Predict for hyper-parameter details.
+//
+// LabelNearestPredictor reuses the LabelNearestContexts in the pool, so this is safe for concurrent use.
+type LabelNearestPredictor struct {
+	Model       *LabelNearest
+	S           uint
+	Alpha, Beta float32
+	// Pruning is true if the nearest entries are found by FindNearestsWithPruning without LabelNearestContext.
+	Pruning bool
+
+	ctxPool sync.Pool
+}
+
+// NewLabelNearestPredictor returns a new LabelNearestPredictor.
+func NewLabelNearestPredictor(model *LabelNearest, S uint, alpha, beta float32) *LabelNearestPredictor {
+	predictor := &LabelNearestPredictor{
+		Model: model,
+		S:     S,
+		Alpha: alpha,
+		Beta:  beta,
+	}
+	predictor.ctxPool.New = func() interface{} {
+		return model.NewContext()
+	}
+	return predictor
+}
+
+// Predict returns the top-K labels with their votes for x.
+func (predictor *LabelNearestPredictor) Predict(x FeatureVector, K uint) ([]LabelScore, error) {
+	if predictor.Pruning {
+		y, labelHist, _ := predictor.Model.PredictWithPruning(x, K, predictor.S, predictor.Alpha, predictor.Beta)
+		return MakeLabelScores(y, labelHist), nil
+	}
+	ctx := predictor.ctxPool.Get().(LabelNearestContext)
+	if len(ctx) < len(predictor.Model.LabelVectors) {
+		// The pooled context is too short for the entries added after its creation.
+		ctx = predictor.Model.NewContext()
+	}
+	y, labelHist, _ := predictor.Model.PredictWithContext(x, K, predictor.S, predictor.Alpha, predictor.Beta, ctx)
+	predictor.ctxPool.Put(ctx)
+	return MakeLabelScores(y, labelHist), nil
+}
@@ -0,0 +1,228 @@
+package sticker
+
+import (
+	"fmt"
+)
+
+// CompressedFeatureIndex is the feature index of LabelNearest compressed by LabelNearest.Compress.
+// The entry indices are encoded as the varint-encoded differences from the previous ones, and the normalized feature values are linearly quantized into 8 or 16 bits (little-endian) between Min and Min+Scale*(2^bits-1).
+type CompressedFeatureIndex struct {
+	Entries, Values []byte
+	Min, Scale      float32
+	// Last is the last entry index, which is the base of the difference of the appended entry index.
+	Last uint32
+}
+
+// newCompressedFeatureIndex returns a new CompressedFeatureIndex of featureIndex with the values quantized into bits bits.
+// The quantization range is the range of the values in featureIndex.
+func newCompressedFeatureIndex(featureIndex KeyValues32, bits uint) *CompressedFeatureIndex {
+	index := &CompressedFeatureIndex{}
+	if len(featureIndex) == 0 {
+		return index
+	}
+	min, max := featureIndex[0].Value, featureIndex[0].Value
+	for _, indexValue := range featureIndex[1:] {
+		if min > indexValue.Value {
+			min = indexValue.Value
+		}
+		if max < indexValue.Value {
+			max = indexValue.Value
+		}
+	}
+	index.Min, index.Scale = min, (max-min)/float32(uint32(1)<<bits-1)
+	index.Entries, index.Values = make([]byte, 0, len(featureIndex)), make([]byte, 0, len(featureIndex)*int(bits/8))
+	for _, indexValue := range featureIndex {
+		index.appendEntry(indexValue.Key)
+		index.appendValue(index.quantize(indexValue.Value, bits), bits)
+	}
+	return index
+}
+
+// accumulate adds the product of each value and the given value to the similarity of each entry in simCounts, and increments its count.
+// This is the inner loop of FindNearestsWithContext decoding the entry indices and values on the fly.
+func (index *CompressedFeatureIndex) accumulate(simCounts []SimCountPair, value float32, bits uint) {
+	entries, values := index.Entries, index.Values
+	i, pos := uint32(0), 0
+	for j := 0; pos < len(entries); j++ {
+		delta, shift := uint32(0), uint(0)
+		for {
+			b := entries[pos]
+			pos++
+			delta |= uint32(b&0x7f) << shift
+			if b < 0x80 {
+				break
+			}
+			shift += 7
+		}
+		i += delta
+		var q uint32
+		if bits == 8 {
+			q = uint32(values[j])
+		} else {
+			q = uint32(values[2*j]) | uint32(values[2*j+1])<<8
+		}
+		simCounts[i].Sim += (index.Min + index.Scale*float32(q)) * value
+		simCounts[i].Count++
+	}
+}
+
+// append appends the i-th entry with the given value, where i must be larger than the entry indices in the index.
+// If the value is out of the quantization range, then the index is re-encoded with the extended range, which may change the other values slightly.
+func (index *CompressedFeatureIndex) append(i uint32, value float32, bits uint) {
+	if len(index.Entries) == 0 || value < index.Min || value > index.Min+index.Scale*float32(uint32(1)<<bits-1) {
+		*index = *newCompressedFeatureIndex(append(index.decompress(bits), KeyValue32{i, value}), bits)
+		return
+	}
+	index.appendEntry(i)
+	index.appendValue(index.quantize(value, bits), bits)
+}
+
+// appendEntry appends the varint-encoded difference of the i-th entry index from the last one.
+func (index *CompressedFeatureIndex) appendEntry(i uint32) {
+	delta := i - index.Last
+	if len(index.Entries) == 0 {
+		delta = i
+	}
+	for delta >= 0x80 {
+		index.Entries = append(index.Entries, byte(delta)|0x80)
+		delta >>= 7
+	}
+	index.Entries = append(index.Entries, byte(delta))
+	index.Last = i
+}
+
+// appendValue appends the quantized value q.
+func (index *CompressedFeatureIndex) appendValue(q uint32, bits uint) {
+	if bits == 8 {
+		index.Values = append(index.Values, byte(q))
+	} else {
+		index.Values = append(index.Values, byte(q), byte(q>>8))
+	}
+}
+
+// decodeEntries returns the entry indices.
+func (index *CompressedFeatureIndex) decodeEntries() []uint32 {
+	entries := make([]uint32, 0, len(index.Entries))
+	i, delta, shift := uint32(0), uint32(0), uint(0)
+	for _, b := range index.Entries {
+		delta |= uint32(b&0x7f) << shift
+		if b >= 0x80 {
+			shift += 7
+			continue
+		}
+		i += delta
+		entries = append(entries, i)
+		delta, shift = 0, 0
+	}
+	return entries
+}
+
+// decompress returns the feature index with the dequantized values.
+func (index *CompressedFeatureIndex) decompress(bits uint) KeyValues32 {
+	entries := index.decodeEntries()
+	featureIndex := make(KeyValues32, len(entries))
+	for j, i := range entries {
+		featureIndex[j] = KeyValue32{i, index.value(j, bits)}
+	}
+	return featureIndex
+}
+
+// quantize returns the quantized value.
+func (index *CompressedFeatureIndex) quantize(value float32, bits uint) uint32 {
+	if index.Scale == 0.0 {
+		return 0
+	}
+	q := Floor32((value-index.Min)/index.Scale + 0.5)
+	if q < 0.0 {
+		return 0
+	}
+	if maxq := float32(uint32(1)<<bits - 1); q > maxq {
+		return uint32(maxq)
+	}
+	return uint32(q)
+}
+
+// value returns the j-th dequantized value.
+func (index *CompressedFeatureIndex) value(j int, bits uint) float32 {
+	q := uint32(index.Values[j*int(bits/8)])
+	if bits == 16 {
+		q |= uint32(index.Values[2*j+1]) << 8
+	}
+	return index.Min + index.Scale*float32(q)
+}
+
+// Compress replaces FeatureIndexList with CompressedFeatureIndexList whose values are quantized into bits bits, which must be 8 or 16.
+// The compressed feature index of the feature having n postings has about (the bytes of n varints)+n*bits/8 bytes instead of 8*n bytes (see FeatureIndexBytes).
+// The similarities are slightly changed by the quantization errors, which are at most the half of Scale of each feature index.
+//
+// The compressed model is inferred by FindNearestsWithContext decoding the feature indices on the fly, and FindNearestsWithPruning falls back to FindNearests.
+// The compressed model can be mutated, but AddWithWeight re-encodes the feature index if the added value is out of its quantization range.
+//
+// This function returns an error if bits is invalid or the model is already compressed.
+func (model *LabelNearest) Compress(bits uint) error {
+	if bits != 8 && bits != 16 {
+		return fmt.Errorf("Compress: bits must be 8 or 16")
+	}
+	if model.QuantizationBits != 0 {
+		return fmt.Errorf("Compress: already compressed with %d bits", model.QuantizationBits)
+	}
+	model.CompressedFeatureIndexList = make(map[uint32]*CompressedFeatureIndex, len(model.FeatureIndexList))
+	for feature, featureIndex := range model.FeatureIndexList {
+		model.CompressedFeatureIndexList[feature] = newCompressedFeatureIndex(featureIndex, bits)
+	}
+	model.FeatureIndexList, model.QuantizationBits, model.featureBounds = nil, bits, nil
+	return nil
+}
+
+// Decompress replaces CompressedFeatureIndexList with FeatureIndexList having the dequantized values.
+// Nothing is done if the model is not compressed.
+func (model *LabelNearest) Decompress() {
+	if model.QuantizationBits == 0 {
+		return
+	}
+	model.FeatureIndexList = make(map[uint32]KeyValues32, len(model.CompressedFeatureIndexList))
+	for feature, index := range model.CompressedFeatureIndexList {
+		model.FeatureIndexList[feature] = index.decompress(model.QuantizationBits)
+	}
+	model.CompressedFeatureIndexList, model.QuantizationBits = nil, 0
+	model.featureBounds = computeFeatureBounds(model.FeatureIndexList)
+}
+
+// FeatureIndexBytes returns the number of bytes of the postings in the feature indices, which excludes the overhead of the map and slices.
+func (model *LabelNearest) FeatureIndexBytes() int {
+	nbytes := 0
+	if model.QuantizationBits != 0 {
+		for _, index := range model.CompressedFeatureIndexList {
+			nbytes += len(index.Entries) + len(index.Values)
+		}
+	} else {
+		for _, featureIndex := range model.FeatureIndexList {
+			nbytes += 8 * len(featureIndex)
+		}
+	}
+	return nbytes
+}
+
+// compactCompressed drops the removed entries from CompressedFeatureIndexList with the mapping newIDs from the old entry indices to the new ones.
+// The quantized values are kept as they are.
+func (model *LabelNearest) compactCompressed(newIDs []uint32) {
+	bytesPerValue := int(model.QuantizationBits / 8)
+	for feature, index := range model.CompressedFeatureIndexList {
+		newIndex := &CompressedFeatureIndex{
+			Values: make([]byte, 0, len(index.Values)),
+			Min:    index.Min,
+			Scale:  index.Scale,
+		}
+		for j, i := range index.decodeEntries() {
+			if !model.isRemoved(i) {
+				newIndex.appendEntry(newIDs[i])
+				newIndex.Values = append(newIndex.Values, index.Values[j*bytesPerValue:(j+1)*bytesPerValue]...)
+			}
+		}
+		if len(newIndex.Entries) > 0 {
+			model.CompressedFeatureIndexList[feature] = newIndex
+		} else {
+			delete(model.CompressedFeatureIndexList, feature)
+		}
+	}
+}
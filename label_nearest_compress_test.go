@@ -0,0 +1,88 @@
+package sticker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestCompressedFeatureIndex(t *testing.T) {
+	featureIndex := KeyValues32{KeyValue32{0, 0.5}, KeyValue32{5, -0.25}, KeyValue32{200, 1.0}, KeyValue32{100000, 0.75}}
+	for _, bits := range []uint{8, 16} {
+		index := newCompressedFeatureIndex(featureIndex, bits)
+		// 200-5 and 100000-200 need 2 and 3 bytes, respectively.
+		goassert.New(t, 1+1+2+3, 4*int(bits/8)).Equal(len(index.Entries), len(index.Values))
+		goassert.New(t, []uint32{0, 5, 200, 100000}).Equal(index.decodeEntries())
+		for j, indexValue := range index.decompress(bits) {
+			goassert.New(t, featureIndex[j].Key).Equal(indexValue.Key)
+			goassert.New(t, true).Equal(Abs32(featureIndex[j].Value-indexValue.Value) <= index.Scale/2)
+		}
+		// The value in the quantization range should be appended without re-encoding.
+		scale := index.Scale
+		index.append(100001, 0.0, bits)
+		goassert.New(t, scale, uint32(100001)).Equal(index.Scale, index.Last)
+		// The value out of the quantization range should extend the range.
+		index.append(100200, 2.0, bits)
+		goassert.New(t, float32(-0.25), uint32(100200)).Equal(index.Min, index.Last)
+		goassert.New(t, []uint32{0, 5, 200, 100000, 100001, 100200}).Equal(index.decodeEntries())
+		goassert.New(t, true).Equal(Abs32(index.value(5, bits)-2.0) <= index.Scale/2)
+	}
+}
+
+func TestLabelNearestCompress(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{2, 2.0}}, FeatureVector{KeyValue32{1, 1.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}}, FeatureVector{KeyValue32{4, 4.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}, KeyValue32{5, 5.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}, KeyValue32{5, 5.0}, KeyValue32{6, 6.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{2}, LabelVector{1},
+			LabelVector{3}, LabelVector{4},
+			LabelVector{5},
+			LabelVector{6},
+		},
+	}
+	plainModel := goassert.New(t).SucceedNew(TrainLabelNearest(ds, nil)).(*LabelNearest)
+	model := goassert.New(t).SucceedNew(TrainLabelNearest(ds, nil)).(*LabelNearest)
+	goassert.New(t, "Compress: bits must be 8 or 16").ExpectError(model.Compress(4))
+	goassert.New(t).SucceedWithoutError(model.Compress(8))
+	goassert.New(t, "Compress: already compressed with 8 bits").ExpectError(model.Compress(16))
+	goassert.New(t, map[uint32]KeyValues32(nil)).Equal(model.FeatureIndexList)
+	// 12 postings have 12 1-byte entries and 12 1-byte values.
+	goassert.New(t, 8*12, 2*12).Equal(plainModel.FeatureIndexBytes(), model.FeatureIndexBytes())
+	// The compressed model should find the same nearest entries with the slightly different similarities.
+	checkNearests := func() {
+		for _, x := range ds.X {
+			plainNearests, nearests := plainModel.FindNearests(x, 3, 1.0), model.FindNearests(x, 3, 1.0)
+			goassert.New(t, len(plainNearests)).Equal(len(nearests))
+			for rank := range nearests {
+				goassert.New(t, plainNearests[rank].Key).Equal(nearests[rank].Key)
+				goassert.New(t, true).Equal(Abs32(plainNearests[rank].Value-nearests[rank].Value) < 0.01)
+			}
+			// The pruning should fall back to FindNearests.
+			goassert.New(t, nearests).Equal(model.FindNearestsWithPruning(x, 3, 1.0))
+		}
+	}
+	checkNearests()
+	// The compressed model should be encoded and decoded.
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeLabelNearest(model, &buf))
+	var decodedModel LabelNearest
+	goassert.New(t).SucceedWithoutError(DecodeLabelNearest(&decodedModel, &buf))
+	goassert.New(t, model).Equal(&decodedModel)
+	// The compressed model should be mutated as the plain model.
+	for _, m := range []*LabelNearest{plainModel, model} {
+		m.Add(FeatureVector{KeyValue32{1, 1.0}, KeyValue32{7, 1.0}}, LabelVector{7})
+		goassert.New(t).SucceedWithoutError(m.Remove(2))
+		m.Compact()
+	}
+	checkNearests()
+	// The compressed model should be decompressed.
+	model.Decompress()
+	goassert.New(t, uint(0), map[uint32]*CompressedFeatureIndex(nil)).Equal(model.QuantizationBits, model.CompressedFeatureIndexList)
+	goassert.New(t, len(plainModel.FeatureIndexList)).Equal(len(model.FeatureIndexList))
+	checkNearests()
+}
@@ -0,0 +1,156 @@
+package sticker
+
+import (
+	"math"
+	"sort"
+)
+
+// computeFeatureBounds returns the pair of the minimum and maximum values in each feature index.
+func computeFeatureBounds(featureIndexList map[uint32]KeyValues32) map[uint32][2]float32 {
+	featureBounds := make(map[uint32][2]float32, len(featureIndexList))
+	for feature, featureIndex := range featureIndexList {
+		if len(featureIndex) == 0 {
+			continue
+		}
+		bounds := [2]float32{featureIndex[0].Value, featureIndex[0].Value}
+		for _, indexValue := range featureIndex[1:] {
+			if bounds[0] > indexValue.Value {
+				bounds[0] = indexValue.Value
+			}
+			if bounds[1] < indexValue.Value {
+				bounds[1] = indexValue.Value
+			}
+		}
+		featureBounds[feature] = bounds
+	}
+	return featureBounds
+}
+
+// labelNearestPruningTerm is the feature of the query used in FindNearestsWithPruning.
+type labelNearestPruningTerm struct {
+	value        float32
+	featureIndex KeyValues32
+	// upper is the upper bound of the contribution of the feature to the similarity.
+	upper float64
+	// pos is the position of the cursor in featureIndex.
+	pos int
+}
+
+// FindNearestsWithPruning is FindNearests skipping the entries which cannot enter the top-S nearest neighbors with the dynamic pruning (MaxScore, Turtle+ 1995).
+// The results are identical to the ones of FindNearests including the order of the ties, so this function can be used interchangeably.
+//
+// The upper bound of the contribution of each feature is computed from the minimum and maximum values in its feature index.
+// The features are sorted by their upper bounds, and the features in the prefix whose total upper bound is below the current S-th similarity are non-essential, so only the entries in the essential features are the candidates.
+// The similarity of each candidate is bounded by the contributions of the essential features and the upper bounds of the non-essential features, and the candidate is scored only if the bound can enter the top-S.
+// If beta is positive, then the bound is also multiplied by the upper bound of the Jaccard similarity, (min(|x|, n)/max(|x|, n))^beta where n is the number of features in the candidate.
+// The bounds have the margin covering the rounding errors in the float32 similarities, so no candidate entering the top-S is skipped.
+//
+// This function falls back to FindNearests if beta is negative (the Jaccard factor has no upper bound), S is 0, or the model has no feature bounds (for example, the model is compressed).
+// The feature bounds are computed in TrainLabelNearest and DecodeLabelNearest, and maintained by AddWithWeight and Compact.
+//
+// References:
+//
+// (Turtle+ 1995) H. Turtle, and J. Flood. "Query Evaluation: Strategies and Optimizations." Information Processing & Management, vol. 31, no. 6, pp. 831-850, 1995.
+func (model *LabelNearest) FindNearestsWithPruning(x FeatureVector, S uint, beta float32) KeyValues32 {
+	if model.featureBounds == nil || S == 0 || beta < 0 {
+		return model.FindNearests(x, S, beta)
+	}
+	terms := make([]labelNearestPruningTerm, 0, len(x))
+	slack := 0.0
+	for _, xpair := range x {
+		featureIndex := model.FeatureIndexList[xpair.Key]
+		if len(featureIndex) == 0 {
+			continue
+		}
+		bounds, value := model.featureBounds[xpair.Key], float64(xpair.Value)
+		terms = append(terms, labelNearestPruningTerm{
+			value:        xpair.Value,
+			featureIndex: featureIndex,
+			upper:        math.Max(0.0, math.Max(value*float64(bounds[0]), value*float64(bounds[1]))),
+		})
+		slack += math.Abs(value) * math.Max(math.Abs(float64(bounds[0])), math.Abs(float64(bounds[1])))
+	}
+	// The rounding error of the sum of the n products in float32 is at most about n*2^-23 times the sum of their absolute values.
+	slack *= 4.0 * float64(len(x)+8) / (1 << 23)
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].upper < terms[j].upper
+	})
+	prefixUppers := make([]float64, len(terms)+1)
+	for j, term := range terms {
+		prefixUppers[j+1] = prefixUppers[j] + term.upper
+	}
+	indexSimsTopS := make(KeyValues32, 0, S)
+	// cannotEnter returns true if no entry whose similarity is at most bound can enter the top-S.
+	cannotEnter := func(bound float64) bool {
+		if len(indexSimsTopS) < cap(indexSimsTopS) {
+			return bound <= 0.0
+		}
+		return bound < float64(indexSimsTopS[len(indexSimsTopS)-1].Value)
+	}
+	nx := float64(len(x))
+	nnonEssentials := 0
+	for {
+		i, found := uint32(0), false
+		for j := nnonEssentials; j < len(terms); j++ {
+			term := &terms[j]
+			if term.pos < len(term.featureIndex) && (!found || term.featureIndex[term.pos].Key < i) {
+				i, found = term.featureIndex[term.pos].Key, true
+			}
+		}
+		if !found {
+			break
+		}
+		bound := prefixUppers[nnonEssentials]
+		for j := nnonEssentials; j < len(terms); j++ {
+			term := &terms[j]
+			if term.pos < len(term.featureIndex) && term.featureIndex[term.pos].Key == i {
+				bound += float64(term.value) * float64(term.featureIndex[term.pos].Value)
+				term.pos++
+			}
+		}
+		if model.isRemoved(i) {
+			continue
+		}
+		bound += slack
+		if beta > 0 && bound > 0.0 {
+			ni := float64(model.NfeaturesList[i])
+			// The Jaccard factor is rounded in float32, so the bound has the relative margin.
+			bound *= math.Pow(math.Min(nx, ni)/math.Max(nx, ni), float64(beta)) * (1.0 + 1e-6)
+		}
+		if cannotEnter(bound) {
+			continue
+		}
+		if sim, count := model.scoreEntry(x, i); sim > 0.0 && count > 0 {
+			indexSimsTopS = model.insertIndexSim(indexSimsTopS, x, i, sim, count, beta)
+			for nnonEssentials < len(terms) && cannotEnter(prefixUppers[nnonEssentials+1]+slack) {
+				nnonEssentials++
+			}
+		}
+	}
+	return indexSimsTopS
+}
+
+// scoreEntry returns the similarity between x and the i-th entry, and the number of their common features.
+// The similarity is summed in the same order as FindNearestsWithContext, so the both are identical.
+func (model *LabelNearest) scoreEntry(x FeatureVector, i uint32) (float32, uint32) {
+	sim, count := float32(0.0), uint32(0)
+	for _, xpair := range x {
+		featureIndex := model.FeatureIndexList[xpair.Key]
+		pos := sort.Search(len(featureIndex), func(p int) bool {
+			return featureIndex[p].Key >= i
+		})
+		if pos < len(featureIndex) && featureIndex[pos].Key == i {
+			sim += featureIndex[pos].Value * xpair.Value
+			count++
+		}
+	}
+	return sim, count
+}
+
+// PredictWithPruning is Predict finding the nearest entries with FindNearestsWithPruning.
+// The results are identical to the ones of Predict.
+func (model *LabelNearest) PredictWithPruning(x FeatureVector, K, S uint, alpha, beta float32) (LabelVector, map[uint32]float32, KeyValues32) {
+	indexSimsTopS := model.FindNearestsWithPruning(x, S, beta)
+	labelHist := model.voteLabelHist(x, alpha, indexSimsTopS)
+	return RankTopK(labelHist, K), labelHist, indexSimsTopS
+}
@@ -0,0 +1,65 @@
+package sticker
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestLabelNearestFindNearestsWithPruning(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	// The quantized values make many ties, and some values are negative.
+	newFeatureVector := func() FeatureVector {
+		x := FeatureVector{}
+		for feature := uint32(0); feature < 32; feature++ {
+			if rng.Intn(4) == 0 {
+				x = append(x, KeyValue32{feature, float32(rng.Intn(5) - 1)})
+			}
+		}
+		return x
+	}
+	ds := &Dataset{}
+	for i := 0; i < 500; i++ {
+		ds.X, ds.Y = append(ds.X, newFeatureVector()), append(ds.Y, LabelVector{uint32(i % 7)})
+	}
+	model := goassert.New(t).SucceedNew(TrainLabelNearest(ds, nil)).(*LabelNearest)
+	for i := uint32(0); i < 500; i += 11 {
+		goassert.New(t).SucceedWithoutError(model.Remove(i))
+	}
+	model.Add(newFeatureVector(), LabelVector{7})
+	queries := FeatureVectors{FeatureVector{}, FeatureVector{KeyValue32{100, 1.0}}}
+	for q := 0; q < 50; q++ {
+		queries = append(queries, newFeatureVector())
+	}
+	// The pruned results should be identical to the exhaustive ones.
+	check := func() {
+		for _, x := range queries {
+			for _, S := range []uint{1, 5, 25} {
+				for _, beta := range []float32{-1.0, 0.0, 0.5, 1.0, 2.0} {
+					goassert.New(t, model.FindNearests(x, S, beta)).Equal(model.FindNearestsWithPruning(x, S, beta))
+				}
+			}
+			y, labelHist, indexSimsTopS := model.Predict(x, 3, 5, 1.0, 1.0)
+			goassert.New(t, y, labelHist, indexSimsTopS).Equal(model.PredictWithPruning(x, 3, 5, 1.0, 1.0))
+		}
+	}
+	check()
+	// The feature bounds should be maintained by Add and Compact, and recomputed in decoding.
+	model.Compact()
+	goassert.New(t, computeFeatureBounds(model.FeatureIndexList)).Equal(model.featureBounds)
+	check()
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeLabelNearest(model, &buf))
+	var decodedModel LabelNearest
+	goassert.New(t).SucceedWithoutError(DecodeLabelNearest(&decodedModel, &buf))
+	goassert.New(t, model.featureBounds).Equal(decodedModel.featureBounds)
+	// The predictor with pruning should return the same results.
+	predictor := NewLabelNearestPredictor(model, 5, 1.0, 1.0)
+	prunedPredictor := NewLabelNearestPredictor(model, 5, 1.0, 1.0)
+	prunedPredictor.Pruning = true
+	for _, x := range queries {
+		goassert.New(t, goassert.New(t).SucceedNew(predictor.Predict(x, 3))).EqualWithoutError(prunedPredictor.Predict(x, 3))
+	}
+}
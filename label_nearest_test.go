@@ -34,6 +34,14 @@ func TestDecodeEncodeLabelNearest(t *testing.T) {
 	goassert.New(t, model).Equal(&decodedModel)
 	// gob.Decoder.Decode won't call LabelNearest.GobDecode, because the encoder did not encode LabelNearest.
 	goassert.New(t, "LabelNearest should be encoded with EncodeLabelNearest").ExpectError(gob.NewEncoder(&buf).Encode(&decodedModel))
+	// The weights should be also encoded.
+	buf.Reset()
+	ds.W = []float32{1.0, 2.0, 3.0, 4.0, 5.0, 6.0}
+	model = goassert.New(t).SucceedNew(TrainLabelNearest(ds, nil)).(*LabelNearest)
+	goassert.New(t).SucceedWithoutError(EncodeLabelNearest(model, &buf))
+	decodedModel = LabelNearest{}
+	goassert.New(t).SucceedWithoutError(DecodeLabelNearest(&decodedModel, &buf))
+	goassert.New(t, ds.W).Equal(decodedModel.Weights)
 }
 
 func TestLabelNearestFindNearests(t *testing.T) {
@@ -90,6 +98,24 @@ func TestLabelNearestPredictAll(t *testing.T) {
 		FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 1.0}},
 		FeatureVector{KeyValue32{1, -1.0}},
 	}, 3, 3, 1.0, 1.0))
+	// The workers should keep the order of the data entries.
+	X := make(FeatureVectors, 100)
+	for i := range X {
+		X[i] = FeatureVector{KeyValue32{uint32(i % 5), 1.0}, KeyValue32{uint32(5 + i%7), 1.0}}
+	}
+	goassert.New(t, model.PredictAllWithWorkers(X, 3, 3, 1.0, 1.0, 1)).Equal(model.PredictAllWithWorkers(X, 3, 3, 1.0, 1.0, 4))
+	// The predictor should predict the same labels with their votes.
+	predictor := NewLabelNearestPredictor(model, 3, 1.0, 1.0)
+	_, labelHist, _ := model.Predict(FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 1.0}}, 3, 3, 1.0, 1.0)
+	goassert.New(t, []LabelScore{{3, labelHist[3]}, {1, labelHist[1]}, {5, labelHist[5]}}).EqualWithoutError(predictor.Predict(FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 1.0}}, 3))
+	goassert.New(t, LabelVectors{LabelVector{1, 3, 5}, LabelVector{4, ^uint32(0), ^uint32(0)}}).EqualWithoutError(PredictAllWithPredictor(predictor, FeatureVectors{
+		FeatureVector{KeyValue32{1, 1.0}},
+		FeatureVector{KeyValue32{4, 1.0}},
+	}, 3, 2))
+	goassert.New(t, []KeyValues32{KeyValues32{KeyValue32{3, labelHist[3]}, KeyValue32{1, labelHist[1]}}, KeyValues32{}}).Equal(model.PredictAllWithScores(FeatureVectors{
+		FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 1.0}},
+		FeatureVector{KeyValue32{10, 1.0}},
+	}, 2, 3, 1.0, 1.0))
 	// Test the sorted order.
 	ds2 := &Dataset{
 		X: FeatureVectors{
@@ -106,4 +132,15 @@ func TestLabelNearestPredictAll(t *testing.T) {
 	model2 := goassert.New(t).SucceedNew(TrainLabelNearest(ds2, nil)).(*LabelNearest)
 	yhat, _, _ := model2.Predict(FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}, KeyValue32{3, 1.0}, KeyValue32{4, 1.0}, KeyValue32{5, 1.0}}, 5, 5, 1.0, 1.0)
 	goassert.New(t, LabelVector{1, 3, 4, 2, 5}).Equal(yhat)
+	// The votes should be weighted with the weights of the entries.
+	ds3 := &Dataset{
+		X: FeatureVectors{FeatureVector{KeyValue32{1, 1.0}}, FeatureVector{KeyValue32{1, 1.0}}},
+		Y: LabelVectors{LabelVector{1}, LabelVector{2}},
+		W: []float32{1.0, 2.0},
+	}
+	model = goassert.New(t).SucceedNew(TrainLabelNearest(ds3, nil)).(*LabelNearest)
+	goassert.New(t, LabelVectors{LabelVector{2, 1}}).Equal(model.PredictAll(FeatureVectors{FeatureVector{KeyValue32{1, 1.0}}}, 2, 2, 1.0, 1.0))
+	ds3.W = []float32{2.0, 1.0}
+	model = goassert.New(t).SucceedNew(TrainLabelNearest(ds3, nil)).(*LabelNearest)
+	goassert.New(t, LabelVectors{LabelVector{1, 2}}).Equal(model.PredictAll(FeatureVectors{FeatureVector{KeyValue32{1, 1.0}}}, 2, 2, 1.0, 1.0))
 }
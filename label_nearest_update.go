@@ -0,0 +1,308 @@
+package sticker
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// LabelNearestDeltaOp is the operation of LabelNearestDelta.
+type LabelNearestDeltaOp uint8
+
+const (
+	// LabelNearestDeltaAdd is the operation of LabelNearest.AddWithWeight.
+	LabelNearestDeltaAdd LabelNearestDeltaOp = iota + 1
+	// LabelNearestDeltaRemove is the operation of LabelNearest.Remove.
+	LabelNearestDeltaRemove
+	// LabelNearestDeltaUpdate is the operation of LabelNearest.Update.
+	LabelNearestDeltaUpdate
+	// LabelNearestDeltaCompact is the operation of LabelNearest.Compact.
+	LabelNearestDeltaCompact
+)
+
+// LabelNearestDelta is the mutation of LabelNearest recorded for saving the mutated model as the append-only delta next to the base model.
+type LabelNearestDelta struct {
+	Op LabelNearestDeltaOp
+	// Index is the index of the removed or updated entry.
+	Index uint32
+	// X and Weight are the feature vector and the weight of the added entry.
+	X      FeatureVector
+	Weight float32
+	// Y is the label vector of the added or updated entry.
+	Y LabelVector
+}
+
+// labelNearestDeltaChunkHeader is the header of each chunk encoded by EncodeLabelNearestDeltas.
+// Nentries and Nremoveds are the numbers of the entries and the removed entries of the model which the chunk is applied to, which are used for rejecting the chunk recorded on another model.
+type labelNearestDeltaChunkHeader struct {
+	Nentries, Nremoveds, Ndeltas uint32
+}
+
+// Add adds the entry with the feature vector x and the label vector y, and returns the index of the added entry.
+// The weight of the added entry is 1.0 (see AddWithWeight).
+func (model *LabelNearest) Add(x FeatureVector, y LabelVector) uint32 {
+	return model.AddWithWeight(x, y, 1.0)
+}
+
+// AddWithWeight is Add with the weight of the added entry multiplied to its votes.
+// If the model has no weights and weight is not 1.0, then the weights of the existing entries are set to 1.0.
+//
+// The feature index of each feature in x is appended the normalized feature value, so the feature indices are kept sorted by the entry index.
+// If the model is compressed, then the compressed feature indices are appended (see Compress).
+func (model *LabelNearest) AddWithWeight(x FeatureVector, y LabelVector, weight float32) uint32 {
+	model.beginDelta()
+	i := uint32(len(model.LabelVectors))
+	lenx := float32(0.0)
+	for _, xpair := range x {
+		lenx += xpair.Value * xpair.Value
+	}
+	lenx = Sqrt32(lenx)
+	if model.QuantizationBits != 0 && model.CompressedFeatureIndexList == nil {
+		model.CompressedFeatureIndexList = make(map[uint32]*CompressedFeatureIndex)
+	}
+	if model.QuantizationBits == 0 && model.FeatureIndexList == nil {
+		model.FeatureIndexList = make(map[uint32]KeyValues32)
+	}
+	for _, xpair := range x {
+		value := xpair.Value / lenx
+		if model.QuantizationBits != 0 {
+			if index := model.CompressedFeatureIndexList[xpair.Key]; index != nil {
+				index.append(i, value, model.QuantizationBits)
+			} else {
+				model.CompressedFeatureIndexList[xpair.Key] = newCompressedFeatureIndex(KeyValues32{KeyValue32{i, value}}, model.QuantizationBits)
+			}
+			continue
+		}
+		model.FeatureIndexList[xpair.Key] = append(model.FeatureIndexList[xpair.Key], KeyValue32{i, value})
+		if model.featureBounds != nil {
+			bounds, ok := model.featureBounds[xpair.Key]
+			if !ok || bounds[0] > value {
+				bounds[0] = value
+			}
+			if !ok || bounds[1] < value {
+				bounds[1] = value
+			}
+			model.featureBounds[xpair.Key] = bounds
+		}
+	}
+	model.NfeaturesList = append(model.NfeaturesList, uint32(len(x)))
+	model.LabelVectors = append(model.LabelVectors, append(LabelVector{}, y...))
+	if model.Weights == nil && weight != 1.0 {
+		model.Weights = make([]float32, i)
+		for j := range model.Weights {
+			model.Weights[j] = 1.0
+		}
+	}
+	if model.Weights != nil {
+		model.Weights = append(model.Weights, weight)
+	}
+	if model.Tombstones != nil {
+		model.Tombstones = append(model.Tombstones, false)
+	}
+	model.deltas = append(model.deltas, LabelNearestDelta{
+		Op:     LabelNearestDeltaAdd,
+		X:      append(FeatureVector{}, x...),
+		Weight: weight,
+		Y:      append(LabelVector{}, y...),
+	})
+	return i
+}
+
+// Compact drops the removed entries, and returns the mapping from the old entry indices to the new ones.
+// The tombstones of the removed entries make the inference slower, so Compact should be called periodically (for example, when Nremoveds exceeds the quarter of the entries).
+func (model *LabelNearest) Compact() *IDMapping {
+	n := len(model.LabelVectors)
+	oldIDs, newIDs := make([]uint32, 0, n), make([]uint32, n)
+	for i := 0; i < n; i++ {
+		if !model.isRemoved(uint32(i)) {
+			newIDs[i] = uint32(len(oldIDs))
+			oldIDs = append(oldIDs, uint32(i))
+		}
+	}
+	if len(oldIDs) == n {
+		return NewIDMapping(oldIDs)
+	}
+	model.beginDelta()
+	model.compactCompressed(newIDs)
+	for feature, featureIndex := range model.FeatureIndexList {
+		newFeatureIndex := featureIndex[:0]
+		for _, indexValue := range featureIndex {
+			if !model.isRemoved(indexValue.Key) {
+				newFeatureIndex = append(newFeatureIndex, KeyValue32{newIDs[indexValue.Key], indexValue.Value})
+			}
+		}
+		if len(newFeatureIndex) > 0 {
+			model.FeatureIndexList[feature] = newFeatureIndex
+		} else {
+			delete(model.FeatureIndexList, feature)
+		}
+	}
+	nfeaturesList, labelVectors := make([]uint32, len(oldIDs)), make(LabelVectors, len(oldIDs))
+	for newID, oldID := range oldIDs {
+		nfeaturesList[newID], labelVectors[newID] = model.NfeaturesList[oldID], model.LabelVectors[oldID]
+	}
+	model.NfeaturesList, model.LabelVectors = nfeaturesList, labelVectors
+	if model.Weights != nil {
+		weights := make([]float32, len(oldIDs))
+		for newID, oldID := range oldIDs {
+			weights[newID] = model.Weights[oldID]
+		}
+		model.Weights = weights
+	}
+	model.Tombstones = nil
+	if model.featureBounds != nil {
+		model.featureBounds = computeFeatureBounds(model.FeatureIndexList)
+	}
+	model.deltas = append(model.deltas, LabelNearestDelta{Op: LabelNearestDeltaCompact})
+	return NewIDMapping(oldIDs)
+}
+
+// Nremoveds returns the number of the removed entries not dropped by Compact yet.
+func (model *LabelNearest) Nremoveds() int {
+	nremoveds := 0
+	for _, removed := range model.Tombstones {
+		if removed {
+			nremoveds++
+		}
+	}
+	return nremoveds
+}
+
+// Remove removes the i-th entry.
+// The removed entry is only marked with the tombstone, so the indices of the other entries are kept until Compact.
+//
+// This function returns an error if the entry does not exist or is already removed.
+func (model *LabelNearest) Remove(i uint32) error {
+	if err := model.checkEntry(i); err != nil {
+		return fmt.Errorf("Remove: %s", err)
+	}
+	model.beginDelta()
+	if model.Tombstones == nil {
+		model.Tombstones = make([]bool, len(model.LabelVectors))
+	}
+	model.Tombstones[i] = true
+	model.deltas = append(model.deltas, LabelNearestDelta{Op: LabelNearestDeltaRemove, Index: i})
+	return nil
+}
+
+// Update replaces the label vector of the i-th entry with y.
+//
+// This function returns an error if the entry does not exist or is already removed.
+func (model *LabelNearest) Update(i uint32, y LabelVector) error {
+	if err := model.checkEntry(i); err != nil {
+		return fmt.Errorf("Update: %s", err)
+	}
+	model.beginDelta()
+	model.LabelVectors[i] = append(LabelVector{}, y...)
+	model.deltas = append(model.deltas, LabelNearestDelta{Op: LabelNearestDeltaUpdate, Index: i, Y: append(LabelVector{}, y...)})
+	return nil
+}
+
+// applyDelta applies delta to the model.
+func (model *LabelNearest) applyDelta(delta LabelNearestDelta) error {
+	switch delta.Op {
+	case LabelNearestDeltaAdd:
+		model.AddWithWeight(delta.X, delta.Y, delta.Weight)
+	case LabelNearestDeltaRemove:
+		return model.Remove(delta.Index)
+	case LabelNearestDeltaUpdate:
+		return model.Update(delta.Index, delta.Y)
+	case LabelNearestDeltaCompact:
+		model.Compact()
+	default:
+		return fmt.Errorf("unknown operation %d", delta.Op)
+	}
+	return nil
+}
+
+// beginDelta records the state of the model before the mutation if no delta is recorded.
+func (model *LabelNearest) beginDelta() {
+	if len(model.deltas) == 0 {
+		model.deltasBase = labelNearestDeltaChunkHeader{
+			Nentries:  uint32(len(model.LabelVectors)),
+			Nremoveds: uint32(model.Nremoveds()),
+		}
+	}
+}
+
+// discardDeltas discards the recorded deltas.
+func (model *LabelNearest) discardDeltas() {
+	model.deltas, model.deltasBase = nil, labelNearestDeltaChunkHeader{}
+}
+
+// checkEntry returns an error if the i-th entry does not exist or is already removed.
+func (model *LabelNearest) checkEntry(i uint32) error {
+	if i >= uint32(len(model.LabelVectors)) {
+		return fmt.Errorf("entry #%d does not exist", i)
+	}
+	if model.isRemoved(i) {
+		return fmt.Errorf("entry #%d is already removed", i)
+	}
+	return nil
+}
+
+// isRemoved returns true if the i-th entry is removed.
+func (model *LabelNearest) isRemoved(i uint32) bool {
+	return model.Tombstones != nil && model.Tombstones[i]
+}
+
+// DecodeLabelNearestDeltas applies all deltas encoded by EncodeLabelNearestDeltas in r to the model decoded from the base model file.
+// Each chunk is applied only if the numbers of the entries and the removed entries of the model are the ones recorded in the chunk, so the stale deltas recorded before re-encoding the base model are rejected in most cases.
+// The applied deltas are not recorded again.
+//
+// This function returns an error in decoding or applying the deltas, or the chunk is recorded on another model.
+func DecodeLabelNearestDeltas(model *LabelNearest, r io.Reader) error {
+	// gob.Decoder reads exactly each message from io.ByteReader, so the chunks encoded by the different gob.Encoders can be decoded in order.
+	br := bufio.NewReader(r)
+	for chunkId := 0; ; chunkId++ {
+		if _, err := br.Peek(1); err == io.EOF {
+			break
+		}
+		decoder := gob.NewDecoder(br)
+		var header labelNearestDeltaChunkHeader
+		if err := decoder.Decode(&header); err != nil {
+			return fmt.Errorf("DecodeLabelNearestDeltas: #%d chunk: header: %s", chunkId, err)
+		}
+		if nentries, nremoveds := uint32(len(model.LabelVectors)), uint32(model.Nremoveds()); header.Nentries != nentries || header.Nremoveds != nremoveds {
+			return fmt.Errorf("DecodeLabelNearestDeltas: #%d chunk: recorded on the model having %d entries (%d removed), but the model has %d entries (%d removed)", chunkId, header.Nentries, header.Nremoveds, nentries, nremoveds)
+		}
+		for j := uint32(0); j < header.Ndeltas; j++ {
+			var delta LabelNearestDelta
+			if err := decoder.Decode(&delta); err != nil {
+				return fmt.Errorf("DecodeLabelNearestDeltas: #%d chunk: #%d delta: %s", chunkId, j, err)
+			}
+			if err := model.applyDelta(delta); err != nil {
+				return fmt.Errorf("DecodeLabelNearestDeltas: #%d chunk: #%d delta: %s", chunkId, j, err)
+			}
+		}
+	}
+	model.discardDeltas()
+	return nil
+}
+
+// EncodeLabelNearestDeltas encodes the deltas recorded since the last encoding or decoding of the model to w as a chunk, and discards them.
+// Usually, w is the delta file next to the base model file opened in append mode, so the deltas can be saved without rewriting the whole model.
+// The chunk records the numbers of the entries and the removed entries before the deltas (see DecodeLabelNearestDeltas).
+// If the base model is re-encoded by EncodeLabelNearest, then the delta file must be truncated, because the base model contains the deltas.
+// Nothing is encoded if no delta is recorded.
+//
+// This function returns an error in encoding.
+func EncodeLabelNearestDeltas(model *LabelNearest, w io.Writer) error {
+	if len(model.deltas) == 0 {
+		return nil
+	}
+	encoder := gob.NewEncoder(w)
+	header := model.deltasBase
+	header.Ndeltas = uint32(len(model.deltas))
+	if err := encoder.Encode(header); err != nil {
+		return fmt.Errorf("EncodeLabelNearestDeltas: header: %s", err)
+	}
+	for j, delta := range model.deltas {
+		if err := encoder.Encode(delta); err != nil {
+			return fmt.Errorf("EncodeLabelNearestDeltas: #%d delta: %s", j, err)
+		}
+	}
+	model.discardDeltas()
+	return nil
+}
@@ -0,0 +1,81 @@
+package sticker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestLabelNearestAddRemoveUpdate(t *testing.T) {
+	ds := &Dataset{
+		X: FeatureVectors{
+			FeatureVector{KeyValue32{2, 2.0}}, FeatureVector{KeyValue32{1, 1.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}}, FeatureVector{KeyValue32{4, 4.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}, KeyValue32{5, 5.0}},
+			FeatureVector{KeyValue32{1, 1.0}, KeyValue32{3, 3.0}, KeyValue32{5, 5.0}, KeyValue32{6, 6.0}},
+		},
+		Y: LabelVectors{
+			LabelVector{2}, LabelVector{1},
+			LabelVector{3}, LabelVector{4},
+			LabelVector{5},
+			LabelVector{6},
+		},
+	}
+	// The added entries should be indexed as trained.
+	fullModel := goassert.New(t).SucceedNew(TrainLabelNearest(ds, nil)).(*LabelNearest)
+	model := goassert.New(t).SucceedNew(TrainLabelNearest(ds.SubSet([]int{0, 1, 2, 3}), nil)).(*LabelNearest)
+	var base bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeLabelNearest(model, &base))
+	goassert.New(t, uint32(4)).Equal(model.Add(ds.X[4], ds.Y[4]))
+	goassert.New(t, uint32(5)).Equal(model.Add(ds.X[5], ds.Y[5]))
+	goassert.New(t, fullModel.NfeaturesList, fullModel.FeatureIndexList, fullModel.LabelVectors).Equal(model.NfeaturesList, model.FeatureIndexList, model.LabelVectors)
+	goassert.New(t, fullModel.FindNearests(ds.X[5], 6, 1.0)).Equal(model.FindNearests(ds.X[5], 6, 1.0))
+	// The weights of the existing entries should be 1.0 if an entry with the weight is added.
+	goassert.New(t, []float32(nil)).Equal(model.Weights)
+	var deltas bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeLabelNearestDeltas(model, &deltas))
+	goassert.New(t, uint32(6)).Equal(model.AddWithWeight(FeatureVector{KeyValue32{2, 1.0}}, LabelVector{2}, 2.0))
+	goassert.New(t, []float32{1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 2.0}).Equal(model.Weights)
+	// The removed entries should not be found.
+	goassert.New(t).SucceedWithoutError(model.Remove(2))
+	goassert.New(t, "Remove: entry #2 is already removed").ExpectError(model.Remove(2))
+	goassert.New(t, "Remove: entry #7 does not exist").ExpectError(model.Remove(7))
+	goassert.New(t, 1).Equal(model.Nremoveds())
+	indices := []uint32{}
+	for _, indexSim := range model.FindNearests(ds.X[5], 6, 0.0) {
+		indices = append(indices, indexSim.Key)
+	}
+	goassert.New(t, []uint32{5, 4, 1}).Equal(indices)
+	goassert.New(t).SucceedWithoutError(model.Update(4, LabelVector{5, 7}))
+	goassert.New(t, "Update: entry #2 is already removed").ExpectError(model.Update(2, LabelVector{}))
+	goassert.New(t, LabelVector{5, 7}).Equal(model.LabelVectors[4])
+	goassert.New(t).SucceedWithoutError(EncodeLabelNearestDeltas(model, &deltas))
+	// The removed entries should be dropped by Compact.
+	mapping := model.Compact()
+	goassert.New(t, []uint32{0, 1, 3, 4, 5, 6}).Equal(mapping.OldIDs)
+	goassert.New(t, 0, []bool(nil)).Equal(model.Nremoveds(), model.Tombstones)
+	compactedModel := goassert.New(t).SucceedNew(TrainLabelNearest(&Dataset{
+		X: FeatureVectors{ds.X[0], ds.X[1], ds.X[3], ds.X[4], ds.X[5], FeatureVector{KeyValue32{2, 1.0}}},
+		Y: LabelVectors{ds.Y[0], ds.Y[1], ds.Y[3], LabelVector{5, 7}, ds.Y[5], LabelVector{2}},
+		W: []float32{1.0, 1.0, 1.0, 1.0, 1.0, 2.0},
+	}, nil)).(*LabelNearest)
+	goassert.New(t, compactedModel.NfeaturesList, compactedModel.FeatureIndexList, compactedModel.LabelVectors, compactedModel.Weights).Equal(model.NfeaturesList, model.FeatureIndexList, model.LabelVectors, model.Weights)
+	goassert.New(t).SucceedWithoutError(model.Remove(0))
+	goassert.New(t).SucceedWithoutError(EncodeLabelNearestDeltas(model, &deltas))
+	// The base model with the deltas should be the mutated model.
+	staleDeltas := append([]byte{}, deltas.Bytes()...)
+	var decodedModel LabelNearest
+	goassert.New(t).SucceedWithoutError(DecodeLabelNearest(&decodedModel, &base))
+	goassert.New(t).SucceedWithoutError(DecodeLabelNearestDeltas(&decodedModel, &deltas))
+	goassert.New(t, model).Equal(&decodedModel)
+	// The tombstones should be also encoded.
+	base.Reset()
+	goassert.New(t).SucceedWithoutError(EncodeLabelNearest(model, &base))
+	decodedModel = LabelNearest{}
+	goassert.New(t).SucceedWithoutError(DecodeLabelNearest(&decodedModel, &base))
+	goassert.New(t, model).Equal(&decodedModel)
+	goassert.New(t, []bool{true, false, false, false, false, false}).Equal(decodedModel.Tombstones)
+	// The stale deltas recorded before re-encoding the base model should be rejected.
+	goassert.New(t, "DecodeLabelNearestDeltas: #0 chunk: recorded on the model having 4 entries \\(0 removed\\), but the model has 6 entries \\(1 removed\\)").ExpectError(DecodeLabelNearestDeltas(&decodedModel, bytes.NewReader(staleDeltas)))
+}
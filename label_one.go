@@ -49,9 +49,13 @@ type LabelOne struct {
 	// Summaries is the summary object slice for each boosting round.
 	// The entries in this summary is considered to provide compact and useful information in best-effort, so this specification would be loose and rapidly changing.
 	Summaries []map[string]interface{}
+	//
+	// FeatureVocabulary and LabelVocabulary are the vocabularies of the features and labels in training, or nil if unknown.
+	FeatureVocabulary, LabelVocabulary *Vocabulary
 }
 
 // TrainLabelOne returns an trained LabelOne on the given dataset ds.
+// The labels are ranked by the frequencies weighted with ds.W, and ds.W is passed to the binary classifier trainer.
 func TrainLabelOne(ds *Dataset, params *LabelOneParameters, debug *log.Logger) (*LabelOne, error) {
 	classifierTrainer, ok := BinaryClassifierTrainers[params.ClassifierTrainerName]
 	if !ok {
@@ -61,11 +65,12 @@ func TrainLabelOne(ds *Dataset, params *LabelOneParameters, debug *log.Logger) (
 	biases, weightLists := []float32{}, make(map[uint32]KeyValues32)
 	labels := []uint32{}
 	summaries := []map[string]interface{}{}
-	// Collect the label frequencies.
+	// Collect the label frequencies weighted with the entry weights.
 	labelFreqs := make(map[uint32]float32)
-	for _, yi := range ds.Y {
+	for i, yi := range ds.Y {
+		wi := ds.Weight(i)
 		for _, label := range yi {
-			labelFreqs[label]++
+			labelFreqs[label] += wi
 		}
 	}
 	T := params.T
@@ -93,7 +98,7 @@ func TrainLabelOne(ds *Dataset, params *LabelOneParameters, debug *log.Logger) (
 		if debug != nil {
 			debug.Printf("TrainLabelOne: t=%d: training the splitter on %d negative(s) and %d positive(s) ...", t, deltaFreq[false], deltaFreq[true])
 		}
-		splitter, err := classifierTrainer(ds.X, deltas, params.C, params.Epsilon, debug)
+		splitter, err := classifierTrainer(ds.X, deltas, ds.W, params.C, params.Epsilon, debug)
 		if err != nil {
 			return nil, fmt.Errorf("BinaryClassifierTrainer(%s): %s", params.ClassifierTrainerName, err)
 		}
@@ -153,6 +158,10 @@ func DecodeLabelOneWithGobDecoder(model *LabelOne, decoder *gob.Decoder) error {
 	if err := decoder.Decode(&model.Summaries); err != nil {
 		return fmt.Errorf("DecodeLabelOne: Summaries: %s", err)
 	}
+	var err error
+	if model.FeatureVocabulary, model.LabelVocabulary, err = DecodeModelVocabulariesWithGobDecoder(decoder); err != nil {
+		return fmt.Errorf("DecodeLabelOne: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -197,6 +206,9 @@ func EncodeLabelOneWithGobEncoder(model *LabelOne, encoder *gob.Encoder) error {
 	if err := encoder.Encode(model.Summaries); err != nil {
 		return fmt.Errorf("EncodeLabelOne: Summaries: %s", err)
 	}
+	if err := EncodeModelVocabulariesWithGobEncoder(model.FeatureVocabulary, model.LabelVocabulary, encoder); err != nil {
+		return fmt.Errorf("EncodeLabelOne: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -221,6 +233,11 @@ func (model *LabelOne) Nrounds() uint {
 
 // Predict returns the top-K predicted labels for the given data entry x with the first T rounds.
 func (model *LabelOne) Predict(x FeatureVector, K uint, T uint) LabelVector {
+	return RankTopK(model.predictLabelDist(x, T), K)
+}
+
+// predictLabelDist returns the accumulated margin of each label for the given data entry x with the first T rounds.
+func (model *LabelOne) predictLabelDist(x FeatureVector, T uint) SparseVector {
 	if T > model.Nrounds() {
 		T = model.Nrounds()
 	}
@@ -233,23 +250,48 @@ func (model *LabelOne) Predict(x FeatureVector, K uint, T uint) LabelVector {
 			z[weightpair.Key] += weightpair.Value * xpair.Value
 		}
 	}
-	y := make(map[uint32]float32)
+	y := make(SparseVector)
 	for t, zt := range z {
 		zt += model.Biases[t]
 		y[model.Labels[t]] += zt
 	}
-	return RankTopK(y, K)
+	return y
 }
 
 // PredictAll returns the slice of the top-K predicted labels for each data entry in X with the first T rounds.
+// The data entries are predicted by runtime.GOMAXPROCS workers (see PredictAllWithWorkers).
 func (model *LabelOne) PredictAll(X FeatureVectors, K uint, T uint) LabelVectors {
-	Y := make(LabelVectors, 0, len(X))
-	for _, xi := range X {
-		Y = append(Y, model.Predict(xi, K, T))
-	}
+	return model.PredictAllWithWorkers(X, K, T, 0)
+}
+
+// PredictAllWithScores returns the slice of the top-K predicted labels with their accumulated margins for each data entry in X with the first T rounds.
+// The data entries are predicted by runtime.GOMAXPROCS workers.
+func (model *LabelOne) PredictAllWithScores(X FeatureVectors, K uint, T uint) []KeyValues32 {
+	Y := make([]KeyValues32, len(X))
+	ParallelizeEntries(len(X), 0, func() func(i int) {
+		return func(i int) {
+			Y[i] = model.PredictWithScores(X[i], K, T)
+		}
+	})
 	return Y
 }
 
+// PredictAllWithWorkers is PredictAll with nworkers workers (see ParallelizeEntries).
+func (model *LabelOne) PredictAllWithWorkers(X FeatureVectors, K uint, T uint, nworkers int) LabelVectors {
+	Y := make(LabelVectors, len(X))
+	ParallelizeEntries(len(X), nworkers, func() func(i int) {
+		return func(i int) {
+			Y[i] = model.Predict(X[i], K, T)
+		}
+	})
+	return Y
+}
+
+// PredictWithScores returns the top-K predicted labels with their accumulated margins for the given data entry x with the first T rounds.
+func (model *LabelOne) PredictWithScores(x FeatureVector, K uint, T uint) KeyValues32 {
+	return RankTopKWithScores(model.predictLabelDist(x, T), K)
+}
+
 // Prune returns the pruned LabelOne which has at most T rounds.
 func (model *LabelOne) Prune(T uint) *LabelOne {
 	if T > model.Nrounds() {
@@ -261,6 +303,9 @@ func (model *LabelOne) Prune(T uint) *LabelOne {
 		WeightLists: make(map[uint32]KeyValues32),
 		Labels:      model.Labels[:T],
 		Summaries:   model.Summaries[:T],
+
+		FeatureVocabulary: model.FeatureVocabulary,
+		LabelVocabulary:   model.LabelVocabulary,
 	}
 	for feature, weightList := range model.WeightLists {
 		l := 0
@@ -276,3 +321,23 @@ func (model *LabelOne) Prune(T uint) *LabelOne {
 	}
 	return newModel
 }
+
+// LabelOnePredictor is the Predictor of LabelOne with the first T rounds.
+type LabelOnePredictor struct {
+	Model *LabelOne
+	T     uint
+}
+
+// NewLabelOnePredictor returns a new LabelOnePredictor.
+func NewLabelOnePredictor(model *LabelOne, T uint) *LabelOnePredictor {
+	return &LabelOnePredictor{
+		Model: model,
+		T:     T,
+	}
+}
+
+// Predict returns the top-K labels with their accumulated margins for x.
+func (predictor *LabelOnePredictor) Predict(x FeatureVector, K uint) ([]LabelScore, error) {
+	labelDist := predictor.Model.predictLabelDist(x, predictor.T)
+	return MakeLabelScores(RankTopK(labelDist, K), labelDist), nil
+}
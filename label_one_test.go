@@ -61,7 +61,19 @@ func TestTrainLabelOne(t *testing.T) {
 	goassert.New(t, YhatK2T5).Equal(model.PredictAll(ds.X, 2, 5))
 	goassert.New(t, YhatK5T5).Equal(model.PredictAll(ds.X, 5, 5))
 	goassert.New(t, YhatK2T2).Equal(model.PredictAll(ds.X, 2, 2))
+	goassert.New(t, YhatK5T5).EqualWithoutError(PredictAllWithPredictor(NewLabelOnePredictor(model, 5), ds.X, 5, 2))
+	labelScores := goassert.New(t).SucceedNew(NewLabelOnePredictor(model, 5).Predict(ds.X[0], 2)).([]LabelScore)
+	goassert.New(t, KeyValues32{KeyValue32{labelScores[0].Label, labelScores[0].Score}, KeyValue32{labelScores[1].Label, labelScores[1].Score}}).Equal(model.PredictAllWithScores(ds.X, 2, 5)[0])
+	goassert.New(t, 2, uint32(0), uint32(1), true).Equal(len(labelScores), labelScores[0].Label, labelScores[1].Label, labelScores[0].Score >= labelScores[1].Score)
 	// debug logger is tested in TestDecodeEncodeLabelOne.
+	// The labels should be ranked by the weighted frequencies.
+	ds.W = make([]float32, 2*n)
+	for i := 0; i < n; i++ {
+		ds.W[2*i], ds.W[2*i+1] = 1.0, 2.0
+	}
+	params.T = 2
+	model = goassert.New(t).SucceedNew(TrainLabelOne(ds, params, nil)).(*LabelOne)
+	goassert.New(t, LabelVector{0, 2}).Equal(model.Labels)
 }
 
 func TestDecodeEncodeLabelOne(t *testing.T) {
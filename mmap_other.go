@@ -0,0 +1,20 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package sticker
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// mapFile returns the contents of file read into the memory, because memory-mapping is not supported.
+func mapFile(file *os.File) ([]byte, bool, error) {
+	data, err := ioutil.ReadAll(file)
+	return data, false, err
+}
+
+// unmapFile does nothing, because mapFile does not map the memory.
+func unmapFile(data []byte) error {
+	return nil
+}
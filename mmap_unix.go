@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package sticker
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile returns the private memory-mapped contents of file.
+// The empty file is read as the empty slice without mapping.
+func mapFile(file *os.File) ([]byte, bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Size() == 0 {
+		return []byte{}, false, nil
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// unmapFile unmaps the memory mapped by mapFile.
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,305 @@
+package sticker
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand"
+)
+
+// NearIndex is the index used by LabelNear for sieving the candidates of the near entries.
+// JaccardHashing, SimHashing and HNSW implement this interface.
+type NearIndex interface {
+	// Add adds the given feature vector as i-th index.
+	Add(vec FeatureVector, i uint32)
+	// Encode encodes the index using encoder, which can be decoded by DecodeNearIndexWithGobDecoder.
+	Encode(encoder *gob.Encoder) error
+	// FindNears returns the histogram of the neighbors from the given feature vector, where the neighbor having the larger count is the nearer.
+	FindNears(vec FeatureVector) KeyCountMap32
+	// FindNearsWithProbes is FindNears probing at most probes buckets in each hash table for the hashing.
+	FindNearsWithProbes(vec FeatureVector, probes uint) KeyCountMap32
+	// Name returns the name of the index used in LabelNearParameters.Hashing.
+	Name() string
+	// Reindex changes the index oldIdx of the given feature vector to newIdx.
+	Reindex(vec FeatureVector, oldIdx, newIdx uint32)
+	// Remove removes the given feature vector added as i-th index.
+	Remove(vec FeatureVector, i uint32)
+	// String returns the name of the index with its parameters.
+	String() string
+	// Summary returns the slice of bucket usage and the bucket size (size of each reservoir) histogram for the hashing.
+	Summary() (backetUsage []int, backetHist []int)
+}
+
+const (
+	// jaccardHashingWeightedFlag is the flag of the weighted JaccardHashing encoded in _R, which keeps the encoding of the unweighted one.
+	jaccardHashingWeightedFlag = uint(1) << 31
+	// simHashingFlag is the flag of SimHashing encoded in _R, which distinguishes SimHashing from JaccardHashing in decoding.
+	simHashingFlag = uint(1) << 30
+	// hnswFlag is the header of HNSW encoded at the position of _R.
+	hnswFlag = uint(1) << 29
+	// hashTablesDeletionsFlag is the flag of the hash tables having the deletions encoded in _R, which keeps the encoding of the hash tables without the deletions.
+	hashTablesDeletionsFlag = uint(1) << 28
+)
+
+// nearIndexName returns the name of NearIndex encoded with the given header.
+func nearIndexName(header uint) string {
+	switch {
+	case header&hnswFlag != 0:
+		return "hnsw"
+	case header&simHashingFlag != 0:
+		return "simhash"
+	default:
+		return "jaccard"
+	}
+}
+
+// DecodeNearIndexWithGobDecoder decodes NearIndex encoded by NearIndex.Encode using decoder.
+//
+// This function returns an error in decoding.
+func DecodeNearIndexWithGobDecoder(decoder *gob.Decoder) (NearIndex, error) {
+	var header uint
+	if err := decoder.Decode(&header); err != nil {
+		return nil, fmt.Errorf("DecodeNearIndex: _R: %s", err)
+	}
+	var index interface {
+		NearIndex
+		decodeWithHeader(decoder *gob.Decoder, header uint) error
+	}
+	switch nearIndexName(header) {
+	case "hnsw":
+		index = &HNSW{}
+	case "simhash":
+		index = &SimHashing{}
+	default:
+		index = &JaccardHashing{}
+	}
+	if err := index.decodeWithHeader(decoder, header); err != nil {
+		return nil, fmt.Errorf("DecodeNearIndex: %s", err)
+	}
+	return index, nil
+}
+
+// DecodeNearIndex decodes NearIndex from r.
+// Directly passing *os.File used by a gob.Decoder to this function causes mysterious errors.
+// Thus, if users use gob.Decoder, then they should call DecodeNearIndexWithGobDecoder.
+//
+// This function returns an error in decoding.
+func DecodeNearIndex(r io.Reader) (NearIndex, error) {
+	return DecodeNearIndexWithGobDecoder(gob.NewDecoder(r))
+}
+
+// hashTables is the hash tables whose buckets have the reservoirs of the indices, which are shared by the implementations of NearIndex.
+type hashTables struct {
+	_R        uint
+	tables    [][][]uint32
+	tableHits [][]uint32
+	// deletions is the pair of the numbers of the uncompensated deletions inside and outside the reservoir of each bucket (k<<32 | bucket), which are compensated by the random pairing (Gemulla+ 2006).
+	deletions map[uint64][2]uint32
+	rng       *rand.Rand
+}
+
+// newHashTables returns new K hash tables having 2^L buckets with the reservoirs of size R.
+func newHashTables(K, L, R uint) hashTables {
+	tables, tableHits := make([][][]uint32, K), make([][]uint32, K)
+	for k := range tables {
+		tables[k], tableHits[k] = make([][]uint32, 1<<L), make([]uint32, 1<<L)
+	}
+	return hashTables{
+		_R:        R,
+		tables:    tables,
+		tableHits: tableHits,
+		rng:       rand.New(rand.NewSource(0)),
+	}
+}
+
+// add adds i-th index to the bucket hvec[k] of each hash table k.
+// If some indices were removed from the bucket, then the index is added to the reservoir with the probability compensating the deletions by the random pairing (Gemulla+ 2006).
+func (ht *hashTables) add(hvec []uint32, i uint32) {
+	for k, hveck := range hvec {
+		if key := uint64(k)<<32 | uint64(hveck); ht.deletions[key] != [2]uint32{} {
+			deletion := ht.deletions[key]
+			if uint32(ht.rng.Intn(int(deletion[0]+deletion[1]))) < deletion[0] {
+				ht.tables[k][hveck] = append(ht.tables[k][hveck], i)
+				deletion[0]--
+			} else {
+				deletion[1]--
+			}
+			if deletion == [2]uint32{} {
+				delete(ht.deletions, key)
+				if len(ht.deletions) == 0 {
+					ht.deletions = nil
+				}
+			} else {
+				ht.deletions[key] = deletion
+			}
+		} else if uint(len(ht.tables[k][hveck])) < ht._R {
+			ht.tables[k][hveck] = append(ht.tables[k][hveck], uint32(i))
+		} else {
+			j := ht.rng.Intn(int(ht.tableHits[k][hveck]))
+			if uint(j) < ht._R {
+				ht.tables[k][hveck][j] = i
+			}
+		}
+		ht.tableHits[k][hveck]++
+	}
+}
+
+// decodeWithHeader decodes the hash tables following the already decoded header using decoder.
+func (ht *hashTables) decodeWithHeader(decoder *gob.Decoder, header uint) error {
+	ht._R = header &^ (jaccardHashingWeightedFlag | simHashingFlag | hashTablesDeletionsFlag)
+	var K uint
+	if err := decoder.Decode(&K); err != nil {
+		return fmt.Errorf("K: %s", err)
+	}
+	ht.tables = make([][][]uint32, K)
+	for k := range ht.tables {
+		if err := decoder.Decode(&ht.tables[k]); err != nil {
+			return fmt.Errorf("#%d table: %s", k, err)
+		}
+	}
+	ht.tableHits = make([][]uint32, K)
+	if err := decoder.Decode(&ht.tableHits); err != nil {
+		return fmt.Errorf("tableHits: %s", err)
+	}
+	ht.deletions = nil
+	if header&hashTablesDeletionsFlag != 0 {
+		if err := decoder.Decode(&ht.deletions); err != nil {
+			return fmt.Errorf("deletions: %s", err)
+		}
+	}
+	ht.ResetRng()
+	return nil
+}
+
+// encodeWithHeader encodes the hash tables following the header, which is _R with the flags of the implementation, using encoder.
+// The deletions are encoded with hashTablesDeletionsFlag only if any deletion is uncompensated.
+func (ht *hashTables) encodeWithHeader(encoder *gob.Encoder, header uint) error {
+	if len(ht.deletions) > 0 {
+		header |= hashTablesDeletionsFlag
+	}
+	if err := encoder.Encode(header); err != nil {
+		return fmt.Errorf("_R: %s", err)
+	}
+	if err := encoder.Encode(ht.K()); err != nil {
+		return fmt.Errorf("K: %s", err)
+	}
+	for k, table := range ht.tables {
+		if err := encoder.Encode(table); err != nil {
+			return fmt.Errorf("#%d table: %s", k, err)
+		}
+	}
+	if err := encoder.Encode(ht.tableHits); err != nil {
+		return fmt.Errorf("tableHits: %s", err)
+	}
+	if len(ht.deletions) > 0 {
+		if err := encoder.Encode(ht.deletions); err != nil {
+			return fmt.Errorf("deletions: %s", err)
+		}
+	}
+	ht.ResetRng()
+	return nil
+}
+
+// findNears returns the histogram of the indices in the bucket hvec[k] of each hash table k.
+func (ht *hashTables) findNears(hvec []uint32) KeyCountMap32 {
+	nears := NewKeyCountMap32(ht.K() * ht.R())
+	for k, hveck := range hvec {
+		for _, idx := range ht.tables[k][hveck] {
+			nears.Inc(idx)
+		}
+	}
+	return nears
+}
+
+// findNearsWithProbes returns the histogram of the indices in the buckets hvecs[k] of each hash table k.
+// Each index is counted at most once in each hash table if the buckets of each hash table are distinct.
+func (ht *hashTables) findNearsWithProbes(hvecs [][]uint32, probes uint) KeyCountMap32 {
+	nears := NewKeyCountMap32(ht.K() * ht.R() * probes)
+	for k, hvecks := range hvecs {
+		for _, hveck := range hvecks {
+			for _, idx := range ht.tables[k][hveck] {
+				nears.Inc(idx)
+			}
+		}
+	}
+	return nears
+}
+
+// K returns the number of the hash tables.
+func (ht *hashTables) K() uint {
+	return uint(len(ht.tables))
+}
+
+// L returns the bit-width for backet indices in each hash table.
+func (ht *hashTables) L() uint {
+	return uint(bits.Len(uint(len(ht.tables[0]))) - 1)
+}
+
+// R returns the size of a reservoir of each backet.
+func (ht *hashTables) R() uint {
+	return ht._R
+}
+
+// reindex changes the index oldIdx in the bucket hvec[k] of each hash table k to newIdx.
+func (ht *hashTables) reindex(hvec []uint32, oldIdx, newIdx uint32) {
+	for k, hveck := range hvec {
+		for j, idx := range ht.tables[k][hveck] {
+			if idx == oldIdx {
+				ht.tables[k][hveck][j] = newIdx
+			}
+		}
+	}
+}
+
+// remove removes i-th index from the bucket hvec[k] of each hash table k.
+// The reservoir of each bucket stays the uniform sample of the indices in the bucket, because the deletions are compensated by the following additions (see add).
+func (ht *hashTables) remove(hvec []uint32, i uint32) {
+	for k, hveck := range hvec {
+		key := uint64(k)<<32 | uint64(hveck)
+		deletion := ht.deletions[key]
+		reservoir := ht.tables[k][hveck]
+		for j, idx := range reservoir {
+			if idx == i {
+				reservoir[j] = reservoir[len(reservoir)-1]
+				if len(reservoir) > 1 {
+					ht.tables[k][hveck] = reservoir[:len(reservoir)-1]
+				} else {
+					ht.tables[k][hveck] = nil
+				}
+				deletion[0]++
+				break
+			}
+		}
+		if len(ht.tables[k][hveck]) == len(reservoir) {
+			deletion[1]++
+		}
+		if ht.deletions == nil {
+			ht.deletions = make(map[uint64][2]uint32)
+		}
+		ht.deletions[key] = deletion
+		ht.tableHits[k][hveck]--
+	}
+}
+
+// ResetRng resets the internal random number generator.
+func (ht *hashTables) ResetRng() {
+	ht.rng = rand.New(rand.NewSource(0))
+}
+
+// Summary returns the slice of bucket usage and the bucket size (size of each reservoir) histogram.
+func (ht *hashTables) Summary() (backetUsage []int, backetHist []int) {
+	backetUsage = make([]int, len(ht.tables))
+	backetHist = make([]int, ht._R)
+	for t, tblk := range ht.tables {
+		count := 0
+		for _, entry := range tblk {
+			if len(entry) > 0 {
+				backetHist[len(entry)-1]++
+				count++
+			}
+		}
+		backetUsage[t] = count
+	}
+	return
+}
@@ -0,0 +1,48 @@
+package sticker
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelizeEntries processes the entries indexed by [0, n) with the pool of nworkers workers.
+// Each worker calls newWorker once for getting its own processing function, so the worker-local state (for example, LabelNearestContext) can be created in newWorker.
+// The entries are assigned to the workers dynamically, so the processing function should store the result of the i-th entry at the i-th element for keeping the order.
+// If nworkers is 0, then runtime.GOMAXPROCS workers are used.
+//
+// This function returns after all entries are processed.
+func ParallelizeEntries(n, nworkers int, newWorker func() func(i int)) {
+	if nworkers <= 0 {
+		nworkers = runtime.GOMAXPROCS(0)
+	}
+	if nworkers > n {
+		nworkers = n
+	}
+	if nworkers <= 1 {
+		if n > 0 {
+			process := newWorker()
+			for i := 0; i < n; i++ {
+				process(i)
+			}
+		}
+		return
+	}
+	next := int64(-1)
+	var wg sync.WaitGroup
+	for w := 0; w < nworkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			process := newWorker()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				process(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
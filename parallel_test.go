@@ -0,0 +1,35 @@
+package sticker
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestParallelizeEntries(t *testing.T) {
+	for _, nworkers := range []int{0, 1, 3, 100} {
+		n := 50
+		results := make([]int, n)
+		nnewWorkers := int32(0)
+		ParallelizeEntries(n, nworkers, func() func(i int) {
+			atomic.AddInt32(&nnewWorkers, 1)
+			return func(i int) {
+				results[i] += i * i
+			}
+		})
+		for i, result := range results {
+			goassert.New(t, i*i).Equal(result)
+		}
+		// The workers more than the entries should not be created.
+		goassert.New(t, true).Equal(nnewWorkers >= 1 && int(nnewWorkers) <= n)
+		if nworkers > 0 && nworkers <= n {
+			goassert.New(t, int32(nworkers)).Equal(nnewWorkers)
+		}
+	}
+	// No worker should be created for no entry.
+	ParallelizeEntries(0, 4, func() func(i int) {
+		t.Errorf("newWorker should not be called")
+		return func(i int) {}
+	})
+}
@@ -10,14 +10,18 @@ import (
 // BinaryClassifierTrainer_L1SVC_DualCD trains a L1-Support Vector Classifier with Dual Coordinate Descent.
 // This is registered to sticker.BinaryClassifierTrainers.
 //
+// The upper bound of the dual variable of each entry is C multiplied by its weight.
+//
 // This function returns no error currently.
 //
 // Reference: C. Hsieh, K. Chang, C. Lin, S. S. Keerthi, and S. Sundararajan. "A Dual Coordinate Descent Method for Large-Scale Linear SVM." Proceedings of the 25th international conference on Machine learning, ACM, 2008.
-func BinaryClassifierTrainer_L1SVC_DualCD(X sticker.FeatureVectors, Y []bool, C, epsilon float32, debug *log.Logger) (*sticker.BinaryClassifier, error) {
+func BinaryClassifierTrainer_L1SVC_DualCD(X sticker.FeatureVectors, Y []bool, W []float32, C, epsilon float32, debug *log.Logger) (*sticker.BinaryClassifier, error) {
 	rng := rand.New(rand.NewSource(0))
 	n, d := len(X), X.Dim()
 	b, w := float32(0.0), make([]float32, d)
 	Qdiag, beta := make([]float32, n), make([]float32, n)
+	// Cs is the upper bound of beta weighted with W.
+	Cs := make([]float32, n)
 	pi := make([]int, n)
 	for i, xi := range X {
 		q := float32(1.0 * 1.0)
@@ -25,6 +29,10 @@ func BinaryClassifierTrainer_L1SVC_DualCD(X sticker.FeatureVectors, Y []bool, C,
 			q += xipair.Value * xipair.Value
 		}
 		Qdiag[i] = q
+		Cs[i] = C
+		if W != nil {
+			Cs[i] *= W[i]
+		}
 		pi[i] = i
 	}
 	nactives := n
@@ -38,7 +46,7 @@ func BinaryClassifierTrainer_L1SVC_DualCD(X sticker.FeatureVectors, Y []bool, C,
 		}
 		for i_ := 0; i_ < nactives; i_++ {
 			i := pi[i_]
-			xi, yi, betai := X[i], Y[i], beta[i]
+			xi, yi, betai, Ci := X[i], Y[i], beta[i], Cs[i]
 			// G: the gradient of the unconstrained case.
 			//   G = t(e_i) Q \beta - 1 = y_i t(w) x_i - 1
 			G := b * 1.0
@@ -50,7 +58,7 @@ func BinaryClassifierTrainer_L1SVC_DualCD(X sticker.FeatureVectors, Y []bool, C,
 			}
 			G -= 1.0
 			// Shrink the active entries if possible.
-			if (betai == 0.0 && maxG < G) || (betai == C && G < minG) {
+			if (betai == 0.0 && maxG < G) || (betai == Ci && G < minG) {
 				nactives--
 				pi[i_], pi[nactives] = pi[nactives], pi[i_]
 				i_--
@@ -58,14 +66,14 @@ func BinaryClassifierTrainer_L1SVC_DualCD(X sticker.FeatureVectors, Y []bool, C,
 			}
 			// PG: the projected gradient: G or 0.0 if the optimal value is out of the contraint region.
 			//   PG = min{0, G}  if \beta_i = 0
-			//        G          if 0 < \beta_i < C
-			//        max{0, G}  if \beta_i = C
+			//        G          if 0 < \beta_i < C_i
+			//        max{0, G}  if \beta_i = C_i
 			PG := G
 			if betai == 0.0 {
 				if PG > 0.0 {
 					PG = 0.0
 				}
-			} else if betai == C {
+			} else if betai == Ci {
 				if PG < 0.0 {
 					PG = 0.0
 				}
@@ -85,8 +93,8 @@ func BinaryClassifierTrainer_L1SVC_DualCD(X sticker.FeatureVectors, Y []bool, C,
 			newbetai := betai - d
 			if newbetai < 0.0 {
 				newbetai = 0.0
-			} else if newbetai > C {
-				newbetai = C
+			} else if newbetai > Ci {
+				newbetai = Ci
 			}
 			beta[i] = newbetai
 			// Update w = \sum_{i=1}^n \beta_i y_i x_i.
@@ -140,16 +148,26 @@ func BinaryClassifierTrainer_L1SVC_DualCD(X sticker.FeatureVectors, Y []bool, C,
 // It is difficult to control the scaling such that the magnitude of the first derivative equals to its corresponding newton step.
 // Otherwise, the optimization would be slow even when the first derivative is not enough small.
 // Furthermore, even if the optimization stops early, its performance is much worse than L1SVC_DualCD.
+// The loss term of each entry is weighted with C multiplied by its weight.
 //
 // This function returns no error currently.
 //
 // Reference: K. Chang, C. Hsieh, and C. Lin. "Coordinate Descent Method for Large-Scale L2-loss Linear Support Vector Machines." Journal of Machine Learning Research, vol. 9, pp. 1369-1398, 2008.
-func BinaryClassifierTrainer_L2SVC_PrimalCD(X sticker.FeatureVectors, Y []bool, C, epsilon float32, debug *log.Logger) (*sticker.BinaryClassifier, error) {
+func BinaryClassifierTrainer_L2SVC_PrimalCD(X sticker.FeatureVectors, Y []bool, W []float32, C, epsilon float32, debug *log.Logger) (*sticker.BinaryClassifier, error) {
 	// sigma is the hyper-parameter for evaluating the decrease of the objective function value.
 	beta, sigma := float32(0.5), float32(0.01)
 	// b is the bias parameter, and w is the weight vector parameter.
 	b, w := float32(0.0), make(sticker.SparseVector)
+	// Cs is the penalty parameter of each entry weighted with W.
+	Cs := make([]float32, len(X))
+	for i := range Cs {
+		Cs[i] = C
+		if W != nil {
+			Cs[i] *= W[i]
+		}
+	}
 	// featureMapSet is the set of the map for each feature to the indices of the dataset.
+	// valueMapSet is the set of the feature values at the same positions as the indices in featureMapSet.
 	featureMapSet, valueMapSet := make(map[uint32][]int), make(map[uint32][]float32)
 	for i, xi := range X {
 		for _, xipair := range xi {
@@ -166,9 +184,9 @@ func BinaryClassifierTrainer_L2SVC_PrimalCD(X sticker.FeatureVectors, Y []bool,
 	for feature, featureMap := range featureMapSet {
 		valueMap := valueMapSet[feature]
 		Hfeature := float32(0.0)
-		for _, i := range featureMap {
-			xifeature := valueMap[i]
-			Hfeature += C * xifeature * xifeature
+		for k, i := range featureMap {
+			xifeature := valueMap[k]
+			Hfeature += Cs[i] * xifeature * xifeature
 		}
 		HfeatureSet[feature] = 1 + 2*Hfeature
 	}
@@ -176,9 +194,9 @@ func BinaryClassifierTrainer_L2SVC_PrimalCD(X sticker.FeatureVectors, Y []bool,
 	Hintercept := float32(0.0)
 	for i := range X {
 		if Y[i] {
-			Hintercept += C * 1.0 * 1.0
+			Hintercept += Cs[i] * 1.0 * 1.0
 		} else {
-			Hintercept += C * 1.0 * 1.0
+			Hintercept += Cs[i] * 1.0 * 1.0
 		}
 	}
 	HfeatureSet[^uint32(0)] = 1 + 2*Hintercept
@@ -191,9 +209,9 @@ func BinaryClassifierTrainer_L2SVC_PrimalCD(X sticker.FeatureVectors, Y []bool,
 		for feature, featureMap := range featureMapSet {
 			valueMap := valueMapSet[feature]
 			// Penalty Term: 1/2 \|w\|_2^2
-			// Loss term: \sum_{i=1}^n C \max\{0, 1 - Y[i] t(w) X[i]\}
-			// First-order derivative: w[feature] + \sum_{i=1}^n C * 2 \max\{0, 1 - Y[i] t(w) X[i]\} * (-Y[i] X[i][feature]).
-			// (Generalized) Second-order derivative: 1 + 2\sum_{i=1}^n C * 2 \delta[1 - Y[i] t(w) X[i] > 0] * X[i][feature]^2.
+			// Loss term: \sum_{i=1}^n C_i \max\{0, 1 - Y[i] t(w) X[i]\}
+			// First-order derivative: w[feature] + \sum_{i=1}^n C_i * 2 \max\{0, 1 - Y[i] t(w) X[i]\} * (-Y[i] X[i][feature]).
+			// (Generalized) Second-order derivative: 1 + 2\sum_{i=1}^n C_i * 2 \delta[1 - Y[i] t(w) X[i] > 0] * X[i][feature]^2.
 			// Here, the generalized derivative of max\{0, x\} is defined as \delta[x > 0].
 			loss, d1, d2 := float32(0.0), float32(0.0), float32(0.0)
 			if feature == ^uint32(0) {
@@ -204,22 +222,22 @@ func BinaryClassifierTrainer_L2SVC_PrimalCD(X sticker.FeatureVectors, Y []bool,
 					}
 					if margin := 1 - yi*Z[i]; margin > 0 {
 						nhasMargin++
-						loss += C * margin * margin
-						d1 += C * yi * 1.0 * margin
-						d2 += C * 1.0 * 1.0
+						loss += Cs[i] * margin * margin
+						d1 += Cs[i] * yi * 1.0 * margin
+						d2 += Cs[i] * 1.0 * 1.0
 					}
 				}
 			} else {
-				for _, i := range featureMap {
-					xifeature := valueMap[i]
+				for k, i := range featureMap {
+					xifeature := valueMap[k]
 					yi := float32(-1.0)
 					if Y[i] {
 						yi = +1.0
 					}
 					if margin := 1 - yi*Z[i]; margin > 0 {
-						loss += C * margin * margin
-						d1 += C * yi * xifeature * margin
-						d2 += C * xifeature * xifeature
+						loss += Cs[i] * margin * margin
+						d1 += Cs[i] * yi * xifeature * margin
+						d2 += Cs[i] * xifeature * xifeature
 					}
 				}
 			}
@@ -245,17 +263,17 @@ func BinaryClassifierTrainer_L2SVC_PrimalCD(X sticker.FeatureVectors, Y []bool,
 							yi = +1.0
 						}
 						if newmargin := 1 - yi*(Z[i]+delta*1.0); newmargin > 0 {
-							newloss += C * newmargin * newmargin
+							newloss += Cs[i] * newmargin * newmargin
 						}
 					}
 				} else {
-					for _, i := range featureMap {
+					for k, i := range featureMap {
 						yi := float32(-1.0)
 						if Y[i] {
 							yi = +1.0
 						}
-						if newmargin := 1 - yi*(Z[i]+delta*valueMap[i]); newmargin > 0 {
-							newloss += C * newmargin * newmargin
+						if newmargin := 1 - yi*(Z[i]+delta*valueMap[k]); newmargin > 0 {
+							newloss += Cs[i] * newmargin * newmargin
 						}
 					}
 				}
@@ -274,8 +292,8 @@ func BinaryClassifierTrainer_L2SVC_PrimalCD(X sticker.FeatureVectors, Y []bool,
 					Z[i] += delta * 1.0
 				}
 			} else {
-				for _, i := range featureMap {
-					Z[i] += delta * valueMap[i]
+				for k, i := range featureMap {
+					Z[i] += delta * valueMap[k]
 				}
 			}
 			absDelta := sticker.Abs32(delta)
@@ -17,7 +17,7 @@ func TestBinaryClassifierTrainer_L1SVC_DualCD(t *testing.T) {
 		sticker.FeatureVector{sticker.KeyValue32{0, 0.0}, sticker.KeyValue32{1, 0.0}},
 		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 1.0}},
 	}, []bool{false, true}
-	bsvc1 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X1, Y1, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc1 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X1, Y1, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(t, Y1).Equal(sticker.ClassifyAllToBinaryClass(bsvc1.PredictAll(X1)))
 	// Case 2: fully-separable 2x2 points
 	X2, Y2 := sticker.FeatureVectors{
@@ -26,7 +26,7 @@ func TestBinaryClassifierTrainer_L1SVC_DualCD(t *testing.T) {
 		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 1.0}},
 		sticker.FeatureVector{sticker.KeyValue32{0, 2.0}, sticker.KeyValue32{1, 2.0}},
 	}, []bool{false, false, true, true}
-	bsvc2 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X2, Y2, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc2 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X2, Y2, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(t, Y2).Equal(sticker.ClassifyAllToBinaryClass(bsvc2.PredictAll(X2)))
 	// Case 3: fully-separable 1 and 3 points
 	X3, Y3 := sticker.FeatureVectors{
@@ -35,7 +35,7 @@ func TestBinaryClassifierTrainer_L1SVC_DualCD(t *testing.T) {
 		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 1.0}},
 		sticker.FeatureVector{sticker.KeyValue32{0, 2.0}, sticker.KeyValue32{1, 2.0}},
 	}, []bool{true, false, true, true}
-	bsvc3 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X3, Y3, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc3 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X3, Y3, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(t, Y3).Equal(sticker.ClassifyAllToBinaryClass(bsvc3.PredictAll(X3)))
 	// Case 4: fully-separable 3 and 1 points
 	X4, Y4 := sticker.FeatureVectors{
@@ -44,11 +44,21 @@ func TestBinaryClassifierTrainer_L1SVC_DualCD(t *testing.T) {
 		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 1.0}},
 		sticker.FeatureVector{sticker.KeyValue32{0, 2.0}, sticker.KeyValue32{1, 2.0}},
 	}, []bool{false, false, false, true}
-	bsvc4 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X4, Y4, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc4 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X4, Y4, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(t, Y4).Equal(sticker.ClassifyAllToBinaryClass(bsvc4.PredictAll(X4)))
+	// Case 5: the conflicting points weighted differently
+	X5, Y5 := sticker.FeatureVectors{
+		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}},
+		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}},
+		sticker.FeatureVector{sticker.KeyValue32{1, 1.0}},
+	}, []bool{false, true, false}
+	bsvc5 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X5, Y5, []float32{1.0, 10.0, 1.0}, C, epsilon, nil)).(*sticker.BinaryClassifier)
+	goassert.New(t, []bool{true, true, false}).Equal(sticker.ClassifyAllToBinaryClass(bsvc5.PredictAll(X5)))
+	bsvc5 = goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X5, Y5, []float32{10.0, 1.0, 1.0}, C, epsilon, nil)).(*sticker.BinaryClassifier)
+	goassert.New(t, []bool{false, false, false}).Equal(sticker.ClassifyAllToBinaryClass(bsvc5.PredictAll(X5)))
 	// Expect any debug log.
 	var debugBuffer bytes.Buffer
-	goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X1, Y1, C, epsilon, log.New(&debugBuffer, "", 0)))
+	goassert.New(t).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X1, Y1, nil, C, epsilon, log.New(&debugBuffer, "", 0)))
 	goassert.New(t, true).Equal(debugBuffer.String() != "")
 }
 
@@ -59,7 +69,7 @@ func TestBinaryClassifierTrainer_L2SVC_PrimalCD(t *testing.T) {
 		sticker.FeatureVector{sticker.KeyValue32{0, 0.0}, sticker.KeyValue32{1, 0.0}},
 		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 1.0}},
 	}, []bool{false, true}
-	bsvc1 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X1, Y1, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc1 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X1, Y1, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(t, Y1).Equal(sticker.ClassifyAllToBinaryClass(bsvc1.PredictAll(X1)))
 	// Case 2: fully-separable 2x2 points
 	X2, Y2 := sticker.FeatureVectors{
@@ -68,7 +78,7 @@ func TestBinaryClassifierTrainer_L2SVC_PrimalCD(t *testing.T) {
 		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 1.0}},
 		sticker.FeatureVector{sticker.KeyValue32{0, 2.0}, sticker.KeyValue32{1, 2.0}},
 	}, []bool{false, false, true, true}
-	bsvc2 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X2, Y2, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc2 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X2, Y2, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(t, Y2).Equal(sticker.ClassifyAllToBinaryClass(bsvc2.PredictAll(X2)))
 	// Case 3: fully-separable 1 and 3 points
 	X3, Y3 := sticker.FeatureVectors{
@@ -77,7 +87,7 @@ func TestBinaryClassifierTrainer_L2SVC_PrimalCD(t *testing.T) {
 		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 1.0}},
 		sticker.FeatureVector{sticker.KeyValue32{0, 2.0}, sticker.KeyValue32{1, 2.0}},
 	}, []bool{true, false, true, true}
-	bsvc3 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X3, Y3, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc3 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X3, Y3, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(t, Y3).Equal(sticker.ClassifyAllToBinaryClass(bsvc3.PredictAll(X3)))
 	// Case 4: fully-separable 3 and 1 points
 	X4, Y4 := sticker.FeatureVectors{
@@ -86,11 +96,30 @@ func TestBinaryClassifierTrainer_L2SVC_PrimalCD(t *testing.T) {
 		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 1.0}},
 		sticker.FeatureVector{sticker.KeyValue32{0, 2.0}, sticker.KeyValue32{1, 2.0}},
 	}, []bool{false, false, false, true}
-	bsvc4 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X4, Y4, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc4 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X4, Y4, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(t, Y4).Equal(sticker.ClassifyAllToBinaryClass(bsvc4.PredictAll(X4)))
+	// Case 5: fully-separable sparse points, where each feature is missing in some entries
+	X5, Y5 := sticker.FeatureVectors{
+		sticker.FeatureVector{sticker.KeyValue32{1, 1.0}},
+		sticker.FeatureVector{sticker.KeyValue32{1, 2.0}},
+		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}},
+		sticker.FeatureVector{sticker.KeyValue32{0, 2.0}, sticker.KeyValue32{1, 0.5}},
+	}, []bool{false, false, true, true}
+	bsvc5 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X5, Y5, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	goassert.New(t, Y5).Equal(sticker.ClassifyAllToBinaryClass(bsvc5.PredictAll(X5)))
+	// Case 6: the conflicting points weighted differently
+	X6, Y6 := sticker.FeatureVectors{
+		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}},
+		sticker.FeatureVector{sticker.KeyValue32{0, 1.0}},
+		sticker.FeatureVector{sticker.KeyValue32{1, 1.0}},
+	}, []bool{false, true, false}
+	bsvc6 := goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X6, Y6, []float32{1.0, 10.0, 1.0}, C, epsilon, nil)).(*sticker.BinaryClassifier)
+	goassert.New(t, []bool{true, true, false}).Equal(sticker.ClassifyAllToBinaryClass(bsvc6.PredictAll(X6)))
+	bsvc6 = goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X6, Y6, []float32{10.0, 1.0, 1.0}, C, epsilon, nil)).(*sticker.BinaryClassifier)
+	goassert.New(t, []bool{false, false, false}).Equal(sticker.ClassifyAllToBinaryClass(bsvc6.PredictAll(X6)))
 	// Expect any debug log.
 	var debugBuffer bytes.Buffer
-	goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X1, Y1, C, epsilon, log.New(&debugBuffer, "", 0)))
+	goassert.New(t).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X1, Y1, nil, C, epsilon, log.New(&debugBuffer, "", 0)))
 	goassert.New(t, true).Equal(debugBuffer.String() != "")
 }
 
@@ -118,7 +147,7 @@ func BenchmarkBinaryClassifierTrainer_L1SVC_DualCD(b *testing.B) {
 	C, epsilon, debug := float32(3.0), float32(0.1), (*log.Logger)(nil)
 	X, Y := createBenchmarkDatasetForBinaryClassifier()
 	// Check the integrity
-	bsvc := goassert.New(b).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X, Y, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc := goassert.New(b).SucceedNew(BinaryClassifierTrainer_L1SVC_DualCD(X, Y, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(b, Y).Equal(sticker.ClassifyAllToBinaryClass(bsvc.PredictAll(X)))
 	// Check the number of support vectors.
 	nSVs := 0
@@ -133,7 +162,7 @@ func BenchmarkBinaryClassifierTrainer_L1SVC_DualCD(b *testing.B) {
 	}
 	b.ResetTimer()
 	for t := 0; t < b.N; t++ {
-		BinaryClassifierTrainer_L1SVC_DualCD(X, Y, C, epsilon, nil)
+		BinaryClassifierTrainer_L1SVC_DualCD(X, Y, nil, C, epsilon, nil)
 	}
 }
 
@@ -141,10 +170,10 @@ func BenchmarkBinaryClassifierTrainer_L2SVC_PrimalCD(b *testing.B) {
 	C, epsilon, debug := float32(3.0), float32(0.1), (*log.Logger)(nil)
 	X, Y := createBenchmarkDatasetForBinaryClassifier()
 	// Check the integrity
-	bsvc := goassert.New(b).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X, Y, C, epsilon, debug)).(*sticker.BinaryClassifier)
+	bsvc := goassert.New(b).SucceedNew(BinaryClassifierTrainer_L2SVC_PrimalCD(X, Y, nil, C, epsilon, debug)).(*sticker.BinaryClassifier)
 	goassert.New(b, Y).Equal(sticker.ClassifyAllToBinaryClass(bsvc.PredictAll(X)))
 	b.ResetTimer()
 	for t := 0; t < b.N; t++ {
-		BinaryClassifierTrainer_L2SVC_PrimalCD(X, Y, C, epsilon, nil)
+		BinaryClassifierTrainer_L2SVC_PrimalCD(X, Y, nil, C, epsilon, nil)
 	}
 }
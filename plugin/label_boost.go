@@ -61,9 +61,13 @@ type LabelBoost struct {
 	// Summaries is the summary object slice for each boosting round.
 	// The entries in this summary is considered to provide compact and useful information in best-effort, so this specification would be loose and rapidly changing.
 	Summaries []map[string]interface{}
+	//
+	// FeatureVocabulary and LabelVocabulary are the vocabularies of the features and labels in training, or nil if unknown.
+	FeatureVocabulary, LabelVocabulary *sticker.Vocabulary
 }
 
 // TrainLabelBoost returns an trained LabelBoost on the given dataset ds.
+// The penalty parameter of each entry (or each positive/negative pair) is weighted with the weight of the entry (or the product of the weights of the pair) in ds.W.
 func TrainLabelBoost(ds *sticker.Dataset, params *LabelBoostParameters, debug *log.Logger) (*LabelBoost, error) {
 	rng := rand.New(rand.NewSource(0))
 	painter, ok := Painters[params.PainterName]
@@ -132,7 +136,7 @@ func TrainLabelBoost(ds *sticker.Dataset, params *LabelBoostParameters, debug *l
 						maxNegZi = zij
 					}
 				}
-				deltai, weighti := false, params.C
+				deltai, weighti := false, params.C*ds.Weight(i)
 				if nremains == 0 {
 					deltai = true
 				} else if nremains < len(labelSet) {
@@ -202,7 +206,7 @@ func TrainLabelBoost(ds *sticker.Dataset, params *LabelBoostParameters, debug *l
 								break
 							}
 						}
-						pairIndices, pairMargins, pairCs = append(pairIndices, [2]int{i, j}), append(pairMargins, zil-zjl), append(pairCs, params.C/float32(len(labelList)*int(params.NegativeSampleSize)*len(positiveList)))
+						pairIndices, pairMargins, pairCs = append(pairIndices, [2]int{i, j}), append(pairMargins, zil-zjl), append(pairCs, ds.Weight(i)*ds.Weight(j)*params.C/float32(len(labelList)*int(params.NegativeSampleSize)*len(positiveList)))
 					}
 				}
 			}
@@ -294,6 +298,10 @@ func DecodeLabelBoostWithGobDecoder(model *LabelBoost, decoder *gob.Decoder) err
 	if err := decoder.Decode(&model.Summaries); err != nil {
 		return fmt.Errorf("DecodeLabelBoost: Summaries: %s", err)
 	}
+	var err error
+	if model.FeatureVocabulary, model.LabelVocabulary, err = sticker.DecodeModelVocabulariesWithGobDecoder(decoder); err != nil {
+		return fmt.Errorf("DecodeLabelBoost: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -338,6 +346,9 @@ func EncodeLabelBoostWithGobEncoder(model *LabelBoost, encoder *gob.Encoder) err
 	if err := encoder.Encode(model.Summaries); err != nil {
 		return fmt.Errorf("EncodeLabelBoost: Summaries: %s", err)
 	}
+	if err := sticker.EncodeModelVocabulariesWithGobEncoder(model.FeatureVocabulary, model.LabelVocabulary, encoder); err != nil {
+		return fmt.Errorf("EncodeLabelBoost: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -362,6 +373,11 @@ func (model *LabelBoost) Nrounds() uint {
 
 // Predict returns the top-K predicted labels for the given data point x with the first T rounds.
 func (model *LabelBoost) Predict(x sticker.FeatureVector, K uint, T uint) sticker.LabelVector {
+	return sticker.RankTopK(model.predictLabelDist(x, T), K)
+}
+
+// predictLabelDist returns the accumulated margin of each label for the given data point x with the first T rounds.
+func (model *LabelBoost) predictLabelDist(x sticker.FeatureVector, T uint) sticker.SparseVector {
 	if T > model.Nrounds() {
 		T = model.Nrounds()
 	}
@@ -374,21 +390,66 @@ func (model *LabelBoost) Predict(x sticker.FeatureVector, K uint, T uint) sticke
 			z[weightpair.Key] += weightpair.Value * xpair.Value
 		}
 	}
-	y := make(map[uint32]float32)
+	y := make(sticker.SparseVector)
 	for t, zt := range z {
 		zt += model.Biases[t]
 		for _, label := range model.LabelLists[t] {
 			y[label] += zt
 		}
 	}
-	return sticker.RankTopK(y, K)
+	return y
 }
 
 // PredictAll returns the slice of the top-K predicted labels for each data point in X with the first T rounds.
+// The data points are predicted by runtime.GOMAXPROCS workers (see PredictAllWithWorkers).
 func (model *LabelBoost) PredictAll(X sticker.FeatureVectors, K uint, T uint) sticker.LabelVectors {
-	Y := make(sticker.LabelVectors, 0, len(X))
-	for _, xi := range X {
-		Y = append(Y, model.Predict(xi, K, T))
-	}
+	return model.PredictAllWithWorkers(X, K, T, 0)
+}
+
+// PredictAllWithScores returns the slice of the top-K predicted labels with their accumulated margins for each data point in X with the first T rounds.
+// The data points are predicted by runtime.GOMAXPROCS workers.
+func (model *LabelBoost) PredictAllWithScores(X sticker.FeatureVectors, K uint, T uint) []sticker.KeyValues32 {
+	Y := make([]sticker.KeyValues32, len(X))
+	sticker.ParallelizeEntries(len(X), 0, func() func(i int) {
+		return func(i int) {
+			Y[i] = model.PredictWithScores(X[i], K, T)
+		}
+	})
+	return Y
+}
+
+// PredictAllWithWorkers is PredictAll with nworkers workers (see sticker.ParallelizeEntries).
+func (model *LabelBoost) PredictAllWithWorkers(X sticker.FeatureVectors, K uint, T uint, nworkers int) sticker.LabelVectors {
+	Y := make(sticker.LabelVectors, len(X))
+	sticker.ParallelizeEntries(len(X), nworkers, func() func(i int) {
+		return func(i int) {
+			Y[i] = model.Predict(X[i], K, T)
+		}
+	})
 	return Y
 }
+
+// PredictWithScores returns the top-K predicted labels with their accumulated margins for the given data point x with the first T rounds.
+func (model *LabelBoost) PredictWithScores(x sticker.FeatureVector, K uint, T uint) sticker.KeyValues32 {
+	return sticker.RankTopKWithScores(model.predictLabelDist(x, T), K)
+}
+
+// LabelBoostPredictor is the sticker.Predictor of LabelBoost with the first T rounds.
+type LabelBoostPredictor struct {
+	Model *LabelBoost
+	T     uint
+}
+
+// NewLabelBoostPredictor returns a new LabelBoostPredictor.
+func NewLabelBoostPredictor(model *LabelBoost, T uint) *LabelBoostPredictor {
+	return &LabelBoostPredictor{
+		Model: model,
+		T:     T,
+	}
+}
+
+// Predict returns the top-K labels with their accumulated margins for x.
+func (predictor *LabelBoostPredictor) Predict(x sticker.FeatureVector, K uint) ([]sticker.LabelScore, error) {
+	labelDist := predictor.Model.predictLabelDist(x, predictor.T)
+	return sticker.MakeLabelScores(sticker.RankTopK(labelDist, K), labelDist), nil
+}
@@ -71,6 +71,7 @@ type LabelTree struct {
 
 // TrainLabelTree returns a trained LabelTree on the given dataset.
 // The 16 MSBs of seed are used as the tree id which is reported in the debug log.
+// The label frequency tables are weighted with ds.W, and ds.W is passed to the binary classifier trainer.
 //
 // This function returns an error if the height of the tree is greater than 64 or in training the tree.
 func TrainLabelTree(ds *sticker.Dataset, params *LabelTreeParameters, seed int64, debug *log.Logger) (*LabelTree, error) {
@@ -107,9 +108,10 @@ func TrainLabelTree(ds *sticker.Dataset, params *LabelTreeParameters, seed int64
 		stackId, stackDs = stackId[:len(stackId)-1], stackDs[:len(stackDs)-1]
 		// Set the label frequency table of the leaf.
 		labelFreq := make(sticker.SparseVector)
-		for _, yi := range subds.Y {
+		for i, yi := range subds.Y {
+			wi := subds.Weight(i)
 			for _, label := range yi {
-				labelFreq[label]++
+				labelFreq[label] += wi
 			}
 		}
 		K := params.K
@@ -150,7 +152,7 @@ func TrainLabelTree(ds *sticker.Dataset, params *LabelTreeParameters, seed int64
 		if debug != nil {
 			debug.Printf("TrainLabelTree(seed>>48=%d,leafId=0b%b): training the splitter: %d in left and %d in right ...", seed>>48, leafId, nLeftRights[false], nLeftRights[true])
 		}
-		splitter, err := binaryClassifierTrainer(subsubds.X, delta, params.C, params.Epsilon, nil)
+		splitter, err := binaryClassifierTrainer(subsubds.X, delta, subsubds.W, params.C, params.Epsilon, nil)
 		if err != nil {
 			if debug != nil {
 				debug.Printf("TrainLabelTree(seed>>48=%d,leafId=0b%b): BinaryClassifierTrainer(%s): %s", seed>>48, leafId, params.ClassifierTrainerName, err)
@@ -172,19 +174,28 @@ func TrainLabelTree(ds *sticker.Dataset, params *LabelTreeParameters, seed int64
 		}, &sticker.Dataset{
 			Y: make(sticker.LabelVectors, 0, nPredLeftRights[false]),
 		}
+		if subds.W != nil {
+			leftSubds.W, rightSubds.W = make([]float32, 0, nPredLeftRights[false]), make([]float32, 0, nPredLeftRights[true])
+		}
 		leftLabelFreq, rightLabelFreq := make(sticker.SparseVector), make(sticker.SparseVector)
 		for i, predDeltai := range predDelta {
-			yi := subds.Y[i]
+			yi, wi := subds.Y[i], subds.Weight(i)
 			var labelFreqi sticker.SparseVector
 			if predDeltai {
 				rightSubds.Y = append(rightSubds.Y, yi)
 				labelFreqi = rightLabelFreq
+				if subds.W != nil {
+					rightSubds.W = append(rightSubds.W, wi)
+				}
 			} else {
 				leftSubds.Y = append(leftSubds.Y, yi)
 				labelFreqi = leftLabelFreq
+				if subds.W != nil {
+					leftSubds.W = append(leftSubds.W, wi)
+				}
 			}
 			for _, label := range yi {
-				labelFreqi[label]++
+				labelFreqi[label] += wi
 			}
 		}
 		leftLabelRankTopK, rightLabelRankTopK := sticker.RankTopK(leftLabelFreq, params.K), sticker.RankTopK(rightLabelFreq, params.K)
@@ -486,6 +497,9 @@ type LabelForest struct {
 	// SummaryS is the sub-sampling summary.
 	// This summary is considered to provide compact and useful information in best-effort, so this specification would be loose and rapidly changing.
 	Summary map[string]interface{}
+	//
+	// FeatureVocabulary and LabelVocabulary are the vocabularies of the features and labels in training, or nil if unknown.
+	FeatureVocabulary, LabelVocabulary *sticker.Vocabulary
 }
 
 // TrainLabelForest returns a trained LabelForest on ds with multiple go-routines.
@@ -609,6 +623,10 @@ func DecodeLabelForestWithGobDecoder(forest *LabelForest, decoder *gob.Decoder)
 	if err := decoder.Decode(&forest.Summary); err != nil {
 		return fmt.Errorf("DecodeLabelForest: Summary: %s", err)
 	}
+	var err error
+	if forest.FeatureVocabulary, forest.LabelVocabulary, err = sticker.DecodeModelVocabulariesWithGobDecoder(decoder); err != nil {
+		return fmt.Errorf("DecodeLabelForest: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -639,6 +657,9 @@ func EncodeLabelForestWithGobEncoder(forest *LabelForest, encoder *gob.Encoder)
 	if err := encoder.Encode(forest.Summary); err != nil {
 		return fmt.Errorf("EncodeLabelForest: Summary: %s", err)
 	}
+	if err := sticker.EncodeModelVocabulariesWithGobEncoder(forest.FeatureVocabulary, forest.LabelVocabulary, encoder); err != nil {
+		return fmt.Errorf("EncodeLabelForest: vocabularies: %s", err)
+	}
 	return nil
 }
 
@@ -670,23 +691,34 @@ func (forest *LabelForest) ClassifyWithWeight(x sticker.FeatureVector) ([]uint64
 }
 
 // ClassifyAll returns the slice of the leaf id slices for each feature vector.
+// The feature vectors are classified by runtime.GOMAXPROCS workers (see ClassifyAllWithWorkers).
 func (forest *LabelForest) ClassifyAll(X sticker.FeatureVectors) [][]uint64 {
-	leafIdsSlice := make([][]uint64, len(X))
-	for i, x := range X {
-		leafIdsSlice[i] = forest.Classify(x)
-	}
-	return leafIdsSlice
+	return forest.ClassifyAllWithWorkers(X, 0)
 }
 
 // ClassifyAllWithWeight returns the slice of the leaf id slices and the weight slices for each feature vector.
+// The feature vectors are classified by runtime.GOMAXPROCS workers.
 func (forest *LabelForest) ClassifyAllWithWeight(X sticker.FeatureVectors) ([][]uint64, [][]float32) {
 	leafIdsSlice, weightsSlice := make([][]uint64, len(X)), make([][]float32, len(X))
-	for i, x := range X {
-		leafIdsSlice[i], weightsSlice[i] = forest.ClassifyWithWeight(x)
-	}
+	sticker.ParallelizeEntries(len(X), 0, func() func(i int) {
+		return func(i int) {
+			leafIdsSlice[i], weightsSlice[i] = forest.ClassifyWithWeight(X[i])
+		}
+	})
 	return leafIdsSlice, weightsSlice
 }
 
+// ClassifyAllWithWorkers is ClassifyAll with nworkers workers (see sticker.ParallelizeEntries).
+func (forest *LabelForest) ClassifyAllWithWorkers(X sticker.FeatureVectors, nworkers int) [][]uint64 {
+	leafIdsSlice := make([][]uint64, len(X))
+	sticker.ParallelizeEntries(len(X), nworkers, func() func(i int) {
+		return func(i int) {
+			leafIdsSlice[i] = forest.Classify(X[i])
+		}
+	})
+	return leafIdsSlice
+}
+
 // GobEncode returns the error always, because users should encode large LabelForest objects with EncodeLabelForest.
 func (forest *LabelForest) GobEncode() ([]byte, error) {
 	return nil, fmt.Errorf("LabelForest should be encoded with EncodeLabelForest")
@@ -694,50 +726,117 @@ func (forest *LabelForest) GobEncode() ([]byte, error) {
 
 // Predict returns the top-K labels for the given result of Classify.
 func (forest *LabelForest) Predict(leafIds []uint64, K uint) sticker.LabelVector {
-	labelDist := make(map[uint32]float32)
+	return sticker.RankTopK(forest.predictLabelDist(leafIds, nil), K)
+}
+
+// predictLabelDist returns the average of the normalized label frequencies of the leaves weighted by weights.
+// The weights are 1.0 if weights is nil.
+// The sum is returned as it is if the sum of the weights is not positive or infinite (for example, the weight of the tree having only the root leaf is +Inf).
+func (forest *LabelForest) predictLabelDist(leafIds []uint64, weights []float32) sticker.SparseVector {
+	labelDist := make(sticker.SparseVector)
+	sumWeights := float32(0.0)
 	for treeId, tree := range forest.Trees {
 		labelFreq := tree.LabelFreqSet[leafIds[treeId]]
 		Z := float32(0.0)
 		for _, freq := range labelFreq {
 			Z += freq
 		}
+		weight := float32(1.0)
+		if weights != nil {
+			weight = weights[treeId]
+		}
 		for label, freq := range labelFreq {
-			labelDist[label] += freq / Z
+			labelDist[label] += freq / Z * weight
 		}
+		sumWeights += weight
 	}
-	return sticker.RankTopK(labelDist, K)
+	if sumWeights > 0.0 && !sticker.IsInf32(sumWeights, +1) {
+		for label := range labelDist {
+			labelDist[label] /= sumWeights
+		}
+	}
+	return labelDist
+}
+
+// PredictWithScores returns the top-K labels with their averaged normalized frequencies in the leaves for the given result of Classify or ClassifyWithWeight.
+// The leaves are weighted by weights, which is nil for the result of Classify.
+func (forest *LabelForest) PredictWithScores(leafIds []uint64, weights []float32, K uint) sticker.KeyValues32 {
+	return sticker.RankTopKWithScores(forest.predictLabelDist(leafIds, weights), K)
 }
 
 // PredictWithWeight returns the top-K labels for the given result of ClassifyWithWeight.
 func (forest *LabelForest) PredictWithWeight(leafIds []uint64, weights []float32, K uint) sticker.LabelVector {
-	labelDist := make(map[uint32]float32)
-	for treeId, tree := range forest.Trees {
-		labelFreq := tree.LabelFreqSet[leafIds[treeId]]
-		Z := float32(0.0)
-		for _, freq := range labelFreq {
-			Z += freq
-		}
-		for label, freq := range labelFreq {
-			labelDist[label] += freq / Z * weights[treeId]
-		}
-	}
-	return sticker.RankTopK(labelDist, K)
+	return sticker.RankTopK(forest.predictLabelDist(leafIds, weights), K)
 }
 
 // PredictAll returns the top-K labels for the given result of ClassifyAll.
+// The results are predicted by runtime.GOMAXPROCS workers (see PredictAllWithWorkers).
 func (forest *LabelForest) PredictAll(leafIdsSlice [][]uint64, K uint) sticker.LabelVectors {
-	YK := make(sticker.LabelVectors, len(leafIdsSlice))
-	for i, leafIds := range leafIdsSlice {
-		YK[i] = forest.Predict(leafIds, K)
-	}
+	return forest.PredictAllWithWorkers(leafIdsSlice, K, 0)
+}
+
+// PredictAllWithScores returns the top-K labels with their averaged normalized frequencies in the leaves for the given result of ClassifyAll or ClassifyAllWithWeight.
+// weightsSlice is nil for the result of ClassifyAll.
+// The results are predicted by runtime.GOMAXPROCS workers.
+func (forest *LabelForest) PredictAllWithScores(leafIdsSlice [][]uint64, weightsSlice [][]float32, K uint) []sticker.KeyValues32 {
+	YK := make([]sticker.KeyValues32, len(leafIdsSlice))
+	sticker.ParallelizeEntries(len(leafIdsSlice), 0, func() func(i int) {
+		return func(i int) {
+			var weights []float32
+			if weightsSlice != nil {
+				weights = weightsSlice[i]
+			}
+			YK[i] = forest.PredictWithScores(leafIdsSlice[i], weights, K)
+		}
+	})
 	return YK
 }
 
 // PredictAllWithWeight returns the top-K labels for the given result of ClassifyAllWithWeight.
+// The results are predicted by runtime.GOMAXPROCS workers.
 func (forest *LabelForest) PredictAllWithWeight(leafIdsSlice [][]uint64, weightsSlice [][]float32, K uint) sticker.LabelVectors {
 	YK := make(sticker.LabelVectors, len(leafIdsSlice))
-	for i, leafIds := range leafIdsSlice {
-		YK[i] = forest.PredictWithWeight(leafIds, weightsSlice[i], K)
-	}
+	sticker.ParallelizeEntries(len(leafIdsSlice), 0, func() func(i int) {
+		return func(i int) {
+			YK[i] = forest.PredictWithWeight(leafIdsSlice[i], weightsSlice[i], K)
+		}
+	})
 	return YK
 }
+
+// PredictAllWithWorkers is PredictAll with nworkers workers (see sticker.ParallelizeEntries).
+func (forest *LabelForest) PredictAllWithWorkers(leafIdsSlice [][]uint64, K uint, nworkers int) sticker.LabelVectors {
+	YK := make(sticker.LabelVectors, len(leafIdsSlice))
+	sticker.ParallelizeEntries(len(leafIdsSlice), nworkers, func() func(i int) {
+		return func(i int) {
+			YK[i] = forest.Predict(leafIdsSlice[i], K)
+		}
+	})
+	return YK
+}
+
+// LabelForestPredictor is the sticker.Predictor of LabelForest.
+// If Weighted is true, then the leaves are weighted as ClassifyWithWeight.
+type LabelForestPredictor struct {
+	Forest   *LabelForest
+	Weighted bool
+}
+
+// NewLabelForestPredictor returns a new LabelForestPredictor.
+func NewLabelForestPredictor(forest *LabelForest, weighted bool) *LabelForestPredictor {
+	return &LabelForestPredictor{
+		Forest:   forest,
+		Weighted: weighted,
+	}
+}
+
+// Predict returns the top-K labels with their averaged normalized frequencies in the leaves for x.
+func (predictor *LabelForestPredictor) Predict(x sticker.FeatureVector, K uint) ([]sticker.LabelScore, error) {
+	var labelDist sticker.SparseVector
+	if predictor.Weighted {
+		labelDist = predictor.Forest.predictLabelDist(predictor.Forest.ClassifyWithWeight(x))
+	} else {
+		labelDist = predictor.Forest.predictLabelDist(predictor.Forest.Classify(x), nil)
+	}
+	return sticker.MakeLabelScores(sticker.RankTopK(labelDist, K), labelDist), nil
+}
@@ -177,6 +177,17 @@ func TestTrainLabelTree(t *testing.T) {
 	treeSingleton := goassert.New(t).SucceedNew(TrainLabelTree(dsSingleton, params, 0, nil)).(*LabelTree)
 	goassert.New(t, true).Equal(treeSingleton.IsTerminalLeaf(0x1))
 	goassert.New(t, sticker.SparseVector{0: float32(n), 1: float32(n)}).Equal(treeSingleton.LabelFreqSet[0x1])
+	// The label frequency tables should be weighted with the weights of the entries.
+	wds := &sticker.Dataset{
+		X: sticker.FeatureVectors{
+			sticker.FeatureVector{sticker.KeyValue32{0, 1.0}},
+			sticker.FeatureVector{sticker.KeyValue32{0, -1.0}},
+		},
+		Y: sticker.LabelVectors{sticker.LabelVector{0, 1}, sticker.LabelVector{0, 2}},
+		W: []float32{0.5, 2.0},
+	}
+	wtree := goassert.New(t).SucceedNew(TrainLabelTree(wds, NewLabelTreeParameters(), 0, nil)).(*LabelTree)
+	goassert.New(t, sticker.SparseVector{0: 2.5, 1: 0.5, 2: 2.0}).Equal(wtree.LabelFreqSet[0x1])
 }
 
 func TestLabelForestClassify_Predict(t *testing.T) {
@@ -237,6 +248,18 @@ func TestLabelForestClassify_Predict(t *testing.T) {
 	goassert.New(t, sticker.LabelVectors{sticker.LabelVector{0, 9, ^uint32(0)}, sticker.LabelVector{9, 0, 3}, sticker.LabelVector{9, 2, 3}}).Equal(forest.PredictAll(leafIdsSlice, 3))
 	goassert.New(t, sticker.LabelVectors{sticker.LabelVector{0, 9, ^uint32(0), ^uint32(0)}, sticker.LabelVector{9, 0, 3, ^uint32(0)}, sticker.LabelVector{9, 2, 3, 0}}).Equal(forest.PredictAll(leafIdsSlice, 4))
 	goassert.New(t, sticker.LabelVectors{sticker.LabelVector{0, 9, ^uint32(0), ^uint32(0)}, sticker.LabelVector{9, 0, 3, ^uint32(0)}, sticker.LabelVector{9, 3, 2, 0}}).Equal(forest.PredictAllWithWeight(leafIdsSlice, weightsSlice, 4))
+	// The scores should be the averaged normalized frequencies in the leaves.
+	goassert.New(t, []sticker.KeyValues32{
+		sticker.KeyValues32{sticker.KeyValue32{0, 0.5}, sticker.KeyValue32{9, 0.5}},
+		sticker.KeyValues32{sticker.KeyValue32{9, (1.0/2 + 1.0/2 + 1.0/3) / 3}, sticker.KeyValue32{0, 1.0 / 3}},
+	}).Equal(forest.PredictAllWithScores(leafIdsSlice[:2], nil, 2))
+	goassert.New(t, sticker.KeyValues32{sticker.KeyValue32{9, (1.0/2 + 1.0/3 + 2.0/3) / 4}, sticker.KeyValue32{3, 2.0 / 3 * 2 / 4}}).Equal(forest.PredictAllWithScores(leafIdsSlice, weightsSlice, 2)[2])
+	// The infinite weight of the tree having only the root leaf should not make the scores NaN.
+	goassert.New(t, sticker.LabelVector{0, 1, 9}).Equal(forest.PredictWithWeight([]uint64{0x1, 0x2, 0x2}, []float32{sticker.Inf32(+1), 1, 1}, 3))
+	// The predictor should predict the same labels with the averaged normalized frequencies.
+	goassert.New(t, forest.PredictAll(leafIdsSlice, 4)).EqualWithoutError(sticker.PredictAllWithPredictor(NewLabelForestPredictor(forest, false), X, 4, 0))
+	goassert.New(t, forest.PredictAllWithWeight(leafIdsSlice, weightsSlice, 4)).EqualWithoutError(sticker.PredictAllWithPredictor(NewLabelForestPredictor(forest, true), X, 4, 0))
+	goassert.New(t, []sticker.LabelScore{{0, 0.5}, {9, 0.5}}).EqualWithoutError(NewLabelForestPredictor(forest, false).Predict(X[0], 4))
 }
 
 func TestTrainLabelForest(t *testing.T) {
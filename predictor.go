@@ -0,0 +1,68 @@
+package sticker
+
+import (
+	"sync"
+)
+
+// LabelScore is the label with its score predicted by Predictor.
+type LabelScore struct {
+	Label uint32
+	Score float32
+}
+
+// MakeLabelScores returns the label scores of the labels in labelDist.
+// The padding label ^uint32(0) in labels is skipped, so this can be used with the output of RankTopK.
+func MakeLabelScores(labels LabelVector, labelDist SparseVector) []LabelScore {
+	labelScores := make([]LabelScore, 0, len(labels))
+	for _, label := range labels {
+		if label != ^uint32(0) {
+			labelScores = append(labelScores, LabelScore{label, labelDist[label]})
+		}
+	}
+	return labelScores
+}
+
+// Predictor is the common interface of the label models whose inference parameters are bound.
+// Each model has its adapter (for example, NewLabelNearestPredictor), so the callers can handle every model in the same way.
+// Predict should be safe for concurrent use, because PredictAllWithPredictor calls it on multiple workers.
+type Predictor interface {
+	// Predict returns the top-K labels with their scores in descending order of score for the given data entry x.
+	// The returned slice has at most K labels, and is not padded with ^uint32(0) unlike RankTopK.
+	Predict(x FeatureVector, K uint) ([]LabelScore, error)
+}
+
+// PredictAllWithPredictor returns the top-K labels for each data entry in X predicted by predictor with nworkers workers (see ParallelizeEntries).
+// Each label vector is padded with ^uint32(0) like RankTopK, so the results can be used with ReportPrecision and so on.
+//
+// This function returns the error of the first failed data entry in prediction.
+func PredictAllWithPredictor(predictor Predictor, X FeatureVectors, K uint, nworkers int) (LabelVectors, error) {
+	Yhat := make(LabelVectors, len(X))
+	var mutexErr sync.Mutex
+	erri, err := len(X), error(nil)
+	ParallelizeEntries(len(X), nworkers, func() func(i int) {
+		return func(i int) {
+			labelScores, errPredict := predictor.Predict(X[i], K)
+			if errPredict != nil {
+				mutexErr.Lock()
+				if erri > i {
+					erri, err = i, errPredict
+				}
+				mutexErr.Unlock()
+				return
+			}
+			yihat := make(LabelVector, K)
+			for rank := range yihat {
+				if rank < len(labelScores) {
+					yihat[rank] = labelScores[rank].Label
+				} else {
+					yihat[rank] = ^uint32(0)
+				}
+			}
+			Yhat[i] = yihat
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Yhat, nil
+}
@@ -0,0 +1,33 @@
+package sticker
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+type errorPredictor struct{}
+
+func (predictor errorPredictor) Predict(x FeatureVector, K uint) ([]LabelScore, error) {
+	return nil, fmt.Errorf("cannot predict")
+}
+
+func TestMakeLabelScores(t *testing.T) {
+	goassert.New(t, []LabelScore{{3, 2.0}, {1, 1.0}, {2, 0.0}}).Equal(MakeLabelScores(LabelVector{3, 1, 2, ^uint32(0)}, SparseVector{1: 1.0, 3: 2.0}))
+	goassert.New(t, []LabelScore{}).Equal(MakeLabelScores(LabelVector{}, SparseVector{1: 1.0}))
+}
+
+func TestPredictAllWithPredictor(t *testing.T) {
+	model := &LabelConst{
+		LabelList:     LabelVector{1, 0},
+		LabelFreqList: []float32{2, 1},
+	}
+	var predictor Predictor = NewLabelConstPredictor(model)
+	goassert.New(t, []LabelScore{{1, 2}}).EqualWithoutError(predictor.Predict(FeatureVector{}, 1))
+	goassert.New(t, []LabelScore{{1, 2}, {0, 1}}).EqualWithoutError(predictor.Predict(FeatureVector{}, 3))
+	X := FeatureVectors{FeatureVector{}, FeatureVector{KeyValue32{0, 1.0}}}
+	goassert.New(t, model.PredictAll(X, 3)).EqualWithoutError(PredictAllWithPredictor(predictor, X, 3, 0))
+	goassert.New(t, LabelVectors{LabelVector{}, LabelVector{}}).EqualWithoutError(PredictAllWithPredictor(predictor, X, 0, 1))
+	goassert.New(t, "cannot predict").ExpectError(PredictAllWithPredictor(errorPredictor{}, X, 3, 2))
+}
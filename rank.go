@@ -96,6 +96,103 @@ func RankTopK(labelDist SparseVector, K uint) LabelVector {
 	return y
 }
 
+// RankTopKWithHierarchy returns the top-K labels consistent with the hierarchy, so no label is ranked without its ancestors.
+// The score of each label is raised to the maximum score of its descendants at first, then each label is preceded by its ancestors as ConsistentRanks.
+// If hierarchy is nil, then this function is equivalent to RankTopK.
+func RankTopKWithHierarchy(labelDist SparseVector, K uint, hierarchy *LabelHierarchy) LabelVector {
+	if hierarchy == nil {
+		return RankTopK(labelDist, K)
+	}
+	labelDist = hierarchy.PropagateScores(labelDist)
+	Kall := K
+	if Kall < uint(len(labelDist)) {
+		Kall = uint(len(labelDist))
+	}
+	return hierarchy.ConsistentRanks(RankTopK(labelDist, Kall), K)
+}
+
+// RankTopKWithScores returns the top-K labels with their scores in labelDist in the same order as RankTopK.
+// Unlike RankTopK, the returned slice is not padded, so it has at most K pairs.
+func RankTopKWithScores(labelDist SparseVector, K uint) KeyValues32 {
+	labels := RankTopK(labelDist, K)
+	labelScores := make(KeyValues32, 0, len(labels))
+	for _, label := range labels {
+		if label == ^uint32(0) {
+			break
+		}
+		labelScores = append(labelScores, KeyValue32{label, labelDist[label]})
+	}
+	return labelScores
+}
+
+// reportHierarchicalIntersections returns the sizes of the ancestor closures of each label vector in Y and the top-K labels in Yhat, and of their intersection.
+func reportHierarchicalIntersections(Y LabelVectors, K uint, Yhat LabelVectors, hierarchy *LabelHierarchy) (ns, nhats, nintersects []int) {
+	ns, nhats, nintersects = make([]int, len(Y)), make([]int, len(Y)), make([]int, len(Y))
+	for i, yi := range Y {
+		yihat := Yhat[i]
+		if len(yihat) > int(K) {
+			yihat = yihat[:K]
+		}
+		labelSeti := make(map[uint32]struct{})
+		for _, label := range hierarchy.Closure(yi) {
+			labelSeti[label] = struct{}{}
+		}
+		closureihat := hierarchy.Closure(yihat)
+		for _, label := range closureihat {
+			if _, ok := labelSeti[label]; ok {
+				nintersects[i]++
+			}
+		}
+		ns[i], nhats[i] = len(labelSeti), len(closureihat)
+	}
+	return
+}
+
+// ReportHierarchicalF1 reports the hierarchical F1@K value of each label vector in Y.
+// The hierarchical F1@K is the harmonic mean of the hierarchical Precision@K and Recall@K, and 0 if both of them are 0.
+func ReportHierarchicalF1(Y LabelVectors, K uint, Yhat LabelVectors, hierarchy *LabelHierarchy) []float32 {
+	hPKs, hRKs := ReportHierarchicalPrecision(Y, K, Yhat, hierarchy), ReportHierarchicalRecall(Y, K, Yhat, hierarchy)
+	hFKs := make([]float32, len(Y))
+	for i := range hFKs {
+		if hPKs[i]+hRKs[i] > 0.0 {
+			hFKs[i] = 2.0 * hPKs[i] * hRKs[i] / (hPKs[i] + hRKs[i])
+		}
+	}
+	return hFKs
+}
+
+// ReportHierarchicalPrecision reports the hierarchical Precision@K value of each label vector in Y.
+// The hierarchical Precision@K is the ratio of the labels in the ancestor closure of the top-K labels in Yhat found in the ancestor closure of the label vector (Kiritchenko+ 2005).
+// This is 0 if no label is predicted.
+//
+// References:
+//
+// (Kiritchenko+ 2005) S. Kiritchenko, S. Matwin, and A. F. Famili. "Functional Annotation of Genes Using Hierarchical Text Categorization." Proceedings of the ACL Workshop on Linking Biological Literature, Ontologies and Databases: Mining Biological Semantics, 2005.
+func ReportHierarchicalPrecision(Y LabelVectors, K uint, Yhat LabelVectors, hierarchy *LabelHierarchy) []float32 {
+	_, nhats, nintersects := reportHierarchicalIntersections(Y, K, Yhat, hierarchy)
+	hPKs := make([]float32, len(Y))
+	for i := range hPKs {
+		if nhats[i] > 0 {
+			hPKs[i] = float32(nintersects[i]) / float32(nhats[i])
+		}
+	}
+	return hPKs
+}
+
+// ReportHierarchicalRecall reports the hierarchical Recall@K value of each label vector in Y.
+// The hierarchical Recall@K is the ratio of the labels in the ancestor closure of the label vector found in the ancestor closure of the top-K labels in Yhat (Kiritchenko+ 2005).
+// This is 0 if the label vector is empty.
+func ReportHierarchicalRecall(Y LabelVectors, K uint, Yhat LabelVectors, hierarchy *LabelHierarchy) []float32 {
+	ns, _, nintersects := reportHierarchicalIntersections(Y, K, Yhat, hierarchy)
+	hRKs := make([]float32, len(Y))
+	for i := range hRKs {
+		if ns[i] > 0 {
+			hRKs[i] = float32(nintersects[i]) / float32(ns[i])
+		}
+	}
+	return hRKs
+}
+
 // ReportMaxPrecision reports the maximum Precision@K value of each label vector in Y.
 func ReportMaxPrecision(Y LabelVectors, K uint) []float32 {
 	pKs := make([]float32, len(Y))
@@ -112,6 +209,7 @@ func ReportMaxPrecision(Y LabelVectors, K uint) []float32 {
 // ReportNDCG reports the nDCG@K (normalized DCG@K) value of each label vector in Y.
 //
 // nDCG@0 is undefined, so this function returns a slice filled with NaN.
+// nDCG@K of the empty label vector is also undefined, so it is NaN, and it should be excluded from the averages.
 //
 // NOTICE: The maximum nDCG@K is always 1.0, because nDCG@K is normalized.
 func ReportNDCG(Y LabelVectors, K uint, Yhat LabelVectors) []float32 {
@@ -125,6 +125,8 @@ func TestReportNDCG(t *testing.T) {
 		LabelVector{9, 0, 3, ^uint32(0), ^uint32(0)}, LabelVector{9, 0, 3, ^uint32(0), ^uint32(0)}, LabelVector{9, 0, 3, ^uint32(0), ^uint32(0)},
 		LabelVector{9, 0, 2, 3, ^uint32(0)},
 	}))
+	// nDCG@K of the empty label vector is undefined.
+	goassert.New(t, "[]float32{NaN, 1}").Equal(fmt.Sprintf("%#v", ReportNDCG(LabelVectors{LabelVector{}, LabelVector{1}}, 1, LabelVectors{LabelVector{1}, LabelVector{1}})))
 }
 
 func TestReportMaxPrecision(t *testing.T) {
@@ -237,3 +239,48 @@ func TestReportPrecision(t *testing.T) {
 		LabelVector{9, 0, 2, 3, ^uint32(0)},
 	}))
 }
+
+func TestRankTopKWithHierarchy(t *testing.T) {
+	hierarchy := newTestLabelHierarchy(t)
+	labelDist := SparseVector{0: 1, 1: 1, 3: 4, 5: 2, 9: 3}
+	goassert.New(t, LabelVector{3, 9, 5, 0, 1}).Equal(RankTopKWithHierarchy(labelDist, 5, nil))
+	// Each label should follow its ancestors raised to its score.
+	goassert.New(t, LabelVector{0, 1, 3}).Equal(RankTopKWithHierarchy(labelDist, 3, hierarchy))
+	goassert.New(t, LabelVector{0, 1, 3, 9, 2, 4, 5, ^uint32(0)}).Equal(RankTopKWithHierarchy(labelDist, 8, hierarchy))
+}
+
+func TestRankTopKWithScores(t *testing.T) {
+	labelDist := SparseVector{0: 4, 1: 2, 2: 2, 3: 2, 9: 4}
+	goassert.New(t, KeyValues32{}).Equal(RankTopKWithScores(labelDist, 0))
+	goassert.New(t, KeyValues32{KeyValue32{0, 4}, KeyValue32{9, 4}, KeyValue32{1, 2}}).Equal(RankTopKWithScores(labelDist, 3))
+	goassert.New(t, KeyValues32{KeyValue32{0, 4}, KeyValue32{9, 4}, KeyValue32{1, 2}, KeyValue32{2, 2}, KeyValue32{3, 2}}).Equal(RankTopKWithScores(labelDist, 6))
+}
+
+func TestReportHierarchical(t *testing.T) {
+	hierarchy := newTestLabelHierarchy(t)
+	Y := LabelVectors{
+		LabelVector{3}, LabelVector{3}, LabelVector{5},
+		LabelVector{}, LabelVector{9},
+	}
+	Yhat := LabelVectors{
+		LabelVector{3, 5}, LabelVector{2, 3}, LabelVector{1, ^uint32(0)},
+		LabelVector{0, 1}, LabelVector{},
+	}
+	// The closures of Y are {0, 1, 3}, {0, 1, 3}, {0, 2, 4, 5}, {} and {9}.
+	goassert.New(t, []float32{
+		3.0 / 3, 1.0 / 2, 1.0 / 2,
+		0.0, 0.0,
+	}).Equal(ReportHierarchicalPrecision(Y, 1, Yhat, hierarchy))
+	goassert.New(t, []float32{
+		3.0 / 6, 3.0 / 4, 1.0 / 2,
+		0.0, 0.0,
+	}).Equal(ReportHierarchicalPrecision(Y, 2, Yhat, hierarchy))
+	goassert.New(t, []float32{
+		3.0 / 3, 3.0 / 3, 1.0 / 4,
+		0.0, 0.0,
+	}).Equal(ReportHierarchicalRecall(Y, 2, Yhat, hierarchy))
+	goassert.New(t, []float32{
+		2.0 * (3.0 / 6) / (3.0/6 + 1.0), 2.0 * (3.0 / 4) / (3.0/4 + 1.0), 2.0 * (1.0 / 2) * (1.0 / 4) / (1.0/2 + 1.0/4),
+		0.0, 0.0,
+	}).Equal(ReportHierarchicalF1(Y, 2, Yhat, hierarchy))
+}
@@ -0,0 +1,210 @@
+package sticker
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// SimHashing is the sparse signed random projection (SimHash) for estimating cosine similarity (Charikar 2002).
+// Each bit of the bucket index in each hash table is the sign of the projection of the feature vector onto the random vector whose elements are +1 or -1, so two feature vectors with angle theta have the same bit with the probability 1-theta/pi.
+// The random vectors are never stored, because their elements are determined by hashing the hash table and the feature key, so the projection costs only the non-zero features.
+// The buckets have the reservoirs in the same way as JaccardHashing.
+//
+// References:
+//
+// (Charikar 2002) M. S. Charikar. "Similarity Estimation Techniques from Rounding Algorithms." In STOC, pp. 380-388, 2002.
+type SimHashing struct {
+	hashTables
+}
+
+// NewSimHashing returns an new SimHashing.
+// Every two feature vectors collide in each hash table with the probability (1-theta/pi)^L, so L should be smaller than the one of JaccardHashing (for example, 8).
+// L must be at most 32.
+func NewSimHashing(K, L, R uint) *SimHashing {
+	return &SimHashing{
+		hashTables: newHashTables(K, L, R),
+	}
+}
+
+// Add adds the given feature vector as i-th index to the hash tables.
+// See JaccardHashing.Add for the reservoirs.
+func (hashing *SimHashing) Add(vec FeatureVector, i uint32) {
+	hashing.add(hashing.Hash(vec), i)
+}
+
+// DecodeSimHashingWithGobDecoder decodes SimHashing using decoder.
+//
+// This function returns an error in decoding.
+func DecodeSimHashingWithGobDecoder(hashing *SimHashing, decoder *gob.Decoder) error {
+	var header uint
+	if err := decoder.Decode(&header); err != nil {
+		return fmt.Errorf("DecodeSimHashing: _R: %s", err)
+	}
+	if name := nearIndexName(header); name != "simhash" {
+		return fmt.Errorf("DecodeSimHashing: NearIndex %q is encoded", name)
+	}
+	if err := hashing.decodeWithHeader(decoder, header); err != nil {
+		return fmt.Errorf("DecodeSimHashing: %s", err)
+	}
+	return nil
+}
+
+// DecodeSimHashing decodes SimHashing from r.
+// Directly passing *os.File used by a gob.Decoder to this function causes mysterious errors.
+// Thus, if users use gob.Decoder, then they should call DecodeSimHashingWithGobDecoder.
+//
+// This function returns an error in decoding.
+func DecodeSimHashing(hashing *SimHashing, r io.Reader) error {
+	return DecodeSimHashingWithGobDecoder(hashing, gob.NewDecoder(r))
+}
+
+// decodeWithHeader decodes SimHashing following the already decoded header using decoder.
+func (hashing *SimHashing) decodeWithHeader(decoder *gob.Decoder, header uint) error {
+	return hashing.hashTables.decodeWithHeader(decoder, header)
+}
+
+// EncodeSimHashingWithGobEncoder encodes SimHashing using encoder.
+// The deletions of the removed indices are also encoded.
+//
+// This function returns an error in encoding.
+func EncodeSimHashingWithGobEncoder(hashing *SimHashing, encoder *gob.Encoder) error {
+	if err := hashing.encodeWithHeader(encoder, hashing._R|simHashingFlag); err != nil {
+		return fmt.Errorf("EncodeSimHashing: %s", err)
+	}
+	return nil
+}
+
+// EncodeSimHashing encodes SimHashing to w.
+// Directly passing *os.File used by a gob.Encoder to this function causes mysterious errors.
+// Thus, if users use gob.Encoder, then they should call EncodeSimHashingWithGobEncoder.
+//
+// This function returns an error in encoding.
+func EncodeSimHashing(hashing *SimHashing, w io.Writer) error {
+	return EncodeSimHashingWithGobEncoder(hashing, gob.NewEncoder(w))
+}
+
+// Encode encodes the hashing using encoder (see EncodeSimHashingWithGobEncoder).
+func (hashing *SimHashing) Encode(encoder *gob.Encoder) error {
+	return EncodeSimHashingWithGobEncoder(hashing, encoder)
+}
+
+// GobEncode returns the error always, because users should encode large SimHashing objects with EncodeSimHashing.
+func (hashing *SimHashing) GobEncode() ([]byte, error) {
+	return nil, fmt.Errorf("SimHashing should be encoded with EncodeSimHashing")
+}
+
+// FindNears returns the histogram of the neighbors from the given feature vector.
+func (hashing *SimHashing) FindNears(vec FeatureVector) KeyCountMap32 {
+	return hashing.findNears(hashing.Hash(vec))
+}
+
+// FindNearsWithProbes is FindNears probing at most probes buckets in each hash table (see HashWithProbes).
+// Only the exact bucket is probed as FindNears if probes is less than 2.
+// Each index is counted at most once in each hash table, because the probed buckets are distinct.
+func (hashing *SimHashing) FindNearsWithProbes(vec FeatureVector, probes uint) KeyCountMap32 {
+	if probes < 2 {
+		return hashing.FindNears(vec)
+	}
+	return hashing.findNearsWithProbes(hashing.HashWithProbes(vec, probes), probes)
+}
+
+// Hash returns the K hashed values of the given feature vector.
+// The hashed value of the empty feature vector is 0.
+func (hashing *SimHashing) Hash(vec FeatureVector) []uint32 {
+	projs := make([]float32, hashing.L())
+	H := make([]uint32, hashing.K())
+	for k := range H {
+		H[k] = hashing.project(vec, uint32(k), projs)
+	}
+	return H
+}
+
+// HashWithProbes returns at most probes hashed values of the given feature vector for each hash table, which are used for multi-probe querying.
+// The first value of each hash table is the one returned by Hash, and the following values are the ones whose bit having the i-th smallest absolute projection is flipped.
+// Thus, the following values are the buckets which the feature vector would fall in with the slight perturbation.
+// At most L+1 values are returned for each hash table.
+func (hashing *SimHashing) HashWithProbes(vec FeatureVector, probes uint) [][]uint32 {
+	if probes < 1 {
+		probes = 1
+	}
+	if L := hashing.L(); probes > L+1 {
+		probes = L + 1
+	}
+	projs := make([]float32, hashing.L())
+	// order has the bits in ascending order of the absolute projection.
+	order := make([]int, 0, probes-1)
+	H := make([][]uint32, hashing.K())
+	for k := range H {
+		h := hashing.project(vec, uint32(k), projs)
+		order = order[:0]
+		for l, proj := range projs {
+			pos := len(order)
+			for pos > 0 && Abs32(projs[order[pos-1]]) > Abs32(proj) {
+				pos--
+			}
+			if uint(pos) == probes-1 {
+				continue
+			}
+			if uint(len(order)) < probes-1 {
+				order = append(order, 0)
+			}
+			copy(order[pos+1:], order[pos:])
+			order[pos] = l
+		}
+		hveck := make([]uint32, 1, probes)
+		hveck[0] = h
+		for _, l := range order {
+			hveck = append(hveck, h^(1<<uint(l)))
+		}
+		H[k] = hveck
+	}
+	return H
+}
+
+// project stores the projections of the given feature vector onto the random vectors of the k-th hash table into projs, and returns the hashed value made of their signs.
+func (hashing *SimHashing) project(vec FeatureVector, k uint32, projs []float32) uint32 {
+	for l := range projs {
+		projs[l] = 0.0
+	}
+	for _, vecpair := range vec {
+		// Each bit of signs determines the element of each random vector.
+		// The hash tables are spread by the golden ratio as the quantized levels of the weighted JaccardHashing.
+		signs, value := HashUint32(HashUint32(vecpair.Key)+0x9e3779b9*k), vecpair.Value
+		for l := range projs {
+			if signs&(1<<uint(l)) != 0 {
+				projs[l] += value
+			} else {
+				projs[l] -= value
+			}
+		}
+	}
+	h := uint32(0)
+	for l, proj := range projs {
+		if proj > 0.0 {
+			h |= 1 << uint(l)
+		}
+	}
+	return h
+}
+
+// Name returns "simhash".
+func (hashing *SimHashing) Name() string {
+	return "simhash"
+}
+
+// Reindex changes the index oldIdx of the given feature vector to newIdx in the hash tables.
+func (hashing *SimHashing) Reindex(vec FeatureVector, oldIdx, newIdx uint32) {
+	hashing.reindex(hashing.Hash(vec), oldIdx, newIdx)
+}
+
+// Remove removes the given feature vector added as i-th index from the hash tables.
+// See JaccardHashing.Remove for the reservoirs.
+func (hashing *SimHashing) Remove(vec FeatureVector, i uint32) {
+	hashing.remove(hashing.Hash(vec), i)
+}
+
+// String returns the name with K, L and R.
+func (hashing *SimHashing) String() string {
+	return fmt.Sprintf("simhash(K=%d,L=%d,R=%d)", hashing.K(), hashing.L(), hashing.R())
+}
@@ -0,0 +1,92 @@
+package sticker
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/bits"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestSimHashingHash(t *testing.T) {
+	countMatches := func(hashing *SimHashing, x, y FeatureVector) int {
+		hx, hy := hashing.Hash(x), hashing.Hash(y)
+		count := 0
+		for k := range hx {
+			if hx[k] == hy[k] {
+				count++
+			}
+		}
+		return count
+	}
+	x := FeatureVector{KeyValue32{1, 1.0}}
+	y := FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}}
+	z := FeatureVector{KeyValue32{2, 1.0}}
+	hashing := NewSimHashing(4096, 1, 8)
+	goassert.New(t, 3048).Equal(countMatches(hashing, x, y)) // Answer: 3072
+	goassert.New(t, 2027).Equal(countMatches(hashing, x, z)) // Answer: 2048
+	goassert.New(t, 4096).Equal(countMatches(hashing, y, FeatureVector{KeyValue32{1, 3.0}, KeyValue32{2, 3.0}}))
+	goassert.New(t, 0).Equal(countMatches(hashing, x, FeatureVector{KeyValue32{1, -1.0}}))
+	goassert.New(t, []uint32{0, 0}).Equal(NewSimHashing(2, 8, 8).Hash(FeatureVector{}))
+	// The following probes should flip the bits having the smallest absolute projections.
+	hashing = NewSimHashing(16, 8, 8)
+	x = FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 2.0}, KeyValue32{3, 3.0}, KeyValue32{4, 4.0}}
+	H, H3, H16 := hashing.Hash(x), hashing.HashWithProbes(x, 3), hashing.HashWithProbes(x, 16)
+	projs := make([]float32, hashing.L())
+	for k, hvecks := range H3 {
+		goassert.New(t, 3).Equal(len(hvecks))
+		goassert.New(t, H[k]).Equal(hvecks[0])
+		goassert.New(t, 9).Equal(len(H16[k]))
+		goassert.New(t, hvecks).Equal(H16[k][:3])
+		hashing.project(x, uint32(k), projs)
+		for j := 1; j < len(H16[k]); j++ {
+			goassert.New(t, 1).Equal(bits.OnesCount32(H[k] ^ H16[k][j]))
+			if j > 1 {
+				prevl, l := bits.TrailingZeros32(H[k]^H16[k][j-1]), bits.TrailingZeros32(H[k]^H16[k][j])
+				goassert.New(t, true).Equal(Abs32(projs[prevl]) <= Abs32(projs[l]))
+			}
+		}
+	}
+}
+
+func TestSimHashing(t *testing.T) {
+	x1 := FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}}
+	x2 := FeatureVector{KeyValue32{1, 1.0}, KeyValue32{2, 1.0}, KeyValue32{5, 1.0}, KeyValue32{6, 1.0}}
+	hashing := NewSimHashing(16, 8, 8)
+	goassert.New(t, uint(16), uint(8), uint(8), "simhash").Equal(hashing.K(), hashing.L(), hashing.R(), hashing.Name())
+	hashing.Add(x1, 0)
+	hashing.Add(x2, 1)
+	goassert.New(t, map[uint32]uint32{0: 16, 1: 1}).Equal(hashing.FindNears(x1).Map())
+	goassert.New(t, map[uint32]uint32{0: 1, 1: 16}).Equal(hashing.FindNears(x2).Map())
+	// Multi-probe querying should find the similar entry in more hash tables.
+	goassert.New(t, map[uint32]uint32{0: 16, 1: 2}).Equal(hashing.FindNearsWithProbes(x1, 4).Map())
+	// The removed index should not be found, and the reindexed index should be found.
+	hashing.Remove(x1, 0)
+	hashing.Reindex(x2, 1, 0)
+	goassert.New(t, map[uint32]uint32{0: 16}).Equal(hashing.FindNears(x2).Map())
+	goassert.New(t, map[uint32]uint32{0: 1}).Equal(hashing.FindNears(x1).Map())
+	// Check gob encoding/decoding.
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeSimHashing(hashing, &buf))
+	var decodedHashing SimHashing
+	goassert.New(t).SucceedWithoutError(DecodeSimHashing(&decodedHashing, &buf))
+	goassert.New(t, hashing).Equal(&decodedHashing)
+	goassert.New(t, true).Equal(len(decodedHashing.deletions) > 0)
+	// gob.Decoder.Decode won't call SimHashing.GobDecode, because the encoder did not encode SimHashing.
+	goassert.New(t, "SimHashing should be encoded with EncodeSimHashing").ExpectError(gob.NewEncoder(&buf).Encode(&decodedHashing))
+	// NearIndex should be decoded as the encoded implementation.
+	buf.Reset()
+	encoder := gob.NewEncoder(&buf)
+	goassert.New(t).SucceedWithoutError(hashing.Encode(encoder))
+	goassert.New(t).SucceedWithoutError(NewWeightedJaccardHashing(16, 10, 8).Encode(encoder))
+	decoder := gob.NewDecoder(&buf)
+	goassert.New(t, hashing).EqualWithoutError(DecodeNearIndexWithGobDecoder(decoder))
+	goassert.New(t, NewWeightedJaccardHashing(16, 10, 8)).EqualWithoutError(DecodeNearIndexWithGobDecoder(decoder))
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(EncodeSimHashing(hashing, &buf))
+	goassert.New(t, "DecodeJaccardHashing: NearIndex \"simhash\" is encoded").ExpectError(DecodeJaccardHashing(&JaccardHashing{}, &buf))
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(EncodeJaccardHashing(NewJaccardHashing(16, 10, 8), &buf))
+	goassert.New(t, "DecodeSimHashing: NearIndex \"jaccard\" is encoded").ExpectError(DecodeSimHashing(&SimHashing{}, &buf))
+}
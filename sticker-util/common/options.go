@@ -186,6 +186,8 @@ func ReadLabelNear(filename string) (*sticker.LabelNear, error) {
 }
 
 // ReadLabelNearest reads the .labelnearest model file.
+// The deltas in the delta file (filename + ".delta") written by sticker.EncodeLabelNearestDeltas are also applied if found.
+// The delta file must be truncated when the model file is re-saved, otherwise the stale deltas are rejected in applying.
 func ReadLabelNearest(filename string) (*sticker.LabelNearest, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -196,6 +198,18 @@ func ReadLabelNearest(filename string) (*sticker.LabelNearest, error) {
 	if err := sticker.DecodeLabelNearest(&model, file); err != nil {
 		return nil, fmt.Errorf("ReadLabelNearest: %s: %s", filename, err)
 	}
+	deltaFilename := filename + ".delta"
+	deltaFile, err := os.Open(deltaFilename)
+	if os.IsNotExist(err) {
+		return &model, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadLabelNearest: %s: %s", deltaFilename, err)
+	}
+	defer deltaFile.Close()
+	if err := sticker.DecodeLabelNearestDeltas(&model, deltaFile); err != nil {
+		return nil, fmt.Errorf("ReadLabelNearest: %s: %s", deltaFilename, err)
+	}
 	return &model, nil
 }
 
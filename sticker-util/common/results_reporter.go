@@ -9,41 +9,72 @@ import (
 )
 
 // ResultsReporter manages the precision@K and nDCG@K results on the given LabelVectors.
+// If the label hierarchy is set, then this also manages the hierarchical precision@K, recall@K and F1@K results.
+//
+// ResultsReporter keeps only the sums of the results, so this can be used on the entries streamed chunk by chunk.
 type ResultsReporter struct {
-	_Y                     sticker.LabelVectors
-	_Ks                    []uint
-	maxK                   uint
-	avgMaxPKs              map[uint]float32
-	pKsSet, nKsSet         map[uint][]float32
-	avgPKs, avgNKs         map[uint]float32
-	startTime, lastEndTime time.Time
+	_Y                       sticker.LabelVectors
+	_Ks                      []uint
+	maxK                     uint
+	nentries, nprocesseds    int
+	nlabeleds                int
+	avgMaxPKs                map[uint]float32
+	sumMaxPKs, sumPKs        map[uint]float32
+	sumNKs                   map[uint]float32
+	avgPKs, avgNKs           map[uint]float32
+	hierarchy                *sticker.LabelHierarchy
+	consistent               bool
+	sumHPKs, sumHRKs         map[uint]float32
+	sumHFKs                  map[uint]float32
+	avgHPKs, avgHRKs         map[uint]float32
+	avgHFKs                  map[uint]float32
+	startTime, lastEndTime   time.Time
+	elapsedTimeBeforeStarted time.Duration
 }
 
 // NewResultsReporter returns a new ResultsReporter.
 func NewResultsReporter(Y sticker.LabelVectors, Ks []uint) *ResultsReporter {
+	reporter := NewStreamResultsReporter(len(Y), Ks)
+	reporter._Y = Y
+	return reporter
+}
+
+// NewStreamResultsReporter returns a new ResultsReporter on the nentries entries reported by ReportChunk.
+func NewStreamResultsReporter(nentries int, Ks []uint) *ResultsReporter {
 	maxK := uint(0)
 	for _, K := range Ks {
 		if maxK < K {
 			maxK = K
 		}
 	}
-	pKsSet, nKsSet := make(map[uint][]float32), make(map[uint][]float32)
-	for _, K := range Ks {
-		pKsSet[K], nKsSet[K] = make([]float32, 0, len(Y)), make([]float32, 0, len(Y))
-	}
 	return &ResultsReporter{
-		_Y:     Y,
-		_Ks:    Ks,
-		maxK:   maxK,
-		pKsSet: pKsSet,
-		nKsSet: nKsSet,
-		avgPKs: make(map[uint]float32),
-		avgNKs: make(map[uint]float32),
+		_Ks:       Ks,
+		maxK:      maxK,
+		nentries:  nentries,
+		sumMaxPKs: make(map[uint]float32),
+		sumPKs:    make(map[uint]float32),
+		sumNKs:    make(map[uint]float32),
+		avgPKs:    make(map[uint]float32),
+		avgNKs:    make(map[uint]float32),
+		sumHPKs:   make(map[uint]float32),
+		sumHRKs:   make(map[uint]float32),
+		sumHFKs:   make(map[uint]float32),
+		avgHPKs:   make(map[uint]float32),
+		avgHRKs:   make(map[uint]float32),
+		avgHFKs:   make(map[uint]float32),
 	}
 }
 
 // AvgMaxPrecisionKs returns the average max Precision@Ks.
+// If the reporter is created by NewStreamResultsReporter, then this is the average on the processed entries.
 func (reporter *ResultsReporter) AvgMaxPrecisionKs() map[uint]float32 {
+	if reporter._Y == nil {
+		avgMaxPKs := make(map[uint]float32)
+		for _, K := range reporter._Ks {
+			avgMaxPKs[K] = reporter.sumMaxPKs[K] / float32(reporter.nprocesseds)
+		}
+		return avgMaxPKs
+	}
 	if reporter.avgMaxPKs == nil {
 		reporter.avgMaxPKs = make(map[uint]float32)
 		for _, K := range reporter._Ks {
@@ -58,9 +89,39 @@ func (reporter *ResultsReporter) AvgMaxPrecisionKs() map[uint]float32 {
 	return reporter.avgMaxPKs
 }
 
+// AvgHierarchicalF1Ks returns the average hierarchical F1@Ks on the processed entries.
+// This is empty if the label hierarchy is not set.
+func (reporter *ResultsReporter) AvgHierarchicalF1Ks() map[uint]float32 {
+	return reporter.avgHFKs
+}
+
+// AvgHierarchicalPrecisionKs returns the average hierarchical Precision@Ks on the processed entries.
+// This is empty if the label hierarchy is not set.
+func (reporter *ResultsReporter) AvgHierarchicalPrecisionKs() map[uint]float32 {
+	return reporter.avgHPKs
+}
+
+// AvgHierarchicalRecallKs returns the average hierarchical Recall@Ks on the processed entries.
+// This is empty if the label hierarchy is not set.
+func (reporter *ResultsReporter) AvgHierarchicalRecallKs() map[uint]float32 {
+	return reporter.avgHRKs
+}
+
+// AvgNDCGKs returns the average nDCG@Ks on the processed entries.
+// The entries having no label are excluded, because their nDCG@K is undefined.
+func (reporter *ResultsReporter) AvgNDCGKs() map[uint]float32 {
+	return reporter.avgNKs
+}
+
+// AvgPrecisionKs returns the average Precision@Ks on the processed entries.
+func (reporter *ResultsReporter) AvgPrecisionKs() map[uint]float32 {
+	return reporter.avgPKs
+}
+
 // InferenceTimes returns the total and average inference time between the ResetTimer time and the Report time per entry.
+// The inference times between the successive ResetTimer and ReportChunk calls are accumulated.
 func (reporter *ResultsReporter) InferenceTimes() (time.Duration, time.Duration) {
-	inferenceTime := reporter.lastEndTime.Sub(reporter.startTime)
+	inferenceTime := reporter.elapsedTimeBeforeStarted + reporter.lastEndTime.Sub(reporter.startTime)
 	return inferenceTime, time.Duration(inferenceTime.Nanoseconds() / int64(reporter.Nprocesseds())).Round(time.Microsecond)
 }
 
@@ -76,46 +137,93 @@ func (reporter *ResultsReporter) MaxK() uint {
 
 // Nprocesseds returns the number of the processed entries.
 func (reporter *ResultsReporter) Nprocesseds() int {
-	for _, pKs := range reporter.pKsSet {
-		return len(pKs)
-	}
-	return 0
+	return reporter.nprocesseds
 }
 
 // Report calculates Precision@Ks and nDCG@Ks with the predicted label vectors Yhat, append them, and returns the average ones.
+// Yhat is the predicted label vectors from the first entry, so the only unprocessed entries are used.
 // If w is not null, this function writes each result.
 func (reporter *ResultsReporter) Report(Yhat sticker.LabelVectors, w io.Writer) (avgPKs, avgNKs map[uint]float32) {
+	startidx, n := reporter.Nprocesseds(), len(Yhat)
+	return reporter.ReportChunk(reporter._Y[startidx:n], Yhat[startidx:], w)
+}
+
+// ReportChunk calculates Precision@Ks and nDCG@Ks with the true label vectors Y and the predicted label vectors Yhat of the next chunk, append them, and returns the average ones.
+// If the label hierarchy is set with consistent, then Yhat is made consistent with the hierarchy before calculating the results.
+// If w is not null, this function writes each result.
+func (reporter *ResultsReporter) ReportChunk(Y, Yhat sticker.LabelVectors, w io.Writer) (avgPKs, avgNKs map[uint]float32) {
 	reporter.lastEndTime = time.Now()
-	startidx := reporter.Nprocesseds()
-	n := len(Yhat)
+	reporter.nprocesseds += len(Y)
+	n := reporter.nprocesseds
+	for _, yi := range Y {
+		if len(yi) > 0 {
+			reporter.nlabeleds++
+		}
+	}
+	if reporter.hierarchy != nil && reporter.consistent {
+		Yhat = reporter.hierarchy.ConsistentRanksAll(Yhat)
+	}
 	for _, K := range reporter._Ks {
-		reporter.pKsSet[K] = append(reporter.pKsSet[K], sticker.ReportPrecision(reporter._Y[startidx:n], K, Yhat[startidx:])...)
-		sumPK := float32(0.0)
-		for _, precisionKi := range reporter.pKsSet[K] {
-			sumPK += precisionKi
+		for _, maxPKi := range sticker.ReportMaxPrecision(Y, K) {
+			reporter.sumMaxPKs[K] += maxPKi
 		}
-		avgPK := sumPK / float32(n)
-		reporter.nKsSet[K] = append(reporter.nKsSet[K], sticker.ReportNDCG(reporter._Y[startidx:n], K, Yhat[startidx:])...)
-		sumNK := float32(0.0)
-		for _, nDCGKi := range reporter.nKsSet[K] {
-			sumNK += nDCGKi
+		for _, precisionKi := range sticker.ReportPrecision(Y, K, Yhat) {
+			reporter.sumPKs[K] += precisionKi
+		}
+		for i, nDCGKi := range sticker.ReportNDCG(Y, K, Yhat) {
+			// nDCG@K of the entry having no label is NaN.
+			if len(Y[i]) > 0 {
+				reporter.sumNKs[K] += nDCGKi
+			}
+		}
+		reporter.avgPKs[K], reporter.avgNKs[K] = reporter.sumPKs[K]/float32(n), float32(0.0)
+		if reporter.nlabeleds > 0 {
+			reporter.avgNKs[K] = reporter.sumNKs[K] / float32(reporter.nlabeleds)
+		}
+		if reporter.hierarchy != nil {
+			for _, hPKi := range sticker.ReportHierarchicalPrecision(Y, K, Yhat, reporter.hierarchy) {
+				reporter.sumHPKs[K] += hPKi
+			}
+			for _, hRKi := range sticker.ReportHierarchicalRecall(Y, K, Yhat, reporter.hierarchy) {
+				reporter.sumHRKs[K] += hRKi
+			}
+			for _, hFKi := range sticker.ReportHierarchicalF1(Y, K, Yhat, reporter.hierarchy) {
+				reporter.sumHFKs[K] += hFKi
+			}
+			reporter.avgHPKs[K], reporter.avgHRKs[K], reporter.avgHFKs[K] = reporter.sumHPKs[K]/float32(n), reporter.sumHRKs[K]/float32(n), reporter.sumHFKs[K]/float32(n)
 		}
-		avgNK := sumNK / float32(n)
-		reporter.avgPKs[K], reporter.avgNKs[K] = avgPK, avgNK
-	}
-	inferenceTime, inferenceTimePerEntry := reporter.InferenceTimes()
-	if w != nil {
-		fmt.Fprintf(w, "finished inference on %d/%d entries (%-5.4g%%) in %s (about %s/entry)\n", n, len(reporter._Y), float32(n)/float32(len(reporter._Y))*100.0, inferenceTime, inferenceTimePerEntry)
 	}
 	if w != nil {
-		for _, K := range reporter._Ks {
-			fmt.Fprintf(w, "Precision@%d=%-5.4g%%/%-5.4g%%, nDCG@%d=%-5.4g%%\n", K, reporter.avgPKs[K]*100, reporter.AvgMaxPrecisionKs()[K]*100, K, reporter.avgNKs[K]*100)
-		}
+		reporter.WriteResults(w)
 	}
 	return reporter.avgPKs, reporter.avgNKs
 }
 
 // ResetTimer resets the start time.
+// The inference time reported until now is kept, so calling this before the inference on each chunk excludes the other times from the inference time.
 func (reporter *ResultsReporter) ResetTimer() {
+	reporter.elapsedTimeBeforeStarted += reporter.lastEndTime.Sub(reporter.startTime)
 	reporter.startTime = time.Now()
+	reporter.lastEndTime = reporter.startTime
+}
+
+// SetLabelHierarchy sets the label hierarchy used for the hierarchical results.
+// If consistent is true, then the predicted label vectors are made consistent with the hierarchy in reporting.
+// This should be called before reporting any chunk.
+func (reporter *ResultsReporter) SetLabelHierarchy(hierarchy *sticker.LabelHierarchy, consistent bool) {
+	reporter.hierarchy, reporter.consistent = hierarchy, consistent
+}
+
+// WriteResults writes the current average results to w.
+func (reporter *ResultsReporter) WriteResults(w io.Writer) {
+	n := reporter.Nprocesseds()
+	inferenceTime, inferenceTimePerEntry := reporter.InferenceTimes()
+	fmt.Fprintf(w, "finished inference on %d/%d entries (%-5.4g%%) in %s (about %s/entry)\n", n, reporter.nentries, float32(n)/float32(reporter.nentries)*100.0, inferenceTime, inferenceTimePerEntry)
+	avgMaxPKs := reporter.AvgMaxPrecisionKs()
+	for _, K := range reporter._Ks {
+		fmt.Fprintf(w, "Precision@%d=%-5.4g%%/%-5.4g%%, nDCG@%d=%-5.4g%%\n", K, reporter.avgPKs[K]*100, avgMaxPKs[K]*100, K, reporter.avgNKs[K]*100)
+		if reporter.hierarchy != nil {
+			fmt.Fprintf(w, "hPrecision@%d=%-5.4g%%, hRecall@%d=%-5.4g%%, hF1@%d=%-5.4g%%\n", K, reporter.avgHPKs[K]*100, K, reporter.avgHRKs[K]*100, K, reporter.avgHFKs[K]*100)
+		}
+	}
 }
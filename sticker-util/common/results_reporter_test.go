@@ -0,0 +1,20 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+	"github.com/hiro4bbh/sticker"
+)
+
+func TestResultsReporterReportChunk(t *testing.T) {
+	reporter := NewStreamResultsReporter(4, []uint{1})
+	// The entries having no label should be excluded from the average nDCG@K.
+	avgPKs, avgNKs := reporter.ReportChunk(sticker.LabelVectors{sticker.LabelVector{}, sticker.LabelVector{1}}, sticker.LabelVectors{sticker.LabelVector{1}, sticker.LabelVector{1}}, nil)
+	goassert.New(t, map[uint]float32{1: 0.5}, map[uint]float32{1: 1.0}).Equal(avgPKs, avgNKs)
+	avgPKs, avgNKs = reporter.ReportChunk(sticker.LabelVectors{sticker.LabelVector{2}, sticker.LabelVector{}}, sticker.LabelVectors{sticker.LabelVector{1}, sticker.LabelVector{1}}, nil)
+	goassert.New(t, map[uint]float32{1: 0.25}, map[uint]float32{1: 0.5}).Equal(avgPKs, avgNKs)
+	// The average nDCG@K is 0 if no entry has the label.
+	avgPKs, avgNKs = NewStreamResultsReporter(1, []uint{1}).ReportChunk(sticker.LabelVectors{sticker.LabelVector{}}, sticker.LabelVectors{sticker.LabelVector{1}}, nil)
+	goassert.New(t, map[uint]float32{1: 0.0}, map[uint]float32{1: 0.0}).Equal(avgPKs, avgNKs)
+}
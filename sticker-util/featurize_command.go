@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hiro4bbh/sticker"
+	"github.com/hiro4bbh/sticker/sticker-util/common"
+)
+
+// FeaturizeCommand have flags for featurize sub-command.
+type FeaturizeCommand struct {
+	Format         string
+	Help           bool
+	InputName      string
+	LabelSeparator string
+	Lowercase      bool
+	MaxCharNgram   uint
+	MaxDF          common.OptionFloat32
+	MaxWordNgram   uint
+	MinCharNgram   uint
+	MinDF          uint
+	MinWordNgram   uint
+	Seed           int
+	StopWords      string
+	TestRatio      common.OptionFloat32
+	TestTableName  string
+	TrainTableName string
+
+	opts    *Options
+	flagSet *flag.FlagSet
+}
+
+// NewFeaturizeCommand returns a new FeaturizeCommand.
+func NewFeaturizeCommand(opts *Options) *FeaturizeCommand {
+	params := sticker.NewTextFeaturizerParameters()
+	return &FeaturizeCommand{
+		Format:         "",
+		Help:           false,
+		InputName:      "corpus.tsv",
+		LabelSeparator: ",",
+		Lowercase:      params.Lowercase,
+		MaxCharNgram:   params.MaxCharNgram,
+		MaxDF:          common.OptionFloat32(params.MaxDF),
+		MaxWordNgram:   params.MaxWordNgram,
+		MinCharNgram:   params.MinCharNgram,
+		MinDF:          params.MinDF,
+		MinWordNgram:   params.MinWordNgram,
+		Seed:           0,
+		StopWords:      "",
+		TestRatio:      0.2,
+		TestTableName:  "test.txt",
+		TrainTableName: "train.txt",
+		opts:           opts,
+	}
+}
+
+func (cmd *FeaturizeCommand) initializeFlagSet() {
+	cmd.flagSet = flag.NewFlagSet("@featurize", flag.ContinueOnError)
+	cmd.flagSet.Usage = func() {}
+	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.StringVar(&cmd.Format, "format", cmd.Format, "Specify the input format (tsv/jsonl, detected from the extension if empty)")
+	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
+	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
+	cmd.flagSet.StringVar(&cmd.InputName, "input", cmd.InputName, "Specify the input filename in the dataset path (each line is labels<TAB>text in tsv, or {\"labels\": [...], \"text\": \"...\"} in jsonl)")
+	cmd.flagSet.StringVar(&cmd.LabelSeparator, "labelSeparator", cmd.LabelSeparator, "Specify the separator of the labels in tsv")
+	cmd.flagSet.BoolVar(&cmd.Lowercase, "lowercase", cmd.Lowercase, "Lowercase the tokens")
+	cmd.flagSet.UintVar(&cmd.MaxCharNgram, "maxCharNgram", cmd.MaxCharNgram, "Specify the maximum n of the character n-grams (not used if 0)")
+	cmd.flagSet.Var(&cmd.MaxDF, "maxDF", "Specify the maximum ratio of the documents having each term")
+	cmd.flagSet.UintVar(&cmd.MaxWordNgram, "maxWordNgram", cmd.MaxWordNgram, "Specify the maximum n of the word n-grams (not used if 0)")
+	cmd.flagSet.UintVar(&cmd.MinCharNgram, "minCharNgram", cmd.MinCharNgram, "Specify the minimum n of the character n-grams")
+	cmd.flagSet.UintVar(&cmd.MinDF, "minDF", cmd.MinDF, "Specify the minimum number of the documents having each term")
+	cmd.flagSet.UintVar(&cmd.MinWordNgram, "minWordNgram", cmd.MinWordNgram, "Specify the minimum n of the word n-grams")
+	cmd.flagSet.IntVar(&cmd.Seed, "seed", cmd.Seed, "Specify the seed in splitting the documents")
+	cmd.flagSet.StringVar(&cmd.StopWords, "stopWords", cmd.StopWords, "Specify the stop word list (english, or the filename in the dataset path having a word per line)")
+	cmd.flagSet.Var(&cmd.TestRatio, "testRatio", "Specify the ratio of the documents in the test table")
+	cmd.flagSet.StringVar(&cmd.TestTableName, "testTable", cmd.TestTableName, "Specify the test table name")
+	cmd.flagSet.StringVar(&cmd.TrainTableName, "trainTable", cmd.TrainTableName, "Specify the training table name")
+}
+
+// Parse parses the flags in args, and returns the remain parts of args.
+//
+// This function returns an error in parsing.
+func (cmd *FeaturizeCommand) Parse(args []string) ([]string, error) {
+	cmd.initializeFlagSet()
+	if err := cmd.flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+	return cmd.flagSet.Args(), nil
+}
+
+// featurizeDocument is the pair of the label names and the raw text.
+type featurizeDocument struct {
+	Labels []string `json:"labels"`
+	Text   string   `json:"text"`
+}
+
+// readDocuments reads the documents in the given format from reader.
+//
+// This function returns an error in reading or parsing the documents.
+func (cmd *FeaturizeCommand) readDocuments(reader io.Reader, format string) ([]featurizeDocument, error) {
+	br := bufio.NewReader(reader)
+	docs := []featurizeDocument{}
+	for l := 1; ; l++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("L%d: cannot read line", l)
+		}
+		if strings.TrimSpace(line) != "" {
+			var doc featurizeDocument
+			switch format {
+			case "jsonl":
+				if err := json.Unmarshal([]byte(line), &doc); err != nil {
+					return nil, fmt.Errorf("L%d: %s", l, err)
+				}
+			case "tsv":
+				cells := strings.SplitN(strings.TrimRight(line, "\r\n"), "\t", 2)
+				if len(cells) != 2 {
+					return nil, fmt.Errorf("L%d: no tab separating labels and text", l)
+				}
+				for _, label := range strings.Split(cells[0], cmd.LabelSeparator) {
+					if label = strings.TrimSpace(label); label != "" {
+						doc.Labels = append(doc.Labels, label)
+					}
+				}
+				doc.Text = cells[1]
+			default:
+				return nil, fmt.Errorf("unknown format: %s", format)
+			}
+			docs = append(docs, doc)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return docs, nil
+}
+
+// readStopWords returns the stop word list specified by cmd.StopWords.
+//
+// This function returns an error in reading the stop word file.
+func (cmd *FeaturizeCommand) readStopWords() ([]string, error) {
+	switch cmd.StopWords {
+	case "":
+		return []string{}, nil
+	case "english":
+		return sticker.EnglishStopWords, nil
+	default:
+		words, err := cmd.opts.ReadMap(cmd.StopWords)
+		if err != nil {
+			return nil, err
+		}
+		stopWords := make([]string, 0, words.Size())
+		for _, word := range words.Names() {
+			if word != "" {
+				stopWords = append(stopWords, word)
+			}
+		}
+		return stopWords, nil
+	}
+}
+
+// writeMap writes the vocabulary to the map file in the dataset path.
+//
+// This function returns an error in writing the file.
+func (cmd *FeaturizeCommand) writeMap(mapname string, vocab *sticker.Vocabulary) error {
+	filename := filepath.Join(cmd.opts.DatasetPath, mapname)
+	cmd.opts.Logger.Printf("writing %d names into map %q ...", vocab.Size(), filename)
+	file, err := sticker.CreateCompressedFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := vocab.WriteVocabulary(file); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	return file.Close()
+}
+
+// Run featurizes the raw text documents, and writes the training and test tables and the feature and label maps.
+func (cmd *FeaturizeCommand) Run() error {
+	if cmd.Help {
+		cmd.ShowHelp()
+		return nil
+	}
+	opts := cmd.opts
+	opts.Logger.Printf("FeaturizeCommand: %#v", cmd)
+	format := cmd.Format
+	if format == "" {
+		format = "tsv"
+		switch filepath.Ext(strings.TrimSuffix(strings.TrimSuffix(cmd.InputName, ".gz"), ".bz2")) {
+		case ".json", ".jsonl":
+			format = "jsonl"
+		}
+	}
+	stopWords, err := cmd.readStopWords()
+	if err != nil {
+		return err
+	}
+	filename := opts.resolveFilename(cmd.InputName)
+	opts.Logger.Printf("reading the documents from %q as %s ...", filename, format)
+	file, err := sticker.OpenDecompressedFile(filename)
+	if err != nil {
+		return err
+	}
+	docs, err := cmd.readDocuments(file, format)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	labeledDocs := make([]featurizeDocument, 0, len(docs))
+	for _, doc := range docs {
+		if len(doc.Labels) > 0 {
+			labeledDocs = append(labeledDocs, doc)
+		}
+	}
+	if nunlabeleds := len(docs) - len(labeledDocs); nunlabeleds > 0 {
+		opts.Logger.Printf("skipping %d documents having no label ...", nunlabeleds)
+	}
+	docs = labeledDocs
+	// The label IDs are assigned in descending order of the frequencies, and ties are ordered by the names.
+	labelFreqs := make(map[string]int)
+	for _, doc := range docs {
+		for _, label := range doc.Labels {
+			labelFreqs[label]++
+		}
+	}
+	labelNames := make([]string, 0, len(labelFreqs))
+	for label := range labelFreqs {
+		labelNames = append(labelNames, label)
+	}
+	sort.Slice(labelNames, func(i, j int) bool {
+		if labelFreqs[labelNames[i]] != labelFreqs[labelNames[j]] {
+			return labelFreqs[labelNames[i]] > labelFreqs[labelNames[j]]
+		}
+		return labelNames[i] < labelNames[j]
+	})
+	labelVocab := sticker.NewVocabularyFromNames(labelNames)
+	labelVocab.Freeze()
+	opts.Logger.Printf("splitting %d documents with the random number generator (seed=%d) ...", len(docs), cmd.Seed)
+	rng := rand.New(rand.NewSource(int64(cmd.Seed)))
+	perm := rng.Perm(len(docs))
+	ntests := int(float32(cmd.TestRatio)*float32(len(docs)) + 0.5)
+	testIndices, trainIndices := append([]int{}, perm[:ntests]...), append([]int{}, perm[ntests:]...)
+	sort.Ints(testIndices)
+	sort.Ints(trainIndices)
+	featurizer := sticker.NewTextFeaturizer(&sticker.TextFeaturizerParameters{
+		Lowercase:    cmd.Lowercase,
+		StopWords:    stopWords,
+		MinWordNgram: cmd.MinWordNgram,
+		MaxWordNgram: cmd.MaxWordNgram,
+		MinCharNgram: cmd.MinCharNgram,
+		MaxCharNgram: cmd.MaxCharNgram,
+		MinDF:        cmd.MinDF,
+		MaxDF:        float32(cmd.MaxDF),
+	})
+	trainTexts := make([]string, 0, len(trainIndices))
+	for _, i := range trainIndices {
+		trainTexts = append(trainTexts, docs[i].Text)
+	}
+	opts.Logger.Printf("fitting the vocabulary on %d training documents ...", len(trainTexts))
+	featurizer.Fit(trainTexts)
+	for _, split := range []struct {
+		tblname string
+		indices []int
+	}{{cmd.TrainTableName, trainIndices}, {cmd.TestTableName, testIndices}} {
+		ds := &sticker.Dataset{
+			X: make(sticker.FeatureVectors, 0, len(split.indices)),
+			Y: make(sticker.LabelVectors, 0, len(split.indices)),
+		}
+		for _, i := range split.indices {
+			y := make(sticker.LabelVector, 0, len(docs[i].Labels))
+			seen := make(map[uint32]bool)
+			for _, label := range docs[i].Labels {
+				if id, _ := labelVocab.ID(label); !seen[id] {
+					seen[id] = true
+					y = append(y, id)
+				}
+			}
+			sort.Slice(y, func(i, j int) bool { return y[i] < y[j] })
+			ds.X, ds.Y = append(ds.X, featurizer.Featurize(docs[i].Text)), append(ds.Y, y)
+		}
+		tblpath := filepath.Join(opts.DatasetPath, split.tblname)
+		opts.Logger.Printf("writing %d entries into table %q ...", ds.Size(), tblpath)
+		if err := ds.WriteTextDatasetFile(tblpath); err != nil {
+			return fmt.Errorf("Dataset.WriteTextDatasetFile: %s: %s", tblpath, err)
+		}
+	}
+	if opts.FeatureMapName != "" {
+		if err := cmd.writeMap(opts.FeatureMapName, featurizer.Vocabulary); err != nil {
+			return err
+		}
+	}
+	if opts.LabelMapName != "" {
+		if err := cmd.writeMap(opts.LabelMapName, labelVocab); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(opts.OutputWriter, "featurized %d documents into %d training and %d test entries with %d features and %d labels\n", len(docs), len(trainIndices), len(testIndices), featurizer.Vocabulary.Size(), labelVocab.Size())
+	return nil
+}
+
+// ShowHelp shows the help.
+func (cmd *FeaturizeCommand) ShowHelp() {
+	fmt.Fprintf(cmd.opts.ErrorWriter, "sticker-util\nCopyright 2017- Tatsuhiro Aoshima (hiro4bbh@gmail.com).\n\nUsage: @featurize [subCommandOptions]\n")
+	if cmd.flagSet == nil {
+		cmd.initializeFlagSet()
+	}
+	cmd.flagSet.SetOutput(cmd.opts.ErrorWriter)
+	cmd.flagSet.PrintDefaults()
+	cmd.flagSet.SetOutput(ioutil.Discard)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hiro4bbh/sticker"
+	"github.com/hiro4bbh/sticker/sticker-util/common"
+)
+
+// FitTransformCommand have flags for fitTransform sub-command.
+type FitTransformCommand struct {
+	B            common.OptionFloat32
+	Help         bool
+	K1           common.OptionFloat32
+	TableNames   common.OptionStrings
+	Transformers string
+
+	opts    *Options
+	flagSet *flag.FlagSet
+}
+
+// NewFitTransformCommand returns a new FitTransformCommand.
+func NewFitTransformCommand(opts *Options) *FitTransformCommand {
+	return &FitTransformCommand{
+		B:            0.75,
+		Help:         false,
+		K1:           1.2,
+		TableNames:   common.OptionStrings{true, []string{"train.txt"}},
+		Transformers: "idf,l2",
+		opts:         opts,
+	}
+}
+
+func (cmd *FitTransformCommand) initializeFlagSet() {
+	cmd.flagSet = flag.NewFlagSet("@fitTransform", flag.ContinueOnError)
+	cmd.flagSet.Usage = func() {}
+	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.Var(&cmd.B, "b", "Specify the length normalization parameter b of bm25")
+	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
+	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
+	cmd.flagSet.Var(&cmd.K1, "k1", "Specify the term frequency saturation parameter k1 of bm25")
+	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
+	cmd.flagSet.StringVar(&cmd.Transformers, "transformers", cmd.Transformers, "Specify the comma-separated transformers applied in order (bm25/idf/l2/maxAbs/sublinearTF)")
+}
+
+// Parse parses the flags in args, and returns the remain parts of args.
+//
+// This function returns an error in parsing.
+func (cmd *FitTransformCommand) Parse(args []string) ([]string, error) {
+	cmd.initializeFlagSet()
+	if err := cmd.flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+	return cmd.flagSet.Args(), nil
+}
+
+// newTransformer returns the unfitted transformer of the given name.
+//
+// This function returns an error if the name is unknown.
+func (cmd *FitTransformCommand) newTransformer(name string) (sticker.Transformer, error) {
+	switch name {
+	case "bm25":
+		return sticker.NewBM25Transformer(float32(cmd.K1), float32(cmd.B)), nil
+	case "idf":
+		return sticker.NewIDFTransformer(), nil
+	case "l2":
+		return sticker.NewL2Transformer(), nil
+	case "maxAbs":
+		return sticker.NewMaxAbsTransformer(), nil
+	case "sublinearTF":
+		return sticker.NewSublinearTFTransformer(), nil
+	default:
+		return nil, fmt.Errorf("unknown transformer: %s", name)
+	}
+}
+
+// Run fits the transformers on the specified table of dataset.
+// The written transform is used by the following training and test commands.
+func (cmd *FitTransformCommand) Run() error {
+	if cmd.Help {
+		cmd.ShowHelp()
+		return nil
+	}
+	opts := cmd.opts
+	opts.Logger.Printf("FitTransformCommand: %#v", cmd)
+	names := strings.Split(cmd.Transformers, ",")
+	transformers := make(sticker.Transformers, 0, len(names))
+	for _, name := range names {
+		transformer, err := cmd.newTransformer(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		transformers = append(transformers, transformer)
+	}
+	ds, err := opts.readDatasets(cmd.TableNames.Values, ^uint(0), false)
+	if err != nil {
+		return err
+	}
+	opts.Logger.Printf("fitting the transformers %q on %d entries ...", cmd.Transformers, ds.Size())
+	if err := transformers.Fit(ds.X); err != nil {
+		return err
+	}
+	filename := opts.Transform
+	if filename == "" {
+		filename = fmt.Sprintf("./transform/%s.%s.transform", opts.GetDatasetName(), common.JoinTableNames(cmd.TableNames.Values))
+		opts.Transform = filename
+	}
+	opts.Logger.Printf("writing the transform to %s ...", filename)
+	file, err := common.CreateWithDir(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := sticker.EncodeTransformer(transformers, file); err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	opts.transformer = transformers
+	return nil
+}
+
+// ShowHelp shows the help.
+func (cmd *FitTransformCommand) ShowHelp() {
+	fmt.Fprintf(cmd.opts.ErrorWriter, "sticker-util\nCopyright 2017- Tatsuhiro Aoshima (hiro4bbh@gmail.com).\n\nUsage: @fitTransform [subCommandOptions]\n")
+	if cmd.flagSet == nil {
+		cmd.initializeFlagSet()
+	}
+	cmd.flagSet.SetOutput(cmd.opts.ErrorWriter)
+	cmd.flagSet.PrintDefaults()
+	cmd.flagSet.SetOutput(ioutil.Discard)
+}
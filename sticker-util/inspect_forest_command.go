@@ -83,8 +83,8 @@ func (cmd *InspectForestCommand) Run() error {
 				return
 			}
 			if err := tmpl.ExecuteTemplate(writer, "inspectForestTemplate.html", map[string]interface{}{
-				"featureMap": opts.featureMap,
-				"labelMap":   opts.labelMap,
+				"featureMap": opts.featureMap.Names(),
+				"labelMap":   opts.labelMap.Names(),
 				"filename":   opts.LabelForest,
 				"forest":     forest,
 				"treeId":     treeId,
@@ -79,8 +79,8 @@ func (cmd *InspectOneCommand) Run() error {
 				return
 			}
 			if err := tmpl.ExecuteTemplate(writer, "inspectOneTemplate.html", map[string]interface{}{
-				"featureMap":  opts.featureMap,
-				"labelMap":    opts.labelMap,
+				"featureMap":  opts.featureMap.Names(),
+				"labelMap":    opts.labelMap.Names(),
 				"filename":    opts.LabelOne,
 				"model":       model,
 				"testResults": results,
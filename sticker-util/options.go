@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
 	"strings"
@@ -18,29 +19,39 @@ import (
 
 	"github.com/hiro4bbh/sticker"
 	"github.com/hiro4bbh/sticker/plugin/next"
+	"github.com/hiro4bbh/sticker/sticker-util/common"
 )
 
 // Options have options for common flags and flags for each sub-command.
 // See the help for details.
 type Options struct {
 	// The following members are the common flags.
-	CPUProfile     string
-	Debug          bool
-	FeatureMapName string
-	Help           bool
-	HTTPResource   string
-	LabelBoost     string
-	LabelConst     string
-	LabelForest    string
-	LabelNear      string
-	LabelNearest   string
-	LabelNext      string
-	LabelOne       string
-	LabelMapName   string
-	Verbose        bool
-	DatasetPath    string
+	AllowEmptyLabels   bool
+	ChunkSize          uint
+	Consistent         bool
+	CPUProfile         string
+	DatasetCache       bool
+	Debug              bool
+	FeatureMapName     string
+	Help               bool
+	HTTPResource       string
+	InferHeader        bool
+	LabelBoost         string
+	LabelConst         string
+	LabelForest        string
+	LabelHierarchyName string
+	LabelNear          string
+	LabelNearest       string
+	LabelNext          string
+	LabelOne           string
+	LabelMapName       string
+	Transform          string
+	Verbose            bool
+	DatasetPath        string
 	// The following members are for each sub-commands.
 	CompareForest *CompareForestCommand
+	Featurize     *FeaturizeCommand
+	FitTransform  *FitTransformCommand
 	InspectForest *InspectForestCommand
 	InspectOne    *InspectOneCommand
 	PruneOne      *PruneOneCommand
@@ -60,6 +71,7 @@ type Options struct {
 	TrainNearest  *TrainNearestCommand
 	TrainNext     next.TrainCommand
 	TrainOne      *TrainOneCommand
+	Validate      *ValidateCommand
 
 	// The following members are for logging or debugging use.
 	ErrorWriter, OutputWriter io.Writer
@@ -68,29 +80,40 @@ type Options struct {
 
 	execpath             string
 	flagSet              *flag.FlagSet
-	featureMap, labelMap []string
+	featureMap, labelMap *sticker.Vocabulary
+	labelHierarchy       *sticker.LabelHierarchy
+	transformer          sticker.Transformer
 }
 
 // NewOptions returns a new Options with default values.
 func NewOptions(execpath string, outputWriter, errorWriter io.Writer) *Options {
 	return &Options{
-		CPUProfile:     "",
-		Debug:          false,
-		FeatureMapName: "feature_map.txt",
-		Help:           false,
-		HTTPResource:   filepath.Join(build.Default.GOPATH, "src/github.com/hiro4bbh/sticker/sticker-util/res"),
-		LabelBoost:     "",
-		LabelConst:     "",
-		LabelForest:    "",
-		LabelNear:      "",
-		LabelNearest:   "",
-		LabelNext:      "",
-		LabelOne:       "",
-		LabelMapName:   "label_map.txt",
-		Verbose:        false,
-		DatasetPath:    "",
+		AllowEmptyLabels:   false,
+		ChunkSize:          uint(65536),
+		Consistent:         false,
+		CPUProfile:         "",
+		DatasetCache:       true,
+		Debug:              false,
+		FeatureMapName:     "feature_map.txt",
+		Help:               false,
+		HTTPResource:       filepath.Join(build.Default.GOPATH, "src/github.com/hiro4bbh/sticker/sticker-util/res"),
+		InferHeader:        false,
+		LabelBoost:         "",
+		LabelConst:         "",
+		LabelForest:        "",
+		LabelHierarchyName: "label_hierarchy.txt",
+		LabelNear:          "",
+		LabelNearest:       "",
+		LabelNext:          "",
+		LabelOne:           "",
+		LabelMapName:       "label_map.txt",
+		Transform:          "",
+		Verbose:            false,
+		DatasetPath:        "",
 
 		CompareForest: nil,
+		Featurize:     nil,
+		FitTransform:  nil,
 		InspectForest: nil,
 		InspectOne:    nil,
 		PruneOne:      nil,
@@ -108,6 +131,7 @@ func NewOptions(execpath string, outputWriter, errorWriter io.Writer) *Options {
 		TrainNearest:  nil,
 		TrainNext:     nil,
 		TrainOne:      nil,
+		Validate:      nil,
 
 		OutputWriter: outputWriter,
 		ErrorWriter:  errorWriter,
@@ -116,17 +140,66 @@ func NewOptions(execpath string, outputWriter, errorWriter io.Writer) *Options {
 	}
 }
 
+// EvaluatePredictors reports the results of each predictor with the corresponding reporter on the chunks read from reader.
+// Each chunk is predicted by nworkers workers (runtime.GOMAXPROCS if 0).
+// If w is not nil, then this function writes the results of each reporter once after all chunks.
+//
+// This function returns an error in reading or prediction.
+func (opts *Options) EvaluatePredictors(reader sticker.DatasetReader, predictors []sticker.Predictor, reporters []*common.ResultsReporter, nworkers int, w io.Writer) error {
+	for {
+		chunk, err := opts.ReadChunk(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, predictor := range predictors {
+			reporter := reporters[i]
+			reporter.ResetTimer()
+			Yhat, err := sticker.PredictAllWithPredictor(predictor, chunk.X, reporter.MaxK(), nworkers)
+			if err != nil {
+				return err
+			}
+			reporter.ReportChunk(chunk.Y, Yhat, nil)
+		}
+	}
+	if w != nil {
+		for _, reporter := range reporters {
+			reporter.WriteResults(w)
+		}
+	}
+	return nil
+}
+
 // FeatureMap returns the feature name.
 func (opts *Options) FeatureMap(feature uint32, quote bool) string {
-	if feature < uint32(len(opts.featureMap)) {
+	if name, ok := opts.featureMap.Name(feature); ok {
 		if quote {
-			return fmt.Sprintf("%q", opts.featureMap[feature])
+			return fmt.Sprintf("%q", name)
 		}
-		return opts.featureMap[feature]
+		return name
 	}
 	return fmt.Sprintf("%d", feature)
 }
 
+// FilterDataset returns the training dataset ds filtered by the label frequencies and the feature document frequencies.
+// The identifiers are not changed, so the trained models can be tested on the unfiltered tables.
+// See sticker.Dataset.FilterLabels and sticker.Dataset.FilterFeatures for details.
+func (opts *Options) FilterDataset(ds *sticker.Dataset, minLabelFreq, maxLabelFreq, ntopLabels, minFeatureDF uint) *sticker.Dataset {
+	if minLabelFreq > 0 || maxLabelFreq > 0 || ntopLabels > 0 {
+		opts.Logger.Printf("filtering the labels (minLabelFreq=%d, maxLabelFreq=%d, ntopLabels=%d) ...", minLabelFreq, maxLabelFreq, ntopLabels)
+		n0 := ds.Size()
+		ds = ds.FilterLabels(minLabelFreq, maxLabelFreq, ntopLabels)
+		opts.Logger.Printf("filtered the labels (total %d entry(s) -> %d entry(s) in dataset)", n0, ds.Size())
+	}
+	if minFeatureDF > 1 {
+		opts.Logger.Printf("filtering the features (minFeatureDF=%d) ...", minFeatureDF)
+		ds = ds.FilterFeatures(minFeatureDF)
+	}
+	return ds
+}
+
 // GetDebugLogger returns opt.DebugLogger.
 func (opts *Options) GetDebugLogger() *log.Logger {
 	return opts.DebugLogger
@@ -160,30 +233,37 @@ func (opts *Options) GetOutputWriter() io.Writer {
 func (opts *Options) initializeFlagSet() {
 	opts.flagSet = flag.NewFlagSet("sticker-util", flag.ContinueOnError)
 	opts.flagSet.SetOutput(ioutil.Discard)
+	opts.flagSet.BoolVar(&opts.AllowEmptyLabels, "allowEmptyLabels", opts.AllowEmptyLabels, "Accept the entries having no label in the tables")
+	opts.flagSet.UintVar(&opts.ChunkSize, "chunkSize", opts.ChunkSize, "Specify the number of the entries in each chunk streamed in testing")
+	opts.flagSet.BoolVar(&opts.Consistent, "consistent", opts.Consistent, "Make the predicted labels consistent with the label hierarchy in testing, so each label follows its ancestors")
 	opts.flagSet.StringVar(&opts.CPUProfile, "cpuprofile", opts.CPUProfile, "Specify the CPU profile filename")
+	opts.flagSet.BoolVar(&opts.DatasetCache, "datasetCache", opts.DatasetCache, "Use the binary cache (tblname.csr) of each table, and create it if needed")
 	opts.flagSet.BoolVar(&opts.Debug, "debug", opts.Debug, "Turn on debug logging")
 	opts.flagSet.StringVar(&opts.FeatureMapName, "featureMap", opts.FeatureMapName, "Specify the feature map filename")
 	opts.flagSet.BoolVar(&opts.Help, "h", opts.Help, "Show the help and exit")
 	opts.flagSet.BoolVar(&opts.Help, "help", opts.Help, "Show the help and exit")
 	opts.flagSet.StringVar(&opts.HTTPResource, "httpResource", opts.HTTPResource, "Specify the HTTP server resource root path")
+	opts.flagSet.BoolVar(&opts.InferHeader, "inferHeader", opts.InferHeader, "Accept the tables without the header line")
 	opts.flagSet.StringVar(&opts.LabelBoost, "labelboost", opts.LabelBoost, "Specify the .labelboost filename")
 	opts.flagSet.StringVar(&opts.LabelConst, "labelconst", opts.LabelConst, "Specify the .labelconst filename")
 	opts.flagSet.StringVar(&opts.LabelForest, "labelforest", opts.LabelForest, "Specify the .labelforest filename")
+	opts.flagSet.StringVar(&opts.LabelHierarchyName, "labelHierarchy", opts.LabelHierarchyName, "Specify the label hierarchy filename having the parent and child label pair per line (ignored if not found)")
 	opts.flagSet.StringVar(&opts.LabelNear, "labelnear", opts.LabelNear, "Specify the .labelnear filename")
 	opts.flagSet.StringVar(&opts.LabelNearest, "labelnearest", opts.LabelNearest, "Specify the .labelnearest filename")
 	opts.flagSet.StringVar(&opts.LabelNext, "labelnext", opts.LabelNext, "Specify the .labelnext filename")
 	opts.flagSet.StringVar(&opts.LabelOne, "labelone", opts.LabelOne, "Specify the .labelone filename")
 	opts.flagSet.StringVar(&opts.LabelMapName, "labelMap", opts.LabelMapName, "Specify the label map filename")
+	opts.flagSet.StringVar(&opts.Transform, "transform", opts.Transform, "Specify the .transform filename applied to the tables in training and test")
 	opts.flagSet.BoolVar(&opts.Verbose, "verbose", opts.Verbose, "Log verbosely")
 }
 
 // LabelMap returns the label name.
 func (opts *Options) LabelMap(label uint32, quote bool) string {
-	if label < uint32(len(opts.labelMap)) {
+	if name, ok := opts.labelMap.Name(label); ok {
 		if quote {
-			return fmt.Sprintf("%q", opts.labelMap[label])
+			return fmt.Sprintf("%q", name)
 		}
-		return opts.labelMap[label]
+		return name
 	}
 	return fmt.Sprintf("%d", label)
 }
@@ -222,6 +302,22 @@ func (opts *Options) Parse(args []string) error {
 			if args, err = opts.CompareForest.Parse(args); err != nil {
 				return fmt.Errorf("@compareForest: %s", err)
 			}
+		case "@featurize":
+			if opts.Featurize != nil {
+				return fmt.Errorf("cannot specify multiple @featurize commands")
+			}
+			opts.Featurize = NewFeaturizeCommand(opts)
+			if args, err = opts.Featurize.Parse(args); err != nil {
+				return fmt.Errorf("@featurize: %s", err)
+			}
+		case "@fitTransform":
+			if opts.FitTransform != nil {
+				return fmt.Errorf("cannot specify multiple @fitTransform commands")
+			}
+			opts.FitTransform = NewFitTransformCommand(opts)
+			if args, err = opts.FitTransform.Parse(args); err != nil {
+				return fmt.Errorf("@fitTransform: %s", err)
+			}
 		case "@inspectForest":
 			if opts.InspectForest != nil {
 				return fmt.Errorf("cannot specify multiple @inspectForest commands")
@@ -360,6 +456,14 @@ func (opts *Options) Parse(args []string) error {
 			if args, err = opts.TrainOne.Parse(args); err != nil {
 				return fmt.Errorf("@trainOne: %s", err)
 			}
+		case "@validate":
+			if opts.Validate != nil {
+				return fmt.Errorf("cannot specify multiple @validate commands")
+			}
+			opts.Validate = NewValidateCommand(opts)
+			if args, err = opts.Validate.Parse(args); err != nil {
+				return fmt.Errorf("@validate: %s", err)
+			}
 		default:
 			return fmt.Errorf("unknown command: %s", cmd)
 		}
@@ -368,27 +472,380 @@ func (opts *Options) Parse(args []string) error {
 }
 
 // ReadDataset reads the dataset of the given table name in the dataset path.
+// The table is parsed with the parameters specified by opts.AllowEmptyLabels and opts.InferHeader.
+// If the table does not exist, then the split table found by resolveSplitFilenames is read instead.
+// If opts.DatasetCache is true, then the binary cache tblname.csr not older than the table (both files of the split table) is memory-mapped instead of parsing the table.
+// The cache is tagged with the parsing parameters (see datasetCacheTag), so the cache created with the different parameters is ignored.
+// The feature hashing and the transform are applied after reading the dataset (see ReadDatasets), so they do not affect the cache.
+// If the cache is unavailable, then the cache is created after reading the table.
+// The memory-mapped cache is never unmapped during the process.
 //
 // This function returns an error in reading the dataset.
 func (opts *Options) ReadDataset(tblname string) (*sticker.Dataset, error) {
-	filename := filepath.Join(opts.DatasetPath, tblname)
-	file, err := os.Open(filename)
+	cachename := filepath.Join(opts.DatasetPath, tblname) + ".csr"
+	filename := opts.resolveFilename(tblname)
+	filenames := []string{filename}
+	xfilename, yfilename, split := opts.resolveSplitFilenames(tblname)
+	if split {
+		filenames = []string{xfilename, yfilename}
+	}
+	params := opts.textDatasetParameters()
+	tag := datasetCacheTag(params)
+	if opts.DatasetCache {
+		if cacheInfo, err := os.Stat(cachename); err == nil && !isOlderThanFiles(cacheInfo, filenames) {
+			opts.Logger.Printf("mapping the dataset cache %q ...", cachename)
+			ds, err := sticker.OpenMappedDataset(cachename)
+			if err == nil && ds.Tag == tag {
+				return ds.Dataset, nil
+			}
+			if err == nil {
+				err = fmt.Errorf("created with the different parsing parameters (tag=0x%x, expected=0x%x)", ds.Tag, tag)
+				ds.Close()
+			}
+			opts.Logger.Printf("ignoring the dataset cache %q: %s", cachename, err)
+		}
+	}
+	var ds *sticker.Dataset
+	if split {
+		opts.Logger.Printf("reading the split table %q and %q ...", xfilename, yfilename)
+		var err error
+		if ds, err = readSplitTable(xfilename, yfilename, params); err != nil {
+			return nil, fmt.Errorf("ReadDataset: %s", err)
+		}
+	} else {
+		file, err := sticker.OpenDecompressedFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("ReadDataset: %s: %s", filename, err)
+		}
+		defer file.Close()
+		if ds, err = sticker.ReadTextDatasetWithParameters(file, params); err != nil {
+			return nil, fmt.Errorf("ReadDataset: %s: %s", filename, err)
+		}
+	}
+	if opts.DatasetCache {
+		opts.Logger.Printf("creating the dataset cache %q ...", cachename)
+		if err := writeDatasetCache(ds, cachename, tag); err != nil {
+			opts.Logger.Printf("cannot create the dataset cache %q: %s", cachename, err)
+		}
+	}
+	return ds, nil
+}
+
+// textDatasetParameters returns the parameters for parsing the tables specified by opts.AllowEmptyLabels and opts.InferHeader.
+func (opts *Options) textDatasetParameters() *sticker.TextDatasetParameters {
+	return &sticker.TextDatasetParameters{
+		AllowEmptyLabels: opts.AllowEmptyLabels,
+		InferHeader:      opts.InferHeader,
+		Nworkers:         runtime.GOMAXPROCS(0),
+	}
+}
+
+// resolveSplitFilenames returns the paths of the feature and label texts of the split table (see sticker.ReadSplitTextDataset) of the given table name, and true if they are found.
+// The split table is looked up only if the table (or its compressed file) does not exist.
+// The candidates of name.txt are name_X_Xf.txt and name_X_Y.txt (as The Extreme Classification Repository), and name_X.txt and name_Y.txt.
+func (opts *Options) resolveSplitFilenames(tblname string) (string, string, bool) {
+	if _, err := os.Stat(opts.resolveFilename(tblname)); err == nil {
+		return "", "", false
+	}
+	ext := filepath.Ext(tblname)
+	base := strings.TrimSuffix(tblname, ext)
+	for _, suffixes := range [][2]string{{"_X_Xf", "_X_Y"}, {"_X", "_Y"}} {
+		xfilename, yfilename := opts.resolveFilename(base+suffixes[0]+ext), opts.resolveFilename(base+suffixes[1]+ext)
+		if _, err := os.Stat(xfilename); err != nil {
+			continue
+		}
+		if _, err := os.Stat(yfilename); err != nil {
+			continue
+		}
+		return xfilename, yfilename, true
+	}
+	return "", "", false
+}
+
+// readSplitTable reads the split table from the feature text xfilename and the label text yfilename.
+//
+// This function returns an error in reading the split table.
+func readSplitTable(xfilename, yfilename string, params *sticker.TextDatasetParameters) (*sticker.Dataset, error) {
+	xfile, err := sticker.OpenDecompressedFile(xfilename)
 	if err != nil {
-		return nil, fmt.Errorf("ReadDataset: %s: %s", filename, err)
+		return nil, fmt.Errorf("%s: %s", xfilename, err)
 	}
-	defer file.Close()
-	ds, err := sticker.ReadTextDataset(file)
+	defer xfile.Close()
+	yfile, err := sticker.OpenDecompressedFile(yfilename)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", yfilename, err)
+	}
+	defer yfile.Close()
+	ds, err := sticker.ReadSplitTextDataset(xfile, yfile, params)
 	if err != nil {
-		return nil, fmt.Errorf("ReadDataset: %s: %s", filename, err)
+		return nil, fmt.Errorf("%s, %s: %s", xfilename, yfilename, err)
 	}
 	return ds, nil
 }
 
+// resolveFilename returns the path of the given file name in the dataset path.
+// If the file does not exist, then the existing compressed file name.gz or name.bz2 is returned instead.
+func (opts *Options) resolveFilename(name string) string {
+	filename := filepath.Join(opts.DatasetPath, name)
+	if _, err := os.Stat(filename); err == nil {
+		return filename
+	}
+	for _, ext := range []string{".gz", ".bz2"} {
+		if _, err := os.Stat(filename + ext); err == nil {
+			return filename + ext
+		}
+	}
+	return filename
+}
+
+// datasetCacheTag returns the tag of the dataset cache identifying the parsing parameters which change the read dataset.
+func datasetCacheTag(params *sticker.TextDatasetParameters) uint16 {
+	tag := uint16(0)
+	for bit, enabled := range []bool{params.AllowEmptyLabels, params.InferHeader, params.Lenient} {
+		if enabled {
+			tag |= 1 << uint(bit)
+		}
+	}
+	return tag
+}
+
+// isOlderThanFiles returns true if the file of info is older than any of the given files.
+// The files which cannot be stated are ignored.
+func isOlderThanFiles(info os.FileInfo, filenames []string) bool {
+	for _, filename := range filenames {
+		if fileInfo, err := os.Stat(filename); err == nil && info.ModTime().Before(fileInfo.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDatasetCache writes the binary cache of ds tagged with tag atomically.
+func writeDatasetCache(ds *sticker.Dataset, cachename string, tag uint16) error {
+	tmpfile, err := ioutil.TempFile(filepath.Dir(cachename), filepath.Base(cachename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if err := ds.WriteBinaryDatasetWithTag(tmpfile, tag); err != nil {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	if err := tmpfile.Close(); err != nil {
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	if err := os.Chmod(tmpfile.Name(), 0644); err != nil {
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	return os.Rename(tmpfile.Name(), cachename)
+}
+
+// DatasetTablesReader is the sticker.DatasetReader reading the entries in the multiple tables of the dataset in order.
+// Only the selected entries are read, so the tables never reside in the memory.
+// The split tables and the tables without the header line are exceptions, which are read into the memory at opening.
+type DatasetTablesReader struct {
+	filenames   []string
+	files       []io.ReadCloser
+	readers     []sticker.DatasetReader
+	selected    []bool
+	transformer sticker.Transformer
+	nentries    int
+	t, i        int
+}
+
+// NewStreamResultsReporter returns a new common.ResultsReporter on the nentries entries with the label hierarchy if loaded.
+func (opts *Options) NewStreamResultsReporter(nentries int, Ks []uint) *common.ResultsReporter {
+	reporter := common.NewStreamResultsReporter(nentries, Ks)
+	if opts.labelHierarchy != nil {
+		reporter.SetLabelHierarchy(opts.labelHierarchy, opts.Consistent)
+	}
+	return reporter
+}
+
+// OpenDatasets opens the multiple datasets for reading at most maxentries data entries.
+// If sampling is true, then the data entries are randomly sampled without replacement as ReadDatasets does.
+// The selected entries are same as ReadDatasets, but they are read in the order of the tables.
+// If opts.Transform is specified or hashBits is positive, then the feature vectors are transformed as ReadDatasets does.
+//
+// This function returns an error in opening the datasets or reading the transform, or tblnames is empty.
+func (opts *Options) OpenDatasets(tblnames []string, maxentries uint, sampling bool, hashBits uint) (*DatasetTablesReader, error) {
+	dsname := opts.GetDatasetName()
+	if len(tblnames) == 0 {
+		return nil, fmt.Errorf("specify the table names")
+	}
+	transformer, err := opts.getDatasetTransformer(hashBits)
+	if err != nil {
+		return nil, err
+	}
+	reader := &DatasetTablesReader{
+		transformer: transformer,
+	}
+	n := 0
+	for _, tblname := range tblnames {
+		opts.Logger.Printf("opening table %q of dataset %q ...", tblname, dsname)
+		if xfilename, yfilename, ok := opts.resolveSplitFilenames(tblname); ok {
+			opts.Logger.Printf("reading the split table %q and %q ...", xfilename, yfilename)
+			ds, err := readSplitTable(xfilename, yfilename, opts.textDatasetParameters())
+			if err != nil {
+				reader.Close()
+				return nil, fmt.Errorf("OpenDatasets: %s", err)
+			}
+			reader.filenames = append(reader.filenames, xfilename)
+			reader.readers = append(reader.readers, sticker.NewDatasetReader(ds))
+			n += ds.Size()
+			continue
+		}
+		filename := opts.resolveFilename(tblname)
+		file, err := sticker.OpenDecompressedFile(filename)
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("OpenDatasets: %s: %s", filename, err)
+		}
+		textReader, err := sticker.NewTextDatasetReaderWithParameters(file, opts.textDatasetParameters())
+		if err != nil {
+			file.Close()
+			reader.Close()
+			return nil, fmt.Errorf("OpenDatasets: %s: %s", filename, err)
+		}
+		if !textReader.HasHeader() {
+			// The number of the entries is unknown, so the table is read into the memory.
+			ds, err := readAllEntries(textReader)
+			file.Close()
+			if err != nil {
+				reader.Close()
+				return nil, fmt.Errorf("OpenDatasets: %s: %s", filename, err)
+			}
+			reader.filenames = append(reader.filenames, filename)
+			reader.readers = append(reader.readers, sticker.NewDatasetReader(ds))
+			n += ds.Size()
+			continue
+		}
+		reader.filenames = append(reader.filenames, filename)
+		reader.files = append(reader.files, file)
+		reader.readers = append(reader.readers, textReader)
+		n += textReader.Nentries()
+	}
+	reader.nentries = n
+	if maxentries < uint(n) {
+		reader.nentries = int(maxentries)
+		reader.selected = make([]bool, n)
+		if sampling {
+			rng := rand.New(rand.NewSource(0))
+			perm := make([]int, n)
+			for i := range perm {
+				perm[i] = i
+			}
+			for i := 0; i < int(maxentries); i++ {
+				j := i + rng.Intn(n-i)
+				perm[i], perm[j] = perm[j], perm[i]
+			}
+			for _, i := range perm[:maxentries] {
+				reader.selected[i] = true
+			}
+		} else {
+			for i := 0; i < int(maxentries); i++ {
+				reader.selected[i] = true
+			}
+		}
+	}
+	return reader, nil
+}
+
+// readAllEntries returns the dataset of the all remaining entries in reader.
+//
+// This function returns an error in reading the entries.
+func readAllEntries(reader sticker.DatasetReader) (*sticker.Dataset, error) {
+	ds := &sticker.Dataset{
+		X: sticker.FeatureVectors{},
+		Y: sticker.LabelVectors{},
+	}
+	for {
+		x, y, err := reader.Read()
+		if err == io.EOF {
+			return ds, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ds.X, ds.Y = append(ds.X, x), append(ds.Y, y)
+	}
+}
+
+// Close closes the all tables.
+func (reader *DatasetTablesReader) Close() error {
+	var err error
+	for _, file := range reader.files {
+		if err2 := file.Close(); err == nil {
+			err = err2
+		}
+	}
+	reader.files = nil
+	return err
+}
+
+// Nentries returns the number of the read entries.
+func (reader *DatasetTablesReader) Nentries() int {
+	return reader.nentries
+}
+
+// Read returns the next selected pair of the feature vector and the label vector.
+//
+// This function returns io.EOF if all entries are read, or an error in reading the entry.
+func (reader *DatasetTablesReader) Read() (sticker.FeatureVector, sticker.LabelVector, error) {
+	for reader.t < len(reader.readers) {
+		x, y, err := reader.readers[reader.t].Read()
+		if err == io.EOF {
+			reader.t++
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("ReadDataset: %s: %s", reader.filenames[reader.t], err)
+		}
+		i := reader.i
+		reader.i++
+		if reader.selected == nil || reader.selected[i] {
+			if reader.transformer != nil {
+				x = reader.transformer.Apply(x)
+			}
+			return x, y, nil
+		}
+	}
+	return nil, nil, io.EOF
+}
+
+// ReadChunk returns the next chunk having at most opts.ChunkSize entries.
+//
+// This function returns io.EOF if all entries are read, or an error in reading the entries.
+func (opts *Options) ReadChunk(reader sticker.DatasetReader) (*sticker.Dataset, error) {
+	return sticker.ReadDatasetChunk(reader, int(opts.ChunkSize))
+}
+
 // ReadDatasets reads the multiple datasets with at most maxentries data entries.
 // If sampling is true, then the data entries are randomly sampled without replacement.
+// If opts.Transform is specified, then the feature vectors are transformed.
+// If hashBits is positive, then the feature IDs are hashed into 2^hashBits buckets after the transform.
 //
-// This function returns an error in reading the datasets, or tblnames is empty.
-func (opts *Options) ReadDatasets(tblnames []string, maxentries uint, sampling bool) (*sticker.Dataset, error) {
+// This function returns an error in reading the datasets or the transform, or tblnames is empty.
+func (opts *Options) ReadDatasets(tblnames []string, maxentries uint, sampling bool, hashBits uint) (*sticker.Dataset, error) {
+	transformer, err := opts.getDatasetTransformer(hashBits)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := opts.readDatasets(tblnames, maxentries, sampling)
+	if err != nil {
+		return nil, err
+	}
+	if transformer != nil {
+		opts.Logger.Printf("transforming %d entries (transform=%q, hashBits=%d) ...", ds.Size(), opts.Transform, hashBits)
+		ds = ds.Transform(transformer)
+	}
+	return ds, nil
+}
+
+// readDatasets is ReadDatasets without the transform.
+func (opts *Options) readDatasets(tblnames []string, maxentries uint, sampling bool) (*sticker.Dataset, error) {
 	dsname := opts.GetDatasetName()
 	ds := &sticker.Dataset{
 		X: sticker.FeatureVectors{},
@@ -403,17 +860,23 @@ func (opts *Options) ReadDatasets(tblnames []string, maxentries uint, sampling b
 		if err != nil {
 			return nil, err
 		}
-		ds.X, ds.Y = append(ds.X, subds.X...), append(ds.Y, subds.Y...)
+		ds.Append(subds)
 	}
 	if sampling {
 		rng := rand.New(rand.NewSource(0))
 		for i := 0; i < int(maxentries); i++ {
 			j := i + rng.Intn(ds.Size()-i)
 			ds.X[i], ds.X[j], ds.Y[i], ds.Y[j] = ds.X[j], ds.X[i], ds.Y[j], ds.Y[i]
+			if ds.W != nil {
+				ds.W[i], ds.W[j] = ds.W[j], ds.W[i]
+			}
 		}
 	}
 	if maxentries < uint(ds.Size()) {
 		ds.X, ds.Y = ds.X[:maxentries], ds.Y[:maxentries]
+		if ds.W != nil {
+			ds.W = ds.W[:maxentries]
+		}
 	}
 	return ds, nil
 }
@@ -442,24 +905,103 @@ func (opts *Options) RunHTTPServer(addr string, initializer func(handleFunc func
 	return http.ListenAndServe(addr, mux)
 }
 
-// ReadMap reads the map file in the dataset path.
+// getDatasetTransformer returns the transformer applying the transform in opts.Transform and the feature hashing with hashBits.
+// This returns nil if no transform is needed.
+//
+// This function returns an error in reading the transformer, or hashBits is larger than 31.
+func (opts *Options) getDatasetTransformer(hashBits uint) (sticker.Transformer, error) {
+	if hashBits > 31 {
+		return nil, fmt.Errorf("hashBits must be at most 31")
+	}
+	transformer, err := opts.GetTransformer()
+	if err != nil || hashBits == 0 {
+		return transformer, err
+	}
+	hashing := sticker.NewFeatureHashingTransformer(hashBits)
+	if transformer == nil {
+		return hashing, nil
+	}
+	return sticker.Transformers{transformer, hashing}, nil
+}
+
+// GetTransformer returns the transformer in opts.Transform, or nil if opts.Transform is empty.
+// The transformer is read only at the first call.
+//
+// This function returns an error in reading the transformer.
+func (opts *Options) GetTransformer() (sticker.Transformer, error) {
+	if opts.Transform == "" || opts.transformer != nil {
+		return opts.transformer, nil
+	}
+	opts.Logger.Printf("loading the transform from %q ...", opts.Transform)
+	file, err := os.Open(opts.Transform)
+	if err != nil {
+		return nil, fmt.Errorf("GetTransformer: %s: %s", opts.Transform, err)
+	}
+	defer file.Close()
+	transformer, err := sticker.DecodeTransformer(file)
+	if err != nil {
+		return nil, fmt.Errorf("GetTransformer: %s: %s", opts.Transform, err)
+	}
+	opts.transformer = transformer
+	return transformer, nil
+}
+
+// ModelVocabularies returns the feature and label vocabularies stored in the trained models.
+// The vocabulary is nil if the map is empty, and the feature vocabulary is nil if the feature IDs are hashed into hashBits bits.
+func (opts *Options) ModelVocabularies(hashBits uint) (featureVocab, labelVocab *sticker.Vocabulary) {
+	if opts.featureMap.Size() > 0 && hashBits == 0 {
+		featureVocab = opts.featureMap
+	}
+	if opts.labelMap.Size() > 0 {
+		labelVocab = opts.labelMap
+	}
+	return
+}
+
+// ReadMap reads the map file in the dataset path as the frozen vocabulary.
 // If mapname is "", then the map is empty.
+// The compressed map file is decompressed transparently.
 //
 // This function returns an error in reading the file.
-func (opts *Options) ReadMap(mapname string) ([]string, error) {
-	if mapname == "" {
-		return make([]string, 0), nil
+func (opts *Options) ReadMap(mapname string) (*sticker.Vocabulary, error) {
+	vocab := sticker.NewVocabulary()
+	if mapname != "" {
+		filename := opts.resolveFilename(mapname)
+		file, err := sticker.OpenDecompressedFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("ReadMap: %s: %s", filename, err)
+		}
+		defer file.Close()
+		if vocab, err = sticker.ReadVocabulary(file); err != nil {
+			return nil, fmt.Errorf("ReadMap: %s: %s", filename, err)
+		}
+	}
+	vocab.Freeze()
+	return vocab, nil
+}
+
+// ReadLabelHierarchy returns the label hierarchy read from the file in the dataset path.
+// If the file does not exist, then this function returns nil, because the label hierarchy is optional.
+//
+// This function returns an error in reading.
+func (opts *Options) ReadLabelHierarchy(name string) (*sticker.LabelHierarchy, error) {
+	if name == "" {
+		return nil, nil
 	}
-	filename := filepath.Join(opts.DatasetPath, mapname)
-	b, err := ioutil.ReadFile(filename)
+	filename := opts.resolveFilename(name)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil, nil
+	}
+	file, err := sticker.OpenDecompressedFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("ReadMap: %s: %s", filename, err)
+		return nil, fmt.Errorf("ReadLabelHierarchy: %s: %s", filename, err)
 	}
-	m := strings.Split(string(b), "\n")
-	for i, s := range m {
-		m[i] = strings.TrimSpace(s)
+	defer file.Close()
+	hierarchy, err := sticker.ReadLabelHierarchy(file)
+	if err != nil {
+		return nil, fmt.Errorf("ReadLabelHierarchy: %s: %s", filename, err)
 	}
-	return m, nil
+	return hierarchy, nil
 }
 
 // Run runs the specified sub-commands.
@@ -490,6 +1032,15 @@ func (opts *Options) Run() error {
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
+	// @featurize writes the feature and label maps, so it runs before loading them.
+	if opts.Featurize != nil {
+		startTime := time.Now()
+		if err := opts.Featurize.Run(); err != nil {
+			return fmt.Errorf("@featurize: %s", err)
+		}
+		finishTime := time.Now()
+		opts.Logger.Printf("finished @featurize in %s", finishTime.Sub(startTime))
+	}
 	opts.Logger.Printf("loading feature map from %q ...", opts.FeatureMapName)
 	var err error
 	opts.featureMap, err = opts.ReadMap(opts.FeatureMapName)
@@ -501,6 +1052,19 @@ func (opts *Options) Run() error {
 	if err != nil {
 		return err
 	}
+	opts.Logger.Printf("loading label hierarchy from %q ...", opts.LabelHierarchyName)
+	opts.labelHierarchy, err = opts.ReadLabelHierarchy(opts.LabelHierarchyName)
+	if err != nil {
+		return err
+	}
+	if opts.Validate != nil {
+		startTime := time.Now()
+		if err := opts.Validate.Run(); err != nil {
+			return fmt.Errorf("@validate: %s", err)
+		}
+		finishTime := time.Now()
+		opts.Logger.Printf("finished @validate in %s", finishTime.Sub(startTime))
+	}
 	if opts.Shuffle != nil {
 		startTime := time.Now()
 		if err := opts.Shuffle.Run(); err != nil {
@@ -514,6 +1078,14 @@ func (opts *Options) Run() error {
 			return err
 		}
 	}
+	if opts.FitTransform != nil {
+		startTime := time.Now()
+		if err := opts.FitTransform.Run(); err != nil {
+			return fmt.Errorf("@fitTransform: %s", err)
+		}
+		finishTime := time.Now()
+		opts.Logger.Printf("finished @fitTransform in %s", finishTime.Sub(startTime))
+	}
 	if opts.TrainBoost != nil {
 		startTime := time.Now()
 		if err := opts.TrainBoost.Run(); err != nil {
@@ -690,7 +1262,7 @@ func (opts *Options) SetLabelNext(value string) {
 
 // ShowHelp shows the help.
 func (opts *Options) ShowHelp() {
-	fmt.Fprintf(opts.ErrorWriter, "sticker-util\nCopyright 2017- Tatsuhiro Aoshima (hiro4bbh@gmail.com).\n\nUsage: %s [commonOptions] datasetPath (@{compareForest|inspectForest|inspectOne|pruneOne|shuffle|summarize|trainBoost|trainConst|trainForest|trainNear|trainNearest|trainNew|trainOne|testBoost|testConst|testForest|testNear|testNearest|testNext|testOne} [subCommandOptions])*\n", opts.execpath)
+	fmt.Fprintf(opts.ErrorWriter, "sticker-util\nCopyright 2017- Tatsuhiro Aoshima (hiro4bbh@gmail.com).\n\nUsage: %s [commonOptions] datasetPath (@{compareForest|featurize|fitTransform|inspectForest|inspectOne|pruneOne|shuffle|summarize|trainBoost|trainConst|trainForest|trainNear|trainNearest|trainNew|trainOne|testBoost|testConst|testForest|testNear|testNearest|testNext|testOne|validate} [subCommandOptions])*\n", opts.execpath)
 	if opts.flagSet == nil {
 		opts.initializeFlagSet()
 	}
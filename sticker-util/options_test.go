@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiro4bbh/go-assert"
+	"github.com/hiro4bbh/sticker"
+)
+
+func writeTestDatasetFiles(t *testing.T, dirname string, files map[string]string) {
+	for name, content := range files {
+		goassert.New(t).SucceedWithoutError(ioutil.WriteFile(filepath.Join(dirname, name), []byte(content), 0644))
+	}
+}
+
+func TestOptionsTolerantTables(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "sticker-util-test")
+	goassert.New(t).SucceedWithoutError(err)
+	defer os.RemoveAll(dirname)
+	// train.txt has no header line, and its second entry has no label.
+	// test.txt is split into test_X_Xf.txt and test_X_Y.txt as The Extreme Classification Repository.
+	writeTestDatasetFiles(t, dirname, map[string]string{
+		"train.txt":     "0 0:1.0 1:0.5\n 1:1.0\n1,2 2:1.0\n",
+		"test_X_Xf.txt": "2 3\n0:1.0\n2:1.0\n",
+		"test_X_Y.txt":  "2 3\n0:1\n1:1 2:1\n",
+	})
+	newOptions := func(flags []string, commands ...string) (*Options, *bytes.Buffer) {
+		output := &bytes.Buffer{}
+		opts := NewOptions("sticker-util", output, output)
+		args := append(append([]string{"-datasetCache=false", "-featureMap=", "-labelMap="}, flags...), dirname)
+		goassert.New(t).SucceedWithoutError(opts.Parse(append(args, commands...)))
+		return opts, output
+	}
+
+	opts, _ := newOptions(nil)
+	goassert.New(t).SucceedWithoutError(opts.Run())
+	_, err = opts.ReadDatasets([]string{"train.txt"}, ^uint(0), false, 0)
+	goassert.New(t, true).Equal(err != nil)
+	_, err = opts.OpenDatasets([]string{"train.txt"}, ^uint(0), false, 0)
+	goassert.New(t, true).Equal(err != nil)
+
+	opts, _ = newOptions([]string{"-allowEmptyLabels", "-inferHeader"})
+	goassert.New(t).SucceedWithoutError(opts.Run())
+	trainDataset := &sticker.Dataset{
+		X: sticker.FeatureVectors{
+			sticker.FeatureVector{sticker.KeyValue32{0, 1.0}, sticker.KeyValue32{1, 0.5}},
+			sticker.FeatureVector{sticker.KeyValue32{1, 1.0}},
+			sticker.FeatureVector{sticker.KeyValue32{2, 1.0}},
+		},
+		Y: sticker.LabelVectors{
+			sticker.LabelVector{0},
+			sticker.LabelVector{},
+			sticker.LabelVector{1, 2},
+		},
+	}
+	testDataset := &sticker.Dataset{
+		X: sticker.FeatureVectors{
+			sticker.FeatureVector{sticker.KeyValue32{0, 1.0}},
+			sticker.FeatureVector{sticker.KeyValue32{2, 1.0}},
+		},
+		Y: sticker.LabelVectors{
+			sticker.LabelVector{0},
+			sticker.LabelVector{1, 2},
+		},
+	}
+	for _, tblname := range []string{"train.txt", "test.txt"} {
+		expected := map[string]*sticker.Dataset{"train.txt": trainDataset, "test.txt": testDataset}[tblname]
+		goassert.New(t, expected).EqualWithoutError(opts.ReadDatasets([]string{tblname}, ^uint(0), false, 0))
+		reader := goassert.New(t).SucceedNew(opts.OpenDatasets([]string{tblname}, ^uint(0), false, 0)).(*DatasetTablesReader)
+		goassert.New(t, expected.Size()).Equal(reader.Nentries())
+		goassert.New(t, expected).EqualWithoutError(opts.ReadChunk(reader))
+		goassert.New(t).SucceedWithoutError(reader.Close())
+	}
+
+	// The tolerant tables can be trained and tested with the sub-commands.
+	opts, output := newOptions([]string{"-allowEmptyLabels", "-inferHeader", "-labelconst", filepath.Join(dirname, "model.labelconst")}, "@trainConst", "@testConst", "-table", "test.txt")
+	goassert.New(t).SucceedWithoutError(opts.Run())
+	goassert.New(t, true).Equal(strings.Contains(output.String(), "finished inference on 2/2 entries"))
+}
+
+func TestOptionsReadDatasetCache(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "sticker-util-test")
+	goassert.New(t).SucceedWithoutError(err)
+	defer os.RemoveAll(dirname)
+	writeTestDatasetFiles(t, dirname, map[string]string{
+		"test_X_Xf.txt": "2 3\n0:1.0\n2:1.0\n",
+		"test_X_Y.txt":  "2 3\n0:1\n\n",
+	})
+	readDataset := func(flags ...string) (*sticker.Dataset, string, error) {
+		output := &bytes.Buffer{}
+		opts := NewOptions("sticker-util", output, output)
+		goassert.New(t).SucceedWithoutError(opts.Parse(append(append([]string{"-verbose", "-featureMap=", "-labelMap="}, flags...), dirname)))
+		goassert.New(t).SucceedWithoutError(opts.Run())
+		ds, err := opts.ReadDataset("test.txt")
+		return ds, output.String(), err
+	}
+	expected := &sticker.Dataset{
+		X: sticker.FeatureVectors{
+			sticker.FeatureVector{sticker.KeyValue32{0, 1.0}},
+			sticker.FeatureVector{sticker.KeyValue32{2, 1.0}},
+		},
+		Y: sticker.LabelVectors{
+			sticker.LabelVector{0},
+			sticker.LabelVector{},
+		},
+	}
+	ds, log, err := readDataset("-allowEmptyLabels")
+	goassert.New(t, expected, true).EqualWithoutError(ds, strings.Contains(log, "creating the dataset cache"), err)
+	ds, log, err = readDataset("-allowEmptyLabels")
+	goassert.New(t, expected, false).EqualWithoutError(ds, strings.Contains(log, "creating the dataset cache"), err)
+	// The cache created with the different parsing parameters should be ignored.
+	_, log, err = readDataset()
+	goassert.New(t, true).Equal(err != nil)
+	goassert.New(t, true).Equal(strings.Contains(log, "created with the different parsing parameters"))
+	// The cache older than the label file of the split table should be ignored.
+	ds, log, err = readDataset("-allowEmptyLabels")
+	goassert.New(t, expected, false).EqualWithoutError(ds, strings.Contains(log, "creating the dataset cache"), err)
+	cacheInfo, err := os.Stat(filepath.Join(dirname, "test.txt.csr"))
+	goassert.New(t).SucceedWithoutError(err)
+	newer := cacheInfo.ModTime().Add(time.Second)
+	goassert.New(t).SucceedWithoutError(os.Chtimes(filepath.Join(dirname, "test_X_Y.txt"), newer, newer))
+	ds, log, err = readDataset("-allowEmptyLabels")
+	goassert.New(t, expected, true).EqualWithoutError(ds, strings.Contains(log, "creating the dataset cache"), err)
+}
@@ -1,12 +1,10 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
-	"os"
 	"path/filepath"
 
 	"github.com/hiro4bbh/sticker"
@@ -15,11 +13,14 @@ import (
 
 // ShuffleCommand have flags for shuffle sub-command.
 type ShuffleCommand struct {
+	Gzip       bool
 	Help       bool
 	Ks         common.OptionUints
+	Order      uint
 	ReportOnly bool
 	S          uint
 	Seed       int
+	Strategy   string
 	TableNames common.OptionStrings
 
 	opts    *Options
@@ -29,11 +30,14 @@ type ShuffleCommand struct {
 // NewShuffleCommand returns a new ShuffleCommand.
 func NewShuffleCommand(opts *Options) *ShuffleCommand {
 	return &ShuffleCommand{
+		Gzip:       false,
 		Help:       false,
 		Ks:         common.OptionUints{true, []uint{1, 3, 5}},
+		Order:      1,
 		ReportOnly: false,
 		S:          5,
 		Seed:       0,
+		Strategy:   "random",
 		TableNames: common.OptionStrings{true, []string{"train.txt", "test.txt"}},
 		opts:       opts,
 	}
@@ -43,12 +47,15 @@ func (cmd *ShuffleCommand) initializeFlagSet() {
 	cmd.flagSet = flag.NewFlagSet("@shuffle", flag.ContinueOnError)
 	cmd.flagSet.Usage = func() {}
 	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.BoolVar(&cmd.Gzip, "gzip", cmd.Gzip, "Write the gzip-compressed splitted tables with extension .gz")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.Var(&cmd.Ks, "K", "Specify the K values for reporting the attainable precision@K")
+	cmd.flagSet.UintVar(&cmd.Order, "order", cmd.Order, "Specify the order of the stratification (1: labels, 2: labels and label pairs costing O(|y|^2) on each label vector y)")
 	cmd.flagSet.BoolVar(&cmd.ReportOnly, "reportOnly", cmd.ReportOnly, "Report only, and do not write the splitted tables")
 	cmd.flagSet.UintVar(&cmd.S, "S", cmd.S, "Specify the number of splitted tables")
 	cmd.flagSet.IntVar(&cmd.Seed, "seed", cmd.Seed, "Specify the seed in shuffling")
+	cmd.flagSet.StringVar(&cmd.Strategy, "strategy", cmd.Strategy, "Specify the splitting strategy (random/stratified)")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
 }
 
@@ -72,6 +79,12 @@ func (cmd *ShuffleCommand) Run() error {
 	opts := cmd.opts
 	opts.Logger.Printf("ShuffleCommands: %#v", cmd)
 	S := cmd.S
+	if S == 0 {
+		return fmt.Errorf("specify positive S")
+	}
+	if cmd.Strategy != "random" && cmd.Strategy != "stratified" {
+		return fmt.Errorf("unknown strategy: %s", cmd.Strategy)
+	}
 	n := 0
 	ds := &sticker.Dataset{
 		X: sticker.FeatureVectors{},
@@ -89,7 +102,7 @@ func (cmd *ShuffleCommand) Run() error {
 			return err
 		}
 		starts = append(starts, ds.Size())
-		ds.X, ds.Y = append(ds.X, subds.X...), append(ds.Y, subds.Y...)
+		ds.Append(subds)
 		n += subds.Size()
 	}
 	starts = append(starts, ds.Size())
@@ -151,21 +164,46 @@ func (cmd *ShuffleCommand) Run() error {
 		}
 	}
 	report(ds, starts, cmd.TableNames.Values)
-	opts.Logger.Printf("shuffling %d entries into %d tables with the random number generator (seed=%d) ...", n, S, cmd.Seed)
 	rng := rand.New(rand.NewSource(int64(cmd.Seed)))
-	for i := 0; i < n; i++ {
-		j := i + rng.Intn(n-i)
-		ds.X[i], ds.Y[i], ds.X[j], ds.Y[j] = ds.X[j], ds.Y[j], ds.X[i], ds.Y[i]
-	}
 	joinedTblname := common.JoinTableNames(cmd.TableNames.Values)
-	unit := (uint(n) + S - 1) / S
+	ext := ""
+	if cmd.Gzip {
+		ext = ".gz"
+	}
 	starts, ends, tblnames := make([]int, 0, S), make([]int, 0, S), make([]string, 0, S)
-	for s := uint(0); s < S; s++ {
-		start, end := s*unit, (s+1)*unit
-		if end > uint(n) {
-			end = uint(n)
+	switch cmd.Strategy {
+	case "random":
+		opts.Logger.Printf("shuffling %d entries into %d tables with the random number generator (seed=%d) ...", n, S, cmd.Seed)
+		for i := 0; i < n; i++ {
+			j := i + rng.Intn(n-i)
+			ds.X[i], ds.Y[i], ds.X[j], ds.Y[j] = ds.X[j], ds.Y[j], ds.X[i], ds.Y[i]
+			if ds.W != nil {
+				ds.W[i], ds.W[j] = ds.W[j], ds.W[i]
+			}
 		}
-		starts, ends, tblnames = append(starts, int(start)), append(ends, int(end)), append(tblnames, fmt.Sprintf("%s.%d.txt", joinedTblname, s))
+		unit := (uint(n) + S - 1) / S
+		for s := uint(0); s < S; s++ {
+			start, end := s*unit, (s+1)*unit
+			if end > uint(n) {
+				end = uint(n)
+			}
+			starts, ends = append(starts, int(start)), append(ends, int(end))
+		}
+	case "stratified":
+		opts.Logger.Printf("stratifying %d entries into %d tables with order %d and the random number generator (seed=%d) ...", n, S, cmd.Order, cmd.Seed)
+		stratifiedds := &sticker.Dataset{
+			X: make(sticker.FeatureVectors, 0, n),
+			Y: make(sticker.LabelVectors, 0, n),
+		}
+		for _, split := range sticker.StratifyLabelVectors(ds.Y, S, cmd.Order, rng) {
+			starts = append(starts, stratifiedds.Size())
+			stratifiedds.Append(ds.SubSet(split))
+			ends = append(ends, stratifiedds.Size())
+		}
+		ds = stratifiedds
+	}
+	for s := uint(0); s < S; s++ {
+		tblnames = append(tblnames, fmt.Sprintf("%s.%d.txt%s", joinedTblname, s, ext))
 	}
 	starts = append(starts, ds.Size())
 	opts.Logger.Printf("Counting the unique labels on %d-fold datasets ...", S)
@@ -175,21 +213,16 @@ func (cmd *ShuffleCommand) Run() error {
 			start, end, tblname := starts[s], ends[s], tblnames[s]
 			tblpath := filepath.Join(opts.DatasetPath, tblname)
 			opts.Logger.Printf("writing %d entries (%d-%d) into #%d table %q ...", end-start, start, end-1, s, tblpath)
-			file, err := os.Create(tblpath)
-			if err != nil {
-				return fmt.Errorf("os.Create: %s: %s", tblpath, err)
-			}
 			subds := &sticker.Dataset{
 				X: ds.X[start:end],
 				Y: ds.Y[start:end],
 			}
-			writer := bufio.NewWriter(file)
-			if err := subds.WriteTextDataset(writer); err != nil {
-				file.Close()
-				return fmt.Errorf("Dataset.WriteToText: %s: %s", tblpath, err)
+			if ds.W != nil {
+				subds.W = ds.W[start:end]
+			}
+			if err := subds.WriteTextDatasetFile(tblpath); err != nil {
+				return fmt.Errorf("Dataset.WriteTextDatasetFile: %s: %s", tblpath, err)
 			}
-			writer.Flush()
-			file.Close()
 		}
 	}
 	return nil
@@ -73,10 +73,6 @@ func (cmd *SummarizeCommand) Run() error {
 	}
 	nentries := ds.Size()
 	opts.Logger.Printf("collecting feature and label frequencies ...")
-	labelInvMap := make(map[string]uint32)
-	for id, label := range opts.labelMap {
-		labelInvMap[label] = uint32(id)
-	}
 	nfeatures, nlabels := ds.X.Dim(), ds.Y.Dim()
 	featureActVec, labelCountVec := make([]float32, 0, nentries), make([]float32, 0, nentries)
 	featureFreqs, labelFreqs := make(sticker.SparseVector), make(sticker.SparseVector)
@@ -197,7 +193,7 @@ func (cmd *SummarizeCommand) Run() error {
 			}
 			labelsMap := make(map[uint32]struct{})
 			for _, labelName := range labels {
-				label, ok := labelInvMap[labelName]
+				label, ok := opts.labelMap.ID(labelName)
 				if !ok {
 					label64, err := strconv.ParseUint(labelName, 10, 64)
 					if err != nil {
@@ -6,18 +6,23 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"runtime"
 
+	"github.com/hiro4bbh/sticker"
+	"github.com/hiro4bbh/sticker/plugin"
 	"github.com/hiro4bbh/sticker/sticker-util/common"
 )
 
 // TestBoostCommand have flags for testBoost sub-command.
 type TestBoostCommand struct {
+	HashBits   uint
 	Help       bool
 	Ks         common.OptionUints
 	N          uint
 	Restore    bool
 	Ts         common.OptionUints
 	TableNames common.OptionStrings
+	Workers    uint
 
 	Result map[string]interface{}
 
@@ -28,12 +33,14 @@ type TestBoostCommand struct {
 // NewTestBoostCommand returns a new TestBoostCommand.
 func NewTestBoostCommand(opts *Options) *TestBoostCommand {
 	return &TestBoostCommand{
+		HashBits:   0,
 		Help:       false,
 		Ks:         common.OptionUints{true, []uint{1, 3, 5}},
 		N:          ^uint(0),
 		Restore:    false,
 		Ts:         common.OptionUints{true, []uint{0}},
 		TableNames: common.OptionStrings{true, []string{"test.txt"}},
+		Workers:    uint(runtime.GOMAXPROCS(0)),
 		opts:       opts,
 	}
 }
@@ -42,6 +49,7 @@ func (cmd *TestBoostCommand) initializeFlagSet() {
 	cmd.flagSet = flag.NewFlagSet("@testBoost", flag.ContinueOnError)
 	cmd.flagSet.Usage = func() {}
 	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.Var(&cmd.Ks, "K", "Specify the top-K values")
@@ -49,6 +57,7 @@ func (cmd *TestBoostCommand) initializeFlagSet() {
 	cmd.flagSet.BoolVar(&cmd.Restore, "restore", cmd.Restore, "Restore the test result if true")
 	cmd.flagSet.Var(&cmd.Ts, "T", "Specify the used numbers of rounds (use all rounds if zero)")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
+	cmd.flagSet.UintVar(&cmd.Workers, "workers", cmd.Workers, "Specify the number of the workers in prediction (GOMAXPROCS if 0)")
 }
 
 // Parse parses the flags in args, and returns the remain parts of args.
@@ -82,38 +91,45 @@ func (cmd *TestBoostCommand) Run() error {
 		defer f.Close()
 		return gob.NewDecoder(f).Decode(&cmd.Result)
 	}
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, cmd.N, true)
+	reader, err := opts.OpenDatasets(cmd.TableNames.Values, cmd.N, true, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	defer reader.Close()
 	opts.Logger.Printf("loading .labelboost model from %q ...", opts.LabelBoost)
 	model, err := common.ReadLabelBoost(opts.LabelBoost)
 	if err != nil {
 		return err
 	}
-	rounds := make([]interface{}, 0, len(cmd.Ts.Values))
-	var avgMaxPrecisions map[uint]float32
+	predictors := make([]sticker.Predictor, 0, len(cmd.Ts.Values))
+	reporters := make([]*common.ResultsReporter, 0, len(cmd.Ts.Values))
 	for _, T := range cmd.Ts.Values {
-		reporter := common.NewResultsReporter(ds.Y, cmd.Ks.Values)
-		if avgMaxPrecisions == nil {
-			avgMaxPrecisions = reporter.AvgMaxPrecisionKs()
-		}
-		opts.Logger.Printf("predicting top-%d labels with first %d rounds ...", reporter.MaxK(), T)
-		reporter.ResetTimer()
-		avgPKs, avgNKs := reporter.Report(model.PredictAll(ds.X, reporter.MaxK(), T), opts.OutputWriter)
+		predictors = append(predictors, plugin.NewLabelBoostPredictor(model, T))
+		reporters = append(reporters, opts.NewStreamResultsReporter(reader.Nentries(), cmd.Ks.Values))
+	}
+	opts.Logger.Printf("predicting top-%d labels with first %v rounds ...", reporters[0].MaxK(), cmd.Ts.Values)
+	if err := opts.EvaluatePredictors(reader, predictors, reporters, int(cmd.Workers), nil); err != nil {
+		return err
+	}
+	rounds := make([]interface{}, 0, len(cmd.Ts.Values))
+	for iT, T := range cmd.Ts.Values {
+		reporter := reporters[iT]
+		fmt.Fprintf(opts.OutputWriter, "results with first %d rounds:\n", T)
+		reporter.WriteResults(opts.OutputWriter)
 		inferenceTime, inferenceTimePerEntry := reporter.InferenceTimes()
 		rounds = append(rounds, map[string]interface{}{
 			"T":                     T,
 			"inferenceTime":         fmt.Sprintf("%s", inferenceTime),
 			"inferenceTimePerEntry": fmt.Sprintf("%s", inferenceTimePerEntry),
-			"precisions":            avgPKs,
-			"nDCGs":                 avgNKs,
+			"precisions":            reporter.AvgPrecisionKs(),
+			"nDCGs":                 reporter.AvgNDCGKs(),
 		})
 	}
+	avgMaxPrecisions := reporters[0].AvgMaxPrecisionKs()
 	cmd.Result = map[string]interface{}{
 		"Ks":            cmd.Ks.Values,
 		"maxPrecisions": avgMaxPrecisions,
-		"nentries":      ds.Size(),
+		"nentries":      reader.Nentries(),
 		"rounds":        rounds,
 	}
 	opts.Logger.Printf("dumping the test result to %q ...", restoreName)
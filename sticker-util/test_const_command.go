@@ -4,16 +4,20 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"runtime"
 
+	"github.com/hiro4bbh/sticker"
 	"github.com/hiro4bbh/sticker/sticker-util/common"
 )
 
 // TestConstCommand have flags for testForest sub-command.
 type TestConstCommand struct {
+	HashBits   uint
 	Help       bool
 	Ks         common.OptionUints
 	N          uint
 	TableNames common.OptionStrings
+	Workers    uint
 
 	Result map[string]interface{}
 
@@ -24,10 +28,12 @@ type TestConstCommand struct {
 // NewTestConstCommand returns a new TestConstCommand.
 func NewTestConstCommand(opts *Options) *TestConstCommand {
 	return &TestConstCommand{
+		HashBits:   0,
 		Help:       false,
 		Ks:         common.OptionUints{true, []uint{1, 3, 5}},
 		N:          ^uint(0),
 		TableNames: common.OptionStrings{true, []string{"test.txt"}},
+		Workers:    uint(runtime.GOMAXPROCS(0)),
 		opts:       opts,
 	}
 }
@@ -36,11 +42,13 @@ func (cmd *TestConstCommand) initializeFlagSet() {
 	cmd.flagSet = flag.NewFlagSet("@testConst", flag.ContinueOnError)
 	cmd.flagSet.Usage = func() {}
 	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.Var(&cmd.Ks, "K", "Specify the top-K values")
 	cmd.flagSet.UintVar(&cmd.N, "N", cmd.N, "Specify the maximum number of the tested entries")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
+	cmd.flagSet.UintVar(&cmd.Workers, "workers", cmd.Workers, "Specify the number of the workers in prediction (GOMAXPROCS if 0)")
 }
 
 // Parse parses the flags in args, and returns the remain parts of args.
@@ -62,20 +70,19 @@ func (cmd *TestConstCommand) Run() error {
 	}
 	opts := cmd.opts
 	opts.Logger.Printf("TestConstCommands: %#v", cmd)
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, cmd.N, true)
+	reader, err := opts.OpenDatasets(cmd.TableNames.Values, cmd.N, true, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	defer reader.Close()
 	opts.Logger.Printf("loading .labelconst model from %q ...", opts.LabelConst)
 	model, err := common.ReadLabelConst(opts.LabelConst)
 	if err != nil {
 		return err
 	}
-	reporter := common.NewResultsReporter(ds.Y, cmd.Ks.Values)
+	reporter := opts.NewStreamResultsReporter(reader.Nentries(), cmd.Ks.Values)
 	opts.Logger.Printf("predicting top-%d labels ...", reporter.MaxK())
-	reporter.ResetTimer()
-	reporter.Report(model.PredictAll(ds.X, reporter.MaxK()), opts.OutputWriter)
-	return nil
+	return opts.EvaluatePredictors(reader, []sticker.Predictor{sticker.NewLabelConstPredictor(model)}, []*common.ResultsReporter{reporter}, int(cmd.Workers), opts.OutputWriter)
 }
 
 // ShowHelp shows the help.
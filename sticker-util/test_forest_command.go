@@ -3,21 +3,26 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/bits"
+	"runtime"
 
 	"github.com/hiro4bbh/sticker"
+	"github.com/hiro4bbh/sticker/plugin"
 	"github.com/hiro4bbh/sticker/sticker-util/common"
 )
 
 // TestForestCommand have flags for testForest sub-command.
 type TestForestCommand struct {
+	HashBits    uint
 	Help        bool
 	Ks          common.OptionUints
 	N           uint
 	OnlyResults bool
 	TableNames  common.OptionStrings
 	Weighted    bool
+	Workers     uint
 
 	opts    *Options
 	flagSet *flag.FlagSet
@@ -26,12 +31,14 @@ type TestForestCommand struct {
 // NewTestForestCommand returns a new TestForestCommand.
 func NewTestForestCommand(opts *Options) *TestForestCommand {
 	return &TestForestCommand{
+		HashBits:    0,
 		Help:        false,
 		Ks:          common.OptionUints{true, []uint{1, 3, 5}},
 		N:           ^uint(0),
 		OnlyResults: false,
 		TableNames:  common.OptionStrings{true, []string{"test.txt"}},
 		Weighted:    false,
+		Workers:     uint(runtime.GOMAXPROCS(0)),
 		opts:        opts,
 	}
 }
@@ -40,6 +47,7 @@ func (cmd *TestForestCommand) initializeFlagSet() {
 	cmd.flagSet = flag.NewFlagSet("@testForest", flag.ContinueOnError)
 	cmd.flagSet.Usage = func() {}
 	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.Var(&cmd.Ks, "K", "Specify the top-K values")
@@ -47,6 +55,7 @@ func (cmd *TestForestCommand) initializeFlagSet() {
 	cmd.flagSet.BoolVar(&cmd.OnlyResults, "onlyResults", cmd.OnlyResults, "Report only the test results")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
 	cmd.flagSet.BoolVar(&cmd.Weighted, "weighted", cmd.Weighted, "Use the weighted forest")
+	cmd.flagSet.UintVar(&cmd.Workers, "workers", cmd.Workers, "Specify the number of the workers in prediction (GOMAXPROCS if 0)")
 }
 
 // Parse parses the flags in args, and returns the remain parts of args.
@@ -68,60 +77,78 @@ func (cmd *TestForestCommand) Run() error {
 	}
 	opts := cmd.opts
 	opts.Logger.Printf("TestForestCommands: %#v", cmd)
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, cmd.N, true)
+	reader, err := opts.OpenDatasets(cmd.TableNames.Values, cmd.N, true, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	defer reader.Close()
 	opts.Logger.Printf("loading .labelforest model from %q ...", opts.LabelForest)
 	forest, err := common.ReadLabelForest(opts.LabelForest)
 	if err != nil {
 		return err
 	}
-	reporter := common.NewResultsReporter(ds.Y, cmd.Ks.Values)
-	reporter.ResetTimer()
-	var leafIdsSlice [][]uint64
-	var Yhat sticker.LabelVectors
+	reporter := opts.NewStreamResultsReporter(reader.Nentries(), cmd.Ks.Values)
 	if cmd.Weighted {
-		opts.Logger.Printf("classifying all entries with weights ...")
-		var weightsSlice [][]float32
-		leafIdsSlice, weightsSlice = forest.ClassifyAllWithWeight(ds.X)
 		opts.Logger.Printf("predicting top-%d labels with weights ...", reporter.MaxK())
-		Yhat = forest.PredictAllWithWeight(leafIdsSlice, weightsSlice, reporter.MaxK())
 	} else {
-		opts.Logger.Printf("classifying all entries ...")
-		leafIdsSlice = forest.ClassifyAll(ds.X)
 		opts.Logger.Printf("predicting top-%d labels ...", reporter.MaxK())
-		Yhat = forest.PredictAll(leafIdsSlice, reporter.MaxK())
 	}
-	reporter.Report(Yhat, opts.OutputWriter)
 	if cmd.OnlyResults {
-		return nil
+		predictor := plugin.NewLabelForestPredictor(forest, cmd.Weighted)
+		return opts.EvaluatePredictors(reader, []sticker.Predictor{predictor}, []*common.ResultsReporter{reporter}, int(cmd.Workers), opts.OutputWriter)
 	}
 	sumHeights := make([]int, len(forest.Trees))
 	sumTV := float32(0.0)
 	labelFreqSlice := make(sticker.SparseVectors, len(forest.Trees))
-	for i, leafIds := range leafIdsSlice {
-		for treeId, leafId := range leafIds {
-			sumHeights[treeId] += bits.Len64(leafId) - 1
-			labelFreqSlice[treeId] = forest.Trees[treeId].LabelFreqSet[leafId]
+	for i := 0; ; {
+		chunk, err := opts.ReadChunk(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
 		}
-		TV := sticker.AvgTotalVariationAmongSparseVectors(labelFreqSlice)
-		if sticker.IsNaN32(TV) {
-			return fmt.Errorf("#%d data point: labelFreqSlice=%#v, TV=%g", i, labelFreqSlice, TV)
+		reporter.ResetTimer()
+		leafIdsSlice, Yhat := make([][]uint64, chunk.Size()), make(sticker.LabelVectors, chunk.Size())
+		sticker.ParallelizeEntries(chunk.Size(), int(cmd.Workers), func() func(ii int) {
+			return func(ii int) {
+				if cmd.Weighted {
+					var weights []float32
+					leafIdsSlice[ii], weights = forest.ClassifyWithWeight(chunk.X[ii])
+					Yhat[ii] = forest.PredictWithWeight(leafIdsSlice[ii], weights, reporter.MaxK())
+				} else {
+					leafIdsSlice[ii] = forest.Classify(chunk.X[ii])
+					Yhat[ii] = forest.Predict(leafIdsSlice[ii], reporter.MaxK())
+				}
+			}
+		})
+		reporter.ReportChunk(chunk.Y, Yhat, nil)
+		for _, leafIds := range leafIdsSlice {
+			for treeId, leafId := range leafIds {
+				sumHeights[treeId] += bits.Len64(leafId) - 1
+				labelFreqSlice[treeId] = forest.Trees[treeId].LabelFreqSet[leafId]
+			}
+			TV := sticker.AvgTotalVariationAmongSparseVectors(labelFreqSlice)
+			if sticker.IsNaN32(TV) {
+				return fmt.Errorf("#%d data point: labelFreqSlice=%#v, TV=%g", i, labelFreqSlice, TV)
+			}
+			sumTV += TV
+			i++
 		}
-		sumTV += TV
 	}
-	avgTV := sumTV / float32(len(leafIdsSlice))
+	reporter.WriteResults(opts.OutputWriter)
+	n := reporter.Nprocesseds()
+	avgTV := sumTV / float32(n)
 	avgHeights := make([]float32, len(forest.Trees))
 	for treeId, sumHeight := range sumHeights {
-		avgHeights[treeId] = float32(sumHeight) / float32(ds.Size())
+		avgHeights[treeId] = float32(sumHeight) / float32(n)
 	}
 	avgHeight := float32(0.0)
 	for _, h := range avgHeights {
 		avgHeight += h
 	}
 	avgHeight /= float32(len(avgHeights))
-	expectAvgHeight := sticker.LogBinary32(float32(ds.Size()) / float32(forest.TreeParams.MaxEntriesInLeaf))
+	expectAvgHeight := sticker.LogBinary32(float32(n) / float32(forest.TreeParams.MaxEntriesInLeaf))
 	opts.Logger.Printf("Tree Balances: [avg(height[.])]=%.4g, log2(n/MaxEntriesInLeaf)=%.4g", avgHeights, expectAvgHeight)
 	fmt.Printf("Average Tree Balance: avg([avg(height[.])])/log2(n/MaxEntriesInLeaf)=%.4g\n", avgHeight/expectAvgHeight)
 	fmt.Printf("Average Total Variation among Trees: %.5g\n", avgTV)
@@ -3,7 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"runtime"
 
 	"github.com/hiro4bbh/sticker"
 	"github.com/hiro4bbh/sticker/sticker-util/common"
@@ -14,12 +16,16 @@ type TestNearCommand struct {
 	Alpha      common.OptionFloat32
 	Beta       common.OptionFloat32
 	C          uint
+	Ef         uint
+	HashBits   uint
 	Help       bool
 	Ks         common.OptionUints
 	N          uint
 	Per        uint
+	Probes     uint
 	S          uint
 	TableNames common.OptionStrings
+	Workers    uint
 
 	opts    *Options
 	flagSet *flag.FlagSet
@@ -31,12 +37,16 @@ func NewTestNearCommand(opts *Options) *TestNearCommand {
 		Alpha:      common.OptionFloat32(1.0),
 		Beta:       common.OptionFloat32(1.0),
 		C:          uint(2),
+		Ef:         uint(0),
+		HashBits:   0,
 		Help:       false,
 		Ks:         common.OptionUints{true, []uint{1, 3, 5}},
 		N:          ^uint(0),
 		Per:        uint(0),
+		Probes:     uint(1),
 		S:          uint(1),
 		TableNames: common.OptionStrings{true, []string{"test.txt"}},
+		Workers:    uint(runtime.GOMAXPROCS(0)),
 		opts:       opts,
 	}
 }
@@ -48,13 +58,17 @@ func (cmd *TestNearCommand) initializeFlagSet() {
 	cmd.flagSet.Var(&cmd.Alpha, "alpha", "Specify the smoothing parameter for weighting the voted by each neighbor")
 	cmd.flagSet.Var(&cmd.Beta, "beta", "Specify the balancing parameter between the Jaccard and cosine similarity")
 	cmd.flagSet.UintVar(&cmd.C, "c", cmd.C, "Specify the factor of candidate near neighbors")
+	cmd.flagSet.UintVar(&cmd.Ef, "ef", cmd.Ef, "Specify the size of the dynamic candidate list in searching for hnsw (the one in training if 0)")
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.Var(&cmd.Ks, "K", "Specify the top-K values")
 	cmd.flagSet.UintVar(&cmd.N, "N", cmd.N, "Specify the maximum number of the tested entries")
 	cmd.flagSet.UintVar(&cmd.Per, "per", cmd.Per, "Specify the deep-inspection timing counts (not do deep-inspection if 0)")
+	cmd.flagSet.UintVar(&cmd.Probes, "probes", cmd.Probes, "Specify the number of the probed buckets in each hash table (more probes find more near neighbors with more computation)")
 	cmd.flagSet.UintVar(&cmd.S, "S", cmd.S, "Specify the number of nearest neighbors")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
+	cmd.flagSet.UintVar(&cmd.Workers, "workers", cmd.Workers, "Specify the number of the workers in prediction (GOMAXPROCS if 0, and ignored in deep-inspection)")
 }
 
 // Parse parses the flags in args, and returns the remain parts of args.
@@ -76,39 +90,58 @@ func (cmd *TestNearCommand) Run() error {
 	}
 	opts := cmd.opts
 	opts.Logger.Printf("TestNearCommands: %#v", cmd)
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, cmd.N, true)
+	reader, err := opts.OpenDatasets(cmd.TableNames.Values, cmd.N, true, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	defer reader.Close()
 	opts.Logger.Printf("loading .labelnear model from %q ...", opts.LabelNear)
 	model, err := common.ReadLabelNear(opts.LabelNear)
 	if err != nil {
 		return err
 	}
+	if index, ok := model.Hashing.(*sticker.HNSW); ok && cmd.Ef > 0 {
+		index.SetEf(cmd.Ef)
+	}
 	if opts.Verbose {
 		bucketUsage, bucketSizeHist := model.Hashing.Summary()
-		opts.Logger.Printf("JaccardHashing(K=%d,L=%d,R=%d): bucketUsage=%d", model.Hashing.K(), model.Hashing.L(), model.Hashing.R(), bucketUsage)
-		opts.Logger.Printf("JaccardHashing(K=%d,L=%d,R=%d): bucketSizeHist=%d", model.Hashing.K(), model.Hashing.L(), model.Hashing.R(), bucketSizeHist)
+		opts.Logger.Printf("%s: bucketUsage=%d", model.Hashing, bucketUsage)
+		opts.Logger.Printf("%s: bucketSizeHist=%d", model.Hashing, bucketSizeHist)
 	}
-	reporter := common.NewResultsReporter(ds.Y, cmd.Ks.Values)
+	reporter := opts.NewStreamResultsReporter(reader.Nentries(), cmd.Ks.Values)
 	opts.Logger.Printf("predicting top-%d labels ...", reporter.MaxK())
-	reporter.ResetTimer()
 	if cmd.Per == 0 {
-		reporter.Report(model.PredictAll(ds.X, reporter.MaxK(), cmd.C, cmd.S, float32(cmd.Alpha), float32(cmd.Beta)), opts.OutputWriter)
-	} else {
-		Yhat := make(sticker.LabelVectors, 0, ds.Size())
-		for i, xi := range ds.X {
-			yihat, labelHist, indexSimsTopS := model.Predict(xi, reporter.MaxK(), cmd.C, cmd.S, float32(cmd.Alpha), float32(cmd.Beta))
+		predictor := sticker.NewLabelNearPredictor(model, cmd.C, cmd.S, float32(cmd.Alpha), float32(cmd.Beta))
+		predictor.Probes = cmd.Probes
+		return opts.EvaluatePredictors(reader, []sticker.Predictor{predictor}, []*common.ResultsReporter{reporter}, int(cmd.Workers), opts.OutputWriter)
+	}
+	for i := 0; ; {
+		chunk, err := opts.ReadChunk(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		reporter.ResetTimer()
+		Yhat, start := make(sticker.LabelVectors, 0, chunk.Size()), 0
+		for ii, xi := range chunk.X {
+			yihat, labelHist, indexSimsTopS := model.PredictWithProbes(xi, reporter.MaxK(), cmd.C, cmd.S, cmd.Probes, float32(cmd.Alpha), float32(cmd.Beta))
 			Yhat = append(Yhat, yihat)
 			if uint(i)%cmd.Per == 0 {
 				if opts.DebugLogger != nil {
-					InspectDataEntryWithNeighbors(opts, reporter, i, xi, ds.Y[i], yihat, labelHist, indexSimsTopS)
+					InspectDataEntryWithNeighbors(opts, reporter, i, xi, chunk.Y[ii], yihat, labelHist, indexSimsTopS)
 				}
-				reporter.Report(Yhat, opts.OutputWriter)
+				reporter.ReportChunk(chunk.Y[start:ii+1], Yhat[start:], opts.OutputWriter)
+				start = ii + 1
 			}
+			i++
+		}
+		if start < chunk.Size() {
+			reporter.ReportChunk(chunk.Y[start:], Yhat[start:], nil)
 		}
-		reporter.Report(Yhat, opts.OutputWriter)
 	}
+	reporter.WriteResults(opts.OutputWriter)
 	return nil
 }
 
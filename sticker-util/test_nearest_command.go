@@ -3,7 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"runtime"
 	"sort"
 
 	"github.com/hiro4bbh/sticker"
@@ -74,12 +76,15 @@ func InspectDataEntryWithNeighbors(opts *Options, reporter *common.ResultsReport
 type TestNearestCommand struct {
 	Alpha      common.OptionFloat32
 	Beta       common.OptionFloat32
+	HashBits   uint
 	Help       bool
 	Ks         common.OptionUints
 	N          uint
 	Per        uint
+	Pruning    bool
 	S          uint
 	TableNames common.OptionStrings
+	Workers    uint
 
 	opts    *Options
 	flagSet *flag.FlagSet
@@ -90,12 +95,15 @@ func NewTestNearestCommand(opts *Options) *TestNearestCommand {
 	return &TestNearestCommand{
 		Alpha:      common.OptionFloat32(1.0),
 		Beta:       common.OptionFloat32(1.0),
+		HashBits:   0,
 		Help:       false,
 		Ks:         common.OptionUints{true, []uint{1, 3, 5}},
 		N:          ^uint(0),
 		Per:        uint(0),
+		Pruning:    false,
 		S:          uint(1),
 		TableNames: common.OptionStrings{true, []string{"test.txt"}},
+		Workers:    uint(runtime.GOMAXPROCS(0)),
 		opts:       opts,
 	}
 }
@@ -106,13 +114,16 @@ func (cmd *TestNearestCommand) initializeFlagSet() {
 	cmd.flagSet.SetOutput(ioutil.Discard)
 	cmd.flagSet.Var(&cmd.Alpha, "alpha", "Specify the smoothing parameter for weighting the voted by each neighbour")
 	cmd.flagSet.Var(&cmd.Beta, "beta", "Specify the balancing parameter between the Jaccard and cosine similarity")
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.Var(&cmd.Ks, "K", "Specify the top-K values")
 	cmd.flagSet.UintVar(&cmd.N, "N", cmd.N, "Specify the maximum number of the tested entries")
 	cmd.flagSet.UintVar(&cmd.Per, "per", cmd.Per, "Specify the deep-inspection timing counts (not do deep-inspection if 0)")
+	cmd.flagSet.BoolVar(&cmd.Pruning, "pruning", cmd.Pruning, "Specify whether the nearest neighbours are found with the dynamic pruning (the results are identical)")
 	cmd.flagSet.UintVar(&cmd.S, "S", cmd.S, "Specify the number of nearest neighbours")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
+	cmd.flagSet.UintVar(&cmd.Workers, "workers", cmd.Workers, "Specify the number of the workers in prediction (GOMAXPROCS if 0, and ignored in deep-inspection)")
 }
 
 // Parse parses the flags in args, and returns the remain parts of args.
@@ -134,35 +145,58 @@ func (cmd *TestNearestCommand) Run() error {
 	}
 	opts := cmd.opts
 	opts.Logger.Printf("TestNearestCommands: %#v", cmd)
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, cmd.N, true)
+	reader, err := opts.OpenDatasets(cmd.TableNames.Values, cmd.N, true, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	defer reader.Close()
 	opts.Logger.Printf("loading .labelnearest model from %q ...", opts.LabelNearest)
 	model, err := common.ReadLabelNearest(opts.LabelNearest)
 	if err != nil {
 		return err
 	}
-	reporter := common.NewResultsReporter(ds.Y, cmd.Ks.Values)
+	reporter := opts.NewStreamResultsReporter(reader.Nentries(), cmd.Ks.Values)
 	opts.Logger.Printf("predicting top-%d labels ...", reporter.MaxK())
-	reporter.ResetTimer()
 	if cmd.Per == 0 {
-		reporter.Report(model.PredictAll(ds.X, reporter.MaxK(), cmd.S, float32(cmd.Alpha), float32(cmd.Beta)), opts.OutputWriter)
-	} else {
-		Yhat := make(sticker.LabelVectors, 0, ds.Size())
-		ctx := model.NewContext()
-		for i, xi := range ds.X {
-			yihat, labelHist, indexSimsTopS := model.PredictWithContext(xi, reporter.MaxK(), cmd.S, float32(cmd.Alpha), float32(cmd.Beta), ctx)
+		predictor := sticker.NewLabelNearestPredictor(model, cmd.S, float32(cmd.Alpha), float32(cmd.Beta))
+		predictor.Pruning = cmd.Pruning
+		return opts.EvaluatePredictors(reader, []sticker.Predictor{predictor}, []*common.ResultsReporter{reporter}, int(cmd.Workers), opts.OutputWriter)
+	}
+	ctx := model.NewContext()
+	for i := 0; ; {
+		chunk, err := opts.ReadChunk(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		reporter.ResetTimer()
+		Yhat, start := make(sticker.LabelVectors, 0, chunk.Size()), 0
+		for ii, xi := range chunk.X {
+			var yihat sticker.LabelVector
+			var labelHist map[uint32]float32
+			var indexSimsTopS sticker.KeyValues32
+			if cmd.Pruning {
+				yihat, labelHist, indexSimsTopS = model.PredictWithPruning(xi, reporter.MaxK(), cmd.S, float32(cmd.Alpha), float32(cmd.Beta))
+			} else {
+				yihat, labelHist, indexSimsTopS = model.PredictWithContext(xi, reporter.MaxK(), cmd.S, float32(cmd.Alpha), float32(cmd.Beta), ctx)
+			}
 			Yhat = append(Yhat, yihat)
 			if uint(i)%cmd.Per == 0 {
 				if opts.DebugLogger != nil {
-					InspectDataEntryWithNeighbors(opts, reporter, i, xi, ds.Y[i], yihat, labelHist, indexSimsTopS)
+					InspectDataEntryWithNeighbors(opts, reporter, i, xi, chunk.Y[ii], yihat, labelHist, indexSimsTopS)
 				}
-				reporter.Report(Yhat, opts.OutputWriter)
+				reporter.ReportChunk(chunk.Y[start:ii+1], Yhat[start:], opts.OutputWriter)
+				start = ii + 1
 			}
+			i++
+		}
+		if start < chunk.Size() {
+			reporter.ReportChunk(chunk.Y[start:], Yhat[start:], nil)
 		}
-		reporter.Report(Yhat, opts.OutputWriter)
 	}
+	reporter.WriteResults(opts.OutputWriter)
 	return nil
 }
 
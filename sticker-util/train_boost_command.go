@@ -11,8 +11,13 @@ import (
 
 // TrainBoostCommand have flags for trainBoost sub-command.
 type TrainBoostCommand struct {
+	MaxLabelFreq       uint
+	MinFeatureDF       uint
+	MinLabelFreq       uint
+	NtopLabels         uint
 	RankerTrainerName  string
 	C, Epsilon         common.OptionFloat32
+	HashBits           uint
 	Help               bool
 	NegativeSampleSize uint
 	PainterK           uint
@@ -28,9 +33,14 @@ type TrainBoostCommand struct {
 func NewTrainBoostCommand(opts *Options) *TrainBoostCommand {
 	boostParams := plugin.NewLabelBoostParameters()
 	return &TrainBoostCommand{
+		MaxLabelFreq:       0,
+		MinFeatureDF:       0,
+		MinLabelFreq:       0,
+		NtopLabels:         0,
 		RankerTrainerName:  boostParams.RankerTrainerName,
 		C:                  common.OptionFloat32(boostParams.C),
 		Epsilon:            common.OptionFloat32(boostParams.Epsilon),
+		HashBits:           0,
 		Help:               false,
 		NegativeSampleSize: boostParams.NegativeSampleSize,
 		PainterK:           boostParams.PainterK,
@@ -45,9 +55,14 @@ func (cmd *TrainBoostCommand) initializeFlagSet() {
 	cmd.flagSet = flag.NewFlagSet("@trainBoost", flag.ContinueOnError)
 	cmd.flagSet.Usage = func() {}
 	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.UintVar(&cmd.MaxLabelFreq, "maxLabelFreq", cmd.MaxLabelFreq, "Specify the maximum frequency of the used labels (not limited if 0)")
+	cmd.flagSet.UintVar(&cmd.MinFeatureDF, "minFeatureDF", cmd.MinFeatureDF, "Specify the minimum document frequency of the used features")
+	cmd.flagSet.UintVar(&cmd.MinLabelFreq, "minLabelFreq", cmd.MinLabelFreq, "Specify the minimum frequency of the used labels")
+	cmd.flagSet.UintVar(&cmd.NtopLabels, "ntopLabels", cmd.NtopLabels, "Specify the number of the used top labels (all labels are used if 0)")
 	cmd.flagSet.StringVar(&cmd.RankerTrainerName, "rankerTrainer", cmd.RankerTrainerName, "Specify the binary ranker trainer name")
 	cmd.flagSet.Var(&cmd.C, "C", "Specify the inverse of the penalty parameter for each binary classifier")
 	cmd.flagSet.Var(&cmd.Epsilon, "epsilon", "Specify the tolerance parameter for each binary classifier")
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.UintVar(&cmd.NegativeSampleSize, "negativeSampleSize", cmd.NegativeSampleSize, "Specify the size of each negative sample for Multi-Label Ranking Hinge Boosting (specify 0 for Multi-Label Hinge Boosting)")
@@ -82,14 +97,16 @@ func (cmd *TrainBoostCommand) Run() error {
 	params.NegativeSampleSize = cmd.NegativeSampleSize
 	params.PainterK, params.PainterName = cmd.PainterK, cmd.PainterName
 	params.T = cmd.T
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false)
+	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	ds = opts.FilterDataset(ds, cmd.MinLabelFreq, cmd.MaxLabelFreq, cmd.NtopLabels, cmd.MinFeatureDF)
 	model, err := plugin.TrainLabelBoost(ds, params, opts.DebugLogger)
 	if err != nil {
 		return err
 	}
+	model.FeatureVocabulary, model.LabelVocabulary = opts.ModelVocabularies(cmd.HashBits)
 	filename := opts.LabelBoost
 	if filename == "" {
 		filename = fmt.Sprintf("./labelboost/%s.%s.T%d.labelboost", opts.GetDatasetName(), common.JoinTableNames(cmd.TableNames.Values), cmd.T)
@@ -11,8 +11,13 @@ import (
 
 // TrainConstCommand have flags for trainBoost sub-command.
 type TrainConstCommand struct {
-	Help       bool
-	TableNames common.OptionStrings
+	HashBits     uint
+	Help         bool
+	MaxLabelFreq uint
+	MinFeatureDF uint
+	MinLabelFreq uint
+	NtopLabels   uint
+	TableNames   common.OptionStrings
 
 	opts    *Options
 	flagSet *flag.FlagSet
@@ -21,9 +26,14 @@ type TrainConstCommand struct {
 // NewTrainConstCommand returns a new TrainConstCommand.
 func NewTrainConstCommand(opts *Options) *TrainConstCommand {
 	return &TrainConstCommand{
-		Help:       false,
-		TableNames: common.OptionStrings{true, []string{"train.txt"}},
-		opts:       opts,
+		HashBits:     0,
+		Help:         false,
+		MaxLabelFreq: 0,
+		MinFeatureDF: 0,
+		MinLabelFreq: 0,
+		NtopLabels:   0,
+		TableNames:   common.OptionStrings{true, []string{"train.txt"}},
+		opts:         opts,
 	}
 }
 
@@ -31,8 +41,13 @@ func (cmd *TrainConstCommand) initializeFlagSet() {
 	cmd.flagSet = flag.NewFlagSet("@trainConst", flag.ContinueOnError)
 	cmd.flagSet.Usage = func() {}
 	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
+	cmd.flagSet.UintVar(&cmd.MaxLabelFreq, "maxLabelFreq", cmd.MaxLabelFreq, "Specify the maximum frequency of the used labels (not limited if 0)")
+	cmd.flagSet.UintVar(&cmd.MinFeatureDF, "minFeatureDF", cmd.MinFeatureDF, "Specify the minimum document frequency of the used features")
+	cmd.flagSet.UintVar(&cmd.MinLabelFreq, "minLabelFreq", cmd.MinLabelFreq, "Specify the minimum frequency of the used labels")
+	cmd.flagSet.UintVar(&cmd.NtopLabels, "ntopLabels", cmd.NtopLabels, "Specify the number of the used top labels (all labels are used if 0)")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
 }
 
@@ -55,14 +70,16 @@ func (cmd *TrainConstCommand) Run() error {
 	}
 	opts := cmd.opts
 	opts.Logger.Printf("TrainConstCommands: %#v", cmd)
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false)
+	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	ds = opts.FilterDataset(ds, cmd.MinLabelFreq, cmd.MaxLabelFreq, cmd.NtopLabels, cmd.MinFeatureDF)
 	model, err := sticker.TrainLabelConst(ds, opts.DebugLogger)
 	if err != nil {
 		return err
 	}
+	model.FeatureVocabulary, model.LabelVocabulary = opts.ModelVocabularies(cmd.HashBits)
 	filename := opts.LabelConst
 	if filename == "" {
 		filename = fmt.Sprintf("./labelconst/%s.%s.labelconst", opts.GetDatasetName(), common.JoinTableNames(cmd.TableNames.Values))
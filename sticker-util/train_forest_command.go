@@ -7,7 +7,6 @@ import (
 	"runtime"
 	"strings"
 
-	"github.com/hiro4bbh/sticker"
 	"github.com/hiro4bbh/sticker/plugin"
 	"github.com/hiro4bbh/sticker/sticker-util/common"
 )
@@ -19,9 +18,13 @@ type TrainForestCommand struct {
 	ClassifierTrainerName string
 	C, Epsilon            common.OptionFloat32
 	FeatureSubSamplerName string
+	HashBits              uint
 	Help                  bool
 	K                     uint
 	MaxEntriesInLeaf      uint
+	MaxLabelFreq          uint
+	MinFeatureDF          uint
+	MinLabelFreq          uint
 	NtopLabels            uint
 	Ntrees                uint
 	SubSamplerName        string
@@ -43,9 +46,13 @@ func NewTrainForestCommand(opts *Options) *TrainForestCommand {
 		C:                     common.OptionFloat32(treeParams.C),
 		Epsilon:               common.OptionFloat32(treeParams.Epsilon),
 		FeatureSubSamplerName: treeParams.FeatureSubSamplerName,
+		HashBits:         0,
 		Help:             false,
 		K:                treeParams.K,
 		MaxEntriesInLeaf: treeParams.MaxEntriesInLeaf,
+		MaxLabelFreq:     0,
+		MinFeatureDF:     0,
+		MinLabelFreq:     0,
 		NtopLabels:       0,
 		Ntrees:           uint(runtime.GOMAXPROCS(0)),
 		SubSamplerName:   "random",
@@ -66,10 +73,14 @@ func (cmd *TrainForestCommand) initializeFlagSet() {
 	cmd.flagSet.Var(&cmd.C, "C", "Specify the inverse of the penalty for each binary classifier")
 	cmd.flagSet.Var(&cmd.Epsilon, "epsilon", "Specify the tolerance parameter for each binary classifier")
 	cmd.flagSet.StringVar(&cmd.FeatureSubSamplerName, "featureSubSampler", cmd.FeatureSubSamplerName, "Specify the dataset feature sub-sampler name")
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.UintVar(&cmd.K, "K", cmd.K, "Specify the maximum number of the labels in each terminal leaf")
 	cmd.flagSet.UintVar(&cmd.MaxEntriesInLeaf, "maxEntriesInLeaf", cmd.MaxEntriesInLeaf, "Specify the maximum number of the entries in each leaf (best-effort)")
+	cmd.flagSet.UintVar(&cmd.MaxLabelFreq, "maxLabelFreq", cmd.MaxLabelFreq, "Specify the maximum frequency of the used labels (not limited if 0)")
+	cmd.flagSet.UintVar(&cmd.MinFeatureDF, "minFeatureDF", cmd.MinFeatureDF, "Specify the minimum document frequency of the used features")
+	cmd.flagSet.UintVar(&cmd.MinLabelFreq, "minLabelFreq", cmd.MinLabelFreq, "Specify the minimum frequency of the used labels")
 	cmd.flagSet.UintVar(&cmd.NtopLabels, "ntopLabels", cmd.NtopLabels, "Specify the number of the used top labels (all labels are used if 0)")
 	cmd.flagSet.UintVar(&cmd.Ntrees, "ntrees", cmd.Ntrees, "Specify the number of the trained trees")
 	cmd.flagSet.StringVar(&cmd.SubSamplerName, "subSampler", cmd.SubSamplerName, "Specify the dataset sub-sampler name")
@@ -115,46 +126,16 @@ func (cmd *TrainForestCommand) Run() error {
 	default:
 		return fmt.Errorf("unknown subSampler: %s", cmd.SubSamplerName)
 	}
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false)
+	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false, cmd.HashBits)
 	if err != nil {
 		return err
 	}
-	if cmd.NtopLabels > 0 {
-		opts.Logger.Printf("collecting label frequencies ...")
-		labelFreq := make(sticker.SparseVector)
-		for _, yi := range ds.Y {
-			for _, label := range yi {
-				labelFreq[label] += 1
-			}
-		}
-		opts.Logger.Printf("ranking top-%d labels ...", cmd.NtopLabels)
-		labelRankK := sticker.RankTopK(labelFreq, cmd.NtopLabels)
-		labelInvRankK := sticker.InvertRanks(labelRankK)
-		remain := []int{}
-		ndeleteds := 0
-		for i, yi := range ds.Y {
-			yinew := make(sticker.LabelVector, 0)
-			for _, label := range yi {
-				if _, ok := labelInvRankK[label]; ok {
-					yinew = append(yinew, label)
-				}
-			}
-			if len(yi) > len(yinew) {
-				ndeleteds++
-			}
-			if len(yinew) > 0 {
-				remain = append(remain, i)
-			}
-			ds.Y[i] = yinew
-		}
-		n0 := ds.Size()
-		ds = ds.SubSet(remain)
-		opts.Logger.Printf("deleted non-top-%d labels from %d entry(s) (total %d entry(s) -> %d entry(s) in dataset)", cmd.NtopLabels, ndeleteds, n0, ds.Size())
-	}
+	ds = opts.FilterDataset(ds, cmd.MinLabelFreq, cmd.MaxLabelFreq, cmd.NtopLabels, cmd.MinFeatureDF)
 	forest, err := plugin.TrainLabelForest(ds, cmd.Ntrees, subsampler, params, opts.DebugLogger)
 	if err != nil {
 		return err
 	}
+	forest.FeatureVocabulary, forest.LabelVocabulary = opts.ModelVocabularies(cmd.HashBits)
 	filename := opts.LabelForest
 	if filename == "" {
 		filename = fmt.Sprintf("./labelforest/%s.%s.N%d%s%d.labelforest", opts.GetDatasetName(), common.JoinTableNames(cmd.TableNames.Values), cmd.Ntrees, strings.Title(string(cmd.SubSamplerName)), cmd.SubSampleSize)
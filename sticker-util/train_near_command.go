@@ -11,11 +11,20 @@ import (
 
 // TrainNearCommand have flags for trainBoost sub-command.
 type TrainNearCommand struct {
-	Help       bool
-	K          uint
-	L          uint
-	R          uint
-	TableNames common.OptionStrings
+	EfConstruction uint
+	HashBits       uint
+	Hashing        string
+	Help           bool
+	K              uint
+	L              uint
+	M              uint
+	MaxLabelFreq   uint
+	MinFeatureDF   uint
+	MinLabelFreq   uint
+	NtopLabels     uint
+	R              uint
+	TableNames     common.OptionStrings
+	Weighted       bool
 
 	opts    *Options
 	flagSet *flag.FlagSet
@@ -25,12 +34,21 @@ type TrainNearCommand struct {
 func NewTrainNearCommand(opts *Options) *TrainNearCommand {
 	params := sticker.NewLabelNearParameters()
 	return &TrainNearCommand{
-		Help:       false,
-		K:          params.K,
-		L:          params.L,
-		R:          params.R,
-		TableNames: common.OptionStrings{true, []string{"train.txt"}},
-		opts:       opts,
+		EfConstruction: params.EfConstruction,
+		HashBits:       0,
+		Hashing:        params.Hashing,
+		Help:           false,
+		K:              params.K,
+		L:              params.L,
+		M:              params.M,
+		MaxLabelFreq:   0,
+		MinFeatureDF:   0,
+		MinLabelFreq:   0,
+		NtopLabels:     0,
+		R:              params.R,
+		TableNames:     common.OptionStrings{true, []string{"train.txt"}},
+		Weighted:       params.Weighted,
+		opts:           opts,
 	}
 }
 
@@ -38,12 +56,21 @@ func (cmd *TrainNearCommand) initializeFlagSet() {
 	cmd.flagSet = flag.NewFlagSet("@trainNear", flag.ContinueOnError)
 	cmd.flagSet.Usage = func() {}
 	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.UintVar(&cmd.EfConstruction, "efConstruction", cmd.EfConstruction, "Specify the size of the dynamic candidate list in adding for hnsw")
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
+	cmd.flagSet.StringVar(&cmd.Hashing, "hashing", cmd.Hashing, "Specify the index for sieving the candidates (jaccard|simhash|hnsw)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
 	cmd.flagSet.UintVar(&cmd.K, "K", cmd.K, "Show the number of the hash tables")
 	cmd.flagSet.UintVar(&cmd.L, "L", cmd.L, "Show the bit-width of bucket indices in each hash table")
+	cmd.flagSet.UintVar(&cmd.M, "M", cmd.M, "Specify the number of the neighbors of each added node in each layer for hnsw")
+	cmd.flagSet.UintVar(&cmd.MaxLabelFreq, "maxLabelFreq", cmd.MaxLabelFreq, "Specify the maximum frequency of the used labels (not limited if 0)")
+	cmd.flagSet.UintVar(&cmd.MinFeatureDF, "minFeatureDF", cmd.MinFeatureDF, "Specify the minimum document frequency of the used features")
+	cmd.flagSet.UintVar(&cmd.MinLabelFreq, "minLabelFreq", cmd.MinLabelFreq, "Specify the minimum frequency of the used labels")
+	cmd.flagSet.UintVar(&cmd.NtopLabels, "ntopLabels", cmd.NtopLabels, "Specify the number of the used top labels (all labels are used if 0)")
 	cmd.flagSet.UintVar(&cmd.R, "R", cmd.R, "Show the size of a reservoir of each backet")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
+	cmd.flagSet.BoolVar(&cmd.Weighted, "weighted", cmd.Weighted, "Specify whether the weighted Jaccard similarity is used for sieving the candidates")
 }
 
 // Parse parses the flags in args, and returns the remain parts of args.
@@ -54,6 +81,14 @@ func (cmd *TrainNearCommand) Parse(args []string) ([]string, error) {
 	if err := cmd.flagSet.Parse(args); err != nil {
 		return nil, err
 	}
+	if cmd.Hashing == "hnsw" {
+		if cmd.M < 2 {
+			return nil, fmt.Errorf("M must be at least 2 for hnsw")
+		}
+		if cmd.EfConstruction < 1 {
+			return nil, fmt.Errorf("efConstruction must be positive for hnsw")
+		}
+	}
 	return cmd.flagSet.Args(), nil
 }
 
@@ -65,16 +100,19 @@ func (cmd *TrainNearCommand) Run() error {
 	}
 	opts := cmd.opts
 	opts.Logger.Printf("TrainNearCommands: %#v", cmd)
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false)
+	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	ds = opts.FilterDataset(ds, cmd.MinLabelFreq, cmd.MaxLabelFreq, cmd.NtopLabels, cmd.MinFeatureDF)
 	params := sticker.NewLabelNearParameters()
-	params.K, params.L, params.R = cmd.K, cmd.L, cmd.R
+	params.Hashing, params.K, params.L, params.R, params.Weighted = cmd.Hashing, cmd.K, cmd.L, cmd.R, cmd.Weighted
+	params.EfConstruction, params.M = cmd.EfConstruction, cmd.M
 	model, err := sticker.TrainLabelNear(ds, params, opts.DebugLogger)
 	if err != nil {
 		return err
 	}
+	model.FeatureVocabulary, model.LabelVocabulary = opts.ModelVocabularies(cmd.HashBits)
 	filename := opts.LabelNear
 	if filename == "" {
 		filename = fmt.Sprintf("./labelnear/%s.%s.labelnear", opts.GetDatasetName(), common.JoinTableNames(cmd.TableNames.Values))
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestTrainNearCommandParse(t *testing.T) {
+	opts := NewOptions("sticker-util", ioutil.Discard, ioutil.Discard)
+	goassert.New(t, []string{"@testNear"}).EqualWithoutError(NewTrainNearCommand(opts).Parse([]string{"-hashing", "hnsw", "-M", "2", "-efConstruction", "1", "@testNear"}))
+	// HNSW needs at least 2 neighbors and the positive size of the candidate list.
+	goassert.New(t, "M must be at least 2 for hnsw").ExpectError(NewTrainNearCommand(opts).Parse([]string{"-hashing", "hnsw", "-M", "1"}))
+	goassert.New(t, "M must be at least 2 for hnsw").ExpectError(NewTrainNearCommand(opts).Parse([]string{"-hashing", "hnsw", "-M", "0"}))
+	goassert.New(t, "efConstruction must be positive for hnsw").ExpectError(NewTrainNearCommand(opts).Parse([]string{"-hashing", "hnsw", "-efConstruction", "0"}))
+	// M is ignored by the hashing.
+	goassert.New(t, []string{}).EqualWithoutError(NewTrainNearCommand(opts).Parse([]string{"-hashing", "jaccard", "-M", "1"}))
+}
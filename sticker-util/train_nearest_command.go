@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
 
 	"github.com/hiro4bbh/sticker"
 	"github.com/hiro4bbh/sticker/sticker-util/common"
@@ -11,8 +12,16 @@ import (
 
 // TrainNearestCommand have flags for trainBoost sub-command.
 type TrainNearestCommand struct {
-	Help       bool
-	TableNames common.OptionStrings
+	HashBits     uint
+	Help         bool
+	MaxLabelFreq uint
+	MinFeatureDF uint
+	MinLabelFreq uint
+	NtopLabels   uint
+	Quantize     uint
+	ReportN      uint
+	ReportS      uint
+	TableNames   common.OptionStrings
 
 	opts    *Options
 	flagSet *flag.FlagSet
@@ -21,9 +30,17 @@ type TrainNearestCommand struct {
 // NewTrainNearestCommand returns a new TrainNearestCommand.
 func NewTrainNearestCommand(opts *Options) *TrainNearestCommand {
 	return &TrainNearestCommand{
-		Help:       false,
-		TableNames: common.OptionStrings{true, []string{"train.txt"}},
-		opts:       opts,
+		HashBits:     0,
+		Help:         false,
+		MaxLabelFreq: 0,
+		MinFeatureDF: 0,
+		MinLabelFreq: 0,
+		NtopLabels:   0,
+		Quantize:     0,
+		ReportN:      1000,
+		ReportS:      10,
+		TableNames:   common.OptionStrings{true, []string{"train.txt"}},
+		opts:         opts,
 	}
 }
 
@@ -31,8 +48,16 @@ func (cmd *TrainNearestCommand) initializeFlagSet() {
 	cmd.flagSet = flag.NewFlagSet("@trainNearest", flag.ContinueOnError)
 	cmd.flagSet.Usage = func() {}
 	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
+	cmd.flagSet.UintVar(&cmd.MaxLabelFreq, "maxLabelFreq", cmd.MaxLabelFreq, "Specify the maximum frequency of the used labels (not limited if 0)")
+	cmd.flagSet.UintVar(&cmd.MinFeatureDF, "minFeatureDF", cmd.MinFeatureDF, "Specify the minimum document frequency of the used features")
+	cmd.flagSet.UintVar(&cmd.MinLabelFreq, "minLabelFreq", cmd.MinLabelFreq, "Specify the minimum frequency of the used labels")
+	cmd.flagSet.UintVar(&cmd.NtopLabels, "ntopLabels", cmd.NtopLabels, "Specify the number of the used top labels (all labels are used if 0)")
+	cmd.flagSet.UintVar(&cmd.Quantize, "quantize", cmd.Quantize, "Specify the number of bits (8 or 16) of the quantized values in the compressed feature indices (not compressed if 0)")
+	cmd.flagSet.UintVar(&cmd.ReportN, "reportN", cmd.ReportN, "Specify the number of the training entries used for reporting the accuracy of the compressed model")
+	cmd.flagSet.UintVar(&cmd.ReportS, "reportS", cmd.ReportS, "Specify the number of nearest neighbours used for reporting the accuracy of the compressed model")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
 }
 
@@ -55,14 +80,21 @@ func (cmd *TrainNearestCommand) Run() error {
 	}
 	opts := cmd.opts
 	opts.Logger.Printf("TrainNearestCommands: %#v", cmd)
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false)
+	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	ds = opts.FilterDataset(ds, cmd.MinLabelFreq, cmd.MaxLabelFreq, cmd.NtopLabels, cmd.MinFeatureDF)
 	model, err := sticker.TrainLabelNearest(ds, opts.DebugLogger)
 	if err != nil {
 		return err
 	}
+	model.FeatureVocabulary, model.LabelVocabulary = opts.ModelVocabularies(cmd.HashBits)
+	if cmd.Quantize != 0 {
+		if err := cmd.compress(model, ds); err != nil {
+			return err
+		}
+	}
 	filename := opts.LabelNearest
 	if filename == "" {
 		filename = fmt.Sprintf("./labelnearest/%s.%s.labelnearest", opts.GetDatasetName(), common.JoinTableNames(cmd.TableNames.Values))
@@ -77,6 +109,58 @@ func (cmd *TrainNearestCommand) Run() error {
 	if err := sticker.EncodeLabelNearest(model, file); err != nil {
 		return fmt.Errorf("%s: %s", filename, err)
 	}
+	// The deltas of the old model must not be applied to the new model.
+	if err := os.Remove(filename + ".delta"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// compress compresses the model, and reports the size of the feature indices and the accuracy of the nearest neighbours on the first ReportN training entries.
+// The accuracy is the recall of the top-ReportS nearest neighbours of the original model, and the mean absolute error of their similarities.
+func (cmd *TrainNearestCommand) compress(model *sticker.LabelNearest, ds *sticker.Dataset) error {
+	opts := cmd.opts
+	n := ds.Size()
+	if uint(n) > cmd.ReportN {
+		n = int(cmd.ReportN)
+	}
+	nearests := make([]sticker.KeyValues32, n)
+	sticker.ParallelizeEntries(n, 0, func() func(i int) {
+		ctx := model.NewContext()
+		return func(i int) {
+			nearests[i] = model.FindNearestsWithContext(ds.X[i], cmd.ReportS, 0.0, ctx)
+		}
+	})
+	nbytes := model.FeatureIndexBytes()
+	opts.Logger.Printf("compressing the feature indices with %d-bit values ...", cmd.Quantize)
+	if err := model.Compress(cmd.Quantize); err != nil {
+		return err
+	}
+	opts.Logger.Printf("the postings of the feature indices: %d bytes -> %d bytes (%.2f%%)", nbytes, model.FeatureIndexBytes(), float64(model.FeatureIndexBytes())/float64(nbytes)*100)
+	nhits, ntotals, simErrors := make([]int, n), make([]int, n), make([]float64, n)
+	sticker.ParallelizeEntries(n, 0, func() func(i int) {
+		ctx := model.NewContext()
+		return func(i int) {
+			sims := make(map[uint32]float32)
+			for _, indexSim := range model.FindNearestsWithContext(ds.X[i], cmd.ReportS, 0.0, ctx) {
+				sims[indexSim.Key] = indexSim.Value
+			}
+			for _, indexSim := range nearests[i] {
+				if sim, ok := sims[indexSim.Key]; ok {
+					nhits[i]++
+					simErrors[i] += float64(sticker.Abs32(sim - indexSim.Value))
+				}
+			}
+			ntotals[i] = len(nearests[i])
+		}
+	})
+	nhit, ntotal, simError := 0, 0, 0.0
+	for i := 0; i < n; i++ {
+		nhit, ntotal, simError = nhit+nhits[i], ntotal+ntotals[i], simError+simErrors[i]
+	}
+	if nhit > 0 {
+		opts.Logger.Printf("the top-%d nearest neighbours of %d training entries: recall=%.2f%%, mean absolute similarity error=%.3g", cmd.ReportS, n, float64(nhit)/float64(ntotal)*100, simError/float64(nhit))
+	}
 	return nil
 }
 
@@ -13,7 +13,12 @@ import (
 type TrainOneCommand struct {
 	ClassifierTrainerName string
 	C, Epsilon            common.OptionFloat32
+	HashBits              uint
 	Help                  bool
+	MaxLabelFreq          uint
+	MinFeatureDF          uint
+	MinLabelFreq          uint
+	NtopLabels            uint
 	T                     uint
 	TableNames            common.OptionStrings
 
@@ -26,12 +31,17 @@ func NewTrainOneCommand(opts *Options) *TrainOneCommand {
 	boostParams := sticker.NewLabelOneParameters()
 	return &TrainOneCommand{
 		ClassifierTrainerName: boostParams.ClassifierTrainerName,
-		C:          common.OptionFloat32(boostParams.C),
-		Epsilon:    common.OptionFloat32(boostParams.Epsilon),
-		Help:       false,
-		T:          boostParams.T,
-		TableNames: common.OptionStrings{true, []string{"train.txt"}},
-		opts:       opts,
+		C:            common.OptionFloat32(boostParams.C),
+		Epsilon:      common.OptionFloat32(boostParams.Epsilon),
+		HashBits:     0,
+		Help:         false,
+		MaxLabelFreq: 0,
+		MinFeatureDF: 0,
+		MinLabelFreq: 0,
+		NtopLabels:   0,
+		T:            boostParams.T,
+		TableNames:   common.OptionStrings{true, []string{"train.txt"}},
+		opts:         opts,
 	}
 }
 
@@ -42,8 +52,13 @@ func (cmd *TrainOneCommand) initializeFlagSet() {
 	cmd.flagSet.StringVar(&cmd.ClassifierTrainerName, "classifierTrainer", cmd.ClassifierTrainerName, "Specify the binary classifier trainer name")
 	cmd.flagSet.Var(&cmd.C, "C", "Specify the inverse of the penalty parameter for each binary classifier")
 	cmd.flagSet.Var(&cmd.Epsilon, "epsilon", "Specify the tolerance parameter for each binary classifier")
+	cmd.flagSet.UintVar(&cmd.HashBits, "hashBits", cmd.HashBits, "Specify the number of bits b for hashing the feature IDs into 2^b buckets (not hashed if 0)")
 	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
 	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
+	cmd.flagSet.UintVar(&cmd.MaxLabelFreq, "maxLabelFreq", cmd.MaxLabelFreq, "Specify the maximum frequency of the used labels (not limited if 0)")
+	cmd.flagSet.UintVar(&cmd.MinFeatureDF, "minFeatureDF", cmd.MinFeatureDF, "Specify the minimum document frequency of the used features")
+	cmd.flagSet.UintVar(&cmd.MinLabelFreq, "minLabelFreq", cmd.MinLabelFreq, "Specify the minimum frequency of the used labels")
+	cmd.flagSet.UintVar(&cmd.NtopLabels, "ntopLabels", cmd.NtopLabels, "Specify the number of the used top labels (all labels are used if 0)")
 	cmd.flagSet.UintVar(&cmd.T, "T", cmd.T, "Specify the maximum number of the target labels")
 	cmd.flagSet.Var(&cmd.TableNames, "table", "Specify the table names")
 }
@@ -71,14 +86,16 @@ func (cmd *TrainOneCommand) Run() error {
 	params.ClassifierTrainerName = cmd.ClassifierTrainerName
 	params.C, params.Epsilon = float32(cmd.C), float32(cmd.Epsilon)
 	params.T = cmd.T
-	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false)
+	ds, err := opts.ReadDatasets(cmd.TableNames.Values, ^uint(0), false, cmd.HashBits)
 	if err != nil {
 		return err
 	}
+	ds = opts.FilterDataset(ds, cmd.MinLabelFreq, cmd.MaxLabelFreq, cmd.NtopLabels, cmd.MinFeatureDF)
 	model, err := sticker.TrainLabelOne(ds, params, opts.DebugLogger)
 	if err != nil {
 		return err
 	}
+	model.FeatureVocabulary, model.LabelVocabulary = opts.ModelVocabularies(cmd.HashBits)
 	filename := opts.LabelOne
 	if filename == "" {
 		filename = fmt.Sprintf("./labelone/%s.%s.T%d.labelone", opts.GetDatasetName(), common.JoinTableNames(cmd.TableNames.Values), cmd.T)
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/hiro4bbh/sticker"
+)
+
+// ValidateCommand have flags for validate sub-command.
+type ValidateCommand struct {
+	AllowEmptyLabels bool
+	Help             bool
+	MaxIssues        uint
+	OutputName       string
+	TableName        string
+
+	opts    *Options
+	flagSet *flag.FlagSet
+}
+
+// NewValidateCommand returns a new ValidateCommand.
+func NewValidateCommand(opts *Options) *ValidateCommand {
+	return &ValidateCommand{
+		AllowEmptyLabels: false,
+		Help:             false,
+		MaxIssues:        20,
+		OutputName:       "",
+		TableName:        "train.txt",
+		opts:             opts,
+	}
+}
+
+func (cmd *ValidateCommand) initializeFlagSet() {
+	cmd.flagSet = flag.NewFlagSet("@validate", flag.ContinueOnError)
+	cmd.flagSet.Usage = func() {}
+	cmd.flagSet.SetOutput(ioutil.Discard)
+	cmd.flagSet.BoolVar(&cmd.AllowEmptyLabels, "allowEmptyLabels", cmd.AllowEmptyLabels, "Accept the entries having no label")
+	cmd.flagSet.BoolVar(&cmd.Help, "h", cmd.Help, "Show the help and exit")
+	cmd.flagSet.BoolVar(&cmd.Help, "help", cmd.Help, "Show the help and exit")
+	cmd.flagSet.UintVar(&cmd.MaxIssues, "maxIssues", cmd.MaxIssues, "Specify the maximum number of the shown issues")
+	cmd.flagSet.StringVar(&cmd.OutputName, "output", cmd.OutputName, "Specify the table name of the cleaned table (not written if empty, and gzip-compressed if the extension is .gz)")
+	cmd.flagSet.StringVar(&cmd.TableName, "table", cmd.TableName, "Specify the table name")
+}
+
+// Parse parses the flags in args, and returns the remain parts of args.
+//
+// This function returns an error in parsing.
+func (cmd *ValidateCommand) Parse(args []string) ([]string, error) {
+	cmd.initializeFlagSet()
+	if err := cmd.flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+	return cmd.flagSet.Args(), nil
+}
+
+// Run validates the specified table of the dataset leniently, and writes the cleaned table if specified.
+func (cmd *ValidateCommand) Run() error {
+	if cmd.Help {
+		cmd.ShowHelp()
+		return nil
+	}
+	opts := cmd.opts
+	opts.Logger.Printf("ValidateCommand: %#v", cmd)
+	dsname := opts.GetDatasetName()
+	filename := opts.resolveFilename(cmd.TableName)
+	opts.Logger.Printf("validating table %q of dataset %q ...", cmd.TableName, dsname)
+	file, err := sticker.OpenDecompressedFile(filename)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	defer file.Close()
+	ds, report, err := sticker.ReadTextDatasetWithReport(file, &sticker.TextDatasetParameters{
+		AllowEmptyLabels: cmd.AllowEmptyLabels,
+		Lenient:          true,
+		Nworkers:         runtime.GOMAXPROCS(0),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	fmt.Fprintf(opts.OutputWriter, "table %q: read %d lines, and found %d valid entries with %d issues\n", cmd.TableName, report.Nlines, ds.Size(), len(report.Issues))
+	counts := report.Counts()
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(opts.OutputWriter, "%s: %d\n", kind, counts[sticker.TextDatasetIssueKind(kind)])
+	}
+	for k, issue := range report.Issues {
+		if uint(k) >= cmd.MaxIssues {
+			fmt.Fprintf(opts.OutputWriter, "... (%d more issues)\n", len(report.Issues)-k)
+			break
+		}
+		fmt.Fprintf(opts.OutputWriter, "L%d:C%d: %s: %s (%s)\n", issue.Line, issue.Column, issue.Kind, issue.Reason, issue.Field)
+	}
+	if cmd.OutputName != "" {
+		outputpath := filepath.Join(opts.DatasetPath, cmd.OutputName)
+		opts.Logger.Printf("writing %d entries into the cleaned table %q ...", ds.Size(), outputpath)
+		if err := ds.WriteTextDatasetFile(outputpath); err != nil {
+			return fmt.Errorf("Dataset.WriteTextDatasetFile: %s: %s", outputpath, err)
+		}
+	}
+	return nil
+}
+
+// ShowHelp shows the help.
+func (cmd *ValidateCommand) ShowHelp() {
+	fmt.Fprintf(cmd.opts.ErrorWriter, "sticker-util\nCopyright 2017- Tatsuhiro Aoshima (hiro4bbh@gmail.com).\n\nUsage: @validate [subCommandOptions]\n")
+	if cmd.flagSet == nil {
+		cmd.initializeFlagSet()
+	}
+	cmd.flagSet.SetOutput(cmd.opts.ErrorWriter)
+	cmd.flagSet.PrintDefaults()
+	cmd.flagSet.SetOutput(ioutil.Discard)
+}
@@ -0,0 +1,196 @@
+package sticker
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// EnglishStopWords is the list of the common English stop words.
+var EnglishStopWords = []string{
+	"a", "about", "above", "after", "again", "against", "all", "am", "an", "and", "any", "are", "as", "at",
+	"be", "because", "been", "before", "being", "below", "between", "both", "but", "by",
+	"can", "could", "did", "do", "does", "doing", "down", "during", "each", "few", "for", "from", "further",
+	"had", "has", "have", "having", "he", "her", "here", "hers", "herself", "him", "himself", "his", "how",
+	"i", "if", "in", "into", "is", "it", "its", "itself", "just", "me", "more", "most", "my", "myself",
+	"no", "nor", "not", "now", "of", "off", "on", "once", "only", "or", "other", "our", "ours", "ourselves", "out", "over", "own",
+	"same", "she", "should", "so", "some", "such", "than", "that", "the", "their", "theirs", "them", "themselves", "then", "there", "these", "they", "this", "those", "through", "to", "too",
+	"under", "until", "up", "very", "was", "we", "were", "what", "when", "where", "which", "while", "who", "whom", "why", "will", "with", "would",
+	"you", "your", "yours", "yourself", "yourselves",
+}
+
+// TokenizeText returns the tokens in the given text.
+// A token is the maximal sequence of letters, digits and marks.
+func TokenizeText(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
+
+// textFeaturizerPad is the pad of each token in the character n-grams.
+// The pad is not a whitespace, so the n-grams are kept in the feature map read by ReadVocabulary trimming the spaces, and it never appears in the tokens (see TokenizeText).
+const textFeaturizerPad = "_"
+
+// TextFeaturizerParameters has parameters for TextFeaturizer.
+type TextFeaturizerParameters struct {
+	// Lowercase is true if the tokens are lowercased.
+	Lowercase bool
+	// StopWords is the list of the tokens removed before making the n-grams.
+	// The stop words are compared after lowercasing if Lowercase is true.
+	StopWords []string
+	// MinWordNgram and MaxWordNgram are the range of n of the word n-grams joined by single space.
+	// The word n-grams are not used if MaxWordNgram is 0.
+	MinWordNgram, MaxWordNgram uint
+	// MinCharNgram and MaxCharNgram are the range of n of the character n-grams in each token padded by textFeaturizerPad.
+	// The character n-grams have prefix "#", and they are not used if MaxCharNgram is 0.
+	// The n-gram having only the pad is not used.
+	MinCharNgram, MaxCharNgram uint
+	// MinDF is the minimum number of the documents having each term in the vocabulary.
+	MinDF uint
+	// MaxDF is the maximum ratio of the documents having each term in the vocabulary.
+	MaxDF float32
+}
+
+// NewTextFeaturizerParameters returns a new TextFeaturizerParameters with the default values.
+// The default is the lowercased word unigrams without stop words.
+func NewTextFeaturizerParameters() *TextFeaturizerParameters {
+	return &TextFeaturizerParameters{
+		Lowercase:    true,
+		StopWords:    []string{},
+		MinWordNgram: 1,
+		MaxWordNgram: 1,
+		MinCharNgram: 0,
+		MaxCharNgram: 0,
+		MinDF:        1,
+		MaxDF:        1.0,
+	}
+}
+
+// TextFeaturizer is the featurizer from the raw texts to the feature vectors of the term frequencies.
+// The terms are the word and character n-grams, and the features are the terms in the vocabulary fitted on the training texts.
+type TextFeaturizer struct {
+	// Params is the parameters used in fitting.
+	Params TextFeaturizerParameters
+	// Vocabulary is the frozen vocabulary of the terms fitted by Fit.
+	Vocabulary *Vocabulary
+
+	stopWords map[string]bool
+}
+
+// NewTextFeaturizer returns a new unfitted TextFeaturizer with the given parameters.
+func NewTextFeaturizer(params *TextFeaturizerParameters) *TextFeaturizer {
+	featurizer := &TextFeaturizer{
+		Params:     *params,
+		Vocabulary: NewVocabulary(),
+	}
+	featurizer.initializeStopWords()
+	return featurizer
+}
+
+func (featurizer *TextFeaturizer) initializeStopWords() {
+	featurizer.stopWords = make(map[string]bool)
+	for _, word := range featurizer.Params.StopWords {
+		if featurizer.Params.Lowercase {
+			word = strings.ToLower(word)
+		}
+		featurizer.stopWords[word] = true
+	}
+}
+
+// Terms returns the terms in the given text in order of appearance.
+// The word n-grams precede the character n-grams.
+func (featurizer *TextFeaturizer) Terms(text string) []string {
+	params := &featurizer.Params
+	if params.Lowercase {
+		text = strings.ToLower(text)
+	}
+	tokens := TokenizeText(text)
+	words := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if !featurizer.stopWords[token] {
+			words = append(words, token)
+		}
+	}
+	terms := []string{}
+	for n := int(params.MinWordNgram); n <= int(params.MaxWordNgram); n++ {
+		if n == 0 {
+			continue
+		}
+		for start := 0; start+n <= len(words); start++ {
+			terms = append(terms, strings.Join(words[start:start+n], " "))
+		}
+	}
+	for _, word := range words {
+		runes := []rune(textFeaturizerPad + word + textFeaturizerPad)
+		for n := int(params.MinCharNgram); n <= int(params.MaxCharNgram); n++ {
+			if n == 0 {
+				continue
+			}
+			for start := 0; start+n <= len(runes); start++ {
+				gram := string(runes[start : start+n])
+				if gram == textFeaturizerPad {
+					continue
+				}
+				terms = append(terms, "#"+gram)
+			}
+		}
+	}
+	return terms
+}
+
+// Fit fits the vocabulary on the given training texts.
+// The terms whose document frequencies are out of [MinDF, MaxDF*len(texts)] are pruned.
+// The features are ordered in descending order of the document frequencies, and ties are ordered by the terms.
+func (featurizer *TextFeaturizer) Fit(texts []string) {
+	dfs := make(map[string]uint)
+	for _, text := range texts {
+		seen := make(map[string]bool)
+		for _, term := range featurizer.Terms(text) {
+			if !seen[term] {
+				seen[term] = true
+				dfs[term]++
+			}
+		}
+	}
+	maxDF := featurizer.Params.MaxDF * float32(len(texts))
+	terms := make([]string, 0, len(dfs))
+	for term, df := range dfs {
+		if df >= featurizer.Params.MinDF && float32(df) <= maxDF {
+			terms = append(terms, term)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if dfs[terms[i]] != dfs[terms[j]] {
+			return dfs[terms[i]] > dfs[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	featurizer.Vocabulary = NewVocabularyFromNames(terms)
+	featurizer.Vocabulary.Freeze()
+}
+
+// Featurize returns the feature vector of the term frequencies in the given text.
+// The terms not in the vocabulary are ignored.
+func (featurizer *TextFeaturizer) Featurize(text string) FeatureVector {
+	tfs := make(map[uint32]float32)
+	for _, term := range featurizer.Terms(text) {
+		if id, ok := featurizer.Vocabulary.ID(term); ok {
+			tfs[id]++
+		}
+	}
+	x := make(FeatureVector, 0, len(tfs))
+	for id, tf := range tfs {
+		x = append(x, KeyValue32{id, tf})
+	}
+	sort.Sort(x)
+	return x
+}
+
+// FeaturizeAll returns the feature vectors of the given texts.
+func (featurizer *TextFeaturizer) FeaturizeAll(texts []string) FeatureVectors {
+	X := make(FeatureVectors, len(texts))
+	for i, text := range texts {
+		X[i] = featurizer.Featurize(text)
+	}
+	return X
+}
@@ -0,0 +1,48 @@
+package sticker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestTokenizeText(t *testing.T) {
+	goassert.New(t, []string{"Hello", "world", "42", "café"}).Equal(TokenizeText("Hello, world! 42 café..."))
+	goassert.New(t, 0).Equal(len(TokenizeText(" ,. ")))
+}
+
+func TestTextFeaturizer(t *testing.T) {
+	params := NewTextFeaturizerParameters()
+	params.StopWords = []string{"The"}
+	params.MaxWordNgram = 2
+	featurizer := NewTextFeaturizer(params)
+	goassert.New(t, []string{"new", "york", "is", "big", "new york", "york is", "is big"}).Equal(featurizer.Terms("The New York is big"))
+	params = NewTextFeaturizerParameters()
+	params.Lowercase = false
+	params.MaxWordNgram, params.MinCharNgram, params.MaxCharNgram = 0, 2, 3
+	goassert.New(t, []string{"#_A", "#Ab", "#b_", "#_Ab", "#Ab_"}).Equal(NewTextFeaturizer(params).Terms("Ab"))
+	// The n-grams having only the pad should not be used.
+	params.MinCharNgram = 1
+	goassert.New(t, []string{"#A", "#b", "#_A", "#Ab", "#b_", "#_Ab", "#Ab_"}).Equal(NewTextFeaturizer(params).Terms("Ab"))
+	// Fit should prune the terms with the document frequencies.
+	params = NewTextFeaturizerParameters()
+	params.MinDF, params.MaxDF = 2, 0.75
+	featurizer = NewTextFeaturizer(params)
+	featurizer.Fit([]string{"a b c", "a b d", "a c e", "a b"})
+	goassert.New(t, []string{"b", "c"}, true).Equal(featurizer.Vocabulary.Names(), featurizer.Vocabulary.IsFrozen())
+	goassert.New(t, FeatureVector{KeyValue32{0, 2.0}, KeyValue32{1, 1.0}}).Equal(featurizer.Featurize("c B b z"))
+	goassert.New(t, FeatureVectors{FeatureVector{}, FeatureVector{KeyValue32{1, 1.0}}}).Equal(featurizer.FeaturizeAll([]string{"", "c"}))
+	// The character n-grams should be kept in the feature map.
+	params = NewTextFeaturizerParameters()
+	params.MinCharNgram, params.MaxCharNgram = 1, 3
+	featurizer = NewTextFeaturizer(params)
+	featurizer.Fit([]string{"ab b", "a ba"})
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(featurizer.Vocabulary.WriteVocabulary(&buf))
+	vocab := goassert.New(t).SucceedNew(ReadVocabulary(&buf)).(*Vocabulary)
+	goassert.New(t, featurizer.Vocabulary.Names()).Equal(vocab.Names())
+	for id, name := range vocab.Names() {
+		goassert.New(t, uint32(id), true).Equal(vocab.ID(name))
+	}
+}
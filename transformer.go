@@ -0,0 +1,413 @@
+package sticker
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Transformer is the interface for the feature weighting transforms.
+// A Transformer is fitted on the training feature vectors, and applied to any feature vectors.
+// The fitted Transformer can be encoded with EncodeTransformer, so the same weighting can be applied in training and test.
+type Transformer interface {
+	// Fit fits the transform on the given feature vectors.
+	Fit(X FeatureVectors) error
+	// Apply returns the transformed copy of the given feature vector.
+	Apply(x FeatureVector) FeatureVector
+}
+
+// ApplyTransformer returns the transformed copies of the given feature vectors.
+func ApplyTransformer(transformer Transformer, X FeatureVectors) FeatureVectors {
+	Xt := make(FeatureVectors, len(X))
+	for i, xi := range X {
+		Xt[i] = transformer.Apply(xi)
+	}
+	return Xt
+}
+
+// Transform returns the dataset whose feature vectors are transformed by the given transformer.
+// For efficiency, the label vectors and the weights of the returned dataset have references to the ones of the dataset.
+func (ds *Dataset) Transform(transformer Transformer) *Dataset {
+	return &Dataset{
+		X: ApplyTransformer(transformer, ds.X),
+		Y: ds.Y,
+		W: ds.W,
+	}
+}
+
+// DecodeTransformerWithGobDecoder decodes Transformer using decoder.
+//
+// This function returns an error in decoding.
+func DecodeTransformerWithGobDecoder(decoder *gob.Decoder) (Transformer, error) {
+	var transformer Transformer
+	if err := decoder.Decode(&transformer); err != nil {
+		return nil, fmt.Errorf("DecodeTransformer: %s", err)
+	}
+	return transformer, nil
+}
+
+// DecodeTransformer decodes Transformer from r.
+// Directly passing *os.File used by a gob.Decoder to this function causes mysterious errors.
+// Thus, if users use gob.Decoder, then they should call DecodeTransformerWithGobDecoder.
+//
+// This function returns an error in decoding.
+func DecodeTransformer(r io.Reader) (Transformer, error) {
+	return DecodeTransformerWithGobDecoder(gob.NewDecoder(r))
+}
+
+// EncodeTransformerWithGobEncoder encodes Transformer using encoder.
+// The implementations in this package are registered to gob, and the other ones must be registered by users.
+//
+// This function returns an error in encoding.
+func EncodeTransformerWithGobEncoder(transformer Transformer, encoder *gob.Encoder) error {
+	if err := encoder.Encode(&transformer); err != nil {
+		return fmt.Errorf("EncodeTransformer: %s", err)
+	}
+	return nil
+}
+
+// EncodeTransformer encodes Transformer to w.
+// Directly passing *os.File used by a gob.Encoder to this function causes mysterious errors.
+// Thus, if users use gob.Encoder, then they should call EncodeTransformerWithGobEncoder.
+//
+// This function returns an error in encoding.
+func EncodeTransformer(transformer Transformer, w io.Writer) error {
+	return EncodeTransformerWithGobEncoder(transformer, gob.NewEncoder(w))
+}
+
+// Transformers is the Transformer applying the transformers in order.
+type Transformers []Transformer
+
+// Fit fits each transformer on the feature vectors transformed by the preceding transformers.
+//
+// This function returns an error in fitting any transformer.
+func (transformers Transformers) Fit(X FeatureVectors) error {
+	for t, transformer := range transformers {
+		if err := transformer.Fit(X); err != nil {
+			return fmt.Errorf("#%d transformer: %s", t, err)
+		}
+		if t+1 < len(transformers) {
+			X = ApplyTransformer(transformer, X)
+		}
+	}
+	return nil
+}
+
+// Apply applies the transformers in order.
+func (transformers Transformers) Apply(x FeatureVector) FeatureVector {
+	for _, transformer := range transformers {
+		x = transformer.Apply(x)
+	}
+	return x
+}
+
+// countDocumentFrequencies returns the number of the feature vectors having each feature.
+func countDocumentFrequencies(X FeatureVectors) map[uint32]float32 {
+	dfs := make(map[uint32]float32)
+	for _, xi := range X {
+		for _, xipair := range xi {
+			dfs[xipair.Key]++
+		}
+	}
+	return dfs
+}
+
+// IDFTransformer is the Transformer multiplying each feature value by the smoothed inverse document frequency (IDF) of the feature.
+// The IDF of feature j is log((1 + n)/(1 + df_j)) + 1 where n is the number of the feature vectors and df_j is the number of the feature vectors having feature j.
+// Combining with L2Transformer, this is the common TF-IDF weighting.
+type IDFTransformer struct {
+	// IDF is the IDF of each feature in the fitted feature vectors.
+	IDF map[uint32]float32
+	// DefaultIDF is the IDF of the feature not in the fitted feature vectors.
+	DefaultIDF float32
+}
+
+// NewIDFTransformer returns a new unfitted IDFTransformer.
+func NewIDFTransformer() *IDFTransformer {
+	return &IDFTransformer{
+		IDF: make(map[uint32]float32),
+	}
+}
+
+// Fit fits the IDF on the given feature vectors.
+//
+// This function returns no error currently.
+func (transformer *IDFTransformer) Fit(X FeatureVectors) error {
+	n := float32(len(X))
+	transformer.IDF = make(map[uint32]float32)
+	for feature, df := range countDocumentFrequencies(X) {
+		transformer.IDF[feature] = Log32((1.0+n)/(1.0+df)) + 1.0
+	}
+	transformer.DefaultIDF = Log32(1.0+n) + 1.0
+	return nil
+}
+
+// Apply returns the feature vector whose values are multiplied by the IDF.
+func (transformer *IDFTransformer) Apply(x FeatureVector) FeatureVector {
+	xt := make(FeatureVector, len(x))
+	for j, xpair := range x {
+		idf, ok := transformer.IDF[xpair.Key]
+		if !ok {
+			idf = transformer.DefaultIDF
+		}
+		xt[j] = KeyValue32{xpair.Key, xpair.Value * idf}
+	}
+	return xt
+}
+
+// BM25Transformer is the Transformer weighting each feature value with Okapi BM25 (Robertson+ 1994).
+// The feature values are regarded as the term frequencies, and the length of the feature vector is the sum of them.
+//
+// References:
+//
+// (Robertson+ 1994) S. E. Robertson, et al. "Okapi at TREC-3." Proceedings of the Third Text REtrieval Conference, 1994.
+type BM25Transformer struct {
+	// K1 and B are the hyper-parameters saturating the term frequencies and normalizing the lengths, respectively.
+	K1, B float32
+	// AvgLength is the average length of the fitted feature vectors.
+	AvgLength float32
+	// IDF is the BM25 IDF of each feature in the fitted feature vectors.
+	IDF map[uint32]float32
+	// DefaultIDF is the IDF of the feature not in the fitted feature vectors.
+	DefaultIDF float32
+}
+
+// NewBM25Transformer returns a new unfitted BM25Transformer with the given hyper-parameters.
+// The common values are k1=1.2 and b=0.75.
+func NewBM25Transformer(k1, b float32) *BM25Transformer {
+	return &BM25Transformer{
+		K1:  k1,
+		B:   b,
+		IDF: make(map[uint32]float32),
+	}
+}
+
+// bm25Length returns the length of the given feature vector in BM25.
+func bm25Length(x FeatureVector) float32 {
+	length := float32(0.0)
+	for _, xpair := range x {
+		length += Abs32(xpair.Value)
+	}
+	return length
+}
+
+// Fit fits the average length and the IDF on the given feature vectors.
+//
+// This function returns no error currently.
+func (transformer *BM25Transformer) Fit(X FeatureVectors) error {
+	n := float32(len(X))
+	transformer.IDF = make(map[uint32]float32)
+	for feature, df := range countDocumentFrequencies(X) {
+		transformer.IDF[feature] = Log32(1.0 + (n-df+0.5)/(df+0.5))
+	}
+	transformer.DefaultIDF = Log32(1.0 + (n+0.5)/0.5)
+	sumLength := float32(0.0)
+	for _, xi := range X {
+		sumLength += bm25Length(xi)
+	}
+	transformer.AvgLength = 0.0
+	if n > 0 {
+		transformer.AvgLength = sumLength / n
+	}
+	return nil
+}
+
+// Apply returns the feature vector weighted with BM25.
+func (transformer *BM25Transformer) Apply(x FeatureVector) FeatureVector {
+	k1, b := transformer.K1, transformer.B
+	norm := k1
+	if transformer.AvgLength > 0.0 {
+		norm = k1 * (1.0 - b + b*bm25Length(x)/transformer.AvgLength)
+	}
+	xt := make(FeatureVector, len(x))
+	for j, xpair := range x {
+		idf, ok := transformer.IDF[xpair.Key]
+		if !ok {
+			idf = transformer.DefaultIDF
+		}
+		tf := xpair.Value
+		xt[j] = KeyValue32{xpair.Key, idf * tf * (k1 + 1.0) / (tf + norm)}
+	}
+	return xt
+}
+
+// SublinearTFTransformer is the Transformer replacing each positive feature value v with 1 + log(v).
+// The non-positive feature values are kept.
+type SublinearTFTransformer struct{}
+
+// NewSublinearTFTransformer returns a new SublinearTFTransformer.
+func NewSublinearTFTransformer() *SublinearTFTransformer {
+	return &SublinearTFTransformer{}
+}
+
+// Fit does nothing, because SublinearTFTransformer has no parameter.
+func (transformer *SublinearTFTransformer) Fit(X FeatureVectors) error {
+	return nil
+}
+
+// Apply returns the feature vector whose positive values are log-scaled.
+func (transformer *SublinearTFTransformer) Apply(x FeatureVector) FeatureVector {
+	xt := make(FeatureVector, len(x))
+	for j, xpair := range x {
+		value := xpair.Value
+		if value > 0.0 {
+			value = 1.0 + Log32(value)
+		}
+		xt[j] = KeyValue32{xpair.Key, value}
+	}
+	return xt
+}
+
+// GobEncode returns the empty bytes, because SublinearTFTransformer has no parameter.
+func (transformer *SublinearTFTransformer) GobEncode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// GobDecode does nothing, because SublinearTFTransformer has no parameter.
+func (transformer *SublinearTFTransformer) GobDecode(data []byte) error {
+	return nil
+}
+
+// L2Transformer is the Transformer normalizing each feature vector to the unit L2-norm.
+// The zero vector is kept.
+type L2Transformer struct{}
+
+// NewL2Transformer returns a new L2Transformer.
+func NewL2Transformer() *L2Transformer {
+	return &L2Transformer{}
+}
+
+// Fit does nothing, because L2Transformer has no parameter.
+func (transformer *L2Transformer) Fit(X FeatureVectors) error {
+	return nil
+}
+
+// Apply returns the L2-normalized feature vector.
+func (transformer *L2Transformer) Apply(x FeatureVector) FeatureVector {
+	sqnorm := float32(0.0)
+	for _, xpair := range x {
+		sqnorm += xpair.Value * xpair.Value
+	}
+	scale := float32(1.0)
+	if sqnorm > 0.0 {
+		scale = 1.0 / Sqrt32(sqnorm)
+	}
+	xt := make(FeatureVector, len(x))
+	for j, xpair := range x {
+		xt[j] = KeyValue32{xpair.Key, xpair.Value * scale}
+	}
+	return xt
+}
+
+// GobEncode returns the empty bytes, because L2Transformer has no parameter.
+func (transformer *L2Transformer) GobEncode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// GobDecode does nothing, because L2Transformer has no parameter.
+func (transformer *L2Transformer) GobDecode(data []byte) error {
+	return nil
+}
+
+// MaxAbsTransformer is the Transformer dividing each feature value by the maximum absolute value of the feature.
+// The feature not in the fitted feature vectors or having only zero values is kept.
+type MaxAbsTransformer struct {
+	// MaxAbs is the maximum absolute value of each feature in the fitted feature vectors.
+	MaxAbs map[uint32]float32
+}
+
+// NewMaxAbsTransformer returns a new unfitted MaxAbsTransformer.
+func NewMaxAbsTransformer() *MaxAbsTransformer {
+	return &MaxAbsTransformer{
+		MaxAbs: make(map[uint32]float32),
+	}
+}
+
+// Fit fits the maximum absolute values on the given feature vectors.
+//
+// This function returns no error currently.
+func (transformer *MaxAbsTransformer) Fit(X FeatureVectors) error {
+	transformer.MaxAbs = make(map[uint32]float32)
+	for _, xi := range X {
+		for _, xipair := range xi {
+			if absValue := Abs32(xipair.Value); transformer.MaxAbs[xipair.Key] < absValue {
+				transformer.MaxAbs[xipair.Key] = absValue
+			}
+		}
+	}
+	return nil
+}
+
+// Apply returns the feature vector whose values are scaled into [-1, 1].
+func (transformer *MaxAbsTransformer) Apply(x FeatureVector) FeatureVector {
+	xt := make(FeatureVector, len(x))
+	for j, xpair := range x {
+		value := xpair.Value
+		if maxAbs := transformer.MaxAbs[xpair.Key]; maxAbs > 0.0 {
+			value /= maxAbs
+		}
+		xt[j] = KeyValue32{xpair.Key, value}
+	}
+	return xt
+}
+
+// FeatureHashingTransformer is the Transformer hashing the feature IDs into 2^Bits buckets with the signed hashing trick (Weinberger+ 2009).
+// Feature j is mapped to the bucket given by the lower Bits bits of HashUint32(j), and its value is negated if the highest bit is set.
+// The values mapped into the same bucket are summed, so the dimension of the feature vectors is at most 2^Bits.
+//
+// References:
+//
+// (Weinberger+ 2009) K. Weinberger, et al. "Feature Hashing for Large Scale Multitask Learning." Proceedings of the 26th International Conference on Machine Learning, 2009.
+type FeatureHashingTransformer struct {
+	// Bits is the number of the bits of the buckets in [1, 31].
+	Bits uint
+}
+
+// NewFeatureHashingTransformer returns a new FeatureHashingTransformer with 2^bits buckets.
+//
+// This function panics if bits is not in [1, 31].
+func NewFeatureHashingTransformer(bits uint) *FeatureHashingTransformer {
+	if bits < 1 || bits > 31 {
+		panic(fmt.Errorf("bits must be in [1, 31]"))
+	}
+	return &FeatureHashingTransformer{
+		Bits: bits,
+	}
+}
+
+// Fit does nothing, because FeatureHashingTransformer is not fitted.
+func (transformer *FeatureHashingTransformer) Fit(X FeatureVectors) error {
+	return nil
+}
+
+// Apply returns the feature vector whose feature IDs are hashed.
+// The buckets whose summed values are zero are dropped.
+func (transformer *FeatureHashingTransformer) Apply(x FeatureVector) FeatureVector {
+	mask := uint32(1)<<transformer.Bits - 1
+	xt := make(FeatureVector, 0, len(x))
+	for _, xpair := range x {
+		h := HashUint32(xpair.Key)
+		value := xpair.Value
+		if h>>31 != 0 {
+			value = -value
+		}
+		xt = append(xt, KeyValue32{h & mask, value})
+	}
+	sort.Sort(xt)
+	merged := xt[:0]
+	for _, xpair := range xt {
+		if len(merged) > 0 && merged[len(merged)-1].Key == xpair.Key {
+			merged[len(merged)-1].Value += xpair.Value
+			continue
+		}
+		merged = append(merged, xpair)
+	}
+	nonzeros := merged[:0]
+	for _, xpair := range merged {
+		if xpair.Value != 0.0 {
+			nonzeros = append(nonzeros, xpair)
+		}
+	}
+	return nonzeros
+}
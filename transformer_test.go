@@ -0,0 +1,76 @@
+package sticker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestTransformers(t *testing.T) {
+	X := FeatureVectors{
+		FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 2.0}},
+		FeatureVector{KeyValue32{0, 3.0}},
+		FeatureVector{KeyValue32{2, -4.0}},
+		FeatureVector{},
+	}
+	idf := NewIDFTransformer()
+	goassert.New(t).SucceedWithoutError(idf.Fit(X))
+	goassert.New(t, FeatureVector{KeyValue32{0, 1.0 * (Log32(5.0/3.0) + 1.0)}, KeyValue32{3, 2.0 * (Log32(5.0) + 1.0)}}).Equal(idf.Apply(FeatureVector{KeyValue32{0, 1.0}, KeyValue32{3, 2.0}}))
+	bm25 := NewBM25Transformer(1.2, 0.75)
+	goassert.New(t).SucceedWithoutError(bm25.Fit(X))
+	goassert.New(t, float32(2.5)).Equal(bm25.AvgLength)
+	norm := float32(1.2 * (1.0 - 0.75 + 0.75*3.0/2.5))
+	goassert.New(t, FeatureVector{KeyValue32{0, Log32(1.0+2.5/2.5) * 1.0 * 2.2 / (1.0 + norm)}, KeyValue32{1, Log32(1.0+3.5/1.5) * 2.0 * 2.2 / (2.0 + norm)}}).Equal(bm25.Apply(X[0]))
+	goassert.New(t, FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 1.0 + Log32(2.0)}, KeyValue32{2, -4.0}}).Equal(NewSublinearTFTransformer().Apply(FeatureVector{KeyValue32{0, 1.0}, KeyValue32{1, 2.0}, KeyValue32{2, -4.0}}))
+	goassert.New(t, FeatureVector{KeyValue32{0, 0.6}, KeyValue32{1, -0.8}}).Equal(NewL2Transformer().Apply(FeatureVector{KeyValue32{0, 3.0}, KeyValue32{1, -4.0}}))
+	goassert.New(t, FeatureVector{}).Equal(NewL2Transformer().Apply(FeatureVector{}))
+	maxAbs := NewMaxAbsTransformer()
+	goassert.New(t).SucceedWithoutError(maxAbs.Fit(X))
+	goassert.New(t, FeatureVector{KeyValue32{0, 0.5}, KeyValue32{2, 1.0}, KeyValue32{3, 5.0}}).Equal(maxAbs.Apply(FeatureVector{KeyValue32{0, 1.5}, KeyValue32{2, 4.0}, KeyValue32{3, 5.0}}))
+	// Transformers should fit each transformer on the transformed feature vectors.
+	transformers := Transformers{NewSublinearTFTransformer(), NewMaxAbsTransformer(), NewL2Transformer()}
+	goassert.New(t).SucceedWithoutError(transformers.Fit(X))
+	goassert.New(t, map[uint32]float32{0: 1.0 + Log32(3.0), 1: 1.0 + Log32(2.0), 2: 4.0}).Equal(transformers[1].(*MaxAbsTransformer).MaxAbs)
+	ds := &Dataset{X: X, Y: LabelVectors{LabelVector{0}, LabelVector{1}, LabelVector{}, LabelVector{2}}}
+	dst := ds.Transform(transformers)
+	goassert.New(t, FeatureVector{KeyValue32{2, -1.0}}).Equal(dst.X[2])
+	goassert.New(t, ds.Y).Equal(dst.Y)
+	// Check gob encoding/decoding.
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(EncodeTransformer(Transformers{idf, bm25, transformers}, &buf))
+	goassert.New(t, Transformers{idf, bm25, transformers}).EqualWithoutError(DecodeTransformer(&buf))
+}
+
+func TestFeatureHashingTransformer(t *testing.T) {
+	transformer := NewFeatureHashingTransformer(2)
+	bucket := func(key uint32) (uint32, float32) {
+		h := HashUint32(key)
+		if h>>31 != 0 {
+			return h & 3, -1.0
+		}
+		return h & 3, 1.0
+	}
+	x := FeatureVector{}
+	for key := uint32(0); key < 10; key++ {
+		x = append(x, KeyValue32{key, float32(key + 1)})
+	}
+	expected := make(map[uint32]float32)
+	for _, xpair := range x {
+		b, sign := bucket(xpair.Key)
+		expected[b] += sign * xpair.Value
+	}
+	xt := transformer.Apply(x)
+	goassert.New(t, true).Equal(len(xt) <= 4)
+	for j, xtpair := range xt {
+		if j > 0 {
+			goassert.New(t, true).Equal(xt[j-1].Key < xtpair.Key)
+		}
+		goassert.New(t, expected[xtpair.Key]).Equal(xtpair.Value)
+	}
+	b, sign := bucket(7)
+	goassert.New(t, FeatureVector{KeyValue32{b, sign * 2.0}}).Equal(transformer.Apply(FeatureVector{KeyValue32{7, 2.0}}))
+	goassert.New(t, FeatureVector{}).Equal(transformer.Apply(FeatureVector{KeyValue32{7, 0.0}}))
+	ds := &Dataset{X: FeatureVectors{x}, Y: LabelVectors{LabelVector{0}}}
+	goassert.New(t, true).Equal(ds.Transform(transformer).X.Dim() <= 4)
+}
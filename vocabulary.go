@@ -0,0 +1,197 @@
+package sticker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Vocabulary is the bidirectional map between the names and the identifiers of features or labels.
+// The identifiers are assigned from 0 in order of the addition.
+//
+// The frozen Vocabulary rejects the new names, so this can be shared with the fitted models safely.
+type Vocabulary struct {
+	names  []string
+	ids    map[string]uint32
+	frozen bool
+}
+
+// NewVocabulary returns a new empty Vocabulary.
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{
+		names: []string{},
+		ids:   make(map[string]uint32),
+	}
+}
+
+// NewVocabularyFromNames returns a new Vocabulary having the given names in order of identifier.
+// If a name is duplicated, then ID returns the first identifier of the name.
+func NewVocabularyFromNames(names []string) *Vocabulary {
+	vocab := &Vocabulary{
+		names: make([]string, 0, len(names)),
+		ids:   make(map[string]uint32, len(names)),
+	}
+	for _, name := range names {
+		vocab.append(name)
+	}
+	return vocab
+}
+
+// ReadVocabulary returns a new Vocabulary from reader having the name per line in order of identifier.
+// The spaces around each name are trimmed, and the last empty line is ignored.
+//
+// This function returns an error in reading.
+func ReadVocabulary(reader io.Reader) (*Vocabulary, error) {
+	br := bufio.NewReader(reader)
+	vocab := NewVocabulary()
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("L%d: cannot read line", vocab.Size()+1)
+		}
+		if err == io.EOF && line == "" {
+			break
+		}
+		vocab.append(strings.TrimSpace(line))
+		if err == io.EOF {
+			break
+		}
+	}
+	return vocab, nil
+}
+
+// append appends name with the next identifier without checking frozen.
+func (vocab *Vocabulary) append(name string) uint32 {
+	id := uint32(len(vocab.names))
+	vocab.names = append(vocab.names, name)
+	if _, ok := vocab.ids[name]; !ok {
+		vocab.ids[name] = id
+	}
+	return id
+}
+
+// Add returns the identifier of name, and assigns the next identifier to name if name is new.
+//
+// This function returns an error if name is new and the vocabulary is frozen.
+func (vocab *Vocabulary) Add(name string) (uint32, error) {
+	if id, ok := vocab.ids[name]; ok {
+		return id, nil
+	}
+	if vocab.frozen {
+		return 0, fmt.Errorf("cannot add %q to the frozen vocabulary", name)
+	}
+	return vocab.append(name), nil
+}
+
+// Freeze freezes the vocabulary, so Add rejects the new names after that.
+func (vocab *Vocabulary) Freeze() {
+	vocab.frozen = true
+}
+
+// GobDecode decodes the vocabulary from data.
+//
+// This function returns an error in decoding.
+func (vocab *Vocabulary) GobDecode(data []byte) error {
+	var encoded struct {
+		Names  []string
+		Frozen bool
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&encoded); err != nil {
+		return fmt.Errorf("Vocabulary.GobDecode: %s", err)
+	}
+	*vocab = *NewVocabularyFromNames(encoded.Names)
+	vocab.frozen = encoded.Frozen
+	return nil
+}
+
+// GobEncode returns the encoded vocabulary.
+//
+// This function returns an error in encoding.
+func (vocab *Vocabulary) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(struct {
+		Names  []string
+		Frozen bool
+	}{vocab.names, vocab.frozen}); err != nil {
+		return nil, fmt.Errorf("Vocabulary.GobEncode: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ID returns the identifier of name, and true if found.
+func (vocab *Vocabulary) ID(name string) (uint32, bool) {
+	id, ok := vocab.ids[name]
+	return id, ok
+}
+
+// IsFrozen returns true if the vocabulary is frozen.
+func (vocab *Vocabulary) IsFrozen() bool {
+	return vocab.frozen
+}
+
+// Name returns the name of id, and true if found.
+func (vocab *Vocabulary) Name(id uint32) (string, bool) {
+	if id < uint32(len(vocab.names)) {
+		return vocab.names[id], true
+	}
+	return "", false
+}
+
+// Names returns the names in order of identifier.
+// The returned slice must not be modified.
+func (vocab *Vocabulary) Names() []string {
+	return vocab.names
+}
+
+// Size returns the number of the identifiers.
+func (vocab *Vocabulary) Size() int {
+	return len(vocab.names)
+}
+
+// WriteVocabulary writes the name per line in order of identifier to w.
+//
+// This function returns an error in writing.
+func (vocab *Vocabulary) WriteVocabulary(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, name := range vocab.names {
+		if _, err := bw.WriteString(name + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// modelVocabularies is the encoded form of the vocabularies stored at the tail of each model.
+type modelVocabularies struct {
+	FeatureVocabulary *Vocabulary
+	LabelVocabulary   *Vocabulary
+}
+
+// DecodeModelVocabulariesWithGobDecoder decodes the feature and label vocabularies stored at the tail of each model using decoder.
+// The models encoded before the vocabularies were introduced have no vocabularies, so this function returns nil vocabularies at the end of stream.
+//
+// This function returns an error in decoding.
+func DecodeModelVocabulariesWithGobDecoder(decoder *gob.Decoder) (featureVocab, labelVocab *Vocabulary, err error) {
+	var vocabs modelVocabularies
+	if err := decoder.Decode(&vocabs); err != nil {
+		if err == io.EOF {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	return vocabs.FeatureVocabulary, vocabs.LabelVocabulary, nil
+}
+
+// EncodeModelVocabulariesWithGobEncoder encodes the feature and label vocabularies stored at the tail of each model using encoder.
+// The nil vocabularies are decoded as nil.
+//
+// This function returns an error in encoding.
+func EncodeModelVocabulariesWithGobEncoder(featureVocab, labelVocab *Vocabulary, encoder *gob.Encoder) error {
+	return encoder.Encode(modelVocabularies{
+		FeatureVocabulary: featureVocab,
+		LabelVocabulary:   labelVocab,
+	})
+}
@@ -0,0 +1,79 @@
+package sticker
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestVocabulary(t *testing.T) {
+	vocab := NewVocabulary()
+	goassert.New(t, uint32(0)).EqualWithoutError(vocab.Add("a"))
+	goassert.New(t, uint32(1)).EqualWithoutError(vocab.Add("b"))
+	goassert.New(t, uint32(0)).EqualWithoutError(vocab.Add("a"))
+	goassert.New(t, 2, []string{"a", "b"}).Equal(vocab.Size(), vocab.Names())
+	goassert.New(t, uint32(1), true).Equal(vocab.ID("b"))
+	goassert.New(t, uint32(0), false).Equal(vocab.ID("c"))
+	goassert.New(t, "b", true).Equal(vocab.Name(1))
+	goassert.New(t, "", false).Equal(vocab.Name(2))
+	vocab.Freeze()
+	goassert.New(t, true).Equal(vocab.IsFrozen())
+	goassert.New(t, uint32(1)).EqualWithoutError(vocab.Add("b"))
+	goassert.New(t, "cannot add \"c\" to the frozen vocabulary").ExpectError(vocab.Add("c"))
+	// The duplicated names should be looked up by the first identifier.
+	vocab = NewVocabularyFromNames([]string{"x", "", "x"})
+	goassert.New(t, 3, false).Equal(vocab.Size(), vocab.IsFrozen())
+	goassert.New(t, uint32(0), true).Equal(vocab.ID("x"))
+	goassert.New(t, "x", true).Equal(vocab.Name(2))
+}
+
+func TestReadVocabulary(t *testing.T) {
+	goassert.New(t, NewVocabularyFromNames([]string{"a", "b c", ""})).EqualWithoutError(ReadVocabulary(strings.NewReader(" a\nb c\r\n\n")))
+	goassert.New(t, NewVocabularyFromNames([]string{"a", "b"})).EqualWithoutError(ReadVocabulary(strings.NewReader("a\nb")))
+	goassert.New(t, NewVocabulary()).EqualWithoutError(ReadVocabulary(strings.NewReader("")))
+	var buf bytes.Buffer
+	vocab := NewVocabularyFromNames([]string{"a", "b c"})
+	goassert.New(t).SucceedWithoutError(vocab.WriteVocabulary(&buf))
+	goassert.New(t, "a\nb c\n").Equal(buf.String())
+	goassert.New(t, vocab).EqualWithoutError(ReadVocabulary(&buf))
+}
+
+func TestVocabularyGob(t *testing.T) {
+	vocab := NewVocabularyFromNames([]string{"a", "b"})
+	vocab.Freeze()
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(gob.NewEncoder(&buf).Encode(vocab))
+	decodedVocab := &Vocabulary{}
+	goassert.New(t).SucceedWithoutError(gob.NewDecoder(&buf).Decode(decodedVocab))
+	goassert.New(t, vocab).Equal(decodedVocab)
+	// The vocabularies should be stored in the model.
+	model := &LabelConst{
+		LabelList:         LabelVector{1, 0},
+		LabelFreqList:     []float32{2, 1},
+		FeatureVocabulary: NewVocabularyFromNames([]string{"f"}),
+		LabelVocabulary:   vocab,
+	}
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(EncodeLabelConst(model, &buf))
+	var decodedModel LabelConst
+	goassert.New(t).SucceedWithoutError(DecodeLabelConst(&decodedModel, &buf))
+	goassert.New(t, model).Equal(&decodedModel)
+	// The nil vocabularies should be decoded as nil.
+	model.FeatureVocabulary = nil
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(EncodeLabelConst(model, &buf))
+	decodedModel = LabelConst{}
+	goassert.New(t).SucceedWithoutError(DecodeLabelConst(&decodedModel, &buf))
+	goassert.New(t, model).Equal(&decodedModel)
+	// The model encoded without the vocabularies should be decoded.
+	buf.Reset()
+	encoder := gob.NewEncoder(&buf)
+	goassert.New(t).SucceedWithoutError(encoder.Encode(model.LabelList))
+	goassert.New(t).SucceedWithoutError(encoder.Encode(model.LabelFreqList))
+	decodedModel = LabelConst{}
+	goassert.New(t).SucceedWithoutError(DecodeLabelConst(&decodedModel, &buf))
+	goassert.New(t, &LabelConst{LabelList: model.LabelList, LabelFreqList: model.LabelFreqList}).Equal(&decodedModel)
+}